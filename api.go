@@ -0,0 +1,123 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+// collectorsAPIResponse is the JSON body of /api/v1/collectors.
+type collectorsAPIResponse struct {
+	Collectors []collectorsAPICollector `json:"collectors"`
+}
+
+type collectorsAPICollector struct {
+	Name    string                `json:"name"`
+	Enabled bool                  `json:"enabled"`
+	Flags   []collectorsAPIFlag   `json:"flags"`
+	Metrics []collectorsAPIMetric `json:"metrics,omitempty"`
+	// Error is the error of the collector run to list its metrics, if any.
+	Error string `json:"error,omitempty"`
+}
+
+type collectorsAPIFlag struct {
+	Name    string `json:"name"`
+	Help    string `json:"help"`
+	Default string `json:"default"`
+	Value   string `json:"value"`
+}
+
+type collectorsAPIMetric struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Help string `json:"help"`
+}
+
+// collectorsAPIHandler serves /api/v1/collectors, which lists the collectors
+// with their flags for inventory tools. The metric families of the enabled
+// collectors are listed as well, which runs every enabled collector once.
+// Disabled collectors list no metrics, as they may not work on the host.
+func collectorsAPIHandler(collectors func() []collector.CollectorInfo, families func(name string) ([]*dto.MetricFamily, error), logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Only GET requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		infos := collectors()
+		resp := collectorsAPIResponse{Collectors: make([]collectorsAPICollector, len(infos))}
+		var wg sync.WaitGroup
+		for i, info := range infos {
+			c := collectorsAPICollector{Name: info.Name, Enabled: info.Enabled, Flags: []collectorsAPIFlag{}}
+			for _, f := range info.Flags {
+				c.Flags = append(c.Flags, collectorsAPIFlag{Name: f.Name, Help: f.Help, Default: f.Default, Value: f.Value})
+			}
+			resp.Collectors[i] = c
+			if !info.Enabled {
+				continue
+			}
+			wg.Add(1)
+			go func(c *collectorsAPICollector) {
+				defer wg.Done()
+				mfs, err := families(c.Name)
+				if err != nil {
+					c.Error = err.Error()
+				}
+				for _, mf := range mfs {
+					// The scrape metrics are exposed for every collector.
+					if strings.HasPrefix(mf.GetName(), "node_scrape_") {
+						continue
+					}
+					c.Metrics = append(c.Metrics, collectorsAPIMetric{
+						Name: mf.GetName(),
+						Type: strings.ToLower(mf.GetType().String()),
+						Help: mf.GetHelp(),
+					})
+				}
+			}(&resp.Collectors[i])
+		}
+		wg.Wait()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			level.Debug(logger).Log("msg", "Failed to write collectors response", "err", err)
+		}
+	})
+}
+
+// collectorMetricFamilies runs a collector and returns the metric families it
+// exposes, sorted by name.
+func collectorMetricFamilies(logger log.Logger) func(name string) ([]*dto.MetricFamily, error) {
+	return func(name string) ([]*dto.MetricFamily, error) {
+		nc, err := collector.NewNodeCollector(logger, name)
+		if err != nil {
+			return nil, err
+		}
+		r := prometheus.NewRegistry()
+		if err := r.Register(nc); err != nil {
+			return nil, err
+		}
+		return r.Gather()
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/log"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/node_exporter/collector"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCollectorsAPIHandler(t *testing.T) {
+	collectors := func() []collector.CollectorInfo {
+		return []collector.CollectorInfo{
+			{Name: "cpu", Enabled: true, Flags: []collector.CollectorFlag{{Name: "collector.cpu.guest", Help: "Enables metric node_cpu_guest_seconds_total", Default: "true", Value: "true"}}},
+			{Name: "hwmon", Enabled: true},
+			{Name: "perf", Enabled: false},
+		}
+	}
+	families := func(name string) ([]*dto.MetricFamily, error) {
+		switch name {
+		case "cpu":
+			return []*dto.MetricFamily{
+				{Name: proto.String("node_cpu_seconds_total"), Help: proto.String("Seconds the CPUs spent in each mode."), Type: dto.MetricType_COUNTER.Enum()},
+				{Name: proto.String("node_scrape_collector_success"), Help: proto.String("node_exporter: Whether a collector succeeded."), Type: dto.MetricType_GAUGE.Enum()},
+			}, nil
+		case "hwmon":
+			return nil, errors.New("hwmon failed")
+		}
+		t.Errorf("metrics of %s should not be gathered", name)
+		return nil, nil
+	}
+
+	h := collectorsAPIHandler(collectors, families, log.NewNopLogger())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/collectors", nil))
+	var resp collectorsAPIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := collectorsAPIResponse{Collectors: []collectorsAPICollector{
+		{
+			Name:    "cpu",
+			Enabled: true,
+			Flags:   []collectorsAPIFlag{{Name: "collector.cpu.guest", Help: "Enables metric node_cpu_guest_seconds_total", Default: "true", Value: "true"}},
+			Metrics: []collectorsAPIMetric{{Name: "node_cpu_seconds_total", Type: "counter", Help: "Seconds the CPUs spent in each mode."}},
+		},
+		{Name: "hwmon", Enabled: true, Flags: []collectorsAPIFlag{}, Error: "hwmon failed"},
+		{Name: "perf", Flags: []collectorsAPIFlag{}},
+	}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+// serverSettings are the command line settings which the server validates at
+// startup, and check-config the same way.
+type serverSettings struct {
+	allowedCIDRs           []string
+	trustedProxyCIDRs      []string
+	clientCertAllowedNames []string
+	jwtJWKSURL             string
+	compression            []string
+	gzipLevel              int
+	socketMode             string
+	heartbeatInterval      time.Duration
+	heartbeatPushURL       string
+	dashboardEnabled       bool
+	dashboardInterval      time.Duration
+	gossipListenAddress    string
+	gossipPeers            []string
+	gossipInterval         time.Duration
+	gossipPeerTimeout      time.Duration
+	gossipName             string
+	gossipKeyFile          string
+	pushInterval           time.Duration
+	remoteWriteConfigFile  string
+}
+
+// validate calls check with the outcome of validating each group of settings.
+func (s serverSettings) validate(check func(what string, err error)) {
+	_, _, err := parseSourceIPNetworks(s.allowedCIDRs, s.trustedProxyCIDRs)
+	check("allowed networks", err)
+	_, err = compileClientCertNames(s.clientCertAllowedNames)
+	check("client certificate names", err)
+	if s.jwtJWKSURL != "" {
+		check("JWKS URL", validateJWKSURL(s.jwtJWKSURL))
+	}
+	_, err = newCompressor(s.compression, s.gzipLevel)
+	check("compression", err)
+	_, err = parseSocketMode(s.socketMode)
+	check("socket mode", err)
+	if s.heartbeatPushURL != "" && s.heartbeatInterval <= 0 {
+		check("heartbeat", errors.New("--heartbeat.push-url requires --heartbeat.interval"))
+	}
+	if s.dashboardEnabled && s.dashboardInterval <= 0 {
+		check("dashboard", errors.New("--web.dashboard.interval must be positive"))
+	}
+	if s.gossipListenAddress != "" {
+		_, err := parseGossipSettings(s.gossipListenAddress, s.gossipPeers, s.gossipInterval, s.gossipPeerTimeout, s.gossipName, s.gossipKeyFile)
+		check("gossip", err)
+	}
+	if s.remoteWriteConfigFile != "" {
+		c, err := loadRemoteWriteConfig(s.remoteWriteConfigFile)
+		if err == nil {
+			_, err = newRemoteWriteClient(c, s.pushInterval)
+		}
+		check("remote write config "+s.remoteWriteConfigFile, err)
+	}
+}
+
+// checkConfig validates the configuration passed on the command line without
+// starting the exporter, printing the enabled collectors and the files they
+// read. It returns whether the configuration is valid.
+func checkConfig(w io.Writer, configFile string, configErr error, webConfigFile, viewsFile, tenantsFile string, extraLabelFlags []string, relabelConfigFile string, settings serverSettings, logger log.Logger) bool {
+	valid := true
+	check := func(what string, err error) {
+		if err != nil {
+			fmt.Fprintf(w, "FAILED %s: %s\n", what, err)
+			valid = false
+			return
+		}
+		fmt.Fprintf(w, "OK     %s\n", what)
+	}
+
+	if configFile != "" {
+		check("config file "+configFile, configErr)
+	}
+	if webConfigFile != "" {
+		check("web config "+webConfigFile, validateWebConfig(webConfigFile))
+	}
+	if viewsFile != "" {
+		_, err := loadViewsConfig(viewsFile)
+		check("views file "+viewsFile, err)
+	}
+	if tenantsFile != "" {
+		_, err := loadTenantsConfig(tenantsFile)
+		check("tenants file "+tenantsFile, err)
+	}
+	_, err := parseExtraLabels(extraLabelFlags)
+	check("extra labels", err)
+	if relabelConfigFile != "" {
+		_, err := loadRelabelConfigs(relabelConfigFile)
+		check("relabel config "+relabelConfigFile, err)
+	}
+	settings.validate(check)
+
+	for _, dir := range collector.SnapshotDirs() {
+		check("snapshot "+dir, collector.VerifySnapshot(dir))
+	}
+	for _, c := range collector.CheckCollectors(logger) {
+		check("collector "+c.Name, c.Err)
+		for _, file := range c.Files {
+			fmt.Fprintf(w, "         reads %s\n", file)
+		}
+	}
+
+	return valid
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	remoteWriteConfig := filepath.Join(dir, "remote-write.yml")
+	if err := os.WriteFile(remoteWriteConfig, []byte("url: https://prometheus.example.com/api/v1/write\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badRemoteWriteConfig := filepath.Join(dir, "bad-remote-write.yml")
+	if err := os.WriteFile(badRemoteWriteConfig, []byte("timeout: 1x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := serverSettings{
+		allowedCIDRs:           []string{"10.0.8.0/24"},
+		clientCertAllowedNames: []string{`prometheus-\d+`},
+		jwtJWKSURL:             "https://idp.example.com/jwks",
+		compression:            []string{"zstd", "gzip"},
+		gzipLevel:              6,
+		socketMode:             "0660",
+		gossipListenAddress:    "127.0.0.1:0",
+		gossipPeers:            []string{"127.0.0.1:9101"},
+		gossipInterval:         5 * time.Second,
+		gossipPeerTimeout:      30 * time.Second,
+		gossipName:             "node",
+		pushInterval:           time.Minute,
+		remoteWriteConfigFile:  remoteWriteConfig,
+	}
+	var out strings.Builder
+	if !checkConfig(&out, "", nil, "", "", "", nil, "", valid, log.NewNopLogger()) {
+		t.Fatalf("expected a valid configuration, got:\n%s", out.String())
+	}
+
+	// These settings make the server exit at startup.
+	invalid := valid
+	invalid.allowedCIDRs = []string{"notacidr"}
+	invalid.clientCertAllowedNames = []string{"("}
+	invalid.jwtJWKSURL = "idp.example.com/jwks"
+	invalid.gzipLevel = 42
+	invalid.socketMode = "999"
+	invalid.heartbeatPushURL = "https://hc.example.com/ping"
+	invalid.dashboardEnabled = true
+	invalid.gossipKeyFile = filepath.Join(dir, "missing")
+	invalid.remoteWriteConfigFile = badRemoteWriteConfig
+	out.Reset()
+	if checkConfig(&out, "", nil, "", "", "", nil, "", invalid, log.NewNopLogger()) {
+		t.Fatalf("expected an invalid configuration, got:\n%s", out.String())
+	}
+	for _, what := range []string{
+		"allowed networks",
+		"client certificate names",
+		"JWKS URL",
+		"compression",
+		"socket mode",
+		"heartbeat",
+		"dashboard",
+		"gossip",
+		"remote write config " + badRemoteWriteConfig,
+	} {
+		if !strings.Contains(out.String(), "FAILED "+what+": ") {
+			t.Errorf("expected %s to fail, got:\n%s", what, out.String())
+		}
+	}
+}
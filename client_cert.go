@@ -0,0 +1,147 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	clientCertRejectedMissing    = "no_certificate"
+	clientCertRejectedNotAllowed = "not_allowed"
+)
+
+var clientCertRejectedDesc = prometheus.NewDesc(
+	"node_exporter_client_cert_rejected_total",
+	"Number of requests rejected by --web.client-cert-allowed-name, by reason.",
+	[]string{"reason"}, nil,
+)
+
+// clientCertAuthorizer only lets requests through whose verified client
+// certificate has a common name or subject alternative name matching an
+// allowlist. The exporter-toolkit verifies the certificate, its
+// client_allowed_sans only takes exact SANs and rejects the TLS handshake,
+// which is neither counted nor logged with the names presented.
+type clientCertAuthorizer struct {
+	allowed *regexp.Regexp
+	logger  log.Logger
+
+	mtx      sync.Mutex
+	rejected map[string]float64
+}
+
+// clientCertCollector authorizes the requests of every listener.
+var clientCertCollector = &clientCertAuthorizer{}
+
+// configure sets the regular expressions of the allowed names. Requests are
+// not checked without any.
+func (a *clientCertAuthorizer) configure(patterns []string, logger log.Logger) error {
+	re, err := compileClientCertNames(patterns)
+	if err != nil {
+		return err
+	}
+	if re == nil {
+		return nil
+	}
+	a.allowed = re
+	a.logger = logger
+	a.rejected = map[string]float64{clientCertRejectedMissing: 0, clientCertRejectedNotAllowed: 0}
+	return nil
+}
+
+// compileClientCertNames compiles --web.client-cert-allowed-name, nil if
+// there are no patterns.
+func compileClientCertNames(patterns []string) (*regexp.Regexp, error) {
+	re, err := compileMetricNames(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --web.client-cert-allowed-name: %w", err)
+	}
+	return re, nil
+}
+
+// wrap rejects the requests without an allowed client certificate with 403.
+func (a *clientCertAuthorizer) wrap(next http.Handler) http.Handler {
+	if a.allowed == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only verified chains count, certificates presented with
+		// client_auth_type RequireAnyClientCert are not.
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			a.reject(clientCertRejectedMissing)
+			level.Debug(a.logger).Log("msg", "Rejected request without verified client certificate", "remote_addr", r.RemoteAddr)
+			http.Error(w, "Verified client certificate required", http.StatusForbidden)
+			return
+		}
+		names := clientCertNames(r.TLS.VerifiedChains[0][0])
+		for _, name := range names {
+			if a.allowed.MatchString(name) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		a.reject(clientCertRejectedNotAllowed)
+		level.Warn(a.logger).Log("msg", "Rejected request with client certificate not allowed", "remote_addr", r.RemoteAddr, "names", strings.Join(names, ","))
+		http.Error(w, "Client certificate not allowed", http.StatusForbidden)
+	})
+}
+
+func (a *clientCertAuthorizer) reject(reason string) {
+	a.mtx.Lock()
+	a.rejected[reason]++
+	a.mtx.Unlock()
+}
+
+// clientCertNames returns the common name and the subject alternative names
+// of a certificate: DNS names, IP addresses, email addresses and URIs such as
+// SPIFFE IDs.
+func clientCertNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// Describe implements prometheus.Collector.
+func (a *clientCertAuthorizer) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clientCertRejectedDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if the
+// allowlist is disabled.
+func (a *clientCertAuthorizer) Collect(ch chan<- prometheus.Metric) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for reason, count := range a.rejected {
+		ch <- prometheus.MustNewConstMetric(clientCertRejectedDesc, prometheus.CounterValue, count, reason)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClientCertAuthorizer(t *testing.T) {
+	a := &clientCertAuthorizer{}
+	if err := a.configure([]string{`prometheus-\d+\.monitoring\.example\.com`, `spiffe://example\.org/ns/monitoring/.*`}, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	h := a.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	spiffe, _ := url.Parse("spiffe://example.org/ns/monitoring/sa/prometheus")
+	for name, test := range map[string]struct {
+		cert *x509.Certificate
+		want int
+	}{
+		"no certificate": {nil, http.StatusForbidden},
+		"common name":    {&x509.Certificate{Subject: pkix.Name{CommonName: "prometheus-0.monitoring.example.com"}}, http.StatusOK},
+		"DNS SAN":        {&x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"prometheus-1.monitoring.example.com"}}, http.StatusOK},
+		"URI SAN":        {&x509.Certificate{URIs: []*url.URL{spiffe}}, http.StatusOK},
+		"partial match":  {&x509.Certificate{DNSNames: []string{"prometheus-1.monitoring.example.com.evil.com"}}, http.StatusForbidden},
+		"not allowed":    {&x509.Certificate{Subject: pkix.Name{CommonName: "curl"}, IPAddresses: []net.IP{net.ParseIP("192.0.2.1")}}, http.StatusForbidden},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if test.cert != nil {
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{test.cert}}}
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", name, rec.Code, test.want)
+		}
+	}
+
+	want := `# HELP node_exporter_client_cert_rejected_total Number of requests rejected by --web.client-cert-allowed-name, by reason.
+# TYPE node_exporter_client_cert_rejected_total counter
+node_exporter_client_cert_rejected_total{reason="no_certificate"} 1
+node_exporter_client_cert_rejected_total{reason="not_allowed"} 2
+`
+	if err := testutil.CollectAndCompare(a, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
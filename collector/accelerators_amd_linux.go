@@ -0,0 +1,296 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// amdXGMIErrorsDesc is shared with the fabric health, as amdgpu only counts
+// the XGMI errors of a GPU over all its links.
+var amdXGMIErrorsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem+"_amd", "xgmi_errors_total"),
+	"Number of XGMI errors of an AMD GPU over all its links, since the driver was loaded or the counter was reset.",
+	[]string{"pci_address"}, nil,
+)
+
+// amdAcceleratorMetrics exposes the amdgpu sysfs and hwmon statistics of AMD
+// accelerators, the same data rocm-smi reports.
+type amdAcceleratorMetrics struct {
+	logger            log.Logger
+	readPCIeBandwidth bool
+	gpuBusyPercent    *prometheus.Desc
+	memoryVRAMUsed    *prometheus.Desc
+	memoryVRAMTotal   *prometheus.Desc
+	memoryBusy        *prometheus.Desc
+	pcieBandwidth     *prometheus.Desc
+	partitionInfo     *prometheus.Desc
+	xccs              *prometheus.Desc
+	power             *prometheus.Desc
+}
+
+func newAMDAcceleratorMetrics(logger log.Logger) *amdAcceleratorMetrics {
+	subsystem := acceleratorsCollectorSubsystem + "_amd"
+	return &amdAcceleratorMetrics{
+		logger:            logger,
+		readPCIeBandwidth: *acceleratorsAMDPCIeBandwidth,
+		gpuBusyPercent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "gpu_busy_percent"),
+			"How busy the GPU is as a percentage.",
+			[]string{"pci_address"}, nil,
+		),
+		memoryVRAMUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_vram_used_bytes"),
+			"The used amount of VRAM in bytes.",
+			[]string{"pci_address"}, nil,
+		),
+		memoryVRAMTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_vram_size_bytes"),
+			"The size of VRAM in bytes.",
+			[]string{"pci_address"}, nil,
+		),
+		memoryBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_busy_percent"),
+			"How busy the VRAM controller is as a percentage, a measure of memory bandwidth utilization.",
+			[]string{"pci_address"}, nil,
+		),
+		pcieBandwidth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "pcie_bandwidth_bytes_per_second"),
+			"Estimated PCIe throughput of the GPU over the last second in bytes per second, based on packet counts and the maximum payload size.",
+			[]string{"pci_address", "direction"}, nil,
+		),
+		partitionInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "partition_info"),
+			"Current compute (SPX, DPX, QPX, CPX) or memory (NPS1, NPS4) partition mode of a multi-die GPU.",
+			[]string{"pci_address", "type", "mode"}, nil,
+		),
+		xccs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "xcc_count"),
+			"Number of accelerator complex dies (XCDs) of the GPU and the compute partitions it is split into, from the KFD topology.",
+			[]string{"pci_address", "partition"}, nil,
+		),
+		power: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "power_watts"),
+			"Average power drawn by the GPU in watts.",
+			[]string{"pci_address"}, nil,
+		),
+	}
+}
+
+func (m *amdAcceleratorMetrics) enumerate(cards []acceleratorCard) []acceleratorCard {
+	return vendorCards(cards, amdVendorID)
+}
+
+func (m *amdAcceleratorMetrics) telemetry(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	for _, card := range cards {
+		emitAcceleratorFirmware(ch, card.address, amdFirmwareVersions(card))
+		m.update(ch, card)
+		m.updateXGMI(ch, card)
+	}
+}
+
+func (m *amdAcceleratorMetrics) update(ch chan<- prometheus.Metric, card acceleratorCard) {
+	for _, attr := range []struct {
+		file string
+		desc *prometheus.Desc
+	}{
+		{"gpu_busy_percent", m.gpuBusyPercent},
+		{"mem_info_vram_used", m.memoryVRAMUsed},
+		{"mem_info_vram_total", m.memoryVRAMTotal},
+		{"mem_busy_percent", m.memoryBusy},
+	} {
+		value, err := readUintFromFile(filepath.Join(card.path, attr.file))
+		if err != nil {
+			level.Debug(m.logger).Log("msg", "failed to read amdgpu attribute", "device", card.address, "file", attr.file, "err", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(attr.desc, prometheus.GaugeValue, float64(value), card.address)
+	}
+
+	for _, clock := range []struct {
+		file string
+		name string
+	}{
+		{"pp_dpm_sclk", "graphics"},
+		{"pp_dpm_mclk", "memory"},
+	} {
+		data, err := os.ReadFile(filepath.Join(card.path, clock.file))
+		if err != nil {
+			continue
+		}
+		if mhz, err := parseAMDCurrentClock(string(data)); err == nil {
+			ch <- prometheus.MustNewConstMetric(acceleratorClockDesc, prometheus.GaugeValue, mhz*1e6, card.address, clock.name)
+		} else {
+			level.Debug(m.logger).Log("msg", "failed to parse amdgpu clock levels", "device", card.address, "file", clock.file, "err", err)
+		}
+	}
+
+	// Partition modes are only supported by multi-die GPUs such as MI300.
+	for _, partition := range []struct {
+		file string
+		kind string
+	}{
+		{"current_compute_partition", "compute"},
+		{"current_memory_partition", "memory"},
+	} {
+		if data, err := os.ReadFile(filepath.Join(card.path, partition.file)); err == nil {
+			ch <- prometheus.MustNewConstMetric(m.partitionInfo, prometheus.GaugeValue, 1, card.address, partition.kind, strings.TrimSpace(string(data)))
+		}
+	}
+	m.updateXCCs(ch, card)
+
+	// Reading pcie_bw blocks for the one second amdgpu samples the counters.
+	if m.readPCIeBandwidth {
+		if data, err := os.ReadFile(filepath.Join(card.path, "pcie_bw")); err == nil {
+			if rx, tx, err := parseAMDPCIeBandwidth(string(data)); err == nil {
+				ch <- prometheus.MustNewConstMetric(m.pcieBandwidth, prometheus.GaugeValue, rx, card.address, "rx")
+				ch <- prometheus.MustNewConstMetric(m.pcieBandwidth, prometheus.GaugeValue, tx, card.address, "tx")
+			} else {
+				level.Debug(m.logger).Log("msg", "failed to parse amdgpu pcie_bw", "device", card.address, "err", err)
+			}
+		}
+	}
+
+	hwmons, err := filepath.Glob(filepath.Join(card.path, "hwmon", "hwmon*"))
+	if err != nil || len(hwmons) == 0 {
+		return
+	}
+	hwmon := hwmons[0]
+
+	if microWatts, err := readUintFromFile(filepath.Join(hwmon, "power1_average")); err == nil {
+		ch <- prometheus.MustNewConstMetric(m.power, prometheus.GaugeValue, float64(microWatts)/1e6, card.address)
+	}
+}
+
+// updateXCCs exposes the number of XCDs of each compute partition of the GPU.
+// KFD reports each compute partition as a topology node with the PCI location
+// of the GPU, so a GPU in CPX mode has one node per XCD.
+func (m *amdAcceleratorMetrics) updateXCCs(ch chan<- prometheus.Metric, card acceleratorCard) {
+	nodes, err := filepath.Glob(sysFilePath("class/kfd/kfd/topology/nodes/*/properties"))
+	if err != nil || len(nodes) == 0 {
+		return
+	}
+
+	partition := 0
+	for _, node := range nodes {
+		f, err := os.Open(node)
+		if err != nil {
+			continue
+		}
+		props, err := parseKFDProperties(f)
+		f.Close()
+		if err != nil {
+			level.Debug(m.logger).Log("msg", "failed to parse KFD node properties", "file", node, "err", err)
+			continue
+		}
+		// CPU nodes have no XCCs.
+		if props["num_xcc"] == 0 || kfdPCIAddress(props) != card.address {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(m.xccs, prometheus.GaugeValue, float64(props["num_xcc"]), card.address, strconv.Itoa(partition))
+		partition++
+	}
+}
+
+// updateXGMI exposes the XGMI error count of a GPU and its XGMI links to the
+// other GPUs of its hive, read from the KFD topology.
+func (m *amdAcceleratorMetrics) updateXGMI(ch chan<- prometheus.Metric, card acceleratorCard) {
+	if count, err := readUintFromFile(filepath.Join(card.path, "xgmi_error")); err == nil {
+		ch <- prometheus.MustNewConstMetric(amdXGMIErrorsDesc, prometheus.CounterValue, float64(count), card.address)
+	}
+
+	links, err := readKFDXGMILinks(sysFilePath("class/kfd/kfd/topology/nodes"), card.address)
+	if err != nil {
+		level.Debug(m.logger).Log("msg", "failed to read XGMI links", "device", card.address, "err", err)
+		return
+	}
+	emitAcceleratorLinks(ch, card.address, "xgmi", links)
+}
+
+// parseKFDProperties parses the properties file of a KFD topology node, made
+// of "name value" lines.
+func parseKFDProperties(r io.Reader) (map[string]uint64, error) {
+	props := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KFD property %q: %w", fields[0], err)
+		}
+		props[fields[0]] = value
+	}
+	return props, scanner.Err()
+}
+
+// kfdPCIAddress returns the PCI address of a KFD node from its domain and
+// location_id, which holds the bus number and devfn.
+func kfdPCIAddress(props map[string]uint64) string {
+	location := props["location_id"]
+	devfn := location & 0xff
+	return fmt.Sprintf("%04x:%02x:%02x.%x", props["domain"], location>>8, devfn>>3, devfn&0x7)
+}
+
+// parseAMDPCIeBandwidth parses the pcie_bw attribute of amdgpu, holding the
+// number of packets received and sent during the last second and the maximum
+// payload size, into the received and sent bytes per second.
+func parseAMDPCIeBandwidth(s string) (float64, float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return 0, 0, fmt.Errorf("unexpected pcie_bw %q", strings.TrimSpace(s))
+	}
+	var values [3]float64
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid pcie_bw %q: %w", strings.TrimSpace(s), err)
+		}
+		values[i] = float64(v)
+	}
+	return values[0] * values[2], values[1] * values[2], nil
+}
+
+// parseAMDCurrentClock returns the current clock in MHz from a pp_dpm_*
+// attribute of amdgpu, which lists the DPM levels and marks the current one:
+//
+//	0: 500Mhz
+//	1: 1800Mhz *
+func parseAMDCurrentClock(s string) (float64, error) {
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[2] != "*" {
+			continue
+		}
+		mhz := strings.TrimSuffix(strings.ToLower(fields[1]), "mhz")
+		return strconv.ParseFloat(mhz, 64)
+	}
+	return 0, errors.New("no current clock level")
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// acceleratorBackend collects the telemetry of the accelerators of a vendor
+// from its driver interfaces, such as sysfs or a vendor library. The
+// collector takes care of finding and identifying the cards and of the PCI
+// attributes common to all of them, so supporting a new vendor only takes a
+// new backend.
+type acceleratorBackend interface {
+	// enumerate returns the cards, among the accelerators found on the PCI
+	// bus, that the backend handles.
+	enumerate(cards []acceleratorCard) []acceleratorCard
+	// telemetry exposes the metrics of the cards returned by enumerate.
+	telemetry(ch chan<- prometheus.Metric, cards []acceleratorCard)
+}
+
+// vendorCards returns the cards of the vendor with the given PCI ID.
+func vendorCards(cards []acceleratorCard, vendorID string) []acceleratorCard {
+	var matched []acceleratorCard
+	for _, card := range cards {
+		if card.vendorID == vendorID {
+			matched = append(matched, card)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	acceleratorsFabricErrorWindow = kingpin.Flag("collector.accelerators.fabric-error-window",
+		"Time for which a NVLink or XGMI link counts as degraded in the fabric health after one of its error counters increased.").Default("5m").Duration()
+
+	acceleratorFabricLinksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "fabric_links"),
+		"Number of links between the accelerators of the node, by type.",
+		[]string{"type"}, nil,
+	)
+	acceleratorFabricDegradedLinksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "fabric_degraded_links"),
+		"Number of links between the accelerators of the node that are down or had errors within --collector.accelerators.fabric-error-window, by type.",
+		[]string{"type"}, nil,
+	)
+	acceleratorFabricHealthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "fabric_health_score"),
+		"Ratio of the links between the accelerators of the node that are not degraded, over all link types, from 0 to 1.",
+		nil, nil,
+	)
+)
+
+// acceleratorFabric computes the health of the NVLink and XGMI fabric of the
+// node from the link metrics exposed by the backends, so that a single alert
+// covers the fabrics of all vendors. It keeps the error counters of the
+// previous scrape to tell when they increased.
+type acceleratorFabric struct {
+	// errorWindow is how long a link is degraded after its errors
+	// increased.
+	errorWindow time.Duration
+
+	mu sync.Mutex
+	// errors are the error counters of the previous scrape.
+	errors map[fabricErrorKey]float64
+	// lastErrors are the last times the errors of a link, or of all links of
+	// a type of a card if its errors are not counted per link, increased.
+	lastErrors map[fabricLinkKey]time.Time
+}
+
+// fabricLinkKey identifies a link, or all links of a type of a card if link
+// is empty.
+type fabricLinkKey struct {
+	address, linkType, link string
+}
+
+type fabricErrorKey struct {
+	fabricLinkKey
+	error string
+}
+
+func newAcceleratorFabric() *acceleratorFabric {
+	return &acceleratorFabric{
+		errorWindow: *acceleratorsFabricErrorWindow,
+		errors:      map[fabricErrorKey]float64{},
+		lastErrors:  map[fabricLinkKey]time.Time{},
+	}
+}
+
+// fabricScrape holds the link metrics observed during a scrape.
+type fabricScrape struct {
+	up     map[fabricLinkKey]bool
+	errors map[fabricErrorKey]float64
+}
+
+// observe passes the metrics of the backends through to ch, recording the
+// link metrics, until the returned function is called with the end of the
+// scrape. It then exposes the fabric health, if the node has links.
+func (f *acceleratorFabric) observe(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func(now time.Time)) {
+	scrape := &fabricScrape{up: map[fabricLinkKey]bool{}, errors: map[fabricErrorKey]float64{}}
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range metrics {
+			scrape.observe(m)
+			ch <- m
+		}
+		close(done)
+	}()
+	return metrics, func(now time.Time) {
+		close(metrics)
+		<-done
+		f.update(ch, scrape, now)
+	}
+}
+
+func (s *fabricScrape) observe(m prometheus.Metric) {
+	desc := m.Desc()
+	if desc != acceleratorLinkUpDesc && desc != acceleratorLinkErrorsDesc && desc != amdXGMIErrorsDesc {
+		return
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return
+	}
+	labels := make(map[string]string, len(pb.Label))
+	for _, l := range pb.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+
+	switch desc {
+	case acceleratorLinkUpDesc:
+		key := fabricLinkKey{labels["pci_address"], labels["type"], labels["link"]}
+		s.up[key] = pb.Gauge.GetValue() == 1
+	case acceleratorLinkErrorsDesc:
+		key := fabricLinkKey{labels["pci_address"], labels["type"], labels["link"]}
+		s.errors[fabricErrorKey{key, labels["error"]}] = pb.Counter.GetValue()
+	case amdXGMIErrorsDesc:
+		key := fabricLinkKey{address: labels["pci_address"], linkType: "xgmi"}
+		s.errors[fabricErrorKey{fabricLinkKey: key}] = pb.Counter.GetValue()
+	}
+}
+
+// update compares the error counters of a scrape with the previous one and
+// exposes the fabric health.
+func (f *acceleratorFabric) update(ch chan<- prometheus.Metric, scrape *fabricScrape, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	lastErrors := make(map[fabricLinkKey]time.Time, len(f.lastErrors))
+	for key, value := range scrape.errors {
+		last, ok := f.lastErrors[key.fabricLinkKey]
+		if previous, seen := f.errors[key]; seen && value > previous {
+			last, ok = now, true
+		}
+		if ok && last.After(lastErrors[key.fabricLinkKey]) {
+			lastErrors[key.fabricLinkKey] = last
+		}
+	}
+	f.errors, f.lastErrors = scrape.errors, lastErrors
+
+	if len(scrape.up) == 0 {
+		return
+	}
+	recentErrors := func(key fabricLinkKey) bool {
+		last, ok := lastErrors[key]
+		return ok && now.Sub(last) < f.errorWindow
+	}
+	links, degraded := map[string]int{}, map[string]int{}
+	total, healthy := 0, 0
+	for key, up := range scrape.up {
+		links[key.linkType]++
+		total++
+		card := fabricLinkKey{address: key.address, linkType: key.linkType}
+		if !up || recentErrors(key) || recentErrors(card) {
+			degraded[key.linkType]++
+			continue
+		}
+		healthy++
+	}
+	for linkType, count := range links {
+		ch <- prometheus.MustNewConstMetric(acceleratorFabricLinksDesc, prometheus.GaugeValue, float64(count), linkType)
+		ch <- prometheus.MustNewConstMetric(acceleratorFabricDegradedLinksDesc, prometheus.GaugeValue, float64(degraded[linkType]), linkType)
+	}
+	ch <- prometheus.MustNewConstMetric(acceleratorFabricHealthDesc, prometheus.GaugeValue, float64(healthy)/float64(total))
+}
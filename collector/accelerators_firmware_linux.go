@@ -0,0 +1,110 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	acceleratorFirmwareInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "firmware_info"),
+		"Version of a firmware component of an accelerator card, such as the VBIOS, as reported by its driver.",
+		[]string{"pci_address", "component", "version"}, nil,
+	)
+
+	// nvidiaFirmwareFields maps the lines of the NVIDIA GPU information file
+	// to firmware components.
+	nvidiaFirmwareFields = map[string]string{
+		"Video BIOS":   "vbios",
+		"GPU Firmware": "gsp",
+	}
+	// habanaFirmwareFiles are the firmware version attributes of the
+	// habanalabs driver, named <component>_ver.
+	habanaFirmwareFiles = []string{
+		"cpld_ver", "cpucp_kernel_ver", "cpucp_ver", "fuse_ver",
+		"infineon_ver", "preboot_btl_ver", "thermal_ver", "uboot_ver",
+	}
+)
+
+// emitAcceleratorFirmware exposes the versions of the firmware components of
+// a card.
+func emitAcceleratorFirmware(ch chan<- prometheus.Metric, address string, versions map[string]string) {
+	for component, version := range versions {
+		ch <- prometheus.MustNewConstMetric(acceleratorFirmwareInfoDesc, prometheus.GaugeValue, 1, address, component, version)
+	}
+}
+
+// amdFirmwareVersions returns the VBIOS version and the versions of the
+// microcontroller firmwares of an AMD GPU.
+func amdFirmwareVersions(card acceleratorCard) map[string]string {
+	versions := map[string]string{}
+	if version, err := readFirmwareVersion(filepath.Join(card.path, "vbios_version")); err == nil {
+		versions["vbios"] = version
+	}
+	// fw_version holds one <component>_fw_version file per
+	// microcontroller, e.g. smc_fw_version.
+	files, _ := filepath.Glob(filepath.Join(card.path, "fw_version", "*_fw_version"))
+	for _, file := range files {
+		if version, err := readFirmwareVersion(file); err == nil {
+			versions[strings.TrimSuffix(filepath.Base(file), "_fw_version")] = version
+		}
+	}
+	return versions
+}
+
+// nvidiaFirmwareVersions returns the firmware versions listed in the
+// information file of an NVIDIA GPU, see readNVIDIAGPUInformation.
+func nvidiaFirmwareVersions(info map[string]string) map[string]string {
+	versions := map[string]string{}
+	for field, component := range nvidiaFirmwareFields {
+		if version := info[field]; version != "" && version != "N/A" {
+			versions[component] = version
+		}
+	}
+	return versions
+}
+
+// habanaFirmwareVersions returns the firmware versions of a Habana
+// accelerator.
+func habanaFirmwareVersions(card acceleratorCard) map[string]string {
+	versions := map[string]string{}
+	for _, file := range habanaFirmwareFiles {
+		if version, err := readFirmwareVersion(filepath.Join(card.path, file)); err == nil {
+			versions[strings.TrimSuffix(file, "_ver")] = version
+		}
+	}
+	return versions
+}
+
+// readFirmwareVersion reads a version attribute, which the drivers leave empty
+// for components without firmware.
+func readFirmwareVersion(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if version == "" {
+		return "", os.ErrNotExist
+	}
+	return version, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// habanaAcceleratorMetrics exposes the habanalabs sysfs attributes of Gaudi
+// accelerators, found in /sys/class/accel/accel*/device/.
+type habanaAcceleratorMetrics struct {
+	logger      log.Logger
+	clock       *prometheus.Desc
+	maxPower    *prometheus.Desc
+	resets      *prometheus.Desc
+	power       *prometheus.Desc
+	operational *prometheus.Desc
+	deviceInfo  *prometheus.Desc
+}
+
+func newHabanaAcceleratorMetrics(logger log.Logger) *habanaAcceleratorMetrics {
+	subsystem := acceleratorsCollectorSubsystem + "_habana"
+	return &habanaAcceleratorMetrics{
+		logger: logger,
+		clock: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "clock_hertz"),
+			"Clock frequency of the accelerator in hertz.",
+			[]string{"pci_address", "type"}, nil,
+		),
+		maxPower: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_power_watts"),
+			"Maximum power the accelerator is allowed to draw in watts.",
+			[]string{"pci_address"}, nil,
+		),
+		resets: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "resets_total"),
+			"Number of resets of the accelerator since the driver was loaded.",
+			[]string{"pci_address", "type"}, nil,
+		),
+		power: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "power_watts"),
+			"Power drawn by the accelerator in watts.",
+			[]string{"pci_address"}, nil,
+		),
+		operational: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "operational"),
+			"Whether the driver reports the accelerator as operational.",
+			[]string{"pci_address", "status"}, nil,
+		),
+		deviceInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "device_info"),
+			"Device type of the accelerator as reported by the habanalabs driver.",
+			[]string{"pci_address", "device_type"}, nil,
+		),
+	}
+}
+
+func (m *habanaAcceleratorMetrics) enumerate(cards []acceleratorCard) []acceleratorCard {
+	return vendorCards(cards, habanaVendorID)
+}
+
+func (m *habanaAcceleratorMetrics) telemetry(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	for _, card := range cards {
+		emitAcceleratorFirmware(ch, card.address, habanaFirmwareVersions(card))
+		m.update(ch, card)
+	}
+}
+
+func (m *habanaAcceleratorMetrics) update(ch chan<- prometheus.Metric, card acceleratorCard) {
+	// The habanalabs driver registers the card as /sys/class/accel/accel<N>,
+	// whose device is the PCI device itself.
+	if accels, err := filepath.Glob(filepath.Join(card.path, "accel", "accel*")); err != nil || len(accels) == 0 {
+		level.Debug(m.logger).Log("msg", "no accel device found, is the habanalabs driver loaded?", "device", card.address)
+		return
+	}
+
+	if deviceType, err := os.ReadFile(filepath.Join(card.path, "device_type")); err == nil {
+		ch <- prometheus.MustNewConstMetric(m.deviceInfo, prometheus.GaugeValue, 1, card.address, strings.TrimSpace(string(deviceType)))
+	}
+
+	if status, err := os.ReadFile(filepath.Join(card.path, "status")); err == nil {
+		s := strings.TrimSpace(string(status))
+		value := 0.0
+		if s == "operational" {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(m.operational, prometheus.GaugeValue, value, card.address, s)
+	}
+
+	for _, clock := range []struct {
+		file string
+		kind string
+	}{
+		{"clk_cur_freq_mhz", "current"},
+		{"clk_max_freq_mhz", "max"},
+	} {
+		if mhz, err := readUintFromFile(filepath.Join(card.path, clock.file)); err == nil {
+			ch <- prometheus.MustNewConstMetric(m.clock, prometheus.GaugeValue, float64(mhz)*1e6, card.address, clock.kind)
+		}
+	}
+
+	if microWatts, err := readUintFromFile(filepath.Join(card.path, "max_power")); err == nil {
+		ch <- prometheus.MustNewConstMetric(m.maxPower, prometheus.GaugeValue, float64(microWatts)/1e6, card.address)
+	}
+
+	for _, reset := range []struct {
+		file string
+		kind string
+	}{
+		{"soft_reset_cnt", "soft"},
+		{"hard_reset_cnt", "hard"},
+	} {
+		if count, err := readUintFromFile(filepath.Join(card.path, reset.file)); err == nil {
+			ch <- prometheus.MustNewConstMetric(m.resets, prometheus.CounterValue, float64(count), card.address, reset.kind)
+		}
+	}
+
+	hwmons, err := filepath.Glob(filepath.Join(card.path, "hwmon", "hwmon*"))
+	if err != nil || len(hwmons) == 0 {
+		return
+	}
+	hwmon := hwmons[0]
+
+	if microWatts, err := readUintFromFile(filepath.Join(hwmon, "power1_input")); err == nil {
+		ch <- prometheus.MustNewConstMetric(m.power, prometheus.GaugeValue, float64(microWatts)/1e6, card.address)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// xeEngineClasses maps the engine classes of the xe driver to the engine
+// names used by i915.
+var xeEngineClasses = map[string]string{
+	"rcs":  "render",
+	"bcs":  "copy",
+	"vcs":  "video",
+	"vecs": "video-enhance",
+	"ccs":  "compute",
+}
+
+// intelAcceleratorMetrics exposes the engine busyness of Intel GPUs driven by
+// i915 or xe. Neither driver exposes busyness in sysfs, so it is aggregated
+// from the DRM client statistics in /proc/<pid>/fdinfo, see
+// https://docs.kernel.org/gpu/drm-usage-stats.html.
+type intelAcceleratorMetrics struct {
+	logger      log.Logger
+	engineBusy  *prometheus.Desc
+	engineCycle *prometheus.Desc
+
+	mu sync.Mutex
+	// Clients come and go, so the counters are accumulated from the
+	// per-client values of the previous scrape to stay monotonic.
+	lastClients map[drmClientKey]drmClientStats
+	busyTotal   map[drmEngineKey]float64
+	cyclesTotal map[drmEngineKey]float64
+}
+
+type drmClientKey struct {
+	pdev string
+	id   string
+}
+
+type drmEngineKey struct {
+	pdev   string
+	engine string
+}
+
+// drmClientStats holds the busy time in nanoseconds (i915) or busy cycles
+// (xe) of a DRM client by engine.
+type drmClientStats struct {
+	busyNs map[string]float64
+	cycles map[string]float64
+}
+
+func newIntelAcceleratorMetrics(logger log.Logger) *intelAcceleratorMetrics {
+	subsystem := acceleratorsCollectorSubsystem + "_intel"
+	return &intelAcceleratorMetrics{
+		logger: logger,
+		engineBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "engine_busy_seconds_total"),
+			"Time GPU engines spent busy executing work of DRM clients (i915).",
+			[]string{"pci_address", "engine"}, nil,
+		),
+		engineCycle: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "engine_busy_cycles_total"),
+			"GPU engine cycles spent executing work of DRM clients (xe).",
+			[]string{"pci_address", "engine"}, nil,
+		),
+		lastClients: map[drmClientKey]drmClientStats{},
+		busyTotal:   map[drmEngineKey]float64{},
+		cyclesTotal: map[drmEngineKey]float64{},
+	}
+}
+
+func (m *intelAcceleratorMetrics) enumerate(cards []acceleratorCard) []acceleratorCard {
+	return vendorCards(cards, intelVendorID)
+}
+
+func (m *intelAcceleratorMetrics) telemetry(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	if len(cards) == 0 {
+		return
+	}
+
+	clients := m.drmClients()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, stats := range clients {
+		last := m.lastClients[key]
+		for engine, value := range stats.busyNs {
+			m.busyTotal[drmEngineKey{key.pdev, engine}] += counterDelta(last.busyNs[engine], value)
+		}
+		for engine, value := range stats.cycles {
+			m.cyclesTotal[drmEngineKey{key.pdev, engine}] += counterDelta(last.cycles[engine], value)
+		}
+	}
+	m.lastClients = clients
+
+	for _, card := range cards {
+		for key, ns := range m.busyTotal {
+			if key.pdev == card.address {
+				ch <- prometheus.MustNewConstMetric(m.engineBusy, prometheus.CounterValue, ns/1e9, card.address, key.engine)
+			}
+		}
+		for key, cycles := range m.cyclesTotal {
+			if key.pdev == card.address {
+				ch <- prometheus.MustNewConstMetric(m.engineCycle, prometheus.CounterValue, cycles, card.address, key.engine)
+			}
+		}
+	}
+}
+
+// counterDelta returns the increase of a per-client counter since the
+// previous scrape, which is the full value for new clients.
+func counterDelta(last, current float64) float64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+// drmClients returns the statistics of all DRM clients of i915 and xe
+// devices. A client may be shared by several file descriptors and processes,
+// so clients are keyed by device and client ID.
+func (m *intelAcceleratorMetrics) drmClients() map[drmClientKey]drmClientStats {
+	clients := map[drmClientKey]drmClientStats{}
+
+	fdDirs, err := filepath.Glob(procFilePath("[0-9]*/fd"))
+	if err != nil {
+		return clients
+	}
+	for _, fdDir := range fdDirs {
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// Processes of other users cannot be inspected without privileges.
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "/dev/dri/") {
+				continue
+			}
+			fdinfo := filepath.Join(filepath.Dir(fdDir), "fdinfo", fd.Name())
+			key, stats, err := parseDRMFdinfo(fdinfo)
+			if err != nil {
+				level.Debug(m.logger).Log("msg", "failed to parse DRM fdinfo", "file", fdinfo, "err", err)
+				continue
+			}
+			if key.id != "" {
+				clients[key] = stats
+			}
+		}
+	}
+
+	return clients
+}
+
+func parseDRMFdinfo(path string) (drmClientKey, drmClientStats, error) {
+	var key drmClientKey
+	stats := drmClientStats{busyNs: map[string]float64{}, cycles: map[string]float64{}}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return key, stats, err
+	}
+	defer f.Close()
+
+	var driver string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case name == "drm-driver":
+			driver = value
+		case name == "drm-pdev":
+			key.pdev = value
+		case name == "drm-client-id":
+			key.id = value
+		case strings.HasPrefix(name, "drm-engine-") && !strings.HasPrefix(name, "drm-engine-capacity-"):
+			// i915: "drm-engine-render: 123456 ns"
+			ns, err := strconv.ParseFloat(strings.TrimSuffix(value, " ns"), 64)
+			if err == nil {
+				stats.busyNs[strings.TrimPrefix(name, "drm-engine-")] = ns
+			}
+		case strings.HasPrefix(name, "drm-cycles-"):
+			// xe: "drm-cycles-rcs: 123456"
+			class := strings.TrimPrefix(name, "drm-cycles-")
+			cycles, err := strconv.ParseFloat(value, 64)
+			if engine, ok := xeEngineClasses[class]; ok && err == nil {
+				stats.cycles[engine] = cycles
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return key, stats, err
+	}
+
+	if driver != "i915" && driver != "xe" {
+		return drmClientKey{}, stats, nil
+	}
+	return key, stats, nil
+}
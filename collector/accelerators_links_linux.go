@@ -0,0 +1,173 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// kfdIOLinkTypeXGMI is the type of XGMI links in the KFD topology.
+const kfdIOLinkTypeXGMI = 11
+
+// acceleratorLink is a link between accelerators, such as an NVLink or an
+// XGMI link.
+type acceleratorLink struct {
+	link string
+	// peer is the PCI address of the other end, empty if unknown.
+	peer string
+	up   bool
+	// speedBytesPerSecond is 0 if unknown.
+	speedBytesPerSecond float64
+	// errors are the error counters of the link by type.
+	errors map[string]float64
+}
+
+var (
+	// The link metrics are shared by the NVLinks of NVIDIA GPUs and the XGMI
+	// links of AMD GPUs.
+	acceleratorLinkUpDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "link_up"),
+		"Whether a link of an accelerator to a peer, identified by its PCI address, is active.",
+		[]string{"pci_address", "type", "link", "peer"}, nil,
+	)
+	acceleratorLinkSpeedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "link_speed_bytes_per_second"),
+		"Speed of an active link of an accelerator in bytes per second.",
+		[]string{"pci_address", "type", "link"}, nil,
+	)
+	acceleratorLinkErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "link_errors_total"),
+		"Number of errors, such as CRC errors and replays, on a link of an accelerator.",
+		[]string{"pci_address", "type", "link", "error"}, nil,
+	)
+)
+
+// emitAcceleratorLinks exposes the links of a card of the given type, e.g.
+// nvlink.
+func emitAcceleratorLinks(ch chan<- prometheus.Metric, address string, linkType string, links []acceleratorLink) {
+	for _, link := range links {
+		up := 0.0
+		if link.up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(acceleratorLinkUpDesc, prometheus.GaugeValue, up, address, linkType, link.link, link.peer)
+		if link.speedBytesPerSecond > 0 {
+			ch <- prometheus.MustNewConstMetric(acceleratorLinkSpeedDesc, prometheus.GaugeValue, link.speedBytesPerSecond, address, linkType, link.link)
+		}
+		for name, value := range link.errors {
+			ch <- prometheus.MustNewConstMetric(acceleratorLinkErrorsDesc, prometheus.CounterValue, value, address, linkType, link.link, name)
+		}
+	}
+}
+
+// readKFDXGMILinks returns the XGMI links of the GPU at the given PCI address
+// from the io_links of its KFD topology nodes. The KFD only lists active
+// links, with their maximum bandwidth in MB/s. GPUs split into compute
+// partitions have one node per partition, whose links are numbered in order.
+func readKFDXGMILinks(nodesDir string, address string) ([]acceleratorLink, error) {
+	nodes, err := numberedDirs(nodesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := map[uint64]string{}
+	var ownNodes []uint64
+	for _, node := range nodes {
+		props, err := readKFDPropertiesFile(filepath.Join(nodesDir, strconv.FormatUint(node, 10), "properties"))
+		if err != nil {
+			return nil, err
+		}
+		// CPU nodes have no PCI address.
+		if props["location_id"] == 0 && props["domain"] == 0 {
+			continue
+		}
+		addresses[node] = kfdPCIAddress(props)
+		if addresses[node] == address {
+			ownNodes = append(ownNodes, node)
+		}
+	}
+
+	var links []acceleratorLink
+	for _, node := range ownNodes {
+		ioLinksDir := filepath.Join(nodesDir, strconv.FormatUint(node, 10), "io_links")
+		ioLinks, err := numberedDirs(ioLinksDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, ioLink := range ioLinks {
+			props, err := readKFDPropertiesFile(filepath.Join(ioLinksDir, strconv.FormatUint(ioLink, 10), "properties"))
+			if err != nil {
+				return nil, err
+			}
+			if props["type"] != kfdIOLinkTypeXGMI {
+				continue
+			}
+			links = append(links, acceleratorLink{
+				link:                strconv.Itoa(len(links)),
+				peer:                addresses[props["node_to"]],
+				up:                  true,
+				speedBytesPerSecond: float64(props["max_bandwidth"]) * 1e6,
+			})
+		}
+	}
+	return links, nil
+}
+
+// numberedDirs returns the numbers of the entries of a directory named
+// after numbers, such as KFD topology nodes, in increasing order.
+func numberedDirs(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []uint64
+	for _, entry := range entries {
+		if n, err := strconv.ParseUint(entry.Name(), 10, 64); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
+
+func readKFDPropertiesFile(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseKFDProperties(f)
+}
+
+// nvmlBusIDToPCIAddress converts a PCI bus ID returned by NVML, such as
+// "00000000:3B:00.0", to the format of sysfs.
+func nvmlBusIDToPCIAddress(busID string) string {
+	domain, rest, ok := strings.Cut(strings.ToLower(busID), ":")
+	if !ok {
+		return ""
+	}
+	if len(domain) > 4 {
+		domain = domain[len(domain)-4:]
+	}
+	return domain + ":" + rest
+}
@@ -0,0 +1,787 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	acceleratorsCollectorSubsystem = "accelerator"
+)
+
+var (
+	// acceleratorClockDesc and acceleratorThrottleReasonDesc are shared by the
+	// vendor specific metrics.
+	acceleratorClockDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "clock_hertz"),
+		"Current clock frequency of an accelerator in hertz.",
+		[]string{"pci_address", "clock"}, nil,
+	)
+	acceleratorThrottleReasonDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "throttle_reason"),
+		"Whether the clocks of an accelerator are currently held down for the reason.",
+		[]string{"pci_address", "reason"}, nil,
+	)
+
+	// nvmlThrottleReasons are the bits of nvmlClocksThrottleReasons, see
+	// https://docs.nvidia.com/deploy/nvml-api/group__nvmlClocksThrottleReasons.html
+	nvmlThrottleReasons = []struct {
+		bit    uint64
+		reason string
+	}{
+		{0x1, "gpu_idle"},
+		{0x2, "applications_clocks_setting"},
+		{0x4, "sw_power_cap"},
+		{0x8, "hw_slowdown"},
+		{0x10, "sync_boost"},
+		{0x20, "sw_thermal_slowdown"},
+		{0x40, "hw_thermal_slowdown"},
+		{0x80, "hw_power_brake_slowdown"},
+		{0x100, "display_clock_setting"},
+	}
+)
+
+// pciIDRE matches a PCI vendor or device ID as found in sysfs without the 0x
+// prefix.
+var pciIDRE = regexp.MustCompile(`^[0-9a-f]{4}$`)
+
+var (
+	// pciPowerStates are the values of the power_state attribute of PCI devices.
+	pciPowerStates = []string{"D0", "D1", "D2", "D3hot", "D3cold", "unknown", "error"}
+	// pciRuntimeStatuses are the values of the power/runtime_status attribute
+	// of devices.
+	pciRuntimeStatuses = []string{"active", "suspended", "suspending", "resuming", "error", "unsupported"}
+)
+
+var (
+	acceleratorsPCIIDsPath = kingpin.Flag("collector.accelerators.pci-ids-path",
+		"Path to a pci.ids database used to identify 3D controllers and processing accelerators missing from the built-in device list (e.g. /usr/share/hwdata/pci.ids).").String()
+	acceleratorsDeviceMap = kingpin.Flag("collector.accelerators.device-map",
+		"YAML file mapping \"<vendor>:<device>\" PCI IDs to model names, added to the built-in device list. Reloaded on SIGHUP and /-/reload.").String()
+	acceleratorsResourceMap = kingpin.Flag("collector.accelerators.resource-map",
+		"YAML file mapping PCI vendor or \"<vendor>:<device>\" IDs to Kubernetes extended resource names (e.g. nvidia.com/gpu), exposed as resource label. Reloaded on SIGHUP and /-/reload.").String()
+	acceleratorsVendorInclude = kingpin.Flag("collector.accelerators.vendor-include",
+		"Regexp of accelerator vendors to include (mutually exclusive to vendor-exclude), matched against the vendor label.").String()
+	acceleratorsVendorExclude = kingpin.Flag("collector.accelerators.vendor-exclude",
+		"Regexp of accelerator vendors to exclude (mutually exclusive to vendor-include), matched against the vendor label.").String()
+
+	acceleratorsDeviceExcludeFile, acceleratorsDeviceIncludeFile = deviceListFileFlags("accelerators", "device", "PCI addresses of accelerator cards")
+
+	acceleratorsDetectByClass = kingpin.Flag("collector.accelerators.detect-by-class",
+		"Also report 3D controllers and processing accelerators missing from the built-in device list, based on their PCI class.").Bool()
+	acceleratorsAMDPCIeBandwidth = kingpin.Flag("collector.accelerators.amd-pcie-bandwidth",
+		"Expose the PCIe throughput of AMD GPUs. Reading it takes one second per GPU.").Bool()
+	acceleratorsIntelFdinfo = kingpin.Flag("collector.accelerators.intel-fdinfo",
+		"Expose Intel GPU engine busyness aggregated from the DRM client statistics in /proc/<pid>/fdinfo. Requires access to the file descriptors of GPU clients.").Bool()
+	acceleratorsNVML = kingpin.Flag("collector.accelerators.nvml",
+		"Expose NVIDIA GPU utilization, memory, clock, temperature and ECC metrics using NVML. Requires a build with cgo.").Bool()
+	acceleratorsNVMLLibrary = kingpin.Flag("collector.accelerators.nvml-library",
+		"Path or name of the NVML library loaded with --collector.accelerators.nvml.").Default("libnvidia-ml.so.1").String()
+)
+
+// acceleratorVendors maps PCI vendor IDs of known accelerator vendors to the
+// value of the vendor label.
+var acceleratorVendors = map[string]string{
+	amdVendorID:    "AMD",
+	nvidiaVendorID: "NVIDIA",
+	habanaVendorID: "Habana",
+	intelVendorID:  "Intel",
+}
+
+// PCI vendor IDs of the vendors with a backend.
+const (
+	amdVendorID    = "1002"
+	nvidiaVendorID = "10de"
+	habanaVendorID = "1da3"
+	intelVendorID  = "8086"
+)
+
+// acceleratorModels maps "<vendor>:<device>" PCI IDs of known accelerator
+// cards to the value of the model label.
+var acceleratorModels = map[string]string{
+	"1002:740c": "Instinct MI250X/MI250",
+	"1002:740f": "Instinct MI210",
+	"1002:74a1": "Instinct MI300X",
+	"10de:1db4": "Tesla V100-PCIE-16GB",
+	"10de:1db6": "Tesla V100-PCIE-32GB",
+	"10de:1eb8": "Tesla T4",
+	"10de:20b0": "A100-SXM4-40GB",
+	"10de:20b2": "A100-SXM4-80GB",
+	"10de:20b5": "A100-PCIE-80GB",
+	"10de:20f1": "A100-PCIE-40GB",
+	"10de:2235": "A40",
+	"10de:2236": "A10",
+	"10de:2321": "H100L-94GB",
+	"10de:2330": "H100-SXM5-80GB",
+	"10de:2331": "H100-PCIE-80GB",
+	"10de:26b5": "L40",
+	"10de:26b9": "L40S",
+	"10de:27b8": "L4",
+	"1da3:1000": "Gaudi HL-2000",
+	"1da3:1010": "Gaudi HL-2000 (secured)",
+	"1da3:1020": "Gaudi2 HL-225",
+	"8086:0bd5": "Data Center GPU Max 1550",
+	"8086:0bda": "Data Center GPU Max 1100",
+}
+
+type acceleratorsCollector struct {
+	// mu guards the device identification data, which is reloadable.
+	mu          sync.RWMutex
+	pciIDs      *pciIDs
+	deviceMap   map[string]string
+	resourceMap map[string]string
+
+	// The files at these paths are read again by reload.
+	pciIDsPath      string
+	deviceMapPath   string
+	resourceMapPath string
+	detectByClass   bool
+
+	vendorFilter  deviceFilter
+	deviceFilter  deviceFilter
+	logger        log.Logger
+	cardInfo      *prometheus.Desc
+	cards         *prometheus.Desc
+	pcieLinkSpeed *prometheus.Desc
+	pcieLinkWidth *prometheus.Desc
+	sriovNumVFs   *prometheus.Desc
+	sriovMaxVFs   *prometheus.Desc
+	vfInfo        *prometheus.Desc
+	driverInfo    *prometheus.Desc
+	pcieErrors    *prometheus.Desc
+	iommuGroup    *prometheus.Desc
+	subsystemInfo *prometheus.Desc
+	powerState    *prometheus.Desc
+	runtimeStatus *prometheus.Desc
+	temperature   *prometheus.Desc
+
+	// backends collect the vendor specific telemetry.
+	backends []acceleratorBackend
+	presence *acceleratorPresence
+	fabric   *acceleratorFabric
+}
+
+func init() {
+	registerCollector("accelerators", defaultDisabled, NewAcceleratorsCollector)
+}
+
+// NewAcceleratorsCollector returns a new Collector exposing GPUs and other
+// accelerator cards found in /sys/bus/pci/devices.
+func NewAcceleratorsCollector(logger log.Logger) (Collector, error) {
+	if *acceleratorsVendorInclude != "" && *acceleratorsVendorExclude != "" {
+		return nil, errors.New("vendor-include & vendor-exclude are mutually exclusive")
+	}
+	deviceFilter, err := deviceFilter{}.withListFiles(*acceleratorsDeviceExcludeFile, *acceleratorsDeviceIncludeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &acceleratorsCollector{
+		pciIDsPath:      *acceleratorsPCIIDsPath,
+		deviceMapPath:   *acceleratorsDeviceMap,
+		resourceMapPath: *acceleratorsResourceMap,
+		detectByClass:   *acceleratorsDetectByClass,
+		logger:          logger,
+		vendorFilter:    newDeviceFilter(*acceleratorsVendorExclude, *acceleratorsVendorInclude),
+		deviceFilter:    deviceFilter,
+		presence:        newAcceleratorPresence(),
+		fabric:          newAcceleratorFabric(),
+		cardInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "card_info"),
+			"Information about an accelerator card found on the PCI bus.",
+			[]string{"pci_address", "vendor", "model", "revision", "numa_node", "resource"}, nil,
+		),
+		cards: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "cards"),
+			"Number of accelerator cards of a vendor and model, by Kubernetes extended resource name.",
+			[]string{"vendor", "model", "resource"}, nil,
+		),
+		pcieLinkSpeed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "pcie_link_speed_gts"),
+			"PCIe link speed of an accelerator card in GT/s.",
+			[]string{"pci_address", "type"}, nil,
+		),
+		pcieLinkWidth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "pcie_link_width"),
+			"PCIe link width of an accelerator card in lanes.",
+			[]string{"pci_address", "type"}, nil,
+		),
+		sriovNumVFs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "sriov_vfs"),
+			"Number of SR-IOV virtual functions enabled on an accelerator card.",
+			[]string{"pci_address"}, nil,
+		),
+		sriovMaxVFs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "sriov_vfs_max"),
+			"Maximum number of SR-IOV virtual functions supported by an accelerator card.",
+			[]string{"pci_address"}, nil,
+		),
+		vfInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "vf_info"),
+			"SR-IOV virtual function of an accelerator card.",
+			[]string{"pci_address", "physfn", "vf_index"}, nil,
+		),
+		driverInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "driver_info"),
+			"Kernel driver bound to an accelerator card, empty if no driver is bound.",
+			[]string{"pci_address", "driver"}, nil,
+		),
+		pcieErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "pcie_errors_total"),
+			"Number of PCIe AER errors reported by an accelerator card since boot.",
+			[]string{"pci_address", "type", "severity"}, nil,
+		),
+		subsystemInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "subsystem_info"),
+			"PCI subsystem vendor and device IDs of an accelerator card, which tell OEM boards with the same chip apart.",
+			[]string{"pci_address", "subsystem_vendor", "subsystem_device"}, nil,
+		),
+		iommuGroup: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "iommu_group_info"),
+			"IOMMU group of an accelerator card, only exposed if the IOMMU is enabled.",
+			[]string{"pci_address", "iommu_group"}, nil,
+		),
+		powerState: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "power_state"),
+			"PCI power state of an accelerator card, 1 for the current state.",
+			[]string{"pci_address", "state"}, nil,
+		),
+		temperature: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "temperature_celsius"),
+			"Temperature of an accelerator card in degrees Celsius, from the hwmon sensors of its PCI device.",
+			[]string{"pci_address", "sensor"}, nil,
+		),
+		runtimeStatus: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "runtime_pm_status"),
+			"Runtime power management status of an accelerator card, 1 for the current status.",
+			[]string{"pci_address", "status"}, nil,
+		),
+	}
+
+	if c.pciIDsPath != "" {
+		ids, err := loadPCIIDs(c.pciIDsPath)
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to load pci.ids database, only built-in devices will be identified", "path", c.pciIDsPath, "err", err)
+		} else {
+			c.pciIDs = ids
+		}
+	}
+
+	if c.deviceMapPath != "" {
+		deviceMap, err := loadAcceleratorDeviceMap(c.deviceMapPath)
+		if err != nil {
+			return nil, err
+		}
+		c.deviceMap = deviceMap
+	}
+
+	if c.resourceMapPath != "" {
+		resourceMap, err := loadAcceleratorResourceMap(c.resourceMapPath)
+		if err != nil {
+			return nil, err
+		}
+		c.resourceMap = resourceMap
+	}
+
+	nvml := false
+	if *acceleratorsNVML {
+		if err := loadNVML(*acceleratorsNVMLLibrary); err != nil {
+			level.Warn(logger).Log("msg", "failed to load NVML, NVIDIA GPU metrics will not be exposed", "err", err)
+		} else {
+			nvml = true
+		}
+	}
+
+	c.backends = []acceleratorBackend{
+		newAMDAcceleratorMetrics(logger),
+		newHabanaAcceleratorMetrics(logger),
+		newNVIDIAAcceleratorMetrics(logger, nvml),
+	}
+	if *acceleratorsIntelFdinfo {
+		c.backends = append(c.backends, newIntelAcceleratorMetrics(logger))
+	}
+
+	return c, nil
+}
+
+// acceleratorCard is an accelerator found on the PCI bus.
+type acceleratorCard struct {
+	address  string
+	path     string
+	vendorID string
+	vendor   string
+	model    string
+	// resource is the Kubernetes extended resource name of the card, from
+	// --collector.accelerators.resource-map.
+	resource string
+}
+
+func (c *acceleratorsCollector) Update(ch chan<- prometheus.Metric) error {
+	cards, err := c.acceleratorCards()
+	if err != nil {
+		return err
+	}
+
+	type cardType struct{ vendor, model, resource string }
+	counts := map[cardType]int{}
+
+	for _, card := range cards {
+		counts[cardType{card.vendor, card.model, card.resource}]++
+
+		// numa_node is -1 on systems without NUMA support.
+		numaNode := "-1"
+		if data, err := os.ReadFile(filepath.Join(card.path, "numa_node")); err == nil {
+			numaNode = strings.TrimSpace(string(data))
+		}
+		// The revision tells steppings of the same model apart.
+		revision, err := readPCIID(filepath.Join(card.path, "revision"))
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read PCI revision", "device", card.address, "err", err)
+		}
+		ch <- prometheus.MustNewConstMetric(c.cardInfo, prometheus.GaugeValue, 1, card.address, card.vendor, card.model, revision, numaNode, card.resource)
+
+		driver := ""
+		if target, err := os.Readlink(filepath.Join(card.path, "driver")); err == nil {
+			driver = filepath.Base(target)
+		}
+		ch <- prometheus.MustNewConstMetric(c.driverInfo, prometheus.GaugeValue, 1, card.address, driver)
+
+		subsystemVendor, vendorErr := readPCIID(filepath.Join(card.path, "subsystem_vendor"))
+		subsystemDevice, deviceErr := readPCIID(filepath.Join(card.path, "subsystem_device"))
+		if vendorErr == nil && deviceErr == nil {
+			ch <- prometheus.MustNewConstMetric(c.subsystemInfo, prometheus.GaugeValue, 1, card.address, subsystemVendor, subsystemDevice)
+		}
+
+		// Devices are only assigned to IOMMU groups if the IOMMU is enabled.
+		if target, err := os.Readlink(filepath.Join(card.path, "iommu_group")); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.iommuGroup, prometheus.GaugeValue, 1, card.address, filepath.Base(target))
+		}
+
+		c.updatePCIeLink(ch, card)
+		c.updateSRIOV(ch, card)
+		c.updateAER(ch, card)
+		c.updatePowerState(ch, card)
+
+		for sensor, celsius := range readCardTemperatures(card.path) {
+			ch <- prometheus.MustNewConstMetric(c.temperature, prometheus.GaugeValue, celsius, card.address, sensor)
+		}
+	}
+
+	backendCh, finishFabric := c.fabric.observe(ch)
+	for _, backend := range c.backends {
+		if backendCards := backend.enumerate(cards); len(backendCards) > 0 {
+			backend.telemetry(backendCh, backendCards)
+		}
+	}
+	finishFabric(time.Now())
+
+	for t, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.cards, prometheus.GaugeValue, float64(count), t.vendor, t.model, t.resource)
+	}
+	c.presence.update(ch, cards)
+	updateAcceleratorSoftware(ch, cards)
+
+	return nil
+}
+
+// acceleratorCards returns the accelerators found in /sys/bus/pci/devices.
+func (c *acceleratorsCollector) acceleratorCards() ([]acceleratorCard, error) {
+	devicesPath := sysFilePath("bus/pci/devices")
+	devices, err := os.ReadDir(devicesPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			level.Debug(c.logger).Log("msg", "PCI devices not found, skipping", "path", devicesPath)
+			return nil, ErrNoData
+		}
+		return nil, fmt.Errorf("failed to list PCI devices: %w", err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var cards []acceleratorCard
+	for _, device := range devices {
+		address := device.Name()
+		if c.deviceFilter.ignored(address) {
+			continue
+		}
+		devicePath := filepath.Join(devicesPath, address)
+
+		vendorID, err := readPCIID(filepath.Join(devicePath, "vendor"))
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read PCI vendor", "device", address, "err", err)
+			continue
+		}
+		deviceID, err := readPCIID(filepath.Join(devicePath, "device"))
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read PCI device", "device", address, "err", err)
+			continue
+		}
+
+		vendor, model, ok := c.acceleratorModel(vendorID, deviceID)
+		if !ok {
+			if c.pciIDs == nil && !c.detectByClass {
+				continue
+			}
+			class, err := readPCIID(filepath.Join(devicePath, "class"))
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "failed to read PCI class", "device", address, "err", err)
+				continue
+			}
+			vendor, model, ok = c.identifyByClass(vendorID, deviceID, class)
+			if !ok {
+				continue
+			}
+		}
+
+		if c.vendorFilter.ignored(vendor) {
+			level.Debug(c.logger).Log("msg", "ignoring accelerator of excluded vendor", "device", address, "vendor", vendor)
+			continue
+		}
+
+		cards = append(cards, acceleratorCard{
+			address:  address,
+			path:     devicePath,
+			vendorID: vendorID,
+			vendor:   vendor,
+			model:    model,
+			resource: acceleratorResource(c.resourceMap, vendorID, deviceID),
+		})
+	}
+
+	return cards, nil
+}
+
+// updatePCIeLink exposes the current and maximum PCIe link speed and width of
+// a card. Attributes missing from sysfs, e.g. for virtual functions, are
+// skipped.
+func (c *acceleratorsCollector) updatePCIeLink(ch chan<- prometheus.Metric, card acceleratorCard) {
+	for _, kind := range []string{"current", "max"} {
+		if data, err := os.ReadFile(filepath.Join(card.path, kind+"_link_speed")); err == nil {
+			if speed, err := parsePCIeLinkSpeed(string(data)); err == nil {
+				ch <- prometheus.MustNewConstMetric(c.pcieLinkSpeed, prometheus.GaugeValue, speed, card.address, kind)
+			} else {
+				level.Debug(c.logger).Log("msg", "failed to parse PCIe link speed", "device", card.address, "err", err)
+			}
+		}
+		if width, err := readUintFromFile(filepath.Join(card.path, kind+"_link_width")); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.pcieLinkWidth, prometheus.GaugeValue, float64(width), card.address, kind)
+		}
+	}
+}
+
+// updateAER exposes the PCIe Advanced Error Reporting counters of a card.
+// Cards or kernels without AER support are skipped.
+func (c *acceleratorsCollector) updateAER(ch chan<- prometheus.Metric, card acceleratorCard) {
+	for _, severity := range []string{"correctable", "fatal", "nonfatal"} {
+		f, err := os.Open(filepath.Join(card.path, "aer_dev_"+severity))
+		if err != nil {
+			continue
+		}
+		counters, err := parseAERCounters(f)
+		f.Close()
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to parse AER counters", "device", card.address, "severity", severity, "err", err)
+			continue
+		}
+		for errorType, count := range counters {
+			ch <- prometheus.MustNewConstMetric(c.pcieErrors, prometheus.CounterValue, count, card.address, errorType, severity)
+		}
+	}
+}
+
+// updatePowerState exposes the PCI power state (D0 to D3cold) and the runtime
+// power management status of a card as state sets. Attributes missing from
+// older kernels are skipped.
+func (c *acceleratorsCollector) updatePowerState(ch chan<- prometheus.Metric, card acceleratorCard) {
+	for _, attr := range []struct {
+		file   string
+		desc   *prometheus.Desc
+		states []string
+	}{
+		{"power_state", c.powerState, pciPowerStates},
+		{"power/runtime_status", c.runtimeStatus, pciRuntimeStatuses},
+	} {
+		data, err := os.ReadFile(filepath.Join(card.path, attr.file))
+		if err != nil {
+			continue
+		}
+		current := strings.TrimSpace(string(data))
+		known := false
+		for _, state := range attr.states {
+			value := 0.0
+			if state == current {
+				value = 1
+				known = true
+			}
+			ch <- prometheus.MustNewConstMetric(attr.desc, prometheus.GaugeValue, value, card.address, state)
+		}
+		if !known {
+			ch <- prometheus.MustNewConstMetric(attr.desc, prometheus.GaugeValue, 1, card.address, current)
+		}
+	}
+}
+
+// updateSRIOV exposes the SR-IOV virtual functions of a physical function.
+// Cards without SR-IOV support are skipped.
+func (c *acceleratorsCollector) updateSRIOV(ch chan<- prometheus.Metric, card acceleratorCard) {
+	totalVFs, err := readUintFromFile(filepath.Join(card.path, "sriov_totalvfs"))
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.sriovMaxVFs, prometheus.GaugeValue, float64(totalVFs), card.address)
+
+	numVFs, err := readUintFromFile(filepath.Join(card.path, "sriov_numvfs"))
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "failed to read number of SR-IOV virtual functions", "device", card.address, "err", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.sriovNumVFs, prometheus.GaugeValue, float64(numVFs), card.address)
+
+	// Each virtual function is linked from the physical function as virtfn<index>.
+	links, err := filepath.Glob(filepath.Join(card.path, "virtfn*"))
+	if err != nil {
+		return
+	}
+	for _, link := range links {
+		target, err := os.Readlink(link)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to resolve SR-IOV virtual function", "link", link, "err", err)
+			continue
+		}
+		index := strings.TrimPrefix(filepath.Base(link), "virtfn")
+		ch <- prometheus.MustNewConstMetric(c.vfInfo, prometheus.GaugeValue, 1, filepath.Base(target), card.address, index)
+	}
+}
+
+// readCardTemperatures returns the temperatures in degrees Celsius reported by
+// all hwmon devices of a card, such as the amdgpu or habanalabs sensors,
+// keyed by sensor label. Drivers without hwmon support, such as the NVIDIA
+// one, have none.
+func readCardTemperatures(path string) map[string]float64 {
+	temps := map[string]float64{}
+	hwmons, err := filepath.Glob(filepath.Join(path, "hwmon", "hwmon*"))
+	if err != nil {
+		return temps
+	}
+	sort.Strings(hwmons)
+	for _, hwmon := range hwmons {
+		for sensor, celsius := range readAcceleratorTemperatures(hwmon) {
+			// Keep the first of duplicate labels.
+			if _, ok := temps[sensor]; !ok {
+				temps[sensor] = celsius
+			}
+		}
+	}
+	return temps
+}
+
+// readAcceleratorTemperatures returns the temperatures in degrees Celsius
+// reported by the hwmon device of a card, keyed by sensor label.
+func readAcceleratorTemperatures(hwmon string) map[string]float64 {
+	temps := map[string]float64{}
+
+	inputs, err := filepath.Glob(filepath.Join(hwmon, "temp*_input"))
+	if err != nil {
+		return temps
+	}
+	for _, input := range inputs {
+		raw, err := sysReadFile(input)
+		if err != nil {
+			continue
+		}
+		milliCelsius, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			continue
+		}
+		sensor := strings.TrimSuffix(filepath.Base(input), "_input")
+		if label, err := sysReadFile(strings.TrimSuffix(input, "_input") + "_label"); err == nil {
+			sensor = strings.TrimSpace(string(label))
+		}
+		temps[sensor] = float64(milliCelsius) / 1000
+	}
+	return temps
+}
+
+// parsePCIeLinkSpeed parses a link speed such as "16.0 GT/s PCIe" into GT/s.
+func parsePCIeLinkSpeed(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || fields[1] != "GT/s" {
+		return 0, fmt.Errorf("unexpected link speed %q", strings.TrimSpace(s))
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parseAERCounters parses an aer_dev_* file of a PCI device, e.g.
+//
+//	RxErr 0
+//	BadTLP 2
+//	TOTAL_ERR_COR 2
+//
+// The totals are left out as they can be computed from the counters.
+func parseAERCounters(r io.Reader) (map[string]float64, error) {
+	counters := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected AER counter line %q", scanner.Text())
+		}
+		if strings.HasPrefix(fields[0], "TOTAL_ERR_") {
+			continue
+		}
+		count, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AER counter %q: %w", fields[0], err)
+		}
+		counters[fields[0]] = count
+	}
+	return counters, scanner.Err()
+}
+
+// identifyByClass returns the vendor and model labels of a device missing from
+// the built-in device list. 3D controllers and processing accelerators are
+// looked up in the pci.ids database if configured. With --collector.accelerators.detect-by-class, 3D
+// controllers and processing accelerators are reported even when the lookup
+// fails, using the raw device ID as model.
+func (c *acceleratorsCollector) identifyByClass(vendorID, deviceID, class string) (string, string, bool) {
+	if c.pciIDs != nil && isComputeAcceleratorClass(class) {
+		if vendor, model, ok := c.pciIDs.lookup(vendorID, deviceID); ok {
+			if known, ok := acceleratorVendors[vendorID]; ok {
+				vendor = known
+			}
+			return vendor, model, true
+		}
+	}
+
+	if !c.detectByClass || !isComputeAcceleratorClass(class) {
+		return "", "", false
+	}
+
+	return c.vendorName(vendorID), "0x" + deviceID, true
+}
+
+// acceleratorModel returns the vendor and model labels of a device from the
+// device map or the built-in device list.
+func (c *acceleratorsCollector) acceleratorModel(vendorID, deviceID string) (string, string, bool) {
+	model, ok := c.deviceMap[vendorID+":"+deviceID]
+	if !ok {
+		model, ok = acceleratorModels[vendorID+":"+deviceID]
+	}
+	if !ok {
+		return "", "", false
+	}
+	return c.vendorName(vendorID), model, true
+}
+
+// vendorName returns the vendor label of a vendor ID.
+func (c *acceleratorsCollector) vendorName(vendorID string) string {
+	if vendor, ok := acceleratorVendors[vendorID]; ok {
+		return vendor
+	}
+	if c.pciIDs != nil {
+		if vendor, ok := c.pciIDs.vendors[vendorID]; ok {
+			return vendor
+		}
+	}
+	return "0x" + vendorID
+}
+
+// reload re-reads the device map, the resource map and the pci.ids database.
+// The previous data is kept if any fails to load.
+func (c *acceleratorsCollector) reload() error {
+	var (
+		ids         *pciIDs
+		deviceMap   map[string]string
+		resourceMap map[string]string
+		err         error
+	)
+	if c.pciIDsPath != "" {
+		if ids, err = loadPCIIDs(c.pciIDsPath); err != nil {
+			return fmt.Errorf("failed to load pci.ids database: %w", err)
+		}
+	}
+	if c.deviceMapPath != "" {
+		if deviceMap, err = loadAcceleratorDeviceMap(c.deviceMapPath); err != nil {
+			return err
+		}
+	}
+	if c.resourceMapPath != "" {
+		if resourceMap, err = loadAcceleratorResourceMap(c.resourceMapPath); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pciIDs = ids
+	c.deviceMap = deviceMap
+	c.resourceMap = resourceMap
+	level.Info(c.logger).Log("msg", "reloaded accelerator device map", "models", len(deviceMap), "resources", len(resourceMap))
+	return nil
+}
+
+// acceleratorDeviceMap is the format of --collector.accelerators.device-map:
+//
+//	models:
+//	  "10de:2335": H200-SXM-141GB
+type acceleratorDeviceMap struct {
+	Models map[string]string `yaml:"models"`
+}
+
+func loadAcceleratorDeviceMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accelerator device map: %w", err)
+	}
+
+	var m acceleratorDeviceMap
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse accelerator device map: %w", err)
+	}
+
+	models := make(map[string]string, len(m.Models))
+	for id, model := range m.Models {
+		vendorID, deviceID, ok := strings.Cut(strings.ToLower(id), ":")
+		if !ok || !pciIDRE.MatchString(vendorID) || !pciIDRE.MatchString(deviceID) {
+			return nil, fmt.Errorf("invalid PCI ID %q in accelerator device map, expected <vendor>:<device>", id)
+		}
+		if model == "" {
+			return nil, fmt.Errorf("empty model name for %q in accelerator device map", id)
+		}
+		models[vendorID+":"+deviceID] = model
+	}
+	return models, nil
+}
@@ -0,0 +1,627 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// TestAcceleratorFixtures checks that the PCI devices of the end-to-end test
+// fixtures cover every vendor of the built-in device list, so that changes to
+// the device list or the metrics of a vendor show in the e2e output.
+func TestAcceleratorFixtures(t *testing.T) {
+	defer func(path string) { *sysPath = path }(*sysPath)
+	*sysPath = "fixtures/sys"
+
+	c, err := NewAcceleratorsCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards, err := c.(*acceleratorsCollector).acceleratorCards()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]string{}
+	for _, card := range cards {
+		found[card.vendorID] = card.address
+	}
+	for vendorID, vendor := range acceleratorVendors {
+		if _, ok := found[vendorID]; !ok {
+			t.Errorf("no %s card in fixtures/sys/bus/pci/devices", vendor)
+		}
+	}
+
+	// A device of a known vendor missing from the device list, a device of
+	// an unknown vendor, and a device without readable device ID.
+	for _, address := range []string{"0000:00:1f.0", "0000:c1:00.0", "0000:d1:00.0"} {
+		for _, card := range cards {
+			if card.address == address {
+				t.Errorf("%s reported as %s %s", address, card.vendor, card.model)
+			}
+		}
+	}
+}
+
+func TestParsePCIeLinkSpeed(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		want  float64
+		error bool
+	}{
+		{in: "16.0 GT/s PCIe\n", want: 16},
+		{in: "2.5 GT/s", want: 2.5},
+		{in: "Unknown", error: true},
+		{in: "8 GT", error: true},
+	} {
+		got, err := parsePCIeLinkSpeed(tc.in)
+		if tc.error {
+			if err == nil {
+				t.Errorf("parsePCIeLinkSpeed(%q): expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePCIeLinkSpeed(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parsePCIeLinkSpeed(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseDRMFdinfo(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		fdinfo string
+		key    drmClientKey
+		busyNs map[string]float64
+		cycles map[string]float64
+	}{
+		{
+			name: "i915",
+			fdinfo: `pos:	0
+flags:	02100002
+drm-driver:	i915
+drm-pdev:	0000:4d:00.0
+drm-client-id:	42
+drm-engine-render:	2500000 ns
+drm-engine-copy:	0 ns
+drm-engine-video:	1000 ns
+drm-engine-capacity-video:	2
+`,
+			key:    drmClientKey{pdev: "0000:4d:00.0", id: "42"},
+			busyNs: map[string]float64{"render": 2500000, "copy": 0, "video": 1000},
+			cycles: map[string]float64{},
+		},
+		{
+			name: "xe",
+			fdinfo: `drm-driver:	xe
+drm-pdev:	0000:03:00.0
+drm-client-id:	7
+drm-cycles-rcs:	1200
+drm-total-cycles-rcs:	99999
+drm-cycles-ccs:	300
+`,
+			key:    drmClientKey{pdev: "0000:03:00.0", id: "7"},
+			busyNs: map[string]float64{},
+			cycles: map[string]float64{"render": 1200, "compute": 300},
+		},
+		{
+			name: "other driver",
+			fdinfo: `drm-driver:	amdgpu
+drm-pdev:	0000:05:00.0
+drm-client-id:	1
+drm-engine-gfx:	100 ns
+`,
+			busyNs: map[string]float64{"gfx": 100},
+			cycles: map[string]float64{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fdinfo")
+			if err := os.WriteFile(path, []byte(tc.fdinfo), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			key, stats, err := parseDRMFdinfo(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != tc.key {
+				t.Errorf("key = %+v, want %+v", key, tc.key)
+			}
+			if !reflect.DeepEqual(stats.busyNs, tc.busyNs) {
+				t.Errorf("busyNs = %v, want %v", stats.busyNs, tc.busyNs)
+			}
+			if !reflect.DeepEqual(stats.cycles, tc.cycles) {
+				t.Errorf("cycles = %v, want %v", stats.cycles, tc.cycles)
+			}
+		})
+	}
+}
+
+func TestReadMIGInstances(t *testing.T) {
+	migDir := filepath.Join(t.TempDir(), "mig")
+	for _, dir := range []string{"gi1/ci0", "gi2/ci0", "gi2/ci1"} {
+		if err := os.MkdirAll(filepath.Join(migDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(migDir, dir, "access"), []byte("DeviceFileMinor: 84\nDeviceFileMode: 292\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// GPU instances without a compute instance are not usable.
+	if err := os.MkdirAll(filepath.Join(migDir, "gi3"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readMIGInstances(migDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []migInstance{{"1", "0"}, {"2", "0"}, {"2", "1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readMIGInstances() = %v, want %v", got, want)
+	}
+
+	got, err = readMIGInstances(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("readMIGInstances() on a GPU without MIG = %v, %v, want no instances", got, err)
+	}
+}
+
+func TestParseAERCounters(t *testing.T) {
+	got, err := parseAERCounters(strings.NewReader(`RxErr 0
+BadTLP 3
+BadDLLP 1
+Rollover 0
+Timeout 0
+NonFatalErr 0
+CorrIntErr 0
+HeaderOF 0
+TOTAL_ERR_COR 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"RxErr": 0, "BadTLP": 3, "BadDLLP": 1, "Rollover": 0, "Timeout": 0,
+		"NonFatalErr": 0, "CorrIntErr": 0, "HeaderOF": 0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAERCounters() = %v, want %v", got, want)
+	}
+
+	if _, err := parseAERCounters(strings.NewReader("BadTLP three\n")); err == nil {
+		t.Error("parseAERCounters() with an invalid counter: expected error")
+	}
+}
+
+func TestParseAMDPCIeBandwidth(t *testing.T) {
+	rx, tx, err := parseAMDPCIeBandwidth("1000 500 256\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rx != 256000 || tx != 128000 {
+		t.Errorf("parseAMDPCIeBandwidth() = %v, %v, want 256000, 128000", rx, tx)
+	}
+
+	for _, invalid := range []string{"1000 500", "1000 500 x"} {
+		if _, _, err := parseAMDPCIeBandwidth(invalid); err == nil {
+			t.Errorf("parseAMDPCIeBandwidth(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestParseAMDCurrentClock(t *testing.T) {
+	got, err := parseAMDCurrentClock("0: 500Mhz\n1: 1200Mhz *\n2: 1800Mhz\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1200 {
+		t.Errorf("parseAMDCurrentClock() = %v, want 1200", got)
+	}
+
+	if _, err := parseAMDCurrentClock("0: 500Mhz\n1: 1200Mhz\n"); err == nil {
+		t.Error("parseAMDCurrentClock() without current level: expected error")
+	}
+}
+
+func TestKFDProperties(t *testing.T) {
+	props, err := parseKFDProperties(strings.NewReader(`cpu_cores_count 0
+simd_count 304
+num_xcc 8
+location_id 49408
+domain 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props["num_xcc"] != 8 {
+		t.Errorf("num_xcc = %d, want 8", props["num_xcc"])
+	}
+	if got, want := kfdPCIAddress(props), "0000:c1:00.0"; got != want {
+		t.Errorf("kfdPCIAddress() = %s, want %s", got, want)
+	}
+}
+
+func TestLoadAcceleratorDeviceMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.yml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("models:\n  \"10DE:2335\": H200-SXM-141GB\n  \"1002:74b5\": Instinct MI300X VF\n")
+	got, err := loadAcceleratorDeviceMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"10de:2335": "H200-SXM-141GB", "1002:74b5": "Instinct MI300X VF"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadAcceleratorDeviceMap() = %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{
+		"models:\n  \"10de\": H200\n",
+		"models:\n  \"10de:23\": H200\n",
+		"models:\n  \"10de:2335\": \"\"\n",
+		"devices: {}\n",
+	} {
+		write(invalid)
+		if _, err := loadAcceleratorDeviceMap(path); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestLoadAcceleratorResourceMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resources.yml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("resources:\n  \"10DE\": nvidia.com/gpu\n  \"10de:2330\": nvidia.com/h100\n  \"1da3\": habana.ai/gaudi\n")
+	resources, err := loadAcceleratorResourceMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		vendorID, deviceID, want string
+	}{
+		{"10de", "2330", "nvidia.com/h100"},
+		{"10de", "20b2", "nvidia.com/gpu"},
+		{"1da3", "1020", "habana.ai/gaudi"},
+		{"1002", "74a1", ""},
+	} {
+		if got := acceleratorResource(resources, test.vendorID, test.deviceID); got != test.want {
+			t.Errorf("acceleratorResource(%s:%s) = %q, want %q", test.vendorID, test.deviceID, got, test.want)
+		}
+	}
+
+	for _, invalid := range []string{
+		"resources:\n  \"10d\": nvidia.com/gpu\n",
+		"resources:\n  \"10de:23\": nvidia.com/gpu\n",
+		"resources:\n  \"10de\": \"\"\n",
+		"models: {}\n",
+	} {
+		write(invalid)
+		if _, err := loadAcceleratorResourceMap(path); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestAcceleratorPresence(t *testing.T) {
+	dir := t.TempDir()
+	cards := []acceleratorCard{
+		{address: "0000:3b:00.0", path: filepath.Join(dir, "0000:3b:00.0"), vendor: "nvidia", model: "H100"},
+		{address: "0000:5e:00.0", path: filepath.Join(dir, "0000:5e:00.0"), vendor: "nvidia", model: "H100"},
+	}
+	if err := os.Mkdir(cards[0].path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newAcceleratorPresence()
+	collect := func(cards []acceleratorCard) map[string][2]float64 {
+		ch := make(chan prometheus.Metric, 16)
+		p.update(ch, cards)
+		close(ch)
+
+		got := map[string][2]float64{}
+		for m := range ch {
+			var pb dto.Metric
+			if err := m.Write(&pb); err != nil {
+				t.Fatal(err)
+			}
+			var address string
+			for _, l := range pb.Label {
+				if l.GetName() == "pci_address" {
+					address = l.GetValue()
+				}
+			}
+			v := got[address]
+			if pb.Gauge != nil {
+				v[0] = pb.Gauge.GetValue()
+			} else {
+				v[1] = pb.Counter.GetValue()
+			}
+			got[address] = v
+		}
+		return got
+	}
+
+	for i, tc := range []struct {
+		cards []acceleratorCard
+		want  map[string][2]float64
+	}{
+		{cards, map[string][2]float64{"0000:3b:00.0": {1, 0}, "0000:5e:00.0": {1, 0}}},
+		// 0000:3b:00.0 is filtered out while its device still exists,
+		// 0000:5e:00.0 fell off the bus.
+		{nil, map[string][2]float64{"0000:5e:00.0": {0, 1}}},
+		{nil, map[string][2]float64{"0000:5e:00.0": {0, 1}}},
+		{cards[1:], map[string][2]float64{"0000:5e:00.0": {1, 1}}},
+	} {
+		if got := collect(tc.cards); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("scrape %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestAcceleratorFirmwareVersions(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"amd/vbios_version":             "113-D67301-063\n",
+		"amd/fw_version/smc_fw_version": "0x00556800\n",
+		"amd/fw_version/sos_fw_version": "0x00360012\n",
+		"habana/cpucp_ver":              "1.13.0-fw-48.0.1-sec-7\n",
+		"habana/uboot_ver":              "U-Boot 2021.04-hl-gaudi2-1.13.0-fw-48.0.1-sec-7\n",
+		"habana/infineon_ver":           "\n",
+		"nvidia/information":            "Model: \t\t NVIDIA H100 80GB HBM3\nVideo BIOS: \t 96.00.74.00.01\nGPU Firmware: \t N/A\n",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	amd := acceleratorCard{path: filepath.Join(dir, "amd")}
+	if got, want := amdFirmwareVersions(amd), map[string]string{"vbios": "113-D67301-063", "smc": "0x00556800", "sos": "0x00360012"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AMD: got %v, want %v", got, want)
+	}
+	habana := acceleratorCard{path: filepath.Join(dir, "habana")}
+	if got, want := habanaFirmwareVersions(habana), map[string]string{"cpucp": "1.13.0-fw-48.0.1-sec-7", "uboot": "U-Boot 2021.04-hl-gaudi2-1.13.0-fw-48.0.1-sec-7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Habana: got %v, want %v", got, want)
+	}
+
+	info, err := readNVIDIAGPUInformation(filepath.Join(dir, "nvidia/information"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nvidiaFirmwareVersions(info), map[string]string{"vbios": "96.00.74.00.01"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NVIDIA: got %v, want %v", got, want)
+	}
+}
+
+func TestReadToolkitVersions(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"cuda-12/version.json": `{"cuda": {"name": "CUDA SDK", "version": "12.2.2"}, "cuda_cudart": {"version": "12.2.140"}}`,
+		"cuda-10/version.txt":  "CUDA Version 10.2.89\n",
+		"rocm/.info/version":   "6.0.2-115\n",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, want := range map[string]string{"cuda-12": "12.2.2", "cuda-10": "10.2.89"} {
+		if got, err := readCUDAVersion(filepath.Join(dir, path)); err != nil || got != want {
+			t.Errorf("%s: got %q (%v), want %q", path, got, err, want)
+		}
+	}
+	if _, err := readCUDAVersion(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing CUDA installation")
+	}
+	if got, err := readROCmVersion(filepath.Join(dir, "rocm")); err != nil || got != "6.0.2-115" {
+		t.Errorf("ROCm: got %q (%v), want %q", got, err, "6.0.2-115")
+	}
+}
+
+func TestReadKFDXGMILinks(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		// CPU node.
+		"0/properties":             "cpu_cores_count 64\nlocation_id 0\ndomain 0\n",
+		"0/io_links/0/properties":  "type 2\nnode_from 0\nnode_to 1\n",
+		"1/properties":             "simd_count 304\nlocation_id 3072\ndomain 0\n",
+		"1/io_links/0/properties":  "type 2\nnode_from 1\nnode_to 0\n",
+		"1/io_links/1/properties":  "type 11\nnode_from 1\nnode_to 2\nmax_bandwidth 64000\n",
+		"1/io_links/10/properties": "type 11\nnode_from 1\nnode_to 3\nmax_bandwidth 64000\n",
+		"2/properties":             "simd_count 304\nlocation_id 8192\ndomain 0\n",
+		"2/io_links/0/properties":  "type 11\nnode_from 2\nnode_to 1\nmax_bandwidth 64000\n",
+		"3/properties":             "simd_count 304\nlocation_id 12288\ndomain 1\n",
+		"3/io_links/0/properties":  "type 11\nnode_from 3\nnode_to 1\nmax_bandwidth 64000\n",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links, err := readKFDXGMILinks(dir, "0000:0c:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []acceleratorLink{
+		{link: "0", peer: "0000:20:00.0", up: true, speedBytesPerSecond: 64e9},
+		{link: "1", peer: "0001:30:00.0", up: true, speedBytesPerSecond: 64e9},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("got %+v, want %+v", links, want)
+	}
+}
+
+func TestNVMLBusIDToPCIAddress(t *testing.T) {
+	for in, want := range map[string]string{
+		"00000000:3B:00.0": "0000:3b:00.0",
+		"0001:c1:00.0":     "0001:c1:00.0",
+		"":                 "",
+	} {
+		if got := nvmlBusIDToPCIAddress(in); got != want {
+			t.Errorf("nvmlBusIDToPCIAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadCardTemperatures(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"hwmon/hwmon3/name":        "amdgpu\n",
+		"hwmon/hwmon3/temp1_input": "35000\n",
+		"hwmon/hwmon3/temp1_label": "edge\n",
+		"hwmon/hwmon3/temp2_input": "41500\n",
+		"hwmon/hwmon3/temp2_label": "junction\n",
+		"hwmon/hwmon3/temp3_input": "38000\n",
+		"hwmon/hwmon4/temp1_input": "50000\n",
+		"hwmon/hwmon4/temp1_label": "edge\n",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]float64{"edge": 35, "junction": 41.5, "temp3": 38}
+	if got := readCardTemperatures(dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := readCardTemperatures(t.TempDir()); len(got) != 0 {
+		t.Errorf("got %v for a card without hwmon, want none", got)
+	}
+}
+
+func TestAcceleratorFabric(t *testing.T) {
+	defer func(window time.Duration) { *acceleratorsFabricErrorWindow = window }(*acceleratorsFabricErrorWindow)
+	*acceleratorsFabricErrorWindow = 5 * time.Minute
+
+	f := newAcceleratorFabric()
+	scrape := func(now time.Time, nvlinkCRC, xgmiErrors float64, up bool) map[string]float64 {
+		out := make(chan prometheus.Metric)
+		got := map[string]float64{}
+		done := make(chan struct{})
+		go func() {
+			for m := range out {
+				var pb dto.Metric
+				if err := m.Write(&pb); err != nil {
+					t.Error(err)
+				}
+				name := m.Desc().String()
+				for _, l := range pb.Label {
+					name += "," + l.GetValue()
+				}
+				got[name] = pb.Gauge.GetValue()
+			}
+			close(done)
+		}()
+
+		ch, finish := f.observe(out)
+		emitAcceleratorLinks(ch, "0000:3b:00.0", "nvlink", []acceleratorLink{
+			{link: "0", up: true, errors: map[string]float64{"crc": nvlinkCRC}},
+			{link: "1", up: up, errors: map[string]float64{"crc": 0}},
+		})
+		emitAcceleratorLinks(ch, "0000:1b:00.0", "xgmi", []acceleratorLink{{link: "0", up: true}})
+		ch <- prometheus.MustNewConstMetric(amdXGMIErrorsDesc, prometheus.CounterValue, xgmiErrors, "0000:1b:00.0")
+		finish(now)
+		close(out)
+		<-done
+
+		health := map[string]float64{}
+		for name, value := range got {
+			switch {
+			case strings.Contains(name, `"node_accelerator_fabric_health_score"`):
+				health["score"] = value
+			case strings.Contains(name, `"node_accelerator_fabric_degraded_links"`):
+				health["degraded,"+name[strings.LastIndex(name, ",")+1:]] = value
+			case strings.Contains(name, `"node_accelerator_fabric_links"`):
+				health["links,"+name[strings.LastIndex(name, ",")+1:]] = value
+			}
+		}
+		return health
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name                  string
+		at                    time.Duration
+		nvlinkCRC, xgmiErrors float64
+		up                    bool
+		want                  map[string]float64
+	}{
+		{
+			name: "first scrape",
+			up:   true, nvlinkCRC: 10, xgmiErrors: 3,
+			want: map[string]float64{"links,nvlink": 2, "degraded,nvlink": 0, "links,xgmi": 1, "degraded,xgmi": 0, "score": 1},
+		},
+		{
+			name: "link down and card errors",
+			at:   time.Minute, up: false, nvlinkCRC: 10, xgmiErrors: 4,
+			want: map[string]float64{"links,nvlink": 2, "degraded,nvlink": 1, "links,xgmi": 1, "degraded,xgmi": 1, "score": 1.0 / 3},
+		},
+		{
+			name: "link errors",
+			at:   2 * time.Minute, up: true, nvlinkCRC: 11, xgmiErrors: 4,
+			want: map[string]float64{"links,nvlink": 2, "degraded,nvlink": 1, "links,xgmi": 1, "degraded,xgmi": 1, "score": 1.0 / 3},
+		},
+		{
+			name: "errors out of window",
+			at:   10 * time.Minute, up: true, nvlinkCRC: 11, xgmiErrors: 4,
+			want: map[string]float64{"links,nvlink": 2, "degraded,nvlink": 0, "links,xgmi": 1, "degraded,xgmi": 0, "score": 1},
+		},
+	} {
+		got := scrape(now.Add(tc.at), tc.nvlinkCRC, tc.xgmiErrors, tc.up)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
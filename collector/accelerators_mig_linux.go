@@ -0,0 +1,153 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// migInstance is a MIG device, i.e. a compute instance within a GPU instance
+// of a partitioned NVIDIA GPU.
+type migInstance struct {
+	gpuInstance     string
+	computeInstance string
+}
+
+// nvidiaMIGMetrics exposes the MIG instances of NVIDIA GPUs. Instances are
+// enumerated from the nvidia-caps entries in
+// /proc/driver/nvidia/capabilities, which does not require NVML. Their
+// profiles are only known if NVML is loaded.
+type nvidiaMIGMetrics struct {
+	logger    log.Logger
+	nvml      bool
+	info      *prometheus.Desc
+	instances *prometheus.Desc
+}
+
+func newNVIDIAMIGMetrics(logger log.Logger) *nvidiaMIGMetrics {
+	return &nvidiaMIGMetrics{
+		logger: logger,
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "mig_instance_info"),
+			"MIG instance of a partitioned NVIDIA GPU, profile is empty if NVML is not loaded.",
+			[]string{"pci_address", "gpu_instance", "compute_instance", "profile"}, nil,
+		),
+		instances: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "mig_instances"),
+			"Number of MIG instances of an NVIDIA GPU, 0 if MIG is not in use.",
+			[]string{"pci_address"}, nil,
+		),
+	}
+}
+
+func (m *nvidiaMIGMetrics) update(ch chan<- prometheus.Metric, card acceleratorCard) {
+	minor, err := nvidiaDeviceMinor(procFilePath(filepath.Join("driver/nvidia/gpus", card.address, "information")))
+	if err != nil {
+		level.Debug(m.logger).Log("msg", "failed to read NVIDIA device minor, is the nvidia driver loaded?", "device", card.address, "err", err)
+		return
+	}
+
+	instances, err := readMIGInstances(procFilePath(filepath.Join("driver/nvidia/capabilities", "gpu"+minor, "mig")))
+	if err != nil {
+		level.Debug(m.logger).Log("msg", "failed to read MIG instances", "device", card.address, "err", err)
+		return
+	}
+
+	var profiles map[migInstance]string
+	if m.nvml && len(instances) > 0 {
+		profiles, err = nvmlMIGProfiles(card.address)
+		if err != nil {
+			level.Debug(m.logger).Log("msg", "failed to query MIG profiles from NVML", "device", card.address, "err", err)
+		}
+	}
+
+	for _, instance := range instances {
+		ch <- prometheus.MustNewConstMetric(m.info, prometheus.GaugeValue, 1,
+			card.address, instance.gpuInstance, instance.computeInstance, profiles[instance])
+	}
+	ch <- prometheus.MustNewConstMetric(m.instances, prometheus.GaugeValue, float64(len(instances)), card.address)
+}
+
+// nvidiaDeviceMinor returns the minor number of /dev/nvidia<N> from the
+// information file of a GPU in /proc/driver/nvidia/gpus.
+func nvidiaDeviceMinor(path string) (string, error) {
+	info, err := readNVIDIAGPUInformation(path)
+	if err != nil {
+		return "", err
+	}
+	minor, ok := info["Device Minor"]
+	if !ok {
+		return "", fmt.Errorf("no device minor found in %s", path)
+	}
+	return minor, nil
+}
+
+// readNVIDIAGPUInformation parses the "Name: value" lines of
+// /proc/driver/nvidia/gpus/<address>/information.
+func readNVIDIAGPUInformation(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			info[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return info, scanner.Err()
+}
+
+// readMIGInstances returns the MIG instances listed in the mig directory of
+// a GPU in /proc/driver/nvidia/capabilities, laid out as gi<N>/ci<M>/access.
+// GPUs without MIG enabled have no such directory and no instances.
+func readMIGInstances(migDir string) ([]migInstance, error) {
+	cis, err := filepath.Glob(filepath.Join(migDir, "gi*", "ci*", "access"))
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]migInstance, 0, len(cis))
+	for _, ci := range cis {
+		ciDir := filepath.Dir(ci)
+		instances = append(instances, migInstance{
+			gpuInstance:     strings.TrimPrefix(filepath.Base(filepath.Dir(ciDir)), "gi"),
+			computeInstance: strings.TrimPrefix(filepath.Base(ciDir), "ci"),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].gpuInstance != instances[j].gpuInstance {
+			return instances[i].gpuInstance < instances[j].gpuInstance
+		}
+		return instances[i].computeInstance < instances[j].computeInstance
+	})
+
+	return instances, nil
+}
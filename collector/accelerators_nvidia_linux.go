@@ -0,0 +1,175 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nvidiaAcceleratorMetrics is the backend of NVIDIA GPUs. MIG instances and
+// firmware versions are read from /proc/driver/nvidia, everything else is
+// queried from NVML if it is loaded.
+type nvidiaAcceleratorMetrics struct {
+	logger log.Logger
+	mig    *nvidiaMIGMetrics
+
+	nvml            bool
+	nvmlUtilization *prometheus.Desc
+	nvmlMemoryUsed  *prometheus.Desc
+	nvmlMemoryTotal *prometheus.Desc
+	nvmlMemoryUtil  *prometheus.Desc
+	nvmlPCIe        *prometheus.Desc
+	nvmlSMClock     *prometheus.Desc
+	nvmlTemperature *prometheus.Desc
+	nvmlECCErrors   *prometheus.Desc
+}
+
+// nvmlStats holds the NVML statistics of an NVIDIA GPU.
+type nvmlStats struct {
+	utilizationPercent float64
+	memoryUsedBytes    float64
+	memoryTotalBytes   float64
+	// memoryUtilizationPercent is the percent of time over the past sample
+	// period during which memory was being read or written.
+	memoryUtilizationPercent float64
+	pcieSupported            bool
+	pcieRxBytesPerSecond     float64
+	pcieTxBytesPerSecond     float64
+	smClockMHz               float64
+	graphicsClockMHz         float64
+	memoryClockMHz           float64
+	throttleReasonsSupported bool
+	throttleReasons          uint64
+	temperatureCelsius       float64
+	eccSupported             bool
+	eccCorrected             float64
+	eccUncorrected           float64
+}
+
+func newNVIDIAAcceleratorMetrics(logger log.Logger, nvml bool) *nvidiaAcceleratorMetrics {
+	mig := newNVIDIAMIGMetrics(logger)
+	mig.nvml = nvml
+	return &nvidiaAcceleratorMetrics{
+		logger: logger,
+		mig:    mig,
+		nvml:   nvml,
+		nvmlUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_utilization_percent"),
+			"Percent of time over the past sample period during which one or more kernels was executing on the GPU.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlMemoryUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_memory_used_bytes"),
+			"GPU memory allocated by active contexts in bytes.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlMemoryTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_memory_total_bytes"),
+			"Total GPU memory in bytes.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlMemoryUtil: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_memory_utilization_percent"),
+			"Percent of time over the past sample period during which GPU memory was being read or written.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlPCIe: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_pcie_throughput_bytes_per_second"),
+			"PCIe throughput of the GPU over the last 20ms in bytes per second.",
+			[]string{"pci_address", "direction"}, nil,
+		),
+		nvmlSMClock: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_sm_clock_hertz"),
+			"Current streaming multiprocessor clock in hertz.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlTemperature: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_temperature_celsius"),
+			"GPU die temperature in degrees Celsius.",
+			[]string{"pci_address"}, nil,
+		),
+		nvmlECCErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "nvml_ecc_errors_total"),
+			"Number of memory ECC errors over the lifetime of the GPU.",
+			[]string{"pci_address", "type"}, nil,
+		),
+	}
+}
+
+func (m *nvidiaAcceleratorMetrics) enumerate(cards []acceleratorCard) []acceleratorCard {
+	return vendorCards(cards, nvidiaVendorID)
+}
+
+func (m *nvidiaAcceleratorMetrics) telemetry(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	for _, card := range cards {
+		info, err := readNVIDIAGPUInformation(procFilePath(filepath.Join("driver/nvidia/gpus", card.address, "information")))
+		if err == nil {
+			emitAcceleratorFirmware(ch, card.address, nvidiaFirmwareVersions(info))
+		}
+
+		m.mig.update(ch, card)
+		if !m.nvml {
+			continue
+		}
+		m.updateNVML(ch, card)
+		links, err := nvmlNVLinks(card.address)
+		if err != nil {
+			level.Debug(m.logger).Log("msg", "failed to query NVLinks", "device", card.address, "err", err)
+			continue
+		}
+		emitAcceleratorLinks(ch, card.address, "nvlink", links)
+	}
+}
+
+func (m *nvidiaAcceleratorMetrics) updateNVML(ch chan<- prometheus.Metric, card acceleratorCard) {
+	stats, err := nvmlDeviceStats(card.address)
+	if err != nil {
+		level.Debug(m.logger).Log("msg", "failed to query NVML", "device", card.address, "err", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(m.nvmlUtilization, prometheus.GaugeValue, stats.utilizationPercent, card.address)
+	ch <- prometheus.MustNewConstMetric(m.nvmlMemoryUsed, prometheus.GaugeValue, stats.memoryUsedBytes, card.address)
+	ch <- prometheus.MustNewConstMetric(m.nvmlMemoryTotal, prometheus.GaugeValue, stats.memoryTotalBytes, card.address)
+	ch <- prometheus.MustNewConstMetric(m.nvmlMemoryUtil, prometheus.GaugeValue, stats.memoryUtilizationPercent, card.address)
+	if stats.pcieSupported {
+		ch <- prometheus.MustNewConstMetric(m.nvmlPCIe, prometheus.GaugeValue, stats.pcieRxBytesPerSecond, card.address, "rx")
+		ch <- prometheus.MustNewConstMetric(m.nvmlPCIe, prometheus.GaugeValue, stats.pcieTxBytesPerSecond, card.address, "tx")
+	}
+	ch <- prometheus.MustNewConstMetric(m.nvmlSMClock, prometheus.GaugeValue, stats.smClockMHz*1e6, card.address)
+	ch <- prometheus.MustNewConstMetric(acceleratorClockDesc, prometheus.GaugeValue, stats.smClockMHz*1e6, card.address, "sm")
+	ch <- prometheus.MustNewConstMetric(acceleratorClockDesc, prometheus.GaugeValue, stats.graphicsClockMHz*1e6, card.address, "graphics")
+	ch <- prometheus.MustNewConstMetric(acceleratorClockDesc, prometheus.GaugeValue, stats.memoryClockMHz*1e6, card.address, "memory")
+	if stats.throttleReasonsSupported {
+		for _, r := range nvmlThrottleReasons {
+			value := 0.0
+			if stats.throttleReasons&r.bit != 0 {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(acceleratorThrottleReasonDesc, prometheus.GaugeValue, value, card.address, r.reason)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(m.nvmlTemperature, prometheus.GaugeValue, stats.temperatureCelsius, card.address)
+	if stats.eccSupported {
+		ch <- prometheus.MustNewConstMetric(m.nvmlECCErrors, prometheus.CounterValue, stats.eccCorrected, card.address, "corrected")
+		ch <- prometheus.MustNewConstMetric(m.nvmlECCErrors, prometheus.CounterValue, stats.eccUncorrected, card.address, "uncorrected")
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators && cgo
+// +build !noaccelerators,cgo
+
+package collector
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+
+// Subset of the NVML API, see
+// https://docs.nvidia.com/deploy/nvml-api/group__nvmlDeviceQueries.html
+typedef void *nvmlDevice_t;
+typedef struct { unsigned int gpu; unsigned int memory; } nvmlUtilization_t;
+typedef struct { unsigned long long total; unsigned long long free; unsigned long long used; } nvmlMemory_t;
+typedef struct {
+	char busIdLegacy[16];
+	unsigned int domain;
+	unsigned int bus;
+	unsigned int device;
+	unsigned int pciDeviceId;
+	unsigned int pciSubSystemId;
+	char busId[32];
+} nvmlPciInfo_t;
+typedef struct {
+	unsigned int fieldId;
+	unsigned int scopeId;
+	long long timestamp;
+	long long latencyUsec;
+	int valueType;
+	int nvmlReturn;
+	union { double dVal; unsigned int uiVal; unsigned long ulVal; unsigned long long ullVal; long long sllVal; } value;
+} nvmlFieldValue_t;
+
+static int (*nvml_init)(void);
+static int (*nvml_device_by_pci_bus_id)(const char *, nvmlDevice_t *);
+static int (*nvml_device_utilization)(nvmlDevice_t, nvmlUtilization_t *);
+static int (*nvml_device_memory)(nvmlDevice_t, nvmlMemory_t *);
+static int (*nvml_device_clock)(nvmlDevice_t, int, unsigned int *);
+static int (*nvml_device_temperature)(nvmlDevice_t, int, unsigned int *);
+static int (*nvml_device_ecc_errors)(nvmlDevice_t, int, int, unsigned long long *);
+static int (*nvml_device_pcie_throughput)(nvmlDevice_t, int, unsigned int *);
+static int (*nvml_device_throttle_reasons)(nvmlDevice_t, unsigned long long *);
+
+// MIG functions are optional, they are missing from drivers older than R450.
+static int (*nvml_device_max_mig_devices)(nvmlDevice_t, unsigned int *);
+static int (*nvml_device_mig_device)(nvmlDevice_t, unsigned int, nvmlDevice_t *);
+static int (*nvml_device_gpu_instance_id)(nvmlDevice_t, unsigned int *);
+static int (*nvml_device_compute_instance_id)(nvmlDevice_t, unsigned int *);
+static int (*nvml_device_name)(nvmlDevice_t, char *, unsigned int);
+
+// NVLink functions are optional as well.
+static int (*nvml_device_field_values)(nvmlDevice_t, int, nvmlFieldValue_t *);
+static int (*nvml_device_nvlink_state)(nvmlDevice_t, unsigned int, int *);
+static int (*nvml_device_nvlink_remote_pci_info)(nvmlDevice_t, unsigned int, nvmlPciInfo_t *);
+static int (*nvml_device_nvlink_error_counter)(nvmlDevice_t, unsigned int, int, unsigned long long *);
+
+// nvml_load loads libnvidia-ml and initializes NVML. It returns -1 if the
+// library cannot be loaded, -2 if a symbol is missing and the NVML return
+// code of nvmlInit otherwise.
+static int nvml_load(const char *path) {
+	void *lib = dlopen(path, RTLD_NOW);
+	if (!lib) return -1;
+	nvml_init = dlsym(lib, "nvmlInit_v2");
+	nvml_device_by_pci_bus_id = dlsym(lib, "nvmlDeviceGetHandleByPciBusId_v2");
+	nvml_device_utilization = dlsym(lib, "nvmlDeviceGetUtilizationRates");
+	nvml_device_memory = dlsym(lib, "nvmlDeviceGetMemoryInfo");
+	nvml_device_clock = dlsym(lib, "nvmlDeviceGetClockInfo");
+	nvml_device_temperature = dlsym(lib, "nvmlDeviceGetTemperature");
+	nvml_device_ecc_errors = dlsym(lib, "nvmlDeviceGetTotalEccErrors");
+	nvml_device_pcie_throughput = dlsym(lib, "nvmlDeviceGetPcieThroughput");
+	nvml_device_throttle_reasons = dlsym(lib, "nvmlDeviceGetCurrentClocksThrottleReasons");
+	nvml_device_max_mig_devices = dlsym(lib, "nvmlDeviceGetMaxMigDeviceCount");
+	nvml_device_mig_device = dlsym(lib, "nvmlDeviceGetMigDeviceHandleByIndex");
+	nvml_device_gpu_instance_id = dlsym(lib, "nvmlDeviceGetGpuInstanceId");
+	nvml_device_compute_instance_id = dlsym(lib, "nvmlDeviceGetComputeInstanceId");
+	nvml_device_name = dlsym(lib, "nvmlDeviceGetName");
+	nvml_device_field_values = dlsym(lib, "nvmlDeviceGetFieldValues");
+	nvml_device_nvlink_state = dlsym(lib, "nvmlDeviceGetNvLinkState");
+	nvml_device_nvlink_remote_pci_info = dlsym(lib, "nvmlDeviceGetNvLinkRemotePciInfo_v2");
+	nvml_device_nvlink_error_counter = dlsym(lib, "nvmlDeviceGetNvLinkErrorCounter");
+	if (!nvml_init || !nvml_device_by_pci_bus_id || !nvml_device_utilization || !nvml_device_memory ||
+	    !nvml_device_clock || !nvml_device_temperature || !nvml_device_ecc_errors || !nvml_device_pcie_throughput ||
+	    !nvml_device_throttle_reasons) {
+		dlclose(lib);
+		return -2;
+	}
+	return nvml_init();
+}
+
+static int nvml_get_device(const char *bus_id, nvmlDevice_t *dev) { return nvml_device_by_pci_bus_id(bus_id, dev); }
+static int nvml_get_utilization(nvmlDevice_t dev, nvmlUtilization_t *u) { return nvml_device_utilization(dev, u); }
+static int nvml_get_memory(nvmlDevice_t dev, nvmlMemory_t *m) { return nvml_device_memory(dev, m); }
+static int nvml_get_clock(nvmlDevice_t dev, int type, unsigned int *c) { return nvml_device_clock(dev, type, c); }
+static int nvml_get_temperature(nvmlDevice_t dev, int sensor, unsigned int *t) { return nvml_device_temperature(dev, sensor, t); }
+static int nvml_get_ecc_errors(nvmlDevice_t dev, int type, int counter, unsigned long long *e) { return nvml_device_ecc_errors(dev, type, counter, e); }
+static int nvml_get_throttle_reasons(nvmlDevice_t dev, unsigned long long *r) { return nvml_device_throttle_reasons(dev, r); }
+static int nvml_get_pcie_throughput(nvmlDevice_t dev, int counter, unsigned int *kb) { return nvml_device_pcie_throughput(dev, counter, kb); }
+
+static int nvml_has_mig(void) {
+	return nvml_device_max_mig_devices && nvml_device_mig_device && nvml_device_gpu_instance_id &&
+	       nvml_device_compute_instance_id && nvml_device_name;
+}
+static int nvml_get_max_mig_devices(nvmlDevice_t dev, unsigned int *n) { return nvml_device_max_mig_devices(dev, n); }
+static int nvml_get_mig_device(nvmlDevice_t dev, unsigned int i, nvmlDevice_t *mig) { return nvml_device_mig_device(dev, i, mig); }
+static int nvml_get_gpu_instance_id(nvmlDevice_t dev, unsigned int *id) { return nvml_device_gpu_instance_id(dev, id); }
+static int nvml_get_compute_instance_id(nvmlDevice_t dev, unsigned int *id) { return nvml_device_compute_instance_id(dev, id); }
+static int nvml_get_name(nvmlDevice_t dev, char *name, unsigned int len) { return nvml_device_name(dev, name, len); }
+
+static int nvml_has_nvlink(void) {
+	return nvml_device_field_values && nvml_device_nvlink_state && nvml_device_nvlink_remote_pci_info &&
+	       nvml_device_nvlink_error_counter;
+}
+// nvml_get_uint_field returns a field value of unsigned int type.
+static int nvml_get_uint_field(nvmlDevice_t dev, unsigned int id, unsigned int *v) {
+	nvmlFieldValue_t value = {0};
+	value.fieldId = id;
+	int ret = nvml_device_field_values(dev, 1, &value);
+	if (ret != 0) return ret;
+	if (value.nvmlReturn != 0) return value.nvmlReturn;
+	*v = value.value.uiVal;
+	return 0;
+}
+static int nvml_get_nvlink_state(nvmlDevice_t dev, unsigned int link, int *active) { return nvml_device_nvlink_state(dev, link, active); }
+static int nvml_get_nvlink_remote_pci_info(nvmlDevice_t dev, unsigned int link, nvmlPciInfo_t *pci) { return nvml_device_nvlink_remote_pci_info(dev, link, pci); }
+static int nvml_get_nvlink_error_counter(nvmlDevice_t dev, unsigned int link, int counter, unsigned long long *v) { return nvml_device_nvlink_error_counter(dev, link, counter, v); }
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	nvmlSuccess = 0
+
+	nvmlClockGraphics            = 0
+	nvmlClockSM                  = 1
+	nvmlClockMem                 = 2
+	nvmlTemperatureGPU           = 0
+	nvmlMemoryErrorCorrected     = 0
+	nvmlMemoryErrorUncorrected   = 1
+	nvmlAggregateECC             = 1
+	nvmlPCIeUtilTXBytes          = 0
+	nvmlPCIeUtilRXBytes          = 1
+	nvmlLoadErrorLibraryNotFound = -1
+	nvmlLoadErrorSymbolNotFound  = -2
+
+	nvmlDeviceNameBufferSize = 96
+
+	// Field IDs of nvmlDeviceGetFieldValues.
+	nvmlFieldNVLinkSpeedMBpsCommon = 90
+	nvmlFieldNVLinkLinkCount       = 91
+)
+
+// nvmlNVLinkErrorCounters are the nvmlNvLinkErrorCounter_t values.
+var nvmlNVLinkErrorCounters = []struct {
+	counter C.int
+	name    string
+}{
+	{0, "replay"},
+	{1, "recovery"},
+	{2, "crc_flit"},
+	{3, "crc_data"},
+	{4, "ecc_data"},
+}
+
+// loadNVML loads and initializes the NVML library at path.
+func loadNVML(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	switch ret := C.nvml_load(cPath); ret {
+	case nvmlSuccess:
+		return nil
+	case nvmlLoadErrorLibraryNotFound:
+		return fmt.Errorf("failed to load %s: %s", path, C.GoString(C.dlerror()))
+	case nvmlLoadErrorSymbolNotFound:
+		return fmt.Errorf("%s does not provide the required NVML functions", path)
+	default:
+		return fmt.Errorf("nvmlInit failed with return code %d", ret)
+	}
+}
+
+// nvmlDeviceStats queries NVML for the statistics of the GPU at the given PCI
+// address.
+func nvmlDeviceStats(address string) (nvmlStats, error) {
+	var stats nvmlStats
+
+	cAddress := C.CString(address)
+	defer C.free(unsafe.Pointer(cAddress))
+
+	var dev C.nvmlDevice_t
+	if ret := C.nvml_get_device(cAddress, &dev); ret != nvmlSuccess {
+		return stats, fmt.Errorf("nvmlDeviceGetHandleByPciBusId failed with return code %d", ret)
+	}
+
+	var utilization C.nvmlUtilization_t
+	if ret := C.nvml_get_utilization(dev, &utilization); ret != nvmlSuccess {
+		return stats, fmt.Errorf("nvmlDeviceGetUtilizationRates failed with return code %d", ret)
+	}
+	stats.utilizationPercent = float64(utilization.gpu)
+	stats.memoryUtilizationPercent = float64(utilization.memory)
+
+	var memory C.nvmlMemory_t
+	if ret := C.nvml_get_memory(dev, &memory); ret != nvmlSuccess {
+		return stats, fmt.Errorf("nvmlDeviceGetMemoryInfo failed with return code %d", ret)
+	}
+	stats.memoryUsedBytes = float64(memory.used)
+	stats.memoryTotalBytes = float64(memory.total)
+
+	// PCIe throughput is not supported by all GPUs and reported in KB/s.
+	var rx, tx C.uint
+	if C.nvml_get_pcie_throughput(dev, nvmlPCIeUtilRXBytes, &rx) == nvmlSuccess &&
+		C.nvml_get_pcie_throughput(dev, nvmlPCIeUtilTXBytes, &tx) == nvmlSuccess {
+		stats.pcieSupported = true
+		stats.pcieRxBytesPerSecond = float64(rx) * 1024
+		stats.pcieTxBytesPerSecond = float64(tx) * 1024
+	}
+
+	var clock C.uint
+	if ret := C.nvml_get_clock(dev, nvmlClockSM, &clock); ret != nvmlSuccess {
+		return stats, fmt.Errorf("nvmlDeviceGetClockInfo failed with return code %d", ret)
+	}
+	stats.smClockMHz = float64(clock)
+
+	if C.nvml_get_clock(dev, nvmlClockGraphics, &clock) == nvmlSuccess {
+		stats.graphicsClockMHz = float64(clock)
+	}
+	if C.nvml_get_clock(dev, nvmlClockMem, &clock) == nvmlSuccess {
+		stats.memoryClockMHz = float64(clock)
+	}
+
+	var reasons C.ulonglong
+	if C.nvml_get_throttle_reasons(dev, &reasons) == nvmlSuccess {
+		stats.throttleReasonsSupported = true
+		stats.throttleReasons = uint64(reasons)
+	}
+
+	var temperature C.uint
+	if ret := C.nvml_get_temperature(dev, nvmlTemperatureGPU, &temperature); ret != nvmlSuccess {
+		return stats, fmt.Errorf("nvmlDeviceGetTemperature failed with return code %d", ret)
+	}
+	stats.temperatureCelsius = float64(temperature)
+
+	// ECC is not supported by all GPUs.
+	var corrected, uncorrected C.ulonglong
+	if C.nvml_get_ecc_errors(dev, nvmlMemoryErrorCorrected, nvmlAggregateECC, &corrected) == nvmlSuccess &&
+		C.nvml_get_ecc_errors(dev, nvmlMemoryErrorUncorrected, nvmlAggregateECC, &uncorrected) == nvmlSuccess {
+		stats.eccSupported = true
+		stats.eccCorrected = float64(corrected)
+		stats.eccUncorrected = float64(uncorrected)
+	}
+
+	return stats, nil
+}
+
+// nvmlMIGProfiles returns the profiles, such as "1g.10gb", of the MIG
+// instances of the GPU at the given PCI address.
+func nvmlMIGProfiles(address string) (map[migInstance]string, error) {
+	if C.nvml_has_mig() == 0 {
+		return nil, errors.New("NVML library does not support MIG")
+	}
+
+	cAddress := C.CString(address)
+	defer C.free(unsafe.Pointer(cAddress))
+
+	var dev C.nvmlDevice_t
+	if ret := C.nvml_get_device(cAddress, &dev); ret != nvmlSuccess {
+		return nil, fmt.Errorf("nvmlDeviceGetHandleByPciBusId failed with return code %d", ret)
+	}
+
+	var count C.uint
+	if ret := C.nvml_get_max_mig_devices(dev, &count); ret != nvmlSuccess {
+		return nil, fmt.Errorf("nvmlDeviceGetMaxMigDeviceCount failed with return code %d", ret)
+	}
+
+	profiles := map[migInstance]string{}
+	for i := C.uint(0); i < count; i++ {
+		// Unused indices return NVML_ERROR_NOT_FOUND.
+		var mig C.nvmlDevice_t
+		if C.nvml_get_mig_device(dev, i, &mig) != nvmlSuccess {
+			continue
+		}
+		var gi, ci C.uint
+		if C.nvml_get_gpu_instance_id(mig, &gi) != nvmlSuccess || C.nvml_get_compute_instance_id(mig, &ci) != nvmlSuccess {
+			continue
+		}
+		var name [nvmlDeviceNameBufferSize]C.char
+		if C.nvml_get_name(mig, &name[0], nvmlDeviceNameBufferSize) != nvmlSuccess {
+			continue
+		}
+		// MIG devices are named after their parent, e.g. "NVIDIA A100-SXM4-40GB MIG 1g.5gb".
+		_, profile, _ := strings.Cut(C.GoString(&name[0]), " MIG ")
+		instance := migInstance{
+			gpuInstance:     strconv.FormatUint(uint64(gi), 10),
+			computeInstance: strconv.FormatUint(uint64(ci), 10),
+		}
+		profiles[instance] = profile
+	}
+
+	return profiles, nil
+}
+
+// nvmlNVLinks returns the NVLinks of the GPU at the given PCI address.
+func nvmlNVLinks(address string) ([]acceleratorLink, error) {
+	if C.nvml_has_nvlink() == 0 {
+		return nil, errors.New("NVML library does not support NVLink")
+	}
+
+	cAddress := C.CString(address)
+	defer C.free(unsafe.Pointer(cAddress))
+
+	var dev C.nvmlDevice_t
+	if ret := C.nvml_get_device(cAddress, &dev); ret != nvmlSuccess {
+		return nil, fmt.Errorf("nvmlDeviceGetHandleByPciBusId failed with return code %d", ret)
+	}
+
+	// GPUs without NVLink do not support the field.
+	var count C.uint
+	if C.nvml_get_uint_field(dev, nvmlFieldNVLinkLinkCount, &count) != nvmlSuccess {
+		return nil, nil
+	}
+	// All links of a GPU run at the same speed, in MB/s.
+	var speed C.uint
+	if C.nvml_get_uint_field(dev, nvmlFieldNVLinkSpeedMBpsCommon, &speed) != nvmlSuccess {
+		speed = 0
+	}
+
+	links := make([]acceleratorLink, 0, count)
+	for i := C.uint(0); i < count; i++ {
+		var active C.int
+		if C.nvml_get_nvlink_state(dev, i, &active) != nvmlSuccess {
+			continue
+		}
+		link := acceleratorLink{
+			link:   strconv.FormatUint(uint64(i), 10),
+			up:     active != 0,
+			errors: map[string]float64{},
+		}
+		if link.up {
+			link.speedBytesPerSecond = float64(speed) * 1e6
+		}
+		var pci C.nvmlPciInfo_t
+		if C.nvml_get_nvlink_remote_pci_info(dev, i, &pci) == nvmlSuccess {
+			link.peer = nvmlBusIDToPCIAddress(C.GoString(&pci.busId[0]))
+		}
+		for _, c := range nvmlNVLinkErrorCounters {
+			var value C.ulonglong
+			if C.nvml_get_nvlink_error_counter(dev, i, c.counter, &value) == nvmlSuccess {
+				link.errors[c.name] = float64(value)
+			}
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
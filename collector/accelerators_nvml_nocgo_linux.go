@@ -0,0 +1,37 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators && !cgo
+// +build !noaccelerators,!cgo
+
+package collector
+
+import "errors"
+
+var errNVMLRequiresCgo = errors.New("NVML support requires node_exporter to be built with cgo")
+
+func loadNVML(path string) error {
+	return errNVMLRequiresCgo
+}
+
+func nvmlDeviceStats(address string) (nvmlStats, error) {
+	return nvmlStats{}, errNVMLRequiresCgo
+}
+
+func nvmlMIGProfiles(address string) (map[migInstance]string, error) {
+	return nil, errNVMLRequiresCgo
+}
+
+func nvmlNVLinks(address string) ([]acceleratorLink, error) {
+	return nil, errNVMLRequiresCgo
+}
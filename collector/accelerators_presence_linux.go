@@ -0,0 +1,96 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// acceleratorPresence tracks the accelerator cards seen since node_exporter
+// started, so that cards falling off the PCI bus are reported as absent
+// instead of silently disappearing.
+type acceleratorPresence struct {
+	mtx      sync.Mutex
+	seen     map[string]acceleratorCard
+	present  map[string]bool
+	removals map[string]float64
+
+	presentDesc  *prometheus.Desc
+	removalsDesc *prometheus.Desc
+}
+
+func newAcceleratorPresence() *acceleratorPresence {
+	return &acceleratorPresence{
+		seen:     map[string]acceleratorCard{},
+		present:  map[string]bool{},
+		removals: map[string]float64{},
+		presentDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "card_present"),
+			"Whether an accelerator card seen since node_exporter started is still on the PCI bus.",
+			[]string{"pci_address", "vendor", "model"}, nil,
+		),
+		removalsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "card_removals_total"),
+			"Number of times an accelerator card disappeared from the PCI bus.",
+			[]string{"pci_address"}, nil,
+		),
+	}
+}
+
+// update exposes the presence of the given cards and of the cards seen in
+// earlier scrapes.
+func (p *acceleratorPresence) update(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	current := make(map[string]bool, len(cards))
+	for _, card := range cards {
+		current[card.address] = true
+		p.seen[card.address] = card
+		if _, ok := p.removals[card.address]; !ok {
+			p.removals[card.address] = 0
+		}
+	}
+
+	for address, card := range p.seen {
+		if !current[address] {
+			// The device is still there but no longer reported, e.g.
+			// because the filters changed on reload.
+			if _, err := os.Stat(card.path); !errors.Is(err, os.ErrNotExist) {
+				delete(p.seen, address)
+				delete(p.present, address)
+				delete(p.removals, address)
+				continue
+			}
+			if p.present[address] {
+				p.removals[address]++
+			}
+		}
+		p.present[address] = current[address]
+
+		value := 0.0
+		if current[address] {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(p.presentDesc, prometheus.GaugeValue, value, address, card.vendor, card.model)
+		ch <- prometheus.MustNewConstMetric(p.removalsDesc, prometheus.CounterValue, p.removals[address], address)
+	}
+}
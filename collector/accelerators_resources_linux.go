@@ -0,0 +1,74 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// acceleratorResourceMap is the format of
+// --collector.accelerators.resource-map:
+//
+//	resources:
+//	  "10de": nvidia.com/gpu
+//	  "1da3": habana.ai/gaudi
+//	  "10de:2330": nvidia.com/h100
+//
+// Keys are either a PCI vendor ID, matching all cards of the vendor, or a
+// "<vendor>:<device>" PCI ID, which takes precedence.
+type acceleratorResourceMap struct {
+	Resources map[string]string `yaml:"resources"`
+}
+
+func loadAcceleratorResourceMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accelerator resource map: %w", err)
+	}
+
+	var m acceleratorResourceMap
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse accelerator resource map: %w", err)
+	}
+
+	resources := make(map[string]string, len(m.Resources))
+	for id, resource := range m.Resources {
+		key := strings.ToLower(id)
+		vendorID, deviceID, hasDevice := strings.Cut(key, ":")
+		if !pciIDRE.MatchString(vendorID) || (hasDevice && !pciIDRE.MatchString(deviceID)) {
+			return nil, fmt.Errorf("invalid PCI ID %q in accelerator resource map, expected <vendor> or <vendor>:<device>", id)
+		}
+		if resource == "" {
+			return nil, fmt.Errorf("empty resource name for %q in accelerator resource map", id)
+		}
+		resources[key] = resource
+	}
+	return resources, nil
+}
+
+// acceleratorResource returns the Kubernetes extended resource name of a card,
+// or an empty string if it is not in the resource map.
+func acceleratorResource(resources map[string]string, vendorID, deviceID string) string {
+	if resource, ok := resources[vendorID+":"+deviceID]; ok {
+		return resource
+	}
+	return resources[vendorID]
+}
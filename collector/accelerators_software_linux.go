@@ -0,0 +1,145 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	acceleratorsCUDAPath = kingpin.Flag("collector.accelerators.cuda-path",
+		"Installation directory of the CUDA toolkit, whose version is exposed on nodes with NVIDIA GPUs.").Default("/usr/local/cuda").String()
+	acceleratorsROCmPath = kingpin.Flag("collector.accelerators.rocm-path",
+		"Installation directory of ROCm, whose version is exposed on nodes with AMD GPUs.").Default("/opt/rocm").String()
+
+	acceleratorDriverVersionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "kernel_driver_version_info"),
+		"Version of a loaded accelerator kernel driver. Drivers built into the kernel tree have no version of their own.",
+		[]string{"driver", "version"}, nil,
+	)
+	acceleratorToolkitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "toolkit_info"),
+		"Version of an installed accelerator toolkit, such as CUDA or ROCm.",
+		[]string{"toolkit", "version"}, nil,
+	)
+	acceleratorModuleLoadedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "kernel_module_loaded"),
+		"Whether a companion kernel module of the accelerator stack, e.g. for GPUDirect RDMA, is loaded.",
+		[]string{"module"}, nil,
+	)
+	acceleratorFabricManagerDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, acceleratorsCollectorSubsystem, "fabric_manager_running"),
+		"Whether the NVIDIA Fabric Manager, required by NVSwitch systems, is running.",
+		nil, nil,
+	)
+
+	// acceleratorSoftware lists the software stacks of the vendors.
+	acceleratorSoftware = []struct {
+		vendorID string
+		// drivers are the kernel modules of the vendor exposing their
+		// version in /sys/module/<driver>/version.
+		drivers []string
+		// modules are the companion modules whose presence is exposed.
+		modules []string
+		// toolkit reads the version of the toolkit of the vendor.
+		toolkit     string
+		toolkitPath *string
+		readToolkit func(path string) (string, error)
+	}{
+		{nvidiaVendorID, []string{"nvidia"}, []string{"nvidia_uvm", "nvidia_peermem", "nv_peer_mem", "nvidia_fs", "gdrdrv"}, "cuda", acceleratorsCUDAPath, readCUDAVersion},
+		{amdVendorID, []string{"amdgpu"}, nil, "rocm", acceleratorsROCmPath, readROCmVersion},
+		{habanaVendorID, []string{"habanalabs"}, nil, "", nil, nil},
+	}
+)
+
+// updateAcceleratorSoftware exposes the driver and toolkit versions of the
+// vendors of the cards, so that version drift across a fleet can be queried
+// along with the cards.
+func updateAcceleratorSoftware(ch chan<- prometheus.Metric, cards []acceleratorCard) {
+	for _, stack := range acceleratorSoftware {
+		if len(vendorCards(cards, stack.vendorID)) == 0 {
+			continue
+		}
+		for _, driver := range stack.drivers {
+			if version, err := readFirmwareVersion(sysFilePath(filepath.Join("module", driver, "version"))); err == nil {
+				ch <- prometheus.MustNewConstMetric(acceleratorDriverVersionDesc, prometheus.GaugeValue, 1, driver, version)
+			}
+		}
+		for _, module := range stack.modules {
+			_, err := os.Stat(sysFilePath(filepath.Join("module", module)))
+			ch <- prometheus.MustNewConstMetric(acceleratorModuleLoadedDesc, prometheus.GaugeValue, boolToFloat(err == nil), module)
+		}
+		if stack.readToolkit != nil {
+			if version, err := stack.readToolkit(rootfsFilePath(*stack.toolkitPath)); err == nil {
+				ch <- prometheus.MustNewConstMetric(acceleratorToolkitDesc, prometheus.GaugeValue, 1, stack.toolkit, version)
+			}
+		}
+		if stack.vendorID == nvidiaVendorID {
+			ch <- prometheus.MustNewConstMetric(acceleratorFabricManagerDesc, prometheus.GaugeValue, boolToFloat(processRunning("nv-fabricmanager")))
+		}
+	}
+}
+
+// readCUDAVersion returns the version of a CUDA toolkit from version.json,
+// or from version.txt before CUDA 11.1.
+func readCUDAVersion(path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(path, "version.json"))
+	if err == nil {
+		var versions struct {
+			CUDA struct {
+				Version string `json:"version"`
+			} `json:"cuda"`
+		}
+		if err := json.Unmarshal(data, &versions); err != nil {
+			return "", err
+		}
+		return versions.CUDA.Version, nil
+	}
+
+	// CUDA Version 10.2.89
+	data, err = os.ReadFile(filepath.Join(path, "version.txt"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), "CUDA Version "), nil
+}
+
+// readROCmVersion returns the version of ROCm, e.g. 6.0.2-115.
+func readROCmVersion(path string) (string, error) {
+	return readFirmwareVersion(filepath.Join(path, ".info", "version"))
+}
+
+// processRunning returns whether a process with the given name runs. Names
+// are truncated to 15 characters by the kernel.
+func processRunning(name string) bool {
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	comms, _ := filepath.Glob(procFilePath("[0-9]*/comm"))
+	for _, comm := range comms {
+		if data, err := os.ReadFile(comm); err == nil && strings.TrimSpace(string(data)) == name {
+			return true
+		}
+	}
+	return false
+}
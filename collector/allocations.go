@@ -0,0 +1,82 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"runtime/metrics"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	trackCollectorAllocations = kingpin.Flag("collector.track-allocations",
+		"Expose the bytes allocated by each collector. The Go runtime only counts the allocations of the whole process, so the collectors run one at a time instead of in parallel: a scrape takes as long as all collectors together and may exceed the scrape timeout. Only meant to investigate memory usage.").Bool()
+)
+
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+var scrapeAllocBytesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_alloc_bytes"),
+	"node_exporter: Approximate number of bytes allocated on the heap by a collector scrape.",
+	[]string{"collector"},
+	nil,
+)
+
+// allocationsMtx serializes the collectors while their allocations are
+// tracked, as the runtime only counts the allocations of the whole process
+// and has no per goroutine counter. The collectors of a scrape wait for each
+// other, and those of concurrent scrapes for all of them.
+var allocationsMtx sync.Mutex
+
+// updateTrackingAllocations runs a collector and exposes the bytes allocated
+// meanwhile. The metrics of the collector are only passed on once it is done,
+// so that their processing by the registry is not attributed to it. The
+// caller must hold allocationsMtx.
+func updateTrackingAllocations(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric) error {
+	var (
+		collected []prometheus.Metric
+		metricsCh = make(chan prometheus.Metric)
+		done      = make(chan struct{})
+	)
+
+	before := heapAllocs()
+	go func() {
+		for m := range metricsCh {
+			collected = append(collected, m)
+		}
+		close(done)
+	}()
+	err := update(metricsCh)
+	close(metricsCh)
+	<-done
+	allocated := heapAllocs() - before
+
+	for _, m := range collected {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeAllocBytesDesc, prometheus.GaugeValue, float64(allocated), name)
+	return err
+}
+
+// heapAllocs returns the cumulative number of bytes allocated on the heap.
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
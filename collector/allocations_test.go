@@ -0,0 +1,56 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var allocationSink []byte
+
+func TestUpdateTrackingAllocations(t *testing.T) {
+	const size = 1 << 20
+	update := func(ch chan<- prometheus.Metric) error {
+		allocationSink = make([]byte, size)
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, 1, "a")
+		return nil
+	}
+
+	ch := make(chan prometheus.Metric, 2)
+	allocationsMtx.Lock()
+	err := updateTrackingAllocations("alloc_test", update, ch)
+	allocationsMtx.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want the metric of the collector and its allocations", len(metrics))
+	}
+	var pb dto.Metric
+	if err := metrics[1].Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if got := pb.GetGauge().GetValue(); got < size {
+		t.Errorf("got %v allocated bytes, want at least %d", got, size)
+	}
+}
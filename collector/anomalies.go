@@ -0,0 +1,114 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	detectCounterAnomalies = kingpin.Flag("collector.detect-counter-anomalies",
+		"Track the counters exposed by each collector between scrapes and count the ones that went backwards.").Bool()
+)
+
+const (
+	// A counter dropping below counterResetRatio of its previous value is
+	// considered reset, e.g. by a wrap or a driver reload. Smaller decreases
+	// point to a glitch in the source.
+	counterResetRatio = 0.5
+
+	counterAnomalyReset        = "reset"
+	counterAnomalyNonMonotonic = "non_monotonic"
+)
+
+var scrapeCounterAnomaliesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_counter_anomalies_total"),
+	"node_exporter: Number of counters of a collector observed going backwards between scrapes, by type.",
+	[]string{"collector", "type"},
+	nil,
+)
+
+// counterAnomalies holds the counter values of the previous scrape of every
+// collector and the number of anomalies found so far.
+var counterAnomalies = struct {
+	sync.Mutex
+	last      map[string]map[string]float64
+	anomalies map[string]map[string]float64
+}{
+	last:      map[string]map[string]float64{},
+	anomalies: map[string]map[string]float64{},
+}
+
+// counterAnomalyDetector checks the counters exposed by one scrape of a
+// collector against the previous scrape.
+type counterAnomalyDetector struct {
+	collector string
+	current   map[string]float64
+}
+
+func newCounterAnomalyDetector(collector string) *counterAnomalyDetector {
+	return &counterAnomalyDetector{collector: collector, current: map[string]float64{}}
+}
+
+// observe records the value of a metric if it is a counter.
+func (d *counterAnomalyDetector) observe(m prometheus.Metric) {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil || pb.Counter == nil {
+		return
+	}
+
+	var key strings.Builder
+	key.WriteString(m.Desc().String())
+	for _, l := range pb.Label {
+		key.WriteByte(0xff)
+		key.WriteString(l.GetValue())
+	}
+	d.current[key.String()] = pb.Counter.GetValue()
+}
+
+// finish compares the counters of the scrape with the previous scrape and
+// exposes the number of anomalies found so far.
+func (d *counterAnomalyDetector) finish(ch chan<- prometheus.Metric) {
+	counterAnomalies.Lock()
+	defer counterAnomalies.Unlock()
+
+	anomalies, ok := counterAnomalies.anomalies[d.collector]
+	if !ok {
+		anomalies = map[string]float64{counterAnomalyReset: 0, counterAnomalyNonMonotonic: 0}
+		counterAnomalies.anomalies[d.collector] = anomalies
+	}
+
+	last := counterAnomalies.last[d.collector]
+	for key, value := range d.current {
+		previous, ok := last[key]
+		if !ok || value >= previous {
+			continue
+		}
+		if value < previous*counterResetRatio {
+			anomalies[counterAnomalyReset]++
+		} else {
+			anomalies[counterAnomalyNonMonotonic]++
+		}
+	}
+	counterAnomalies.last[d.collector] = d.current
+
+	for anomalyType, count := range anomalies {
+		ch <- prometheus.MustNewConstMetric(scrapeCounterAnomaliesDesc, prometheus.CounterValue, count, d.collector, anomalyType)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type anomalyTestCollector struct {
+	values []float64
+}
+
+var anomalyTestDesc = prometheus.NewDesc("test_total", "Test counter.", []string{"device"}, nil)
+
+func (c *anomalyTestCollector) Update(ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, c.values[0], "a")
+	ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, c.values[1], "b")
+	return nil
+}
+
+type anomalyTestWrapper struct {
+	c Collector
+}
+
+func (w anomalyTestWrapper) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(w, ch)
+}
+
+func (w anomalyTestWrapper) Collect(ch chan<- prometheus.Metric) {
+	updateDetectingAnomalies("anomaly_test", w.c.Update, ch)
+}
+
+func TestCounterAnomalies(t *testing.T) {
+	c := &anomalyTestCollector{values: []float64{100, 100}}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(anomalyTestWrapper{c})
+
+	for _, values := range [][]float64{
+		{200, 150}, // normal increase
+		{10, 149},  // a reset, b glitched
+		{20, 160},  // normal increase
+	} {
+		c.values = values
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `# HELP node_scrape_collector_counter_anomalies_total node_exporter: Number of counters of a collector observed going backwards between scrapes, by type.
+# TYPE node_scrape_collector_counter_anomalies_total counter
+node_scrape_collector_counter_anomalies_total{collector="anomaly_test",type="non_monotonic"} 1
+node_scrape_collector_counter_anomalies_total{collector="anomaly_test",type="reset"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "node_scrape_collector_counter_anomalies_total"); err != nil {
+		t.Fatal(err)
+	}
+}
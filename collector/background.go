@@ -0,0 +1,165 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	collectorBackgroundInterval = kingpin.Flag("collector.background-interval",
+		"Interval at which collectors run in the background, independently of scrapes, which serve the metrics of their latest run. 0 runs collectors on every scrape.").Default("0s").Duration()
+	collectorBackgroundIntervalOverrides = kingpin.Flag("collector.background-interval-override",
+		"Background interval of a collector overriding --collector.background-interval, in the form collector=duration, e.g. ipmi=1m. 0s runs the collector on every scrape. Can be repeated.").Strings()
+)
+
+var scrapeSnapshotAgeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_snapshot_age_seconds"),
+	"node_exporter: Age of the metrics served for a collector running in the background.",
+	[]string{"collector"},
+	nil,
+)
+
+// backgroundCollector runs a collector at a fixed interval and keeps the
+// metrics of its latest run.
+type backgroundCollector struct {
+	stop  chan struct{}
+	ready chan struct{}
+	// downsampler, if not nil, aggregates the gauges of the runs.
+	downsampler *downsampler
+
+	mtx      sync.Mutex
+	metrics  []prometheus.Metric
+	err      error
+	begin    time.Time
+	duration time.Duration
+}
+
+// backgroundCollectors holds the running background collectors by name.
+var backgroundCollectors = struct {
+	sync.Mutex
+	collectors map[string]*backgroundCollector
+}{collectors: map[string]*backgroundCollector{}}
+
+// backgroundEnabled returns whether any collector runs in the background.
+func backgroundEnabled() bool {
+	return *collectorBackgroundInterval > 0 || len(*collectorBackgroundIntervalOverrides) > 0
+}
+
+// parseCollectorBackgroundIntervals returns the background intervals of the
+// given collectors from --collector.background-interval and
+// --collector.background-interval-override.
+func parseCollectorBackgroundIntervals(collectors map[string]Collector) (map[string]time.Duration, error) {
+	intervals, err := parseCollectorDurations(collectors, *collectorBackgroundInterval, *collectorBackgroundIntervalOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("--collector.background-interval-override: %w", err)
+	}
+	return intervals, nil
+}
+
+// backgroundSnapshot returns the metrics of the latest run of a collector in
+// the background, with the start, duration and error of the run. If windows
+// are given, the aggregates of the gauges over them are added. The collector is
+// started with the given update on first use, which waits for its first run.
+func backgroundSnapshot(name string, update func(chan<- prometheus.Metric) error, interval time.Duration, windows []time.Duration) ([]prometheus.Metric, time.Time, time.Duration, error) {
+	backgroundCollectors.Lock()
+	bc, ok := backgroundCollectors.collectors[name]
+	if !ok {
+		bc = &backgroundCollector{stop: make(chan struct{}), ready: make(chan struct{})}
+		if len(windows) > 0 {
+			bc.downsampler = newDownsampler(windows, *collectorDownsampleAggregates)
+		}
+		backgroundCollectors.collectors[name] = bc
+		go bc.run(update, interval)
+	}
+	backgroundCollectors.Unlock()
+
+	<-bc.ready
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+	if bc.downsampler != nil {
+		metrics := append(append([]prometheus.Metric(nil), bc.metrics...), bc.downsampler.aggregate(time.Now())...)
+		return metrics, bc.begin, bc.duration, bc.err
+	}
+	return bc.metrics, bc.begin, bc.duration, bc.err
+}
+
+func (bc *backgroundCollector) run(update func(chan<- prometheus.Metric) error, interval time.Duration) {
+	bc.collect(update)
+	close(bc.ready)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bc.stop:
+			return
+		case <-ticker.C:
+			if quietModeActive() && time.Since(bc.lastBegin()) < *quietInterval {
+				continue
+			}
+			bc.collect(update)
+		}
+	}
+}
+
+// lastBegin returns the start of the latest run.
+func (bc *backgroundCollector) lastBegin() time.Time {
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+	return bc.begin
+}
+
+func (bc *backgroundCollector) collect(update func(chan<- prometheus.Metric) error) {
+	var (
+		collected []prometheus.Metric
+		metricsCh = make(chan prometheus.Metric)
+		done      = make(chan struct{})
+	)
+	go func() {
+		for m := range metricsCh {
+			collected = append(collected, m)
+		}
+		close(done)
+	}()
+	begin := time.Now()
+	err := update(metricsCh)
+	duration := time.Since(begin)
+	close(metricsCh)
+	<-done
+
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+	bc.metrics, bc.begin, bc.duration, bc.err = collected, begin, duration, err
+	if bc.downsampler != nil {
+		bc.downsampler.observe(begin, collected)
+	}
+}
+
+// stopBackgroundCollector stops a collector running in the background, e.g.
+// before it is re-created with new settings. It is started again by the next
+// scrape.
+func stopBackgroundCollector(name string) {
+	backgroundCollectors.Lock()
+	defer backgroundCollectors.Unlock()
+	if bc, ok := backgroundCollectors.collectors[name]; ok {
+		close(bc.stop)
+		delete(backgroundCollectors.collectors, name)
+	}
+}
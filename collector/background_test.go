@@ -0,0 +1,63 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBackgroundSnapshot(t *testing.T) {
+	defer stopBackgroundCollector("background_test")
+
+	var updates atomic.Int32
+	update := func(ch chan<- prometheus.Metric) error {
+		n := updates.Add(1)
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, float64(n), "a")
+		if n == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	// The first snapshot waits for the first run.
+	metrics, begin, _, err := backgroundSnapshot("background_test", update, 5*time.Millisecond, nil)
+	if len(metrics) != 1 || err == nil || begin.IsZero() {
+		t.Fatalf("first snapshot: metrics=%d err=%v begin=%v, want 1 metric and an error", len(metrics), err, begin)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for updates.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("collector ran %d times in the background, want at least 3", updates.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	metrics, _, _, err = backgroundSnapshot("background_test", update, 5*time.Millisecond, nil)
+	if len(metrics) != 1 || err != nil {
+		t.Errorf("later snapshot: metrics=%d err=%v, want 1 metric and no error", len(metrics), err)
+	}
+
+	// Stopped collectors no longer run.
+	stopBackgroundCollector("background_test")
+	stopped := updates.Load()
+	time.Sleep(20 * time.Millisecond)
+	if n := updates.Load(); n > stopped+1 {
+		t.Errorf("collector ran %d times after being stopped", n-stopped)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	circuitBreakerFailures = kingpin.Flag("collector.circuit-breaker.failures",
+		"Number of consecutive scrapes a collector failed or timed out in after which it is no longer run for --collector.circuit-breaker.backoff. Use 0 to disable.").Default("0").Int()
+	circuitBreakerBackoff = kingpin.Flag("collector.circuit-breaker.backoff",
+		"Time for which a collector tripping the circuit breaker is not run. It is then run again, and disabled again on the first failure.").Default("5m").Duration()
+)
+
+var scrapeDisabledDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_disabled"),
+	"node_exporter: Whether a collector is not run after failing repeatedly, see --collector.circuit-breaker.failures.",
+	[]string{"collector"},
+	nil,
+)
+
+// errCollectorDisabled is returned for the scrapes of a collector disabled by
+// the circuit breaker.
+var errCollectorDisabled = errors.New("collector disabled after consecutive failures")
+
+// collectorBreakers holds the consecutive failures of every collector and the
+// end of the backoff of the disabled ones.
+var collectorBreakers = struct {
+	sync.Mutex
+	failures map[string]int
+	until    map[string]time.Time
+}{
+	failures: map[string]int{},
+	until:    map[string]time.Time{},
+}
+
+// circuitBreakerEnabled returns whether collectors failing repeatedly are
+// disabled.
+func circuitBreakerEnabled() bool {
+	return *circuitBreakerFailures > 0
+}
+
+// updateWithCircuitBreaker runs a collector unless it is disabled after
+// failing --collector.circuit-breaker.failures times in a row. Collectors
+// without data do not fail.
+func updateWithCircuitBreaker(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric, logger log.Logger) error {
+	now := time.Now()
+	collectorBreakers.Lock()
+	if until, ok := collectorBreakers.until[name]; ok && now.Before(until) {
+		collectorBreakers.Unlock()
+		return fmt.Errorf("%w until %s", errCollectorDisabled, until.Format(time.RFC3339))
+	}
+	collectorBreakers.Unlock()
+
+	err := update(ch)
+
+	collectorBreakers.Lock()
+	defer collectorBreakers.Unlock()
+	if err == nil || IsNoDataError(err) {
+		delete(collectorBreakers.failures, name)
+		delete(collectorBreakers.until, name)
+		return err
+	}
+	collectorBreakers.failures[name]++
+	if failures := collectorBreakers.failures[name]; failures >= *circuitBreakerFailures {
+		level.Warn(logger).Log("msg", "collector keeps failing, disabling it", "name", name, "failures", failures, "backoff", *circuitBreakerBackoff)
+		collectorBreakers.until[name] = now.Add(*circuitBreakerBackoff)
+	}
+	return err
+}
+
+// exposeCircuitBreaker exposes whether a collector is disabled.
+func exposeCircuitBreaker(name string, ch chan<- prometheus.Metric) {
+	collectorBreakers.Lock()
+	until, ok := collectorBreakers.until[name]
+	collectorBreakers.Unlock()
+	disabled := 0.0
+	if ok && time.Now().Before(until) {
+		disabled = 1
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeDisabledDesc, prometheus.GaugeValue, disabled, name)
+}
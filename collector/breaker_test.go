@@ -0,0 +1,88 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestUpdateWithCircuitBreaker(t *testing.T) {
+	defer func(failures int, backoff time.Duration) {
+		*circuitBreakerFailures, *circuitBreakerBackoff = failures, backoff
+	}(*circuitBreakerFailures, *circuitBreakerBackoff)
+	*circuitBreakerFailures, *circuitBreakerBackoff = 2, 50*time.Millisecond
+
+	runs := 0
+	var updateErr error
+	update := func(ch chan<- prometheus.Metric) error {
+		runs++
+		return updateErr
+	}
+	disabled := func() float64 {
+		ch := make(chan prometheus.Metric, 1)
+		exposeCircuitBreaker("breaker_test", ch)
+		return readGauge(t, <-ch)
+	}
+	ch := make(chan prometheus.Metric)
+	logger := log.NewNopLogger()
+
+	// Collectors without data do not fail.
+	updateErr = ErrNoData
+	for i := 0; i < 3; i++ {
+		updateWithCircuitBreaker("breaker_test", update, ch, logger)
+	}
+	if disabled() != 0 {
+		t.Fatal("collector without data disabled")
+	}
+
+	updateErr = errors.New("read failed")
+	for i := 0; i < 2; i++ {
+		updateWithCircuitBreaker("breaker_test", update, ch, logger)
+	}
+	if disabled() != 1 {
+		t.Fatal("collector failing twice not disabled")
+	}
+	if err := updateWithCircuitBreaker("breaker_test", update, ch, logger); !errors.Is(err, errCollectorDisabled) {
+		t.Fatalf("got error %v, want collector disabled", err)
+	}
+	if runs != 5 {
+		t.Errorf("got %d runs, want disabled collector not to run", runs)
+	}
+
+	// After the backoff, the collector runs again and is enabled once it
+	// succeeds.
+	time.Sleep(*circuitBreakerBackoff)
+	updateErr = nil
+	if err := updateWithCircuitBreaker("breaker_test", update, ch, logger); err != nil {
+		t.Fatal(err)
+	}
+	if disabled() != 0 {
+		t.Error("collector still disabled after succeeding")
+	}
+}
+
+func readGauge(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	return pb.Gauge.GetValue()
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	collectorCacheTTL = kingpin.Flag("collector.cache-ttl",
+		"Duration for which the metrics of a collector are cached and served to further scrapes, 0 to disable. Failed updates are not cached.").Default("0s").Duration()
+	collectorCacheTTLOverrides = kingpin.Flag("collector.cache-ttl-override",
+		"Cache TTL of a collector overriding --collector.cache-ttl, in the form collector=duration, e.g. ethtool=30s. Can be repeated.").Strings()
+)
+
+var scrapeCacheHitDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_cache_hit"),
+	"node_exporter: Whether the metrics of a collector were served from its cache.",
+	[]string{"collector"},
+	nil,
+)
+
+// cachedResult holds the metrics of a successful update of a collector.
+type cachedResult struct {
+	// mtx serializes the updates of a collector, so that concurrent scrapes
+	// share one update instead of all missing the cache.
+	mtx     sync.Mutex
+	metrics []prometheus.Metric
+	expires time.Time
+}
+
+// collectorCache holds the cached results by collector name.
+var collectorCache = struct {
+	sync.Mutex
+	results map[string]*cachedResult
+}{results: map[string]*cachedResult{}}
+
+// cacheEnabled returns whether any collector has a cache TTL.
+func cacheEnabled() bool {
+	return *collectorCacheTTL > 0 || len(*collectorCacheTTLOverrides) > 0
+}
+
+// parseCollectorCacheTTLs returns the cache TTLs of the given collectors from
+// --collector.cache-ttl and --collector.cache-ttl-override.
+func parseCollectorCacheTTLs(collectors map[string]Collector) (map[string]time.Duration, error) {
+	ttls, err := parseCollectorDurations(collectors, *collectorCacheTTL, *collectorCacheTTLOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("--collector.cache-ttl-override: %w", err)
+	}
+	return ttls, nil
+}
+
+// updateCached serves the cached metrics of a collector if they are younger
+// than the TTL, and otherwise runs the update and caches its metrics if it
+// succeeds. It returns whether the metrics were served from the cache.
+func updateCached(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric, ttl time.Duration) (bool, error) {
+	collectorCache.Lock()
+	result, ok := collectorCache.results[name]
+	if !ok {
+		result = &cachedResult{}
+		collectorCache.results[name] = result
+	}
+	collectorCache.Unlock()
+
+	result.mtx.Lock()
+	defer result.mtx.Unlock()
+
+	if time.Now().Before(result.expires) {
+		for _, m := range result.metrics {
+			ch <- m
+		}
+		return true, nil
+	}
+
+	var (
+		collected []prometheus.Metric
+		metricsCh = make(chan prometheus.Metric)
+		done      = make(chan struct{})
+	)
+	go func() {
+		for m := range metricsCh {
+			collected = append(collected, m)
+		}
+		close(done)
+	}()
+	err := update(metricsCh)
+	close(metricsCh)
+	<-done
+
+	for _, m := range collected {
+		ch <- m
+	}
+	if err != nil {
+		result.metrics, result.expires = nil, time.Time{}
+		return false, err
+	}
+	result.metrics, result.expires = collected, time.Now().Add(ttl)
+	return false, nil
+}
+
+// forgetCachedResult drops the cached metrics of a collector, e.g. after it
+// was re-created with new settings.
+func forgetCachedResult(name string) {
+	collectorCache.Lock()
+	defer collectorCache.Unlock()
+	delete(collectorCache.results, name)
+}
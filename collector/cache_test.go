@@ -0,0 +1,65 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestUpdateCached(t *testing.T) {
+	defer forgetCachedResult("cache_test")
+
+	updates := 0
+	var err error
+	update := func(ch chan<- prometheus.Metric) error {
+		updates++
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, float64(updates), "a")
+		return err
+	}
+	run := func(ttl time.Duration) (bool, int, error) {
+		ch := make(chan prometheus.Metric, 10)
+		hit, err := updateCached("cache_test", update, ch, ttl)
+		return hit, len(ch), err
+	}
+
+	if hit, n, _ := run(time.Hour); hit || n != 1 {
+		t.Errorf("first update: hit=%v metrics=%d, want a miss with 1 metric", hit, n)
+	}
+	if hit, n, _ := run(time.Hour); !hit || n != 1 || updates != 1 {
+		t.Errorf("second update: hit=%v metrics=%d updates=%d, want a hit with 1 metric", hit, n, updates)
+	}
+
+	// Failed updates are not cached.
+	forgetCachedResult("cache_test")
+	err = errors.New("failed")
+	if hit, n, gotErr := run(time.Hour); hit || n != 1 || gotErr == nil {
+		t.Errorf("failed update: hit=%v metrics=%d err=%v", hit, n, gotErr)
+	}
+	err = nil
+	if hit, _, _ := run(time.Hour); hit || updates != 3 {
+		t.Errorf("update after failure: hit=%v updates=%d, want a miss", hit, updates)
+	}
+
+	// Expired results are refreshed.
+	forgetCachedResult("cache_test")
+	run(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if hit, _, _ := run(time.Millisecond); hit || updates != 5 {
+		t.Errorf("update after expiry: hit=%v updates=%d, want a miss", hit, updates)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nocarbon
+// +build !nocarbon
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	carbonFile    = kingpin.Flag("collector.carbon.file", "File containing the grid carbon intensity in gCO2eq/kWh.").String()
+	carbonURL     = kingpin.Flag("collector.carbon.url", "Local HTTP endpoint returning the grid carbon intensity in gCO2eq/kWh.").String()
+	carbonTimeout = kingpin.Flag("collector.carbon.timeout", "Timeout for fetching the carbon intensity from --collector.carbon.url.").Default("2s").Duration()
+)
+
+type carbonCollector struct {
+	file      string
+	url       string
+	client    *http.Client
+	intensity *prometheus.Desc
+	logger    log.Logger
+}
+
+func init() {
+	registerCollector("carbon", defaultDisabled, NewCarbonCollector)
+}
+
+// NewCarbonCollector returns a new Collector exposing the carbon intensity of
+// the electricity grid the node is connected to.
+func NewCarbonCollector(logger log.Logger) (Collector, error) {
+	if (*carbonFile == "") == (*carbonURL == "") {
+		return nil, errors.New("exactly one of --collector.carbon.file and --collector.carbon.url must be set")
+	}
+
+	return &carbonCollector{
+		file:   *carbonFile,
+		url:    *carbonURL,
+		client: &http.Client{Timeout: *carbonTimeout},
+		intensity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "carbon", "intensity_gco2_per_kwh"),
+			"Carbon intensity of the electricity grid in grams of CO2 equivalent per kWh.",
+			nil, nil,
+		),
+		logger: logger,
+	}, nil
+}
+
+func (c *carbonCollector) Update(ch chan<- prometheus.Metric) error {
+	data, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	intensity, err := parseCarbonIntensity(data)
+	if err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.intensity, prometheus.GaugeValue, intensity)
+	return nil
+}
+
+func (c *carbonCollector) read() ([]byte, error) {
+	if c.file != "" {
+		data, err := os.ReadFile(c.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read carbon intensity: %w", err)
+		}
+		return data, nil
+	}
+
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch carbon intensity: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch carbon intensity: unexpected status %s", resp.Status)
+	}
+	// Guard against misconfigured endpoints returning large documents.
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseCarbonIntensity parses either a plain number or a JSON object with a
+// carbonIntensity field as returned by the Electricity Maps API.
+func parseCarbonIntensity(data []byte) (float64, error) {
+	s := strings.TrimSpace(string(data))
+	if strings.HasPrefix(s, "{") {
+		var v struct {
+			CarbonIntensity *float64 `json:"carbonIntensity"`
+		}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return 0, fmt.Errorf("failed to parse carbon intensity: %w", err)
+		}
+		if v.CarbonIntensity == nil {
+			return 0, errors.New("failed to parse carbon intensity: missing carbonIntensity field")
+		}
+		return *v.CarbonIntensity, nil
+	}
+
+	intensity, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse carbon intensity: %w", err)
+	}
+	return intensity, nil
+}
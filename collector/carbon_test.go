@@ -0,0 +1,47 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nocarbon
+// +build !nocarbon
+
+package collector
+
+import "testing"
+
+func TestParseCarbonIntensity(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		want  float64
+		error bool
+	}{
+		{in: "412.5\n", want: 412.5},
+		{in: `{"zone":"DE","carbonIntensity":302,"datetime":"2024-06-01T12:00:00.000Z"}`, want: 302},
+		{in: `{"zone":"DE"}`, error: true},
+		{in: "n/a", error: true},
+	} {
+		got, err := parseCarbonIntensity([]byte(tc.in))
+		if tc.error {
+			if err == nil {
+				t.Errorf("parseCarbonIntensity(%q): expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCarbonIntensity(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseCarbonIntensity(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
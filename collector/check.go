@@ -0,0 +1,81 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+)
+
+// CollectorCheck is the result of validating the configuration of an
+// enabled collector.
+type CollectorCheck struct {
+	Name string
+	// Files are the files and directories configured for the collector.
+	Files []string
+	Err   error
+}
+
+// CheckCollectors creates every enabled collector, without keeping it, to
+// validate its flags and configuration files the way node_exporter does on
+// startup. The checks are sorted by collector name.
+func CheckCollectors(logger log.Logger) []CollectorCheck {
+	var checks []CollectorCheck
+	for name, enabled := range collectorState {
+		if !*enabled {
+			continue
+		}
+		checks = append(checks, CollectorCheck{
+			Name:  name,
+			Files: collectorFiles(name),
+			Err:   checkCollector(name, logger),
+		})
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
+// checkCollector creates a collector, turning the panics of invalid regular
+// expressions compiled with regexp.MustCompile into errors.
+func checkCollector(name string, logger log.Logger) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	_, err = factories[name](log.With(logger, "collector", name))
+	return err
+}
+
+// collectorFiles returns the values of the flags of a collector naming files
+// or directories, e.g. --collector.textfile.directory.
+func collectorFiles(name string) []string {
+	var files []string
+	for _, f := range kingpin.CommandLine.Model().Flags {
+		if !strings.HasPrefix(f.Name, "collector."+name+".") || f.String() == "" {
+			continue
+		}
+		for _, suffix := range []string{"file", "path", "map", "dir", "directory"} {
+			if strings.HasSuffix(f.Name, suffix) {
+				files = append(files, f.String())
+				break
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,308 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nochecks
+// +build !nochecks
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	systemddbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	checksFile = kingpin.Flag("collector.checks.file", "YAML file with the checks run by the checks collector.").String()
+)
+
+const (
+	checkTypeFile        = "file"
+	checkTypeProcess     = "process"
+	checkTypePort        = "port"
+	checkTypeSystemdUnit = "systemd_unit"
+
+	// Socket states of /proc/net/{tcp,udp}.
+	checkSocketListen = 0x0a
+	checkSocketClose  = 0x07
+)
+
+// checksConfig is the format of --collector.checks.file:
+//
+//	checks:
+//	  - name: chrony-config
+//	    file: /etc/chrony.conf
+//	  - name: sshd
+//	    process: ^/usr/sbin/sshd
+//	  - name: ssh-port
+//	    port: 22
+//	  - name: dns-port
+//	    port: 53
+//	    protocol: udp
+//	  - name: kubelet
+//	    systemd_unit: kubelet.service
+//
+// Each check has exactly one of file, process, port or systemd_unit.
+type checksConfig struct {
+	Checks []check `yaml:"checks"`
+}
+
+type check struct {
+	Name string `yaml:"name"`
+	// File passes if the file exists.
+	File string `yaml:"file"`
+	// Process passes if the command line of a process matches the regular
+	// expression. Kernel threads are matched by their name.
+	Process string `yaml:"process"`
+	// Port passes if a socket listens on the port, on any address.
+	Port uint64 `yaml:"port"`
+	// Protocol of Port, tcp or udp, tcp by default. Both cover IPv4 and
+	// IPv6.
+	Protocol string `yaml:"protocol"`
+	// SystemdUnit passes if the unit is active.
+	SystemdUnit string `yaml:"systemd_unit"`
+
+	process *regexp.Regexp
+}
+
+// checkType returns the type of a check, which must have exactly one.
+func (c *check) checkType() (string, error) {
+	var types []string
+	if c.File != "" {
+		types = append(types, checkTypeFile)
+	}
+	if c.Process != "" {
+		types = append(types, checkTypeProcess)
+	}
+	if c.Port != 0 {
+		types = append(types, checkTypePort)
+	}
+	if c.SystemdUnit != "" {
+		types = append(types, checkTypeSystemdUnit)
+	}
+	if len(types) != 1 {
+		return "", fmt.Errorf("check %q must have exactly one of file, process, port or systemd_unit", c.Name)
+	}
+	return types[0], nil
+}
+
+type checksCollector struct {
+	file      string
+	fs        procfs.FS
+	status    *prometheus.Desc
+	logger    log.Logger
+	unitState func(ctx context.Context, unit string) (string, error)
+}
+
+func init() {
+	registerCollector("checks", defaultDisabled, NewChecksCollector)
+}
+
+// NewChecksCollector returns a new Collector running the checks of
+// --collector.checks.file.
+func NewChecksCollector(logger log.Logger) (Collector, error) {
+	if *checksFile == "" {
+		return nil, errors.New("--collector.checks.file must be set")
+	}
+	if _, err := readChecks(*checksFile); err != nil {
+		return nil, err
+	}
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open procfs: %w", err)
+	}
+
+	return &checksCollector{
+		file: *checksFile,
+		fs:   fs,
+		status: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "check", "status"),
+			"Whether a check of --collector.checks.file passes.",
+			[]string{"name", "type"}, nil,
+		),
+		logger:    logger,
+		unitState: systemdUnitActiveState,
+	}, nil
+}
+
+func (c *checksCollector) Update(ch chan<- prometheus.Metric) error {
+	// The file is read on every run, so that checks can be changed
+	// without restarting.
+	checks, err := readChecks(c.file)
+	if err != nil {
+		return err
+	}
+
+	var cmdlines []string
+	for _, chk := range checks {
+		typ, _ := chk.checkType()
+		var ok bool
+		switch typ {
+		case checkTypeFile:
+			_, err = os.Stat(rootfsFilePath(chk.File))
+			ok = err == nil
+		case checkTypeProcess:
+			if cmdlines == nil {
+				cmdlines, err = c.cmdlines()
+				if err != nil {
+					return err
+				}
+			}
+			for _, cmdline := range cmdlines {
+				if chk.process.MatchString(cmdline) {
+					ok = true
+					break
+				}
+			}
+		case checkTypePort:
+			ok, err = c.listening(chk.Port, chk.Protocol)
+			if err != nil {
+				return err
+			}
+		case checkTypeSystemdUnit:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			state, err := c.unitState(ctx, chk.SystemdUnit)
+			cancel()
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "unable to get state of unit", "check", chk.Name, "unit", chk.SystemdUnit, "err", err)
+			}
+			ok = state == "active"
+		}
+		ch <- prometheus.MustNewConstMetric(c.status, prometheus.GaugeValue, boolToFloat(ok), chk.Name, typ)
+	}
+	return nil
+}
+
+func readChecks(path string) ([]check, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checks file: %w", err)
+	}
+	var config checksConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse checks file: %w", err)
+	}
+
+	names := map[string]bool{}
+	for i, chk := range config.Checks {
+		if chk.Name == "" {
+			return nil, fmt.Errorf("check %d has no name", i)
+		}
+		if names[chk.Name] {
+			return nil, fmt.Errorf("duplicate check %q", chk.Name)
+		}
+		names[chk.Name] = true
+
+		typ, err := chk.checkType()
+		if err != nil {
+			return nil, err
+		}
+		if typ == checkTypeProcess {
+			chk.process, err = regexp.Compile(chk.Process)
+			if err != nil {
+				return nil, fmt.Errorf("invalid process of check %q: %w", chk.Name, err)
+			}
+		}
+		if chk.Protocol != "" && (typ != checkTypePort || (chk.Protocol != "tcp" && chk.Protocol != "udp")) {
+			return nil, fmt.Errorf("invalid protocol %q of check %q, must be tcp or udp with port", chk.Protocol, chk.Name)
+		}
+		config.Checks[i] = chk
+	}
+	return config.Checks, nil
+}
+
+// cmdlines returns the command lines of all processes, or the names of
+// kernel threads, which have none. Processes exiting meanwhile are skipped.
+func (c *checksCollector) cmdlines() ([]string, error) {
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	cmdlines := make([]string, 0, len(procs))
+	for _, p := range procs {
+		args, err := p.CmdLine()
+		if err != nil {
+			continue
+		}
+		if len(args) == 0 {
+			comm, err := p.Comm()
+			if err != nil {
+				continue
+			}
+			args = []string{comm}
+		}
+		cmdlines = append(cmdlines, strings.Join(args, " "))
+	}
+	return cmdlines, nil
+}
+
+// listening returns whether a TCP socket listens on, or a UDP socket is
+// bound to, a port on any IPv4 or IPv6 address.
+func (c *checksCollector) listening(port uint64, protocol string) (bool, error) {
+	reads := []func() (procfs.NetTCP, error){c.fs.NetTCP, c.fs.NetTCP6}
+	state := uint64(checkSocketListen)
+	if protocol == "udp" {
+		reads = []func() (procfs.NetTCP, error){
+			func() (procfs.NetTCP, error) { s, err := c.fs.NetUDP(); return procfs.NetTCP(s), err },
+			func() (procfs.NetTCP, error) { s, err := c.fs.NetUDP6(); return procfs.NetTCP(s), err },
+		}
+		state = checkSocketClose
+	}
+
+	for _, read := range reads {
+		sockets, err := read()
+		if errors.Is(err, os.ErrNotExist) {
+			// IPv6 may be disabled.
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("couldn't read sockets: %w", err)
+		}
+		for _, s := range sockets {
+			if s.LocalPort == port && s.St == state && s.RemPort == 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// systemdUnitActiveState returns the ActiveState of a systemd unit.
+func systemdUnitActiveState(ctx context.Context, unit string) (string, error) {
+	conn, err := systemddbus.NewWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	p, err := conn.GetUnitPropertyContext(ctx, unit, "ActiveState")
+	if err != nil {
+		return "", err
+	}
+	state, ok := p.Value.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected ActiveState %v", p.Value)
+	}
+	return state, nil
+}
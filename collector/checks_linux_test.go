@@ -0,0 +1,98 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nochecks
+// +build !nochecks
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testChecksCollector struct {
+	c Collector
+}
+
+func (c testChecksCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c testChecksCollector) Collect(ch chan<- prometheus.Metric) {
+	c.c.Update(ch)
+}
+
+func TestChecksCollector(t *testing.T) {
+	defer func(proc, rootfs, file string) {
+		*procPath, *rootfsPath, *checksFile = proc, rootfs, file
+	}(*procPath, *rootfsPath, *checksFile)
+	*procPath = "fixtures/checks/proc"
+	*rootfsPath = "fixtures"
+	*checksFile = "fixtures/checks/checks.yml"
+
+	c, err := NewChecksCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*checksCollector).unitState = func(ctx context.Context, unit string) (string, error) {
+		if unit == "kubelet.service" {
+			return "active", nil
+		}
+		return "", errors.New("unit not found")
+	}
+
+	want := `# HELP node_check_status Whether a check of --collector.checks.file passes.
+# TYPE node_check_status gauge
+node_check_status{name="chrony-config",type="file"} 1
+node_check_status{name="crio",type="systemd_unit"} 0
+node_check_status{name="dns-port",type="port"} 1
+node_check_status{name="http-port",type="port"} 0
+node_check_status{name="kthreadd",type="process"} 1
+node_check_status{name="kubelet",type="systemd_unit"} 1
+node_check_status{name="missing-file",type="file"} 0
+node_check_status{name="nginx",type="process"} 0
+node_check_status{name="ssh-port",type="port"} 1
+node_check_status{name="sshd",type="process"} 1
+`
+	if err := testutil.CollectAndCompare(testChecksCollector{c}, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadChecksInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"checks:\n  - file: /etc/hosts\n",
+		"checks:\n  - name: a\n    file: /etc/hosts\n  - name: a\n    port: 22\n",
+		"checks:\n  - name: a\n",
+		"checks:\n  - name: a\n    file: /etc/hosts\n    port: 22\n",
+		"checks:\n  - name: a\n    process: '('\n",
+		"checks:\n  - name: a\n    port: 22\n    protocol: sctp\n",
+		"checks:\n  - name: a\n    file: /etc/hosts\n    protocol: tcp\n",
+		"checks:\n  - name: a\n    url: http://localhost\n",
+	} {
+		path := filepath.Join(t.TempDir(), "checks.yml")
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readChecks(path); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -36,6 +37,22 @@ var (
 		[]string{"collector"},
 		nil,
 	)
+	// scrapeDurationHistogram replaces scrapeDurationDesc with
+	// --collector.scrape-duration-histogram. It is kept across scrapes so
+	// intermittently slow collectors show up in the distribution.
+	scrapeDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:                       namespace,
+			Subsystem:                       "scrape",
+			Name:                            "collector_duration_seconds",
+			Help:                            "node_exporter: Duration of a collector scrape.",
+			Buckets:                         []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		},
+		[]string{"collector"},
+	)
 	scrapeSuccessDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "scrape", "collector_success"),
 		"node_exporter: Whether a collector succeeded.",
@@ -49,6 +66,11 @@ const (
 	defaultDisabled = false
 )
 
+var (
+	scrapeDurationAsHistogram = kingpin.Flag("collector.scrape-duration-histogram",
+		"Expose node_scrape_collector_duration_seconds as a histogram over all scrapes instead of a gauge of the last scrape.").Default("false").Bool()
+)
+
 var (
 	factories              = make(map[string]func(logger log.Logger) (Collector, error))
 	initiatedCollectorsMtx = sync.Mutex{}
@@ -79,6 +101,31 @@ func registerCollector(collector string, isDefaultEnabled bool, factory func(log
 type NodeCollector struct {
 	Collectors map[string]Collector
 	logger     log.Logger
+	// Spans, if set, records the update of every collector, e.g. as a span
+	// of a trace of the scrape.
+	Spans SpanRecorder
+	// Deadline, if set, is the time by which the scrape has to be done.
+	// Collectors still running at the deadline are cut off like at their
+	// timeout, and the contexts of ContextCollectors are cancelled.
+	Deadline time.Time
+	// timeouts are the timeouts of the collectors, if any.
+	timeouts map[string]time.Duration
+	// cacheTTLs are the durations for which the metrics of the collectors
+	// are cached, if any.
+	cacheTTLs map[string]time.Duration
+	// faults are injected into the collectors with --debug.* flags.
+	faults map[string]*collectorFault
+	// intervals are the intervals of the collectors running in the
+	// background, if any.
+	intervals map[string]time.Duration
+	// downsampled are the collectors whose gauges are aggregated over
+	// --collector.downsample-window.
+	downsampled map[string]bool
+}
+
+// SpanRecorder records the updates of collectors during a scrape.
+type SpanRecorder interface {
+	RecordCollector(name string, begin time.Time, duration time.Duration, err error)
 }
 
 // DisableDefaultCollectors sets the collector state to false for all collectors which
@@ -130,41 +177,252 @@ func NewNodeCollector(logger log.Logger, filters ...string) (*NodeCollector, err
 			if err != nil {
 				return nil, err
 			}
+			restoreState(key, collector, logger)
 			collectors[key] = collector
 			initiatedCollectors[key] = collector
 		}
 	}
-	return &NodeCollector{Collectors: collectors, logger: logger}, nil
+	timeouts, err := parseCollectorTimeouts(collectors)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTLs, err := parseCollectorCacheTTLs(collectors)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := parseCollectorFaults(collectors, logger)
+	if err != nil {
+		return nil, err
+	}
+	intervals, err := parseCollectorBackgroundIntervals(collectors)
+	if err != nil {
+		return nil, err
+	}
+	resolutions, err := parseCollectorDownsampleResolutions(collectors)
+	if err != nil {
+		return nil, err
+	}
+	downsampled := map[string]bool{}
+	for name, resolution := range resolutions {
+		if resolution > 0 {
+			intervals[name] = resolution
+			downsampled[name] = true
+		}
+	}
+	return &NodeCollector{
+		Collectors:  collectors,
+		logger:      logger,
+		timeouts:    timeouts,
+		cacheTTLs:   cacheTTLs,
+		faults:      faults,
+		intervals:   intervals,
+		downsampled: downsampled,
+	}, nil
 }
 
 // Describe implements the prometheus.Collector interface.
 func (n NodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
 	ch <- scrapeSuccessDesc
+	ch <- scrapePanicsDesc
+	ch <- sourceReadFailuresDesc
+	ch <- sourceBackoffPathsDesc
+	if *detectCounterAnomalies {
+		ch <- scrapeCounterAnomaliesDesc
+	}
+	if *trackCollectorAllocations {
+		ch <- scrapeAllocBytesDesc
+	}
+	if timeoutsEnabled() || !n.Deadline.IsZero() {
+		ch <- scrapeTimeoutsDesc
+	}
+	if circuitBreakerEnabled() {
+		ch <- scrapeDisabledDesc
+	}
+	if cacheEnabled() {
+		ch <- scrapeCacheHitDesc
+	}
+	if backgroundEnabled() {
+		ch <- scrapeSnapshotAgeDesc
+	}
+	if scheduleEnabled() {
+		ch <- scrapeDeferredDesc
+	}
+	if quietModeEnabled() {
+		ch <- scrapeQuietModeDesc
+		ch <- scrapeQuietModeTransitionsDesc
+	}
 }
 
 // Collect implements the prometheus.Collector interface.
 func (n NodeCollector) Collect(ch chan<- prometheus.Metric) {
+	quiet := updateQuietMode(time.Now(), n.logger)
 	wg := sync.WaitGroup{}
-	wg.Add(len(n.Collectors))
+	var heavy []string
 	for name, c := range n.Collectors {
+		if quiet && quietDisabled(name) {
+			continue
+		}
+		if n.scheduled(name) {
+			heavy = append(heavy, name)
+			continue
+		}
+		wg.Add(1)
 		go func(name string, c Collector) {
-			execute(name, c, ch, n.logger)
+			n.execute(name, c, ch, quiet, false, n.Deadline)
 			wg.Done()
 		}(name, c)
 	}
+	run, deferred := planHeavy(heavy, *scheduleCPUBudget)
+	for _, name := range deferred {
+		wg.Add(1)
+		go func(name string, c Collector) {
+			n.execute(name, c, ch, quiet, true, n.Deadline)
+			wg.Done()
+		}(name, n.Collectors[name])
+	}
 	wg.Wait()
+	// Heavy collectors run one after the other once the others are done,
+	// so that the CPU time used by each can be told apart. They share the
+	// time left until the deadline. The CPU time is the one of the whole
+	// process, since collectors may use several goroutines and threads, so
+	// concurrent requests are charged to the collector running.
+	for i, name := range run {
+		deadline := n.Deadline
+		if !deadline.IsZero() {
+			now := time.Now()
+			deadline = now.Add(deadline.Sub(now) / time.Duration(len(run)-i))
+		}
+		before := processCPUSeconds()
+		n.execute(name, n.Collectors[name], ch, quiet, false, deadline)
+		cost := time.Duration((processCPUSeconds() - before) * float64(time.Second))
+		recordHeavyCost(name, cost, time.Now())
+	}
+	exposeSourceReads(ch, n.logger)
+	if quietModeEnabled() {
+		exposeQuietMode(ch)
+	}
+	persistState(n.Collectors, n.logger)
+}
+
+// scheduled returns whether a collector is scheduled within
+// --collector.schedule.cpu-budget.
+func (n NodeCollector) scheduled(name string) bool {
+	// Background runs already decouple scrapes from the collector.
+	return scheduleEnabled() && isHeavy(name) && n.intervals[name] == 0
 }
 
-func execute(name string, c Collector, ch chan<- prometheus.Metric, logger log.Logger) {
-	begin := time.Now()
-	err := c.Update(ch)
-	duration := time.Since(begin)
+func (n NodeCollector) execute(name string, c Collector, ch chan<- prometheus.Metric, quiet, deferred bool, deadline time.Time) {
+	timeout, cacheTTL, interval, logger := n.timeouts[name], n.cacheTTLs[name], n.intervals[name], n.logger
+	ctx := context.Background()
+	// Background runs outlive the scrape starting them, and are not
+	// waited for by later scrapes.
+	if !deadline.IsZero() && interval == 0 {
+		// The deadline cuts the collector off like a timeout, so that the
+		// scrape is done before the scraper gives up on it.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+		if left := time.Until(deadline); timeout == 0 || left < timeout {
+			timeout = max(left, time.Millisecond)
+		}
+	}
+	if interval > 0 {
+		// Background runs already decouple scrapes from the collector.
+		cacheTTL = 0
+	}
+	exposeCacheHit := cacheTTL > 0
+	if quiet && interval == 0 && cacheTTL < *quietInterval {
+		cacheTTL = *quietInterval
+	}
+	update := c.Update
+	if cc, ok := c.(ContextCollector); ok {
+		update = func(ch chan<- prometheus.Metric) error {
+			return cc.UpdateContext(ctx, ch)
+		}
+	}
+	update = recoverPanics(name, update, logger)
+	if labels := collectorLabels(name); len(labels) > 0 {
+		unlabeled := update
+		update = func(ch chan<- prometheus.Metric) error {
+			return updateWithLabels(unlabeled, ch, labels)
+		}
+	}
+	if *detectCounterAnomalies {
+		recovered := update
+		update = func(ch chan<- prometheus.Metric) error {
+			return updateDetectingAnomalies(name, recovered, ch)
+		}
+	}
+	if fault, ok := n.faults[name]; ok {
+		update = fault.wrap(update)
+	}
+	update = trackUpdate(name, update)
+	if timeout > 0 {
+		untimed := update
+		update = func(ch chan<- prometheus.Metric) error {
+			return updateWithTimeout(name, untimed, ch, timeout)
+		}
+	}
+	if circuitBreakerEnabled() {
+		unbroken := update
+		update = func(ch chan<- prometheus.Metric) error {
+			return updateWithCircuitBreaker(name, unbroken, ch, logger)
+		}
+	}
+	cacheHit := false
+	if cacheTTL > 0 {
+		uncached := update
+		update = func(ch chan<- prometheus.Metric) (err error) {
+			cacheHit, err = updateCached(name, uncached, ch, cacheTTL)
+			return err
+		}
+	}
+	scheduled := n.scheduled(name)
+	if scheduled {
+		unscheduled := update
+		update = func(ch chan<- prometheus.Metric) error {
+			return updateScheduled(name, unscheduled, ch, deferred)
+		}
+	}
+
+	var err error
+	var begin time.Time
+	var duration time.Duration
+	if interval > 0 {
+		// Collectors running in the background are not run by the scrape,
+		// which serves the metrics of their latest run.
+		var metrics []prometheus.Metric
+		var windows []time.Duration
+		if n.downsampled[name] {
+			windows = *collectorDownsampleWindows
+		}
+		metrics, begin, duration, err = backgroundSnapshot(name, update, interval, windows)
+		for _, m := range metrics {
+			ch <- m
+		}
+	} else if *trackCollectorAllocations {
+		allocationsMtx.Lock()
+		begin = time.Now()
+		err = updateTrackingAllocations(name, update, ch)
+		duration = time.Since(begin)
+		allocationsMtx.Unlock()
+	} else {
+		begin = time.Now()
+		err = update(ch)
+		duration = time.Since(begin)
+	}
+	if n.Spans != nil {
+		n.Spans.RecordCollector(name, begin, duration, err)
+	}
+	recordStatus(name, begin, duration, err)
 	var success float64
 
 	if err != nil {
 		if IsNoDataError(err) {
 			level.Debug(logger).Log("msg", "collector returned no data", "name", name, "duration_seconds", duration.Seconds(), "err", err)
+		} else if errors.Is(err, errCollectorDisabled) {
+			level.Debug(logger).Log("msg", "collector disabled", "name", name, "err", err)
 		} else {
 			level.Error(logger).Log("msg", "collector failed", "name", name, "duration_seconds", duration.Seconds(), "err", err)
 		}
@@ -173,8 +431,92 @@ func execute(name string, c Collector, ch chan<- prometheus.Metric, logger log.L
 		level.Debug(logger).Log("msg", "collector succeeded", "name", name, "duration_seconds", duration.Seconds())
 		success = 1
 	}
-	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+	if *scrapeDurationAsHistogram {
+		histogram := scrapeDurationHistogram.WithLabelValues(name)
+		histogram.Observe(duration.Seconds())
+		ch <- histogram.(prometheus.Histogram)
+	} else {
+		ch <- prometheus.MustNewConstMetric(scrapeDurationDesc, prometheus.GaugeValue, duration.Seconds(), name)
+	}
 	ch <- prometheus.MustNewConstMetric(scrapeSuccessDesc, prometheus.GaugeValue, success, name)
+	exposePanics(name, ch)
+	if timeout > 0 {
+		exposeTimeouts(name, ch)
+	}
+	if circuitBreakerEnabled() {
+		exposeCircuitBreaker(name, ch)
+	}
+	if exposeCacheHit {
+		hit := 0.0
+		if cacheHit {
+			hit = 1
+		}
+		ch <- prometheus.MustNewConstMetric(scrapeCacheHitDesc, prometheus.GaugeValue, hit, name)
+	}
+	if scheduled {
+		ch <- prometheus.MustNewConstMetric(scrapeDeferredDesc, prometheus.GaugeValue, boolToFloat(deferred), name)
+	}
+	if interval > 0 {
+		age := time.Since(begin.Add(duration))
+		ch <- prometheus.MustNewConstMetric(scrapeSnapshotAgeDesc, prometheus.GaugeValue, age.Seconds(), name)
+	}
+}
+
+// updateDetectingAnomalies runs the update of a collector, passing the
+// metrics it exposes through a counterAnomalyDetector.
+func updateDetectingAnomalies(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric) error {
+	detector := newCounterAnomalyDetector(name)
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range metrics {
+			detector.observe(m)
+			ch <- m
+		}
+		close(done)
+	}()
+
+	err := update(metrics)
+	close(metrics)
+	<-done
+
+	detector.finish(ch)
+	return err
+}
+
+// reloadableCollector is implemented by collectors whose configuration files
+// can be re-read without restarting node_exporter.
+type reloadableCollector interface {
+	reload() error
+}
+
+// Reload re-reads the config file, the device list files and the
+// configuration files of all initiated collectors.
+func Reload(logger log.Logger) error {
+	var errs []error
+	if err := reloadConfig(logger); err != nil {
+		level.Error(logger).Log("msg", "failed to reload config file", "err", err)
+		errs = append(errs, err)
+	}
+	if err := reloadDeviceLists(); err != nil {
+		level.Error(logger).Log("msg", "failed to reload device lists", "err", err)
+		errs = append(errs, err)
+	}
+
+	initiatedCollectorsMtx.Lock()
+	defer initiatedCollectorsMtx.Unlock()
+
+	for name, c := range initiatedCollectors {
+		rc, ok := c.(reloadableCollector)
+		if !ok {
+			continue
+		}
+		if err := rc.reload(); err != nil {
+			level.Error(logger).Log("msg", "failed to reload collector", "collector", name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Collector is the interface a collector has to implement.
@@ -183,6 +525,14 @@ type Collector interface {
 	Update(ch chan<- prometheus.Metric) error
 }
 
+// ContextCollector is implemented by collectors that can stop an update
+// early, e.g. ones running commands. UpdateContext is called instead of
+// Update, with a context cancelled at the deadline of the scrape.
+type ContextCollector interface {
+	Collector
+	UpdateContext(ctx context.Context, ch chan<- prometheus.Metric) error
+}
+
 type typedDesc struct {
 	desc      *prometheus.Desc
 	valueType prometheus.ValueType
@@ -0,0 +1,104 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sleepCollector takes the next of its durations on every update.
+type sleepCollector struct {
+	durations []time.Duration
+}
+
+func (c *sleepCollector) Update(ch chan<- prometheus.Metric) error {
+	time.Sleep(c.durations[0])
+	c.durations = c.durations[1:]
+	return nil
+}
+
+// scrapeDuration gathers the node_scrape_collector_duration_seconds family.
+func scrapeDuration(t *testing.T, reg *prometheus.Registry) *dto.MetricFamily {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "node_scrape_collector_duration_seconds" {
+			return mf
+		}
+	}
+	t.Fatal("node_scrape_collector_duration_seconds not found")
+	return nil
+}
+
+func TestScrapeDurationHistogram(t *testing.T) {
+	defer func(v bool) { *scrapeDurationAsHistogram = v }(*scrapeDurationAsHistogram)
+	*scrapeDurationAsHistogram = true
+	defer scrapeDurationHistogram.DeleteLabelValues("histogram_test")
+
+	n := NodeCollector{
+		Collectors: map[string]Collector{"histogram_test": &sleepCollector{durations: []time.Duration{0, 30 * time.Millisecond, 0}}},
+		logger:     log.NewNopLogger(),
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(n)
+
+	// Observations are kept across scrapes.
+	var mf *dto.MetricFamily
+	for i := 0; i < 3; i++ {
+		mf = scrapeDuration(t, reg)
+	}
+	if mf.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("got type %s, want histogram", mf.GetType())
+	}
+	h := mf.GetMetric()[0].GetHistogram()
+	if got := h.GetSampleCount(); got != 3 {
+		t.Errorf("got %d observations, want 3", got)
+	}
+	if got := len(h.GetBucket()); got != 12 {
+		t.Errorf("got %d classic buckets, want 12", got)
+	}
+	for _, b := range h.GetBucket() {
+		var want uint64
+		switch {
+		case b.GetUpperBound() < 0.025:
+			// The fast updates may take longer than the smallest buckets.
+			continue
+		case b.GetUpperBound() == 0.025:
+			want = 2
+		default:
+			want = 3
+		}
+		if b.GetCumulativeCount() != want {
+			t.Errorf("bucket %v has %d observations, want %d", b.GetUpperBound(), b.GetCumulativeCount(), want)
+		}
+	}
+	if h.Schema == nil {
+		t.Error("expected a native histogram")
+	}
+
+	// Without the flag, the duration of the last scrape is a gauge.
+	*scrapeDurationAsHistogram = false
+	n.Collectors["histogram_test"] = &sleepCollector{durations: []time.Duration{0}}
+	if mf := scrapeDuration(t, reg); mf.GetType() != dto.MetricType_GAUGE {
+		t.Errorf("got type %s, want gauge", mf.GetType())
+	}
+}
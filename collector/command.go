@@ -0,0 +1,321 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nocommand
+// +build !nocommand
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	commandSubsystem = "command"
+
+	defaultCommandTimeout        = 10 * time.Second
+	defaultCommandMaxOutputBytes = 4 << 20
+	// commandPath is the PATH of the commands, which don't inherit the
+	// environment of node_exporter.
+	commandPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+var (
+	commandConfigFile = kingpin.Flag("collector.command.config-file", "YAML file listing the commands run by the command collector.").String()
+
+	commandNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// commandConfig is the format of --collector.command.config-file:
+//
+//	commands:
+//	  - name: raid
+//	    command: [/usr/local/bin/raid-status, --prometheus]
+//	    timeout: 10s
+//	    interval: 5m
+//	    user: nobody
+//	    env:
+//	      RAID_CONTROLLER: "0"
+type commandConfig struct {
+	Commands []*commandSpec `yaml:"commands"`
+}
+
+// commandSpec is a command printing metrics in the text format to stdout.
+type commandSpec struct {
+	Name    string   `yaml:"name"`
+	Command []string `yaml:"command"`
+	// Timeout kills the command, with all processes of its process group.
+	Timeout time.Duration `yaml:"timeout"`
+	// Interval reuses the output of the command for scrapes within the
+	// interval, the command runs on every scrape if 0.
+	Interval time.Duration `yaml:"interval"`
+	// User runs the command as another user, which requires node_exporter
+	// to run as root.
+	User string `yaml:"user"`
+	// Env is the environment of the command in addition to PATH.
+	Env            map[string]string `yaml:"env"`
+	Dir            string            `yaml:"dir"`
+	MaxOutputBytes int               `yaml:"max_output_bytes"`
+}
+
+// commandResult is the outcome of a run of a command.
+type commandResult struct {
+	time     time.Time
+	duration time.Duration
+	exitCode int
+	families map[string]*dto.MetricFamily
+	err      error
+}
+
+type commandCollector struct {
+	commands     []*commandSpec
+	successDesc  *prometheus.Desc
+	durationDesc *prometheus.Desc
+	exitCodeDesc *prometheus.Desc
+	logger       log.Logger
+
+	mtx     sync.Mutex
+	results map[string]*commandResult
+}
+
+func init() {
+	registerCollector("command", defaultDisabled, NewCommandCollector)
+}
+
+// NewCommandCollector returns a new Collector exposing the metrics printed by the
+// commands of --collector.command.config-file.
+func NewCommandCollector(logger log.Logger) (Collector, error) {
+	if *commandConfigFile == "" {
+		return nil, errors.New("--collector.command.config-file is required")
+	}
+	commands, err := loadCommandConfig(*commandConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return &commandCollector{
+		commands: commands,
+		successDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, commandSubsystem, "success"),
+			"Whether the last run of the command succeeded and its output was parsed.",
+			[]string{"command"}, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, commandSubsystem, "duration_seconds"),
+			"Duration of the last run of the command.",
+			[]string{"command"}, nil,
+		),
+		exitCodeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, commandSubsystem, "exit_code"),
+			"Exit code of the last run of the command, -1 if it was killed or couldn't be started.",
+			[]string{"command"}, nil,
+		),
+		logger:  logger,
+		results: map[string]*commandResult{},
+	}, nil
+}
+
+func loadCommandConfig(path string) ([]*commandSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command config file: %w", err)
+	}
+
+	var config commandConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse command config file: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, c := range config.Commands {
+		if !commandNameRE.MatchString(c.Name) {
+			return nil, fmt.Errorf("invalid command name %q", c.Name)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate command %q", c.Name)
+		}
+		names[c.Name] = true
+		// Commands are not looked up in PATH, which could be changed
+		// to run something else.
+		if len(c.Command) == 0 || !filepath.IsAbs(c.Command[0]) {
+			return nil, fmt.Errorf("command of %q must start with an absolute path", c.Name)
+		}
+		if c.Timeout < 0 || c.Interval < 0 || c.MaxOutputBytes < 0 {
+			return nil, fmt.Errorf("negative timeout, interval or max_output_bytes of %q", c.Name)
+		}
+		if c.Timeout == 0 {
+			c.Timeout = defaultCommandTimeout
+		}
+		if c.MaxOutputBytes == 0 {
+			c.MaxOutputBytes = defaultCommandMaxOutputBytes
+		}
+	}
+	return config.Commands, nil
+}
+
+func (c *commandCollector) Update(ch chan<- prometheus.Metric) error {
+	return c.UpdateContext(context.Background(), ch)
+}
+
+// UpdateContext runs the commands, killing them when ctx is cancelled.
+func (c *commandCollector) UpdateContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	results := make([]*commandResult, len(c.commands))
+	var wg sync.WaitGroup
+	for i, cmd := range c.commands {
+		wg.Add(1)
+		go func(i int, cmd *commandSpec) {
+			defer wg.Done()
+			results[i] = c.result(ctx, cmd)
+		}(i, cmd)
+	}
+	wg.Wait()
+
+	for i, cmd := range c.commands {
+		r := results[i]
+		success := 0.0
+		if r.err != nil {
+			level.Error(c.logger).Log("msg", "command failed", "command", cmd.Name, "err", r.err)
+		} else {
+			success = 1
+			for _, mf := range r.families {
+				if mf.Help == nil {
+					mf.Help = proto.String(fmt.Sprintf("Metric read from command %s", cmd.Name))
+				}
+				convertMetricFamily(mf, ch, c.logger)
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(c.successDesc, prometheus.GaugeValue, success, cmd.Name)
+		ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, r.duration.Seconds(), cmd.Name)
+		ch <- prometheus.MustNewConstMetric(c.exitCodeDesc, prometheus.GaugeValue, float64(r.exitCode), cmd.Name)
+	}
+	return nil
+}
+
+// result returns the result of the last run of a command, running it again
+// if its interval passed.
+func (c *commandCollector) result(ctx context.Context, cmd *commandSpec) *commandResult {
+	c.mtx.Lock()
+	r, ok := c.results[cmd.Name]
+	c.mtx.Unlock()
+	if ok && cmd.Interval > 0 && time.Since(r.time) < cmd.Interval {
+		return r
+	}
+
+	r = runCommand(ctx, cmd)
+	if ctx.Err() != nil {
+		// Run the command again on the next scrape instead of serving
+		// the cancelled run for its interval.
+		return r
+	}
+	c.mtx.Lock()
+	c.results[cmd.Name] = r
+	c.mtx.Unlock()
+	return r
+}
+
+// runCommand runs a command and parses its output.
+func runCommand(parent context.Context, cmd *commandSpec) *commandResult {
+	ctx, cancel := context.WithTimeout(parent, cmd.Timeout)
+	defer cancel()
+
+	command := exec.CommandContext(ctx, cmd.Command[0], cmd.Command[1:]...)
+	command.Dir = cmd.Dir
+	command.Env = []string{"PATH=" + commandPath}
+	for name, value := range cmd.Env {
+		command.Env = append(command.Env, name+"="+value)
+	}
+	sort.Strings(command.Env)
+	stdout := &limitedBuffer{max: cmd.MaxOutputBytes}
+	stderr := &limitedBuffer{max: 1024}
+	command.Stdout = stdout
+	command.Stderr = stderr
+	// Don't wait for children keeping the output open after a kill.
+	command.WaitDelay = time.Second
+
+	r := &commandResult{time: time.Now(), exitCode: -1}
+	if err := sandboxCommand(command, cmd); err != nil {
+		r.err = err
+		return r
+	}
+
+	err := command.Run()
+	r.duration = time.Since(r.time)
+	if command.ProcessState != nil {
+		r.exitCode = command.ProcessState.ExitCode()
+	}
+	switch {
+	case parent.Err() != nil:
+		r.err = fmt.Errorf("cancelled: %w", parent.Err())
+		return r
+	case ctx.Err() != nil:
+		r.err = fmt.Errorf("timed out after %s", cmd.Timeout)
+		return r
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		r.err = err
+		return r
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(&stdout.buf)
+	if err != nil {
+		r.err = fmt.Errorf("failed to parse output: %w", err)
+		return r
+	}
+	if hasTimestamps(families) {
+		r.err = errors.New("output contains unsupported client-side timestamps")
+		return r
+	}
+	r.families = families
+	return r
+}
+
+// limitedBuffer fails writes beyond max bytes, which makes the command fail
+// instead of node_exporter buffering unbounded output. It doesn't embed
+// bytes.Buffer, whose ReadFrom would be used by io.Copy to bypass Write.
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.max)
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
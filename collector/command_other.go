@@ -0,0 +1,31 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !nocommand
+// +build !linux,!nocommand
+
+package collector
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// sandboxCommand only supports running commands as node_exporter's own
+// user outside of Linux.
+func sandboxCommand(command *exec.Cmd, cmd *commandSpec) error {
+	if cmd.User != "" {
+		return errors.New("running commands as another user is only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nocommand
+// +build !nocommand
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeCommandScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandCollector(t *testing.T) {
+	dir := t.TempDir()
+	ok := writeCommandScript(t, dir, "ok.sh", `echo "# HELP raid_degraded Degraded arrays."
+echo "raid_degraded{array=\"$ARRAY\"} 0"
+`)
+	fail := writeCommandScript(t, dir, "fail.sh", "echo broken >&2; exit 3\n")
+	slow := writeCommandScript(t, dir, "slow.sh", "sleep 10\n")
+	verbose := writeCommandScript(t, dir, "verbose.sh", "exec yes '# comment'\n")
+
+	config := filepath.Join(dir, "commands.yml")
+	if err := os.WriteFile(config, []byte(`commands:
+  - name: ok
+    command: [`+ok+`]
+    env:
+      ARRAY: md0
+  - name: fail
+    command: [`+fail+`]
+  - name: slow
+    command: [`+slow+`]
+    timeout: 100ms
+  - name: verbose
+    command: [`+verbose+`]
+    max_output_bytes: 1024
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	*commandConfigFile = config
+	defer func() { *commandConfigFile = "" }()
+
+	c, err := NewCommandCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP node_command_exit_code Exit code of the last run of the command, -1 if it was killed or couldn't be started.
+# TYPE node_command_exit_code gauge
+node_command_exit_code{command="fail"} 3
+node_command_exit_code{command="ok"} 0
+node_command_exit_code{command="slow"} -1
+node_command_exit_code{command="verbose"} -1
+# HELP node_command_success Whether the last run of the command succeeded and its output was parsed.
+# TYPE node_command_success gauge
+node_command_success{command="fail"} 0
+node_command_success{command="ok"} 1
+node_command_success{command="slow"} 0
+node_command_success{command="verbose"} 0
+# HELP raid_degraded Degraded arrays.
+# TYPE raid_degraded untyped
+raid_degraded{array="md0"} 0
+`
+	begin := time.Now()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "node_command_exit_code", "node_command_success", "raid_degraded"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(begin); d > 5*time.Second {
+		t.Errorf("slow command was not killed after its timeout, scrape took %s", d)
+	}
+}
+
+func TestCommandCollectorDeadline(t *testing.T) {
+	dir := t.TempDir()
+	slow := writeCommandScript(t, dir, "slow.sh", "sleep 10\n")
+	config := filepath.Join(dir, "commands.yml")
+	if err := os.WriteFile(config, []byte(`commands:
+  - name: slow
+    command: [`+slow+`]
+    timeout: 20s
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	*commandConfigFile = config
+	defer func() { *commandConfigFile = "" }()
+
+	c, err := NewCommandCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := NodeCollector{
+		Collectors: map[string]Collector{"command": c},
+		logger:     log.NewNopLogger(),
+		Deadline:   time.Now().Add(200 * time.Millisecond),
+	}
+	want := `# HELP node_scrape_collector_success node_exporter: Whether a collector succeeded.
+# TYPE node_scrape_collector_success gauge
+node_scrape_collector_success{collector="command"} 0
+`
+	begin := time.Now()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(n)
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "node_scrape_collector_success"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(begin); d > 5*time.Second {
+		t.Errorf("collector was not cut off at the deadline, scrape took %s", d)
+	}
+
+	// The command was killed by the cancelled context, so the next scrape
+	// runs it again instead of finding it still running.
+	time.Sleep(2 * time.Second)
+	collectorTimeouts.Lock()
+	running := collectorTimeouts.running["command"]
+	collectorTimeouts.Unlock()
+	if running {
+		t.Error("command still running after the deadline")
+	}
+}
+
+func TestLoadCommandConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for _, invalid := range []string{
+		"commands:\n  - name: a\n    command: [relative.sh]\n",
+		"commands:\n  - name: a\n    command: []\n",
+		"commands:\n  - name: 'a b'\n    command: [/bin/true]\n",
+		"commands:\n  - name: a\n    command: [/bin/true]\n  - name: a\n    command: [/bin/true]\n",
+		"commands:\n  - name: a\n    command: [/bin/true]\n    timeout: -1s\n",
+		"commands:\n  - name: a\n    command: [/bin/true]\n    unknown: 1\n",
+	} {
+		path := filepath.Join(dir, "commands.yml")
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadCommandConfig(path); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
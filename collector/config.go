@@ -0,0 +1,347 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"gopkg.in/yaml.v2"
+)
+
+// collectorsConfig is the format of --config.file:
+//
+//	labels:
+//	  rack: r12
+//	collectors:
+//	  accelerators:
+//	    enabled: true
+//	    vendor-include: NVIDIA
+//	    labels:
+//	      hw_gen: g9
+//	  diskstats:
+//	    device-exclude: ^(z?ram|loop|fd)\d+$
+//	  netdev:
+//	    enabled: false
+//
+// The settings of a collector are the names of its --collector.<name>.*
+// flags without prefix, enabled stands for --collector.<name>. Repeatable
+// flags take lists. labels are added to the metrics of all collectors or of
+// one collector.
+type collectorsConfig struct {
+	Labels     map[string]string                 `yaml:"labels"`
+	Collectors map[string]map[string]interface{} `yaml:"collectors"`
+}
+
+// collectorConfig holds the config file and the flag values applied from it.
+var collectorConfig = struct {
+	sync.Mutex
+	path string
+	// commandLine are the flags given on the command line, which take
+	// precedence over the config file.
+	commandLine map[string]bool
+	// settings are the flag values applied from the config file.
+	settings map[string][]string
+	// restore reset the flags set from the config file to the values
+	// they had before.
+	restore map[string]func()
+}{}
+
+// configGeneration is incremented when Reload re-creates collectors with
+// changed settings.
+var configGeneration struct {
+	sync.Mutex
+	n uint64
+}
+
+// Generation returns a number changing whenever collectors are re-created by
+// Reload, so that NodeCollectors kept across scrapes can be rebuilt.
+func Generation() uint64 {
+	configGeneration.Lock()
+	defer configGeneration.Unlock()
+	return configGeneration.n
+}
+
+// LoadConfig applies the collector settings of a config file to the flags not
+// given on the command line. args are the command line arguments without the
+// program name.
+func LoadConfig(path string, args []string) error {
+	collectorConfig.Lock()
+	defer collectorConfig.Unlock()
+
+	for _, restore := range collectorConfig.restore {
+		restore()
+	}
+	collectorConfig.path = path
+	collectorConfig.commandLine = map[string]bool{}
+	collectorConfig.settings = nil
+	collectorConfig.restore = map[string]func(){}
+	setConfigLabels(configLabels{})
+	if path == "" {
+		return nil
+	}
+
+	ctx, err := kingpin.CommandLine.ParseContext(args)
+	if err != nil {
+		return err
+	}
+	for _, element := range ctx.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			collectorConfig.commandLine[flag.Model().Name] = true
+		}
+	}
+
+	settings, labels, err := readCollectorsConfig(path)
+	if err != nil {
+		return err
+	}
+	if err := applyConfigSettings(settings, nil); err != nil {
+		return err
+	}
+	setConfigLabels(labels)
+	return nil
+}
+
+// reloadConfig re-reads the config file. The collectors whose settings
+// changed are validated and forgotten, to be re-created by the next scrape.
+// The previous settings are kept if the file or a collector is invalid.
+func reloadConfig(logger log.Logger) error {
+	collectorConfig.Lock()
+	defer collectorConfig.Unlock()
+	if collectorConfig.path == "" {
+		return nil
+	}
+
+	settings, labels, err := readCollectorsConfig(collectorConfig.path)
+	if err != nil {
+		return err
+	}
+	previous := collectorConfig.settings
+	changed := changedCollectors(previous, settings)
+	if len(changed) == 0 {
+		// Labels are added at scrape time and need no new collectors.
+		setConfigLabels(labels)
+		return nil
+	}
+
+	initiatedCollectorsMtx.Lock()
+	defer initiatedCollectorsMtx.Unlock()
+
+	// Only the flags of the changed collectors are set again, the other
+	// collectors may be reading theirs.
+	affected := map[string]bool{}
+	for _, name := range changed {
+		affected[name] = true
+	}
+	if err := applyConfigSettings(settings, affected); err != nil {
+		applyConfigSettings(previous, affected)
+		return err
+	}
+	for _, name := range changed {
+		if !*collectorState[name] {
+			continue
+		}
+		if err := checkCollector(name, logger); err != nil {
+			// The previous settings were valid before.
+			applyConfigSettings(previous, affected)
+			return fmt.Errorf("invalid settings of collector %s: %w", name, err)
+		}
+	}
+
+	setConfigLabels(labels)
+	for _, name := range changed {
+		delete(initiatedCollectors, name)
+		forgetCachedResult(name)
+		stopBackgroundCollector(name)
+	}
+	configGeneration.Lock()
+	configGeneration.n++
+	configGeneration.Unlock()
+	level.Info(logger).Log("msg", "Applied changed collector settings", "collectors", strings.Join(changed, ","))
+	return nil
+}
+
+// readCollectorsConfig returns the flag values set by a config file, keyed
+// by flag name, and its labels.
+func readCollectorsConfig(path string) (map[string][]string, configLabels, error) {
+	var labels configLabels
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, labels, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config collectorsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, labels, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if err := checkLabelNames(config.Labels); err != nil {
+		return nil, labels, fmt.Errorf("invalid labels in config file: %w", err)
+	}
+	labels.global = config.Labels
+	labels.collectors = map[string]map[string]string{}
+
+	settings := map[string][]string{}
+	for name, options := range config.Collectors {
+		if _, ok := collectorState[name]; !ok {
+			return nil, labels, fmt.Errorf("unknown collector %q in config file", name)
+		}
+		for option, value := range options {
+			if option == "labels" {
+				if labels.collectors[name], err = parseConfigLabels(value); err != nil {
+					return nil, labels, fmt.Errorf("invalid labels of collector %s in config file: %w", name, err)
+				}
+				continue
+			}
+			flagName := "collector." + name + "." + option
+			if option == "enabled" {
+				flagName = "collector." + name
+			}
+			flag := kingpin.CommandLine.GetFlag(flagName)
+			if flag == nil {
+				return nil, labels, fmt.Errorf("unknown setting %q of collector %s in config file", option, name)
+			}
+
+			var values []string
+			switch v := value.(type) {
+			case []interface{}:
+				if !isCumulative(flag) {
+					return nil, labels, fmt.Errorf("setting %q of collector %s takes a single value", option, name)
+				}
+				for _, e := range v {
+					values = append(values, fmt.Sprint(e))
+				}
+			case map[interface{}]interface{}:
+				return nil, labels, fmt.Errorf("setting %q of collector %s must be a value or a list", option, name)
+			case nil:
+				values = []string{""}
+			default:
+				values = []string{fmt.Sprint(v)}
+			}
+			settings[flagName] = values
+		}
+	}
+	return settings, labels, nil
+}
+
+// applyConfigSettings resets the flags of the given collectors set from the
+// config file and sets them to the given settings, the flags of all collectors
+// if collectors is nil. The flags are reset if a value is invalid. The
+// settings of the other collectors are expected to be unchanged.
+func applyConfigSettings(settings map[string][]string, collectors map[string]bool) error {
+	affected := func(flagName string) bool {
+		return collectors == nil || collectors[configFlagCollector(flagName)]
+	}
+	restore := func() {
+		for name, restore := range collectorConfig.restore {
+			if affected(name) {
+				restore()
+			}
+		}
+	}
+	restore()
+	collectorConfig.settings = nil
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		if affected(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if collectorConfig.commandLine[name] {
+			continue
+		}
+		flag := kingpin.CommandLine.GetFlag(name)
+		value := flag.Model().Value
+		if _, ok := collectorConfig.restore[name]; !ok {
+			collectorConfig.restore[name] = flagRestorer(flag)
+		}
+		if isCumulative(flag) {
+			// Lists replace the values of repeatable flags.
+			resetCumulative(value)
+		}
+		for _, v := range settings[name] {
+			if err := value.Set(v); err != nil {
+				restore()
+				return fmt.Errorf("invalid value %q for --%s in config file: %w", v, name, err)
+			}
+		}
+	}
+	collectorConfig.settings = settings
+	return nil
+}
+
+// flagRestorer returns a function setting a flag back to its current value.
+func flagRestorer(flag *kingpin.FlagClause) func() {
+	value := flag.Model().Value
+	if isCumulative(flag) {
+		slice := reflect.ValueOf(value.(kingpin.Getter).Get()).Elem()
+		saved := reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+		reflect.Copy(saved, slice)
+		return func() { slice.Set(saved) }
+	}
+	saved := value.String()
+	return func() { value.Set(saved) }
+}
+
+func isCumulative(flag *kingpin.FlagClause) bool {
+	c, ok := flag.Model().Value.(interface{ IsCumulative() bool })
+	return ok && c.IsCumulative()
+}
+
+// resetCumulative empties the slice of a repeatable flag.
+func resetCumulative(value kingpin.Value) {
+	slice := reflect.ValueOf(value.(kingpin.Getter).Get()).Elem()
+	slice.Set(reflect.Zero(slice.Type()))
+}
+
+// changedCollectors returns the names of the collectors whose settings differ
+// between two configs.
+func changedCollectors(previous, current map[string][]string) []string {
+	changed := map[string]bool{}
+	for _, settings := range []map[string][]string{previous, current} {
+		for name := range settings {
+			if !reflect.DeepEqual(previous[name], current[name]) {
+				changed[configFlagCollector(name)] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configFlagCollector returns the collector of a flag set by the config file.
+func configFlagCollector(flagName string) string {
+	name := strings.TrimPrefix(flagName, "collector.")
+	for c := range collectorState {
+		if name == c || strings.HasPrefix(name, c+".") {
+			return c
+		}
+	}
+	return name
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { LoadConfig("", nil) })
+
+	write(`labels:
+  rack: r12
+  dc: east
+collectors:
+  maintenance:
+    enabled: true
+    file: /etc/maintenance.yml
+    labels:
+      dc: west
+      hw_gen: 9
+  carbon:
+    timeout: 5s
+`)
+	timeout := *carbonTimeout
+	if err := LoadConfig(path, []string{"--collector.carbon.timeout=1s"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*collectorState["maintenance"] || *maintenanceFile != "/etc/maintenance.yml" {
+		t.Errorf("maintenance collector settings not applied: enabled=%v file=%q", *collectorState["maintenance"], *maintenanceFile)
+	}
+	labels := map[string]string{}
+	for _, l := range collectorLabels("maintenance") {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if !reflect.DeepEqual(labels, map[string]string{"rack": "r12", "dc": "west", "hw_gen": "9"}) {
+		t.Errorf("unexpected labels of maintenance collector %v", labels)
+	}
+	if n := len(collectorLabels("carbon")); n != 2 {
+		t.Errorf("got %d labels for carbon collector, want the 2 global ones", n)
+	}
+	// Flags given on the command line take precedence. LoadConfig does not
+	// parse them, only skips them.
+	if *carbonTimeout != timeout {
+		t.Errorf("carbon timeout = %s, want %s", *carbonTimeout, timeout)
+	}
+
+	// Removed settings are reset on reload, and changed collectors are
+	// re-created.
+	initiatedCollectorsMtx.Lock()
+	initiatedCollectors["maintenance"] = &maintenanceCollector{}
+	initiatedCollectorsMtx.Unlock()
+	generation := Generation()
+	write(`collectors:
+  maintenance:
+    file: /etc/maintenance.yml
+`)
+	if err := reloadConfig(log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	if *collectorState["maintenance"] {
+		t.Error("maintenance collector still enabled after removing the setting")
+	}
+	if _, ok := initiatedCollectors["maintenance"]; ok {
+		t.Error("maintenance collector not re-created after reload")
+	}
+	if Generation() == generation {
+		t.Error("generation not incremented after reload")
+	}
+
+	// Invalid settings keep the previous ones.
+	for _, invalid := range []string{
+		"collectors:\n  nonexistent:\n    enabled: true\n",
+		"collectors:\n  maintenance:\n    unknown: 1\n",
+		"collectors:\n  maintenance:\n    file: [a, b]\n",
+		"collectors:\n  maintenance:\n    enabled: maybe\n",
+		"labels:\n  __reserved: x\n",
+		"collectors:\n  maintenance:\n    labels: [a]\n",
+		"collectors:\n  maintenance:\n    labels:\n      rack: [a]\n",
+	} {
+		write(invalid)
+		if err := reloadConfig(log.NewNopLogger()); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+		if *maintenanceFile != "/etc/maintenance.yml" {
+			t.Errorf("maintenance file = %q after invalid config %q", *maintenanceFile, invalid)
+		}
+	}
+
+	LoadConfig("", nil)
+	if n := len(collectorLabels("maintenance")); n != 0 {
+		t.Errorf("got %d labels after unloading config", n)
+	}
+	if *maintenanceFile != "" {
+		t.Errorf("maintenance file = %q after unloading config", *maintenanceFile)
+	}
+}
+
+func TestReloadConfigUnchangedCollectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { LoadConfig("", nil) })
+
+	write(`collectors:
+  maintenance:
+    file: /etc/maintenance.yml
+  carbon:
+    timeout: 5s
+`)
+	if err := LoadConfig(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Stands for a collector reading its flag, which must not be set again
+	// when its settings didn't change.
+	*carbonTimeout = 7 * time.Second
+
+	write(`collectors:
+  maintenance:
+    file: /etc/other-maintenance.yml
+  carbon:
+    timeout: 5s
+`)
+	if err := reloadConfig(log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	if *maintenanceFile != "/etc/other-maintenance.yml" {
+		t.Errorf("maintenance file = %q, want the reloaded one", *maintenanceFile)
+	}
+	if *carbonTimeout != 7*time.Second {
+		t.Errorf("carbon timeout set again to %s although unchanged", *carbonTimeout)
+	}
+}
+
+func TestLabeledMetric(t *testing.T) {
+	desc := prometheus.NewDesc("node_test", "Test metric.", []string{"rack"}, nil)
+	m := labeledMetric{
+		Metric: prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "own"),
+		labels: []*dto.LabelPair{
+			{Name: proto.String("dc"), Value: proto.String("west")},
+			{Name: proto.String("rack"), Value: proto.String("r12")},
+		},
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	want := &dto.Metric{
+		Label: []*dto.LabelPair{
+			{Name: proto.String("dc"), Value: proto.String("west")},
+			{Name: proto.String("rack"), Value: proto.String("own")},
+		},
+		Gauge: &dto.Gauge{Value: proto.Float64(1)},
+	}
+	if !proto.Equal(&pb, want) {
+		t.Errorf("got %v, want %v", &pb, want)
+	}
+}
+
+func TestCollectorLabelsSkipScrapeMetrics(t *testing.T) {
+	setConfigLabels(configLabels{collectors: map[string]map[string]string{"labels_test": {"dc": "west"}}})
+	defer setConfigLabels(configLabels{})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NodeCollector{
+		Collectors: map[string]Collector{"labels_test": &anomalyTestCollector{values: []float64{1, 2}}},
+		logger:     log.NewNopLogger(),
+	})
+	want := `# HELP node_scrape_collector_success node_exporter: Whether a collector succeeded.
+# TYPE node_scrape_collector_success gauge
+node_scrape_collector_success{collector="labels_test"} 1
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{dc="west",device="a"} 1
+test_total{dc="west",device="b"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "test_total", "node_scrape_collector_success"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noconfigmgmt
+// +build !noconfigmgmt
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+const configmgmtSubsystem = "configmgmt"
+
+var (
+	configmgmtPuppetSummary = kingpin.Flag("collector.configmgmt.puppet-summary",
+		"Last run summary of the Puppet agent.").Default("/opt/puppetlabs/puppet/public/last_run_summary.yaml").String()
+	configmgmtAnsibleReport = kingpin.Flag("collector.configmgmt.ansible-report",
+		"Output of the last ansible-pull run with the json stdout callback, e.g. written with ANSIBLE_STDOUT_CALLBACK=json ansible-pull ... > file.").String()
+	configmgmtSaltReport = kingpin.Flag("collector.configmgmt.salt-report",
+		"Output of the last highstate with JSON output, e.g. written with salt-call --out=json state.apply > file.").String()
+
+	configmgmtResourceStates = []string{"total", "changed", "failed", "skipped"}
+)
+
+// configmgmtRun is the summary of the last run of a configuration management
+// tool.
+type configmgmtRun struct {
+	timestamp time.Time
+	duration  float64
+	// resources maps the states of configmgmtResourceStates to the number of
+	// resources in them.
+	resources map[string]float64
+	// configVersion is the version of the applied catalog, if known.
+	configVersion string
+}
+
+type configmgmtCollector struct {
+	logger log.Logger
+
+	puppetSummary string
+	ansibleReport string
+	saltReport    string
+
+	lastRunDesc       *prometheus.Desc
+	durationDesc      *prometheus.Desc
+	resourcesDesc     *prometheus.Desc
+	configVersionDesc *prometheus.Desc
+}
+
+func init() {
+	registerCollector("configmgmt", defaultDisabled, NewConfigmgmtCollector)
+}
+
+// NewConfigmgmtCollector returns a new Collector exposing the last runs of
+// Puppet, Ansible and Salt.
+func NewConfigmgmtCollector(logger log.Logger) (Collector, error) {
+	return &configmgmtCollector{
+		logger:        logger,
+		puppetSummary: *configmgmtPuppetSummary,
+		ansibleReport: *configmgmtAnsibleReport,
+		saltReport:    *configmgmtSaltReport,
+		lastRunDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, configmgmtSubsystem, "last_run_timestamp_seconds"),
+			"Time of the last run of a configuration management tool.",
+			[]string{"tool"}, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, configmgmtSubsystem, "last_run_duration_seconds"),
+			"Duration of the last run of a configuration management tool.",
+			[]string{"tool"}, nil,
+		),
+		resourcesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, configmgmtSubsystem, "last_run_resources"),
+			"Number of resources of the last run of a configuration management tool, by state.",
+			[]string{"tool", "state"}, nil,
+		),
+		configVersionDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, configmgmtSubsystem, "last_run_config_version_info"),
+			"Version of the catalog applied by the last run of a configuration management tool.",
+			[]string{"tool", "version"}, nil,
+		),
+	}, nil
+}
+
+func (c *configmgmtCollector) Update(ch chan<- prometheus.Metric) error {
+	for _, tool := range []struct {
+		name  string
+		path  string
+		parse func([]byte, time.Time) (configmgmtRun, error)
+	}{
+		{"puppet", c.puppetSummary, parsePuppetSummary},
+		{"ansible", c.ansibleReport, parseAnsibleReport},
+		{"salt", c.saltReport, parseSaltReport},
+	} {
+		if tool.path == "" {
+			continue
+		}
+		path := rootfsFilePath(tool.path)
+		fi, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			level.Debug(c.logger).Log("msg", "no last run summary", "tool", tool.name, "path", tool.path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		run, err := tool.parse(data, fi.ModTime())
+		if err != nil {
+			return fmt.Errorf("failed to parse last run summary of %s in %s: %w", tool.name, tool.path, err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.lastRunDesc, prometheus.GaugeValue, float64(run.timestamp.UnixNano())/1e9, tool.name)
+		ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, run.duration, tool.name)
+		for _, state := range configmgmtResourceStates {
+			ch <- prometheus.MustNewConstMetric(c.resourcesDesc, prometheus.GaugeValue, run.resources[state], tool.name, state)
+		}
+		if run.configVersion != "" {
+			ch <- prometheus.MustNewConstMetric(c.configVersionDesc, prometheus.GaugeValue, 1, tool.name, run.configVersion)
+		}
+	}
+	return nil
+}
+
+// puppetSummary is the part of last_run_summary.yaml of the Puppet agent
+// exposed by the collector.
+type puppetSummary struct {
+	Version struct {
+		// Config is a timestamp by default, or the output of
+		// config_version of the environment.
+		Config interface{} `yaml:"config"`
+	} `yaml:"version"`
+	Resources map[string]float64 `yaml:"resources"`
+	Time      struct {
+		Total   float64 `yaml:"total"`
+		LastRun int64   `yaml:"last_run"`
+	} `yaml:"time"`
+}
+
+func parsePuppetSummary(data []byte, _ time.Time) (configmgmtRun, error) {
+	var summary puppetSummary
+	if err := yaml.Unmarshal(data, &summary); err != nil {
+		return configmgmtRun{}, err
+	}
+	if summary.Time.LastRun == 0 {
+		return configmgmtRun{}, errors.New("no last_run time")
+	}
+
+	run := configmgmtRun{
+		timestamp: time.Unix(summary.Time.LastRun, 0),
+		duration:  summary.Time.Total,
+		resources: map[string]float64{
+			"total":   summary.Resources["total"],
+			"changed": summary.Resources["changed"],
+			"failed":  summary.Resources["failed"] + summary.Resources["failed_to_restart"],
+			"skipped": summary.Resources["skipped"],
+		},
+	}
+	if summary.Version.Config != nil {
+		run.configVersion = fmt.Sprint(summary.Version.Config)
+	}
+	return run, nil
+}
+
+// ansibleReport is the part of the output of the json stdout callback of
+// Ansible exposed by the collector.
+type ansibleReport struct {
+	Plays []struct {
+		Play struct {
+			Duration struct {
+				Start time.Time `json:"start"`
+				End   time.Time `json:"end"`
+			} `json:"duration"`
+		} `json:"play"`
+	} `json:"plays"`
+	Stats map[string]struct {
+		Ok          float64 `json:"ok"`
+		Changed     float64 `json:"changed"`
+		Failures    float64 `json:"failures"`
+		Unreachable float64 `json:"unreachable"`
+		Skipped     float64 `json:"skipped"`
+	} `json:"stats"`
+}
+
+func parseAnsibleReport(data []byte, modTime time.Time) (configmgmtRun, error) {
+	var report ansibleReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return configmgmtRun{}, err
+	}
+	if report.Stats == nil {
+		return configmgmtRun{}, errors.New("no stats")
+	}
+
+	run := configmgmtRun{timestamp: modTime, resources: map[string]float64{}}
+	if n := len(report.Plays); n > 0 {
+		start, end := report.Plays[0].Play.Duration.Start, report.Plays[n-1].Play.Duration.End
+		if !end.IsZero() {
+			run.timestamp = end
+			run.duration = end.Sub(start).Seconds()
+		}
+	}
+	// ansible-pull runs against localhost, but reports of other
+	// inventories are summed up.
+	for _, stats := range report.Stats {
+		// Changed tasks are counted as ok too.
+		run.resources["total"] += stats.Ok + stats.Failures + stats.Unreachable + stats.Skipped
+		run.resources["changed"] += stats.Changed
+		run.resources["failed"] += stats.Failures + stats.Unreachable
+		run.resources["skipped"] += stats.Skipped
+	}
+	return run, nil
+}
+
+// saltState is the result of a state in the JSON output of salt-call.
+type saltState struct {
+	// Result is null for states run with test=True.
+	Result   *bool                  `json:"result"`
+	Changes  map[string]interface{} `json:"changes"`
+	Duration float64                `json:"duration"`
+}
+
+func parseSaltReport(data []byte, modTime time.Time) (configmgmtRun, error) {
+	var report map[string]json.RawMessage
+	if err := json.Unmarshal(data, &report); err != nil {
+		return configmgmtRun{}, err
+	}
+	if len(report) != 1 {
+		return configmgmtRun{}, fmt.Errorf("expected the output of one minion, got %d", len(report))
+	}
+
+	run := configmgmtRun{timestamp: modTime, resources: map[string]float64{}}
+	for _, raw := range report {
+		var states map[string]saltState
+		if err := json.Unmarshal(raw, &states); err != nil {
+			// Failures to compile the highstate are reported as a
+			// list of errors, counted as failed resources.
+			var errs []string
+			if json.Unmarshal(raw, &errs) != nil {
+				return configmgmtRun{}, err
+			}
+			run.resources["failed"] = float64(len(errs))
+			continue
+		}
+		for _, state := range states {
+			run.resources["total"]++
+			// Durations are in milliseconds.
+			run.duration += state.Duration / 1000
+			if len(state.Changes) > 0 {
+				run.resources["changed"]++
+			}
+			if state.Result != nil && !*state.Result {
+				run.resources["failed"]++
+			}
+		}
+	}
+	return run, nil
+}
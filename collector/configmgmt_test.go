@@ -0,0 +1,107 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noconfigmgmt
+// +build !noconfigmgmt
+
+package collector
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testConfigmgmtCollector struct {
+	c Collector
+}
+
+func (c testConfigmgmtCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c testConfigmgmtCollector) Collect(ch chan<- prometheus.Metric) {
+	c.c.Update(ch)
+}
+
+func TestConfigmgmtCollector(t *testing.T) {
+	defer func(rootfs, puppet, ansible, salt string) {
+		*rootfsPath, *configmgmtPuppetSummary, *configmgmtAnsibleReport, *configmgmtSaltReport = rootfs, puppet, ansible, salt
+	}(*rootfsPath, *configmgmtPuppetSummary, *configmgmtAnsibleReport, *configmgmtSaltReport)
+	*rootfsPath = "fixtures"
+	*configmgmtPuppetSummary = "/configmgmt/last_run_summary.yaml"
+	*configmgmtAnsibleReport = "/configmgmt/ansible.json"
+	*configmgmtSaltReport = "/configmgmt/missing.json"
+
+	want := `# HELP node_configmgmt_last_run_config_version_info Version of the catalog applied by the last run of a configuration management tool.
+# TYPE node_configmgmt_last_run_config_version_info gauge
+node_configmgmt_last_run_config_version_info{tool="puppet",version="production-8f3e2a1"} 1
+# HELP node_configmgmt_last_run_duration_seconds Duration of the last run of a configuration management tool.
+# TYPE node_configmgmt_last_run_duration_seconds gauge
+node_configmgmt_last_run_duration_seconds{tool="ansible"} 30.5
+node_configmgmt_last_run_duration_seconds{tool="puppet"} 11.5
+# HELP node_configmgmt_last_run_resources Number of resources of the last run of a configuration management tool, by state.
+# TYPE node_configmgmt_last_run_resources gauge
+node_configmgmt_last_run_resources{state="changed",tool="ansible"} 3
+node_configmgmt_last_run_resources{state="changed",tool="puppet"} 2
+node_configmgmt_last_run_resources{state="failed",tool="ansible"} 1
+node_configmgmt_last_run_resources{state="failed",tool="puppet"} 2
+node_configmgmt_last_run_resources{state="skipped",tool="ansible"} 5
+node_configmgmt_last_run_resources{state="skipped",tool="puppet"} 3
+node_configmgmt_last_run_resources{state="total",tool="ansible"} 26
+node_configmgmt_last_run_resources{state="total",tool="puppet"} 412
+# HELP node_configmgmt_last_run_timestamp_seconds Time of the last run of a configuration management tool.
+# TYPE node_configmgmt_last_run_timestamp_seconds gauge
+node_configmgmt_last_run_timestamp_seconds{tool="ansible"} 1.7025920305e+09
+node_configmgmt_last_run_timestamp_seconds{tool="puppet"} 1.702592e+09
+`
+	c, err := NewConfigmgmtCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(testConfigmgmtCollector{c}, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSaltReport(t *testing.T) {
+	data, err := os.ReadFile("fixtures/configmgmt/salt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Unix(1702592100, 0)
+	run, err := parseSaltReport(data, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := configmgmtRun{
+		timestamp: modTime,
+		duration:  2,
+		resources: map[string]float64{"total": 3, "changed": 1, "failed": 1},
+	}
+	if !reflect.DeepEqual(run, want) {
+		t.Errorf("want %+v, got %+v", want, run)
+	}
+
+	run, err = parseSaltReport([]byte(`{"local": ["Rendering SLS 'base:web' failed: mapping values are not allowed here"]}`), modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.resources["failed"] != 1 {
+		t.Errorf("want 1 failed resource for a highstate failing to render, got %v", run.resources["failed"])
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noconnectivity
+// +build !noconnectivity
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/godbus/dbus/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	connectivitySubsystem = "connectivity"
+
+	networkManagerBusName = "org.freedesktop.NetworkManager"
+	networkManagerPath    = "/org/freedesktop/NetworkManager"
+	networkdBusName       = "org.freedesktop.network1"
+	networkdPath          = "/org/freedesktop/network1"
+)
+
+var (
+	// Taken from NetworkManager 1.46 (NMConnectivityState) and
+	// systemd-networkd 255 (LinkOperationalState).
+	networkManagerConnectivityStates = []string{"unknown", "none", "portal", "limited", "full"}
+	networkdOperationalStates        = []string{"missing", "off", "no-carrier", "dormant", "degraded-carrier", "carrier", "degraded", "enslaved", "routable"}
+	// networkManagerDeviceStates maps NMDeviceState values to their names.
+	networkManagerDeviceStates = map[uint32]string{
+		0: "unknown", 10: "unmanaged", 20: "unavailable", 30: "disconnected", 40: "prepare", 50: "config",
+		60: "need-auth", 70: "ip-config", 80: "ip-check", 90: "secondaries", 100: "activated", 110: "deactivating", 120: "failed",
+	}
+	networkManagerDeviceStateNames = []string{"unknown", "unmanaged", "unavailable", "disconnected", "prepare", "config",
+		"need-auth", "ip-config", "ip-check", "secondaries", "activated", "deactivating", "failed"}
+
+	connectivityStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, connectivitySubsystem, "state"),
+		"Global connectivity state reported by the network manager.", []string{"manager", "state"}, nil,
+	)
+	connectivityLinkStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, connectivitySubsystem, "link_state"),
+		"State of a link reported by the network manager.", []string{"manager", "device", "state"}, nil,
+	)
+	connectivityAddressPresentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, connectivitySubsystem, "link_address_present"),
+		"Whether an address configured on a link by the network manager is present on the link in the kernel.", []string{"manager", "device", "address"}, nil,
+	)
+)
+
+type connectivityCollector struct {
+	logger log.Logger
+}
+
+// connectivityLink is a link managed by a network manager.
+type connectivityLink struct {
+	device string
+	state  string
+	// configured are the statically configured addresses of the link, in
+	// CIDR notation.
+	configured []string
+}
+
+// networkManagerInterface is implemented by the network managers queried
+// over D-Bus.
+type networkManagerInterface interface {
+	// name returns the name of the network manager used as manager label.
+	name() string
+	// states returns the possible global and link states.
+	states() (global, link []string)
+	globalState() (string, error)
+	links() ([]connectivityLink, error)
+}
+
+func init() {
+	registerCollector("connectivity", defaultDisabled, NewConnectivityCollector)
+}
+
+// NewConnectivityCollector returns a new Collector exposing the state of the
+// links managed by NetworkManager or systemd-networkd.
+func NewConnectivityCollector(logger log.Logger) (Collector, error) {
+	return &connectivityCollector{logger}, nil
+}
+
+func (c *connectivityCollector) Update(ch chan<- prometheus.Metric) error {
+	conn, err := newConnectivityDbus()
+	if err != nil {
+		return fmt.Errorf("unable to connect to dbus: %w", err)
+	}
+	defer conn.Close()
+
+	var managers []networkManagerInterface
+	for _, m := range []struct {
+		manager networkManagerInterface
+		busName string
+	}{
+		{&networkManagerDbus{conn: conn}, networkManagerBusName},
+		{&networkdDbus{conn: conn}, networkdBusName},
+	} {
+		var running bool
+		if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, m.busName).Store(&running); err != nil {
+			return fmt.Errorf("unable to look up %s: %w", m.busName, err)
+		}
+		if running {
+			managers = append(managers, m.manager)
+		}
+	}
+	if len(managers) == 0 {
+		level.Debug(c.logger).Log("msg", "neither NetworkManager nor systemd-networkd is running")
+		return ErrNoData
+	}
+
+	for _, m := range managers {
+		if err := collectConnectivityMetrics(ch, m, kernelLinkAddresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectConnectivityMetrics exposes the states of a network manager and its
+// links, and checks the configured addresses of the links against the
+// addresses returned by kernelAddresses.
+func collectConnectivityMetrics(ch chan<- prometheus.Metric, m networkManagerInterface, kernelAddresses func(device string) (map[string]bool, error)) error {
+	globalStates, linkStates := m.states()
+
+	state, err := m.globalState()
+	if err != nil {
+		return fmt.Errorf("unable to get %s state: %w", m.name(), err)
+	}
+	for _, s := range stateSet(globalStates, state) {
+		ch <- prometheus.MustNewConstMetric(connectivityStateDesc, prometheus.GaugeValue, boolToFloat(s == state), m.name(), s)
+	}
+
+	links, err := m.links()
+	if err != nil {
+		return fmt.Errorf("unable to get %s links: %w", m.name(), err)
+	}
+	for _, link := range links {
+		for _, s := range stateSet(linkStates, link.state) {
+			ch <- prometheus.MustNewConstMetric(connectivityLinkStateDesc, prometheus.GaugeValue, boolToFloat(s == link.state), m.name(), link.device, s)
+		}
+		if len(link.configured) == 0 {
+			continue
+		}
+		present, err := kernelAddresses(link.device)
+		if err != nil {
+			// The link may be configured but missing.
+			present = map[string]bool{}
+		}
+		for _, address := range link.configured {
+			ch <- prometheus.MustNewConstMetric(connectivityAddressPresentDesc, prometheus.GaugeValue, boolToFloat(present[address]), m.name(), link.device, address)
+		}
+	}
+	return nil
+}
+
+// stateSet returns the known states, and the current one if it is unknown.
+func stateSet(known []string, current string) []string {
+	for _, s := range known {
+		if s == current {
+			return known
+		}
+	}
+	return append(append([]string(nil), known...), current)
+}
+
+// kernelLinkAddresses returns the addresses of a link in CIDR notation.
+func kernelLinkAddresses(device string) (map[string]bool, error) {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		present[addr.String()] = true
+	}
+	return present, nil
+}
+
+// normalizeCIDR formats an address and prefix length the way the kernel
+// addresses are formatted.
+func normalizeCIDR(address string, prefix int) (string, bool) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", false
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	ipNet := net.IPNet{IP: ip, Mask: net.CIDRMask(prefix, bits)}
+	return ipNet.String(), true
+}
+
+func newConnectivityDbus() (*dbus.Conn, error) {
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// networkManagerDbus queries NetworkManager.
+type networkManagerDbus struct {
+	conn *dbus.Conn
+}
+
+func (n *networkManagerDbus) name() string { return "networkmanager" }
+
+func (n *networkManagerDbus) states() ([]string, []string) {
+	return networkManagerConnectivityStates, networkManagerDeviceStateNames
+}
+
+func (n *networkManagerDbus) globalState() (string, error) {
+	v, err := n.conn.Object(networkManagerBusName, networkManagerPath).GetProperty(networkManagerBusName + ".Connectivity")
+	if err != nil {
+		return "", err
+	}
+	state, ok := v.Value().(uint32)
+	if !ok || int(state) >= len(networkManagerConnectivityStates) {
+		return fmt.Sprint(v.Value()), nil
+	}
+	return networkManagerConnectivityStates[state], nil
+}
+
+func (n *networkManagerDbus) links() ([]connectivityLink, error) {
+	var devices []dbus.ObjectPath
+	if err := n.conn.Object(networkManagerBusName, networkManagerPath).Call(networkManagerBusName+".GetDevices", 0).Store(&devices); err != nil {
+		return nil, err
+	}
+
+	links := make([]connectivityLink, 0, len(devices))
+	for _, path := range devices {
+		device := n.conn.Object(networkManagerBusName, path)
+		iface, err := device.GetProperty(networkManagerBusName + ".Device.Interface")
+		if err != nil {
+			return nil, err
+		}
+		stateValue, err := device.GetProperty(networkManagerBusName + ".Device.State")
+		if err != nil {
+			return nil, err
+		}
+		link := connectivityLink{device: fmt.Sprint(iface.Value())}
+		state, _ := stateValue.Value().(uint32)
+		if name, ok := networkManagerDeviceStates[state]; ok {
+			link.state = name
+		} else {
+			link.state = strconv.FormatUint(uint64(state), 10)
+		}
+
+		// Only activated devices have an applied connection.
+		var (
+			settings map[string]map[string]dbus.Variant
+			version  uint64
+		)
+		if err := device.Call(networkManagerBusName+".Device.GetAppliedConnection", 0, uint32(0)).Store(&settings, &version); err == nil {
+			link.configured = networkManagerAddresses(settings)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// networkManagerAddresses returns the static addresses of the ipv4 and ipv6
+// settings of a connection.
+func networkManagerAddresses(settings map[string]map[string]dbus.Variant) []string {
+	var addresses []string
+	for _, family := range []string{"ipv4", "ipv6"} {
+		data, ok := settings[family]["address-data"].Value().([]map[string]dbus.Variant)
+		if !ok {
+			continue
+		}
+		for _, a := range data {
+			address, _ := a["address"].Value().(string)
+			prefix, _ := a["prefix"].Value().(uint32)
+			if cidr, ok := normalizeCIDR(address, int(prefix)); ok {
+				addresses = append(addresses, cidr)
+			}
+		}
+	}
+	return addresses
+}
+
+// networkdDbus queries systemd-networkd.
+type networkdDbus struct {
+	conn *dbus.Conn
+}
+
+func (n *networkdDbus) name() string { return "networkd" }
+
+func (n *networkdDbus) states() ([]string, []string) {
+	return networkdOperationalStates, networkdOperationalStates
+}
+
+func (n *networkdDbus) globalState() (string, error) {
+	v, err := n.conn.Object(networkdBusName, networkdPath).GetProperty(networkdBusName + ".Manager.OperationalState")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v.Value()), nil
+}
+
+// networkdLinkEntry is an entry of ListLinks. Struct elements must be public
+// for the reflection magic of godbus to work.
+type networkdLinkEntry struct {
+	Index int32
+	Name  string
+	Path  dbus.ObjectPath
+}
+
+func (n *networkdDbus) links() ([]connectivityLink, error) {
+	var entries []networkdLinkEntry
+	if err := n.conn.Object(networkdBusName, networkdPath).Call(networkdBusName+".Manager.ListLinks", 0).Store(&entries); err != nil {
+		return nil, err
+	}
+
+	links := make([]connectivityLink, 0, len(entries))
+	for _, entry := range entries {
+		object := n.conn.Object(networkdBusName, entry.Path)
+		state, err := object.GetProperty(networkdBusName + ".Link.OperationalState")
+		if err != nil {
+			return nil, err
+		}
+		link := connectivityLink{device: entry.Name, state: fmt.Sprint(state.Value())}
+
+		// Describe is only available since systemd 250.
+		var description string
+		if err := object.Call(networkdBusName+".Link.Describe", 0).Store(&description); err == nil {
+			link.configured, err = networkdAddresses(description)
+			if err != nil {
+				return nil, fmt.Errorf("invalid description of link %s: %w", entry.Name, err)
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// networkdLinkDescription is the part of the JSON description of a link
+// holding its addresses.
+type networkdLinkDescription struct {
+	Addresses []struct {
+		// Address is an array of the bytes of the address.
+		Address      []int  `json:"Address"`
+		PrefixLength int    `json:"PrefixLength"`
+		ConfigSource string `json:"ConfigSource"`
+	} `json:"Addresses"`
+}
+
+// networkdAddresses returns the static addresses of the JSON description of
+// a link.
+func networkdAddresses(description string) ([]string, error) {
+	var d networkdLinkDescription
+	if err := json.Unmarshal([]byte(description), &d); err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, a := range d.Addresses {
+		if a.ConfigSource != "static" {
+			continue
+		}
+		ip := make(net.IP, len(a.Address))
+		for i, b := range a.Address {
+			ip[i] = byte(b)
+		}
+		if cidr, ok := normalizeCIDR(ip.String(), a.PrefixLength); ok {
+			addresses = append(addresses, cidr)
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noconnectivity
+// +build !noconnectivity
+
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testNetworkManager struct{}
+
+func (testNetworkManager) name() string { return "networkd" }
+
+func (testNetworkManager) states() ([]string, []string) {
+	return []string{"degraded", "routable"}, []string{"no-carrier", "routable"}
+}
+
+func (testNetworkManager) globalState() (string, error) { return "degraded", nil }
+
+func (testNetworkManager) links() ([]connectivityLink, error) {
+	return []connectivityLink{
+		{device: "eth0", state: "routable", configured: []string{"10.0.0.5/24", "10.0.0.6/24"}},
+		{device: "eth1", state: "configuring"},
+	}, nil
+}
+
+type testConnectivityCollector struct {
+	manager networkManagerInterface
+}
+
+func (c testConnectivityCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c testConnectivityCollector) Collect(ch chan<- prometheus.Metric) {
+	collectConnectivityMetrics(ch, c.manager, func(device string) (map[string]bool, error) {
+		return map[string]bool{"10.0.0.5/24": true, "fe80::1/64": true}, nil
+	})
+}
+
+func TestConnectivityCollectMetrics(t *testing.T) {
+	want := `# HELP node_connectivity_link_address_present Whether an address configured on a link by the network manager is present on the link in the kernel.
+# TYPE node_connectivity_link_address_present gauge
+node_connectivity_link_address_present{address="10.0.0.5/24",device="eth0",manager="networkd"} 1
+node_connectivity_link_address_present{address="10.0.0.6/24",device="eth0",manager="networkd"} 0
+# HELP node_connectivity_link_state State of a link reported by the network manager.
+# TYPE node_connectivity_link_state gauge
+node_connectivity_link_state{device="eth0",manager="networkd",state="no-carrier"} 0
+node_connectivity_link_state{device="eth0",manager="networkd",state="routable"} 1
+node_connectivity_link_state{device="eth1",manager="networkd",state="configuring"} 1
+node_connectivity_link_state{device="eth1",manager="networkd",state="no-carrier"} 0
+node_connectivity_link_state{device="eth1",manager="networkd",state="routable"} 0
+# HELP node_connectivity_state Global connectivity state reported by the network manager.
+# TYPE node_connectivity_state gauge
+node_connectivity_state{manager="networkd",state="degraded"} 1
+node_connectivity_state{manager="networkd",state="routable"} 0
+`
+	if err := testutil.CollectAndCompare(testConnectivityCollector{testNetworkManager{}}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetworkManagerAddresses(t *testing.T) {
+	settings := map[string]map[string]dbus.Variant{
+		"ipv4": {"address-data": dbus.MakeVariant([]map[string]dbus.Variant{
+			{"address": dbus.MakeVariant("10.0.0.5"), "prefix": dbus.MakeVariant(uint32(24))},
+		})},
+		"ipv6": {"address-data": dbus.MakeVariant([]map[string]dbus.Variant{
+			{"address": dbus.MakeVariant("2001:db8::5"), "prefix": dbus.MakeVariant(uint32(64))},
+		})},
+	}
+	want := []string{"10.0.0.5/24", "2001:db8::5/64"}
+	if got := networkManagerAddresses(settings); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNetworkdAddresses(t *testing.T) {
+	description := `{"Index": 2, "Name": "eth0", "Addresses": [
+		{"Family": 2, "Address": [10, 0, 0, 5], "PrefixLength": 24, "ConfigSource": "static", "ConfigState": "configured"},
+		{"Family": 2, "Address": [10, 0, 0, 99], "PrefixLength": 24, "ConfigSource": "DHCPv4", "ConfigState": "configured"},
+		{"Family": 10, "Address": [32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5], "PrefixLength": 64, "ConfigSource": "static"}
+	]}`
+	got, err := networkdAddresses(description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.5/24", "2001:db8::5/64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
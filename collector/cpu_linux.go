@@ -23,6 +23,7 @@ import (
 	"regexp"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -45,10 +46,14 @@ type cpuCollector struct {
 	cpuCoreThrottle    *prometheus.Desc
 	cpuPackageThrottle *prometheus.Desc
 	cpuIsolated        *prometheus.Desc
+	cpuTopology        *prometheus.Desc
 	logger             log.Logger
 	cpuStats           map[int64]procfs.CPUStat
 	cpuStatsMutex      sync.Mutex
 	isolatedCpus       []uint16
+	enableInfo         bool
+	enableTopology     bool
+	enableGuest        bool
 
 	cpuFlagsIncludeRegexp *regexp.Regexp
 	cpuBugsIncludeRegexp  *regexp.Regexp
@@ -60,6 +65,7 @@ const jumpBackSeconds = 3.0
 var (
 	enableCPUGuest       = kingpin.Flag("collector.cpu.guest", "Enables metric node_cpu_guest_seconds_total").Default("true").Bool()
 	enableCPUInfo        = kingpin.Flag("collector.cpu.info", "Enables metric cpu_info").Bool()
+	enableCPUTopology    = kingpin.Flag("collector.cpu.topology", "Enables metric node_cpu_topology_info").Bool()
 	flagsInclude         = kingpin.Flag("collector.cpu.info.flags-include", "Filter the `flags` field in cpuInfo with a value that must be a regular expression").String()
 	bugsInclude          = kingpin.Flag("collector.cpu.info.bugs-include", "Filter the `bugs` field in cpuInfo with a value that must be a regular expression").String()
 	jumpBackDebugMessage = fmt.Sprintf("CPU Idle counter jumped backwards more than %f seconds, possible hotplug event, resetting CPU stats", jumpBackSeconds)
@@ -132,9 +138,17 @@ func NewCPUCollector(logger log.Logger) (Collector, error) {
 			"Whether each core is isolated, information from /sys/devices/system/cpu/isolated.",
 			[]string{"cpu"}, nil,
 		),
-		logger:       logger,
-		isolatedCpus: isolcpus,
-		cpuStats:     make(map[int64]procfs.CPUStat),
+		cpuTopology: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cpuCollectorSubsystem, "topology_info"),
+			"Package, die, cluster and core of each CPU from /sys/devices/system/cpu/cpu*/topology, empty if not reported by the kernel.",
+			[]string{"cpu", "package", "die", "cluster", "core"}, nil,
+		),
+		logger:         logger,
+		isolatedCpus:   isolcpus,
+		cpuStats:       make(map[int64]procfs.CPUStat),
+		enableInfo:     *enableCPUInfo,
+		enableTopology: *enableCPUTopology,
+		enableGuest:    *enableCPUGuest,
 	}
 	err = c.compileIncludeFlags(flagsInclude, bugsInclude)
 	if err != nil {
@@ -144,8 +158,8 @@ func NewCPUCollector(logger log.Logger) (Collector, error) {
 }
 
 func (c *cpuCollector) compileIncludeFlags(flagsIncludeFlag, bugsIncludeFlag *string) error {
-	if (*flagsIncludeFlag != "" || *bugsIncludeFlag != "") && !*enableCPUInfo {
-		*enableCPUInfo = true
+	if (*flagsIncludeFlag != "" || *bugsIncludeFlag != "") && !c.enableInfo {
+		c.enableInfo = true
 		level.Info(c.logger).Log("msg", "--collector.cpu.info has been set to `true` because you set the following flags, like --collector.cpu.info.flags-include and --collector.cpu.info.bugs-include")
 	}
 
@@ -167,7 +181,7 @@ func (c *cpuCollector) compileIncludeFlags(flagsIncludeFlag, bugsIncludeFlag *st
 
 // Update implements Collector and exposes cpu related metrics from /proc/stat and /sys/.../cpu/.
 func (c *cpuCollector) Update(ch chan<- prometheus.Metric) error {
-	if *enableCPUInfo {
+	if c.enableInfo {
 		if err := c.updateInfo(ch); err != nil {
 			return err
 		}
@@ -178,6 +192,11 @@ func (c *cpuCollector) Update(ch chan<- prometheus.Metric) error {
 	if c.isolatedCpus != nil {
 		c.updateIsolated(ch)
 	}
+	if c.enableTopology {
+		if err := c.updateTopology(ch); err != nil {
+			return err
+		}
+	}
 	return c.updateThermalThrottle(ch)
 }
 
@@ -193,7 +212,7 @@ func (c *cpuCollector) updateInfo(ch chan<- prometheus.Metric) error {
 			1,
 			cpu.PhysicalID,
 			cpu.CoreID,
-			strconv.Itoa(int(cpu.Processor)),
+			itoaLabel(int(cpu.Processor)),
 			cpu.VendorID,
 			cpu.CPUFamily,
 			cpu.Model,
@@ -213,7 +232,7 @@ func (c *cpuCollector) updateInfo(ch chan<- prometheus.Metric) error {
 				cpu.CPUMHz*1e6,
 				cpu.PhysicalID,
 				cpu.CoreID,
-				strconv.Itoa(int(cpu.Processor)))
+				itoaLabel(int(cpu.Processor)))
 		}
 	}
 
@@ -248,6 +267,34 @@ func updateFieldInfo(valueList []string, filter *regexp.Regexp, desc *prometheus
 	return nil
 }
 
+// updateTopology reads the topology of each CPU from
+// /sys/devices/system/cpu/cpu*/topology, which tells the dies (chiplets) and
+// clusters of a package apart on multi-die CPUs.
+// https://www.kernel.org/doc/Documentation/cputopology.txt
+func (c *cpuCollector) updateTopology(ch chan<- prometheus.Metric) error {
+	cpus, err := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*"))
+	if err != nil {
+		return err
+	}
+
+	for _, cpu := range cpus {
+		// die_id and cluster_id are missing on older kernels and some
+		// architectures.
+		ids := make([]string, 0, 4)
+		for _, file := range []string{"physical_package_id", "die_id", "cluster_id", "core_id"} {
+			id := ""
+			if value, err := readUintFromFile(filepath.Join(cpu, "topology", file)); err == nil {
+				id = strconv.FormatUint(value, 10)
+			}
+			ids = append(ids, id)
+		}
+		cpuNum := strings.TrimPrefix(filepath.Base(cpu), "cpu")
+		ch <- prometheus.MustNewConstMetric(c.cpuTopology, prometheus.GaugeValue, 1, cpuNum, ids[0], ids[1], ids[2], ids[3])
+	}
+
+	return nil
+}
+
 // updateThermalThrottle reads /sys/devices/system/cpu/cpu* and expose thermal throttle statistics.
 func (c *cpuCollector) updateThermalThrottle(ch chan<- prometheus.Metric) error {
 	cpus, err := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*"))
@@ -329,7 +376,7 @@ func (c *cpuCollector) updateThermalThrottle(ch chan<- prometheus.Metric) error
 // updateIsolated reads /sys/devices/system/cpu/isolated through sysfs and exports isolation level metrics.
 func (c *cpuCollector) updateIsolated(ch chan<- prometheus.Metric) {
 	for _, cpu := range c.isolatedCpus {
-		cpuNum := strconv.Itoa(int(cpu))
+		cpuNum := itoaLabel(int(cpu))
 		ch <- prometheus.MustNewConstMetric(c.cpuIsolated, prometheus.GaugeValue, 1.0, cpuNum)
 	}
 }
@@ -347,7 +394,7 @@ func (c *cpuCollector) updateStat(ch chan<- prometheus.Metric) error {
 	c.cpuStatsMutex.Lock()
 	defer c.cpuStatsMutex.Unlock()
 	for cpuID, cpuStat := range c.cpuStats {
-		cpuNum := strconv.Itoa(int(cpuID))
+		cpuNum := itoaLabel(int(cpuID))
 		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpuStat.User, cpuNum, "user")
 		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpuStat.Nice, cpuNum, "nice")
 		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpuStat.System, cpuNum, "system")
@@ -357,7 +404,7 @@ func (c *cpuCollector) updateStat(ch chan<- prometheus.Metric) error {
 		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpuStat.SoftIRQ, cpuNum, "softirq")
 		ch <- prometheus.MustNewConstMetric(c.cpu, prometheus.CounterValue, cpuStat.Steal, cpuNum, "steal")
 
-		if *enableCPUGuest {
+		if c.enableGuest {
 			// Guest CPU is also accounted for in cpuStat.User and cpuStat.Nice, expose these as separate metrics.
 			ch <- prometheus.MustNewConstMetric(c.cpuGuest, prometheus.CounterValue, cpuStat.Guest, cpuNum, "user")
 			ch <- prometheus.MustNewConstMetric(c.cpuGuest, prometheus.CounterValue, cpuStat.GuestNice, cpuNum, "nice")
@@ -14,12 +14,23 @@
 package collector
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
 )
 
 type deviceFilter struct {
 	ignorePattern *regexp.Regexp
 	acceptPattern *regexp.Regexp
+	ignoreList    *deviceList
+	acceptList    *deviceList
 }
 
 func newDeviceFilter(ignoredPattern, acceptPattern string) (f deviceFilter) {
@@ -34,8 +45,123 @@ func newDeviceFilter(ignoredPattern, acceptPattern string) (f deviceFilter) {
 	return
 }
 
+// withListFiles returns a copy of the filter that also ignores the devices
+// listed in ignoredFile and the devices missing from acceptFile. The files
+// are re-read when the collectors are reloaded.
+func (f deviceFilter) withListFiles(ignoredFile, acceptFile string) (deviceFilter, error) {
+	var err error
+	if ignoredFile != "" {
+		if f.ignoreList, err = openDeviceList(ignoredFile); err != nil {
+			return deviceFilter{}, err
+		}
+	}
+	if acceptFile != "" {
+		if f.acceptList, err = openDeviceList(acceptFile); err != nil {
+			return deviceFilter{}, err
+		}
+	}
+	return f, nil
+}
+
 // ignored returns whether the device should be ignored
 func (f *deviceFilter) ignored(name string) bool {
 	return (f.ignorePattern != nil && f.ignorePattern.MatchString(name)) ||
-		(f.acceptPattern != nil && !f.acceptPattern.MatchString(name))
+		(f.acceptPattern != nil && !f.acceptPattern.MatchString(name)) ||
+		(f.ignoreList != nil && f.ignoreList.contains(name)) ||
+		(f.acceptList != nil && !f.acceptList.contains(name))
+}
+
+// deviceListFileFlags registers the flags of the files listing the devices to
+// exclude or include for a collector, e.g.
+// --collector.netdev.device-exclude-file.
+func deviceListFileFlags(collector, kind, devices string) (exclude, include *string) {
+	exclude = kingpin.Flag(fmt.Sprintf("collector.%s.%s-exclude-file", collector, kind),
+		fmt.Sprintf("File listing %s to exclude, one per line. Re-read on reload.", devices)).String()
+	include = kingpin.Flag(fmt.Sprintf("collector.%s.%s-include-file", collector, kind),
+		fmt.Sprintf("File listing %s to include, one per line. Re-read on reload.", devices)).String()
+	return exclude, include
+}
+
+// deviceList is a set of device names read from a file.
+type deviceList struct {
+	path  string
+	mtx   sync.RWMutex
+	names map[string]struct{}
+}
+
+// deviceLists holds the lists opened by the collectors by path, so that a
+// file used by several collectors is read once.
+var deviceLists = struct {
+	sync.Mutex
+	lists map[string]*deviceList
+}{lists: map[string]*deviceList{}}
+
+func openDeviceList(path string) (*deviceList, error) {
+	deviceLists.Lock()
+	defer deviceLists.Unlock()
+
+	if l, ok := deviceLists.lists[path]; ok {
+		return l, nil
+	}
+	l := &deviceList{path: path}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	deviceLists.lists[path] = l
+	return l, nil
+}
+
+// reloadDeviceLists re-reads all device list files. A list that fails to load
+// keeps its previous content.
+func reloadDeviceLists() error {
+	deviceLists.Lock()
+	defer deviceLists.Unlock()
+
+	var errs []error
+	for _, l := range deviceLists.lists {
+		if err := l.load(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l *deviceList) load() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open device list: %w", err)
+	}
+	defer f.Close()
+
+	names, err := parseDeviceList(f)
+	if err != nil {
+		return fmt.Errorf("failed to read device list %q: %w", l.path, err)
+	}
+
+	l.mtx.Lock()
+	l.names = names
+	l.mtx.Unlock()
+	return nil
+}
+
+func (l *deviceList) contains(name string) bool {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	_, ok := l.names[name]
+	return ok
+}
+
+// parseDeviceList reads one device name per line. Empty lines and lines
+// starting with # are skipped.
+func parseDeviceList(r io.Reader) (map[string]struct{}, error) {
+	names := map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[line] = struct{}{}
+	}
+	return names, scanner.Err()
 }
@@ -14,6 +14,8 @@
 package collector
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -41,3 +43,46 @@ func TestDeviceFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestDeviceFilterListFiles(t *testing.T) {
+	dir := t.TempDir()
+	ignoreFile := filepath.Join(dir, "exclude")
+	acceptFile := filepath.Join(dir, "include")
+	if err := os.WriteFile(ignoreFile, []byte("# storage network\neth1\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(acceptFile, []byte("eth0\neth1\n  eth2  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := newDeviceFilter("^veth", "").withListFiles(ignoreFile, acceptFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]bool{
+		"eth0":  false,
+		"eth1":  true,
+		"eth2":  false,
+		"eth3":  true,
+		"veth0": true,
+	} {
+		if result := filter.ignored(name); result != expected {
+			t.Errorf("name=%v expected=%v result=%v", name, expected, result)
+		}
+	}
+
+	if err := os.WriteFile(ignoreFile, []byte("eth2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadDeviceLists(); err != nil {
+		t.Fatal(err)
+	}
+	if filter.ignored("eth1") || !filter.ignored("eth2") {
+		t.Errorf("device lists not reloaded")
+	}
+
+	if _, err := newDeviceFilter("", "").withListFiles(filepath.Join(dir, "missing"), ""); err == nil {
+		t.Errorf("expected error for missing list file")
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nodhcp
+// +build !nodhcp
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	dhcpLeasePaths = kingpin.Flag("collector.dhcp.lease-path",
+		"Glob of the DHCP lease files of dhclient, NetworkManager and systemd-networkd, relative to --path.rootfs. Can be repeated.").
+		Default("/var/lib/dhcp/*.leases", "/var/lib/dhclient/*.lease*", "/var/lib/NetworkManager/*.lease", "/run/systemd/netif/leases/*").Strings()
+)
+
+const (
+	dhcpSourceDhclient       = "dhclient"
+	dhcpSourceNetworkd       = "networkd"
+	dhcpSourceNetworkManager = "networkmanager"
+
+	// infiniteLifetime is the lifetime of addresses and routes that don't
+	// expire.
+	infiniteLifetime = 0xffffffff
+	// userHZ is the unit of the route expiry times reported by the kernel.
+	userHZ = 100
+)
+
+type dhcpCollector struct {
+	leaseExpiry       *prometheus.Desc
+	leaseRenew        *prometheus.Desc
+	routerLifetime    *prometheus.Desc
+	validLifetime     *prometheus.Desc
+	preferredLifetime *prometheus.Desc
+	leasePaths        []string
+	logger            log.Logger
+}
+
+// dhcpLease is the IPv4 lease of an interface.
+type dhcpLease struct {
+	iface   string
+	address string
+	source  string
+	renew   time.Time
+	expiry  time.Time
+}
+
+func init() {
+	registerCollector("dhcp", defaultDisabled, NewDHCPCollector)
+}
+
+// NewDHCPCollector returns a new Collector exposing the expiry of DHCP leases
+// and the lifetimes of IPv6 router advertisements.
+func NewDHCPCollector(logger log.Logger) (Collector, error) {
+	return &dhcpCollector{
+		leaseExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "dhcp", "lease_expiry_timestamp_seconds"),
+			"Expiry of the DHCP lease of an interface in seconds since epoch.",
+			[]string{"device", "address", "source"}, nil,
+		),
+		leaseRenew: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "dhcp", "lease_renew_timestamp_seconds"),
+			"Time at which the DHCP client renews the lease of an interface in seconds since epoch.",
+			[]string{"device", "address", "source"}, nil,
+		),
+		routerLifetime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "network", "ipv6_router_lifetime_seconds"),
+			"Remaining lifetime of a default router learned from IPv6 router advertisements.",
+			[]string{"device", "router"}, nil,
+		),
+		validLifetime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "network", "ipv6_address_valid_lifetime_seconds"),
+			"Remaining valid lifetime of an IPv6 address with a finite lifetime, such as one autoconfigured from router advertisements.",
+			[]string{"device", "address"}, nil,
+		),
+		preferredLifetime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "network", "ipv6_address_preferred_lifetime_seconds"),
+			"Remaining preferred lifetime of an IPv6 address with a finite lifetime, such as one autoconfigured from router advertisements.",
+			[]string{"device", "address"}, nil,
+		),
+		leasePaths: *dhcpLeasePaths,
+		logger:     logger,
+	}, nil
+}
+
+func (c *dhcpCollector) Update(ch chan<- prometheus.Metric) error {
+	for _, lease := range c.leases() {
+		if !lease.expiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.leaseExpiry, prometheus.GaugeValue, float64(lease.expiry.Unix()), lease.iface, lease.address, lease.source)
+		}
+		if !lease.renew.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.leaseRenew, prometheus.GaugeValue, float64(lease.renew.Unix()), lease.iface, lease.address, lease.source)
+		}
+	}
+	return c.updateIPv6Lifetimes(ch)
+}
+
+// leases reads the lease files. Unreadable files are skipped, as lease
+// directories are often only partly readable by unprivileged users.
+func (c *dhcpCollector) leases() []dhcpLease {
+	var leases []dhcpLease
+	for _, pattern := range c.leasePaths {
+		paths, err := filepath.Glob(rootfsFilePath(pattern))
+		if err != nil {
+			level.Warn(c.logger).Log("msg", "invalid lease path", "path", pattern, "err", err)
+			continue
+		}
+		for _, path := range paths {
+			fileLeases, err := readDHCPLeaseFile(path)
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "failed to read lease file", "path", path, "err", err)
+				continue
+			}
+			leases = append(leases, fileLeases...)
+		}
+	}
+	return leases
+}
+
+// readDHCPLeaseFile reads a dhclient lease file, or a systemd-networkd lease
+// file as also written by the internal DHCP client of NetworkManager.
+func readDHCPLeaseFile(path string) ([]dhcpLease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(string(data), "lease {") {
+		return parseDhclientLeases(string(data))
+	}
+
+	name := filepath.Base(path)
+	source, iface := dhcpSourceNetworkd, name
+	if strings.HasSuffix(name, ".lease") {
+		// NetworkManager names lease files internal-<uuid>-<interface>.lease.
+		source = dhcpSourceNetworkManager
+		iface = strings.TrimSuffix(name, ".lease")
+		if i := strings.LastIndex(iface, "-"); i >= 0 {
+			iface = iface[i+1:]
+		}
+	} else if index, err := strconv.Atoi(name); err == nil {
+		// systemd-networkd names lease files by interface index.
+		if i, err := net.InterfaceByIndex(index); err == nil {
+			iface = i.Name
+		}
+	}
+	lease, err := parseNetworkdLease(string(data), info.ModTime())
+	if err != nil || lease == nil {
+		return nil, err
+	}
+	lease.iface, lease.source = iface, source
+	return []dhcpLease{*lease}, nil
+}
+
+// parseDhclientLeases parses the lease blocks of a dhclient lease file, which
+// appends a block on every renewal. The last lease of every interface is
+// returned.
+func parseDhclientLeases(data string) ([]dhcpLease, error) {
+	var (
+		leases  []dhcpLease
+		byIface = map[string]int{}
+		current *dhcpLease
+	)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "lease {":
+			current = &dhcpLease{source: dhcpSourceDhclient}
+		case current == nil:
+		case line == "}":
+			if current.iface != "" {
+				if i, ok := byIface[current.iface]; ok {
+					leases[i] = *current
+				} else {
+					byIface[current.iface] = len(leases)
+					leases = append(leases, *current)
+				}
+			}
+			current = nil
+		default:
+			key, value, _ := strings.Cut(strings.TrimSuffix(line, ";"), " ")
+			var err error
+			switch key {
+			case "interface":
+				current.iface = strings.Trim(value, `"`)
+			case "fixed-address":
+				current.address = value
+			case "renew":
+				current.renew, err = parseDhclientTime(value)
+			case "expire":
+				current.expiry, err = parseDhclientTime(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s time %q: %w", key, value, err)
+			}
+		}
+	}
+	return leases, scanner.Err()
+}
+
+// parseDhclientTime parses a dhclient lease time, either "<weekday>
+// yyyy/mm/dd hh:mm:ss" in UTC, "epoch <seconds>; # <comment>", or "never",
+// for which the zero time is returned.
+func parseDhclientTime(value string) (time.Time, error) {
+	if value == "never" {
+		return time.Time{}, nil
+	}
+	fields := strings.Fields(value)
+	if len(fields) >= 2 && fields[0] == "epoch" {
+		seconds, err := strconv.ParseInt(strings.TrimSuffix(fields[1], ";"), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	if len(fields) != 3 {
+		return time.Time{}, errors.New("unexpected format")
+	}
+	return time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2])
+}
+
+// parseNetworkdLease parses a systemd-networkd lease file, whose lifetime
+// counts from when the file was written. Files without an address or a
+// lifetime are ignored.
+func parseNetworkdLease(data string, written time.Time) (*dhcpLease, error) {
+	var (
+		lease           dhcpLease
+		lifetime, renew string
+	)
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "ADDRESS":
+			lease.address = value
+		case "LIFETIME":
+			lifetime = value
+		case "T1":
+			renew = value
+		}
+	}
+	if lease.address == "" || lifetime == "" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseUint(lifetime, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lifetime %q: %w", lifetime, err)
+	}
+	if seconds != infiniteLifetime {
+		lease.expiry = written.Add(time.Duration(seconds) * time.Second)
+	}
+	if renew != "" {
+		seconds, err := strconv.ParseUint(renew, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid T1 %q: %w", renew, err)
+		}
+		lease.renew = written.Add(time.Duration(seconds) * time.Second)
+	}
+	return &lease, nil
+}
+
+// updateIPv6Lifetimes exposes the remaining lifetimes of the default routers
+// and addresses learned from IPv6 router advertisements.
+func (c *dhcpCollector) updateIPv6Lifetimes(ch chan<- prometheus.Metric) error {
+	conn, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return fmt.Errorf("couldn't connect rtnetlink: %w", err)
+	}
+	defer conn.Close()
+
+	links, err := conn.Link.List()
+	if err != nil {
+		return fmt.Errorf("couldn't get links: %w", err)
+	}
+	names := make(map[uint32]string, len(links))
+	for _, link := range links {
+		names[link.Index] = link.Attributes.Name
+	}
+
+	routes, err := conn.Route.List()
+	if err != nil {
+		return fmt.Errorf("couldn't get routes: %w", err)
+	}
+	for _, route := range routes {
+		if route.Family != unix.AF_INET6 || route.Protocol != unix.RTPROT_RA || route.DstLength != 0 || route.Attributes.Expires == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.routerLifetime, prometheus.GaugeValue, float64(*route.Attributes.Expires)/userHZ,
+			names[route.Attributes.OutIface], route.Attributes.Gateway.String())
+	}
+
+	addresses, err := conn.Address.List()
+	if err != nil {
+		return fmt.Errorf("couldn't get addresses: %w", err)
+	}
+	for _, address := range addresses {
+		info := address.Attributes.CacheInfo
+		if address.Family != unix.AF_INET6 || info.Valid == infiniteLifetime {
+			continue
+		}
+		device, ip := names[address.Index], address.Attributes.Address.String()
+		ch <- prometheus.MustNewConstMetric(c.validLifetime, prometheus.GaugeValue, float64(info.Valid), device, ip)
+		ch <- prometheus.MustNewConstMetric(c.preferredLifetime, prometheus.GaugeValue, float64(info.Prefered), device, ip)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nodhcp
+// +build !nodhcp
+
+package collector
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadDhclientLeaseFile(t *testing.T) {
+	leases, err := readDHCPLeaseFile("fixtures/dhcp/dhclient.leases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dhcpLease{
+		{
+			iface:   "eth0",
+			address: "10.0.0.5",
+			source:  dhcpSourceDhclient,
+			renew:   time.Date(2024, 6, 4, 10, 30, 0, 0, time.UTC),
+			expiry:  time.Date(2024, 6, 4, 11, 15, 0, 0, time.UTC),
+		},
+		{
+			iface:   "eth1",
+			address: "192.168.1.20",
+			source:  dhcpSourceDhclient,
+			renew:   time.Unix(1717496400, 0),
+		},
+	}
+	checkDHCPLeases(t, leases, want)
+}
+
+func TestReadNetworkManagerLeaseFile(t *testing.T) {
+	path := "fixtures/dhcp/internal-5f3c9a52-7d1e-4a8e-9e2b-1c0f3a6d8e4b-eth2.lease"
+	written := time.Date(2024, 6, 4, 10, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, written, written); err != nil {
+		t.Fatal(err)
+	}
+
+	leases, err := readDHCPLeaseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dhcpLease{{
+		iface:   "eth2",
+		address: "172.16.0.9",
+		source:  dhcpSourceNetworkManager,
+		renew:   written.Add(30 * time.Minute),
+		expiry:  written.Add(time.Hour),
+	}}
+	checkDHCPLeases(t, leases, want)
+}
+
+func checkDHCPLeases(t *testing.T, got, want []dhcpLease) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d leases, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if g, w := got[i], want[i]; g.iface != w.iface || g.address != w.address || g.source != w.source ||
+			!g.renew.Equal(w.renew) || !g.expiry.Equal(w.expiry) {
+			t.Errorf("lease %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
@@ -48,6 +48,8 @@ var (
 
 	diskstatsDeviceInclude = kingpin.Flag("collector.diskstats.device-include", "Regexp of diskstats devices to include (mutually exclusive to device-exclude).").String()
 
+	diskstatsDeviceExcludeFile, diskstatsDeviceIncludeFile = deviceListFileFlags("diskstats", "device", "diskstats devices")
+
 	readsCompletedDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, diskSubsystem, "reads_completed_total"),
 		"The total number of reads completed successfully.",
@@ -115,5 +117,5 @@ func newDiskstatsDeviceFilter(logger log.Logger) (deviceFilter, error) {
 		level.Info(logger).Log("msg", "Parsed Flag --collector.diskstats.device-include", "flag", *diskstatsDeviceInclude)
 	}
 
-	return newDeviceFilter(*diskstatsDeviceExclude, *diskstatsDeviceInclude), nil
+	return newDeviceFilter(*diskstatsDeviceExclude, *diskstatsDeviceInclude).withListFiles(*diskstatsDeviceExcludeFile, *diskstatsDeviceIncludeFile)
 }
@@ -0,0 +1,264 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+var (
+	collectorDownsampleResolutions = kingpin.Flag("collector.downsample-resolution",
+		"Resolution at which a collector is sampled in the background to expose the aggregates of its gauges over --collector.downsample-window, in the form collector=duration, e.g. loadavg=1s. Takes precedence over the background interval of the collector. Can be repeated.").Strings()
+	collectorDownsampleWindows = kingpin.Flag("collector.downsample-window",
+		"Window over which the gauges of downsampled collectors are aggregated, exposed as <metric>_window_<aggregate>{window=\"<window>\"}. Can be repeated.").Default("15s", "1m").DurationList()
+	collectorDownsampleAggregates = kingpin.Flag("collector.downsample-aggregate",
+		"Aggregate of the gauges of downsampled collectors over every window, one of avg, min or max. Can be repeated.").Default("avg", "min", "max").Enums("avg", "min", "max")
+)
+
+// downsampleWindowLabel tells the window of an aggregated series.
+const downsampleWindowLabel = "window"
+
+// downsampleAggregates are the aggregates of --collector.downsample-aggregate
+// by name, the suffix of the aggregated series.
+var downsampleAggregates = map[string]struct {
+	help      string
+	aggregate func(samples []timedValue, start, end time.Time) float64
+}{
+	"avg": {"Time-weighted average over the window.", timeWeightedAverage},
+	"min": {"Minimum over the window.", windowMin},
+	"max": {"Maximum over the window.", windowMax},
+}
+
+// parseCollectorDownsampleResolutions returns the downsampling resolutions of
+// the given collectors from --collector.downsample-resolution, 0 for
+// collectors that are not downsampled.
+func parseCollectorDownsampleResolutions(collectors map[string]Collector) (map[string]time.Duration, error) {
+	resolutions, err := parseCollectorDurations(collectors, 0, *collectorDownsampleResolutions)
+	if err != nil {
+		return nil, fmt.Errorf("--collector.downsample-resolution: %w", err)
+	}
+	if len(resolutions) > 0 {
+		for _, w := range *collectorDownsampleWindows {
+			if w <= 0 {
+				return nil, errors.New("--collector.downsample-window must be positive")
+			}
+		}
+	}
+	return resolutions, nil
+}
+
+type timedValue struct {
+	time  time.Time
+	value float64
+}
+
+// downsampledSeries holds the recent samples of a gauge.
+type downsampledSeries struct {
+	name        string
+	help        string
+	labelNames  []string
+	labelValues []string
+	samples     []timedValue
+}
+
+// downsampler keeps the samples of the gauges of a collector sampled at high
+// resolution and aggregates them over windows, so that scrapes at a lower
+// resolution see every sample instead of the one they happen to hit.
+type downsampler struct {
+	windows    []time.Duration
+	aggregates []string
+	series     map[string]*downsampledSeries
+}
+
+func newDownsampler(windows []time.Duration, aggregates []string) *downsampler {
+	return &downsampler{windows: windows, aggregates: aggregates, series: map[string]*downsampledSeries{}}
+}
+
+// downsampledRun replays the metrics of a run of a collector, so that they
+// can be gathered into metric families by a private registry.
+type downsampledRun []prometheus.Metric
+
+// Describe implements prometheus.Collector. Nothing is described, so that the
+// metrics are not checked against descriptions.
+func (r downsampledRun) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (r downsampledRun) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range r {
+		ch <- m
+	}
+}
+
+// observe records the gauges of a run of the collector. Series missing from
+// the run are forgotten.
+func (d *downsampler) observe(t time.Time, metrics []prometheus.Metric) {
+	var longest time.Duration
+	for _, w := range d.windows {
+		if w > longest {
+			longest = w
+		}
+	}
+
+	// The name and help of the metrics are only available from the
+	// gathered families. Metrics the registry rejects, such as duplicates,
+	// are left out of the families and not downsampled.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(downsampledRun(metrics))
+	families, _ := reg.Gather()
+
+	seen := make(map[string]bool, len(metrics))
+	for _, mf := range families {
+		if mf.GetType() != dto.MetricType_GAUGE {
+			continue
+		}
+		for _, m := range mf.Metric {
+			var key strings.Builder
+			key.WriteString(mf.GetName())
+			for _, l := range m.Label {
+				key.WriteByte(0xff)
+				key.WriteString(l.GetName())
+				key.WriteByte(0xff)
+				key.WriteString(l.GetValue())
+			}
+			s, ok := d.series[key.String()]
+			if !ok {
+				s = &downsampledSeries{name: mf.GetName(), help: mf.GetHelp()}
+				for _, l := range m.Label {
+					s.labelNames = append(s.labelNames, l.GetName())
+					s.labelValues = append(s.labelValues, l.GetValue())
+				}
+				d.series[key.String()] = s
+			}
+			s.samples = append(s.samples, timedValue{time: t, value: m.Gauge.GetValue()})
+			seen[key.String()] = true
+		}
+	}
+
+	// Keep the samples of the longest window, including the one it
+	// starts in.
+	horizon := t.Add(-longest)
+	for key, s := range d.series {
+		if !seen[key] {
+			delete(d.series, key)
+			continue
+		}
+		i := 0
+		for i+1 < len(s.samples) && !s.samples[i+1].time.After(horizon) {
+			i++
+		}
+		s.samples = s.samples[i:]
+	}
+}
+
+// aggregate returns the aggregates of the gauges over the windows ending at
+// now.
+func (d *downsampler) aggregate(now time.Time) []prometheus.Metric {
+	keys := make([]string, 0, len(d.series))
+	for key := range d.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var metrics []prometheus.Metric
+	for _, key := range keys {
+		s := d.series[key]
+		for _, name := range d.aggregates {
+			aggregate := downsampleAggregates[name]
+			desc := prometheus.NewDesc(
+				s.name+"_window_"+name,
+				s.help+" "+aggregate.help,
+				append(append([]string(nil), s.labelNames...), downsampleWindowLabel),
+				nil,
+			)
+			for _, w := range d.windows {
+				value := aggregate.aggregate(s.samples, now.Add(-w), now)
+				values := append(append([]string(nil), s.labelValues...), model.Duration(w).String())
+				metrics = append(metrics, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, values...))
+			}
+		}
+	}
+	return metrics
+}
+
+// timeWeightedAverage averages samples between start and end, each sample
+// holding until the next one. Samples are sorted by time, and there is at
+// least one.
+func timeWeightedAverage(samples []timedValue, start, end time.Time) float64 {
+	var sum, covered float64
+	for i, s := range samples {
+		from := s.time
+		if from.Before(start) {
+			from = start
+		}
+		to := end
+		if i+1 < len(samples) && samples[i+1].time.Before(end) {
+			to = samples[i+1].time
+		}
+		if !to.After(from) {
+			continue
+		}
+		d := to.Sub(from).Seconds()
+		sum += s.value * d
+		covered += d
+	}
+	if covered == 0 {
+		return samples[len(samples)-1].value
+	}
+	return sum / covered
+}
+
+// windowMin returns the minimum of the samples holding between start and
+// end. Samples are sorted by time, and there is at least one.
+func windowMin(samples []timedValue, start, end time.Time) float64 {
+	return windowExtreme(samples, start, end, math.Min)
+}
+
+// windowMax returns the maximum of the samples holding between start and
+// end. Samples are sorted by time, and there is at least one.
+func windowMax(samples []timedValue, start, end time.Time) float64 {
+	return windowExtreme(samples, start, end, math.Max)
+}
+
+// windowExtreme folds the samples holding between start and end with pick,
+// like timeWeightedAverage counting the sample holding at the start.
+func windowExtreme(samples []timedValue, start, end time.Time, pick func(float64, float64) float64) float64 {
+	extreme, found := 0.0, false
+	for i, s := range samples {
+		if i+1 < len(samples) && !samples[i+1].time.After(start) {
+			continue
+		}
+		if s.time.After(end) {
+			break
+		}
+		if !found {
+			extreme, found = s.value, true
+			continue
+		}
+		extreme = pick(extreme, s.value)
+	}
+	if !found {
+		return samples[len(samples)-1].value
+	}
+	return extreme
+}
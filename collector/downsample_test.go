@@ -0,0 +1,164 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTimeWeightedAverage(t *testing.T) {
+	start := time.Unix(1000, 0)
+	samples := []timedValue{
+		{time: start, value: 1},
+		{time: start.Add(10 * time.Second), value: 4},
+		{time: start.Add(15 * time.Second), value: 2},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		start, end time.Time
+		want       float64
+	}{
+		// 1 for 10s, 4 for 5s, 2 for 5s.
+		{"all", start, start.Add(20 * time.Second), 2},
+		// 4 for 5s, 2 for 5s.
+		{"tail", start.Add(10 * time.Second), start.Add(20 * time.Second), 3},
+		// 1 for 5s, 4 for 5s: the sample holding at the start counts.
+		{"middle", start.Add(5 * time.Second), start.Add(15 * time.Second), 2.5},
+		// Nothing covered after the last sample yet.
+		{"empty", start.Add(15 * time.Second), start.Add(15 * time.Second), 2},
+	} {
+		if got := timeWeightedAverage(samples, tc.start, tc.end); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWindowMinMax(t *testing.T) {
+	start := time.Unix(1000, 0)
+	samples := []timedValue{
+		{time: start, value: 1},
+		{time: start.Add(10 * time.Second), value: 4},
+		{time: start.Add(15 * time.Second), value: 2},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		start, end time.Time
+		min, max   float64
+	}{
+		{"all", start, start.Add(20 * time.Second), 1, 4},
+		{"tail", start.Add(10 * time.Second), start.Add(20 * time.Second), 2, 4},
+		// The sample holding at the start counts.
+		{"middle", start.Add(5 * time.Second), start.Add(12 * time.Second), 1, 4},
+		{"last", start.Add(16 * time.Second), start.Add(20 * time.Second), 2, 2},
+	} {
+		if got := windowMin(samples, tc.start, tc.end); got != tc.min {
+			t.Errorf("%s: got min %v, want %v", tc.name, got, tc.min)
+		}
+		if got := windowMax(samples, tc.start, tc.end); got != tc.max {
+			t.Errorf("%s: got max %v, want %v", tc.name, got, tc.max)
+		}
+	}
+}
+
+func TestDownsamplerAggregates(t *testing.T) {
+	gauge := prometheus.NewDesc("node_test_gauge", "Test gauge.", []string{"device"}, nil)
+	counter := prometheus.NewDesc("node_test_total", "Test counter.", nil, nil)
+	d := newDownsampler([]time.Duration{10 * time.Second, time.Minute}, []string{"avg"})
+
+	start := time.Unix(1000, 0)
+	for i := 0; i < 60; i++ {
+		value := 0.0
+		if i >= 50 {
+			value = 6
+		}
+		d.observe(start.Add(time.Duration(i)*time.Second), []prometheus.Metric{
+			prometheus.MustNewConstMetric(gauge, prometheus.GaugeValue, value, "sda"),
+			prometheus.MustNewConstMetric(counter, prometheus.CounterValue, float64(i)),
+		})
+	}
+
+	families := gatherDownsampled(t, d.aggregate(start.Add(60*time.Second)))
+	if len(families) != 1 || families[0].GetName() != "node_test_gauge_window_avg" {
+		t.Fatalf("got %v, want node_test_gauge_window_avg", families)
+	}
+	if got, want := families[0].GetHelp(), "Test gauge. Time-weighted average over the window."; got != want {
+		t.Errorf("got help %q, want %q", got, want)
+	}
+	if len(families[0].Metric) != 2 {
+		t.Fatalf("got %d metrics, want one per window of the gauge", len(families[0].Metric))
+	}
+	want := map[string]float64{"10s": 6, "1m": 1}
+	for _, m := range families[0].Metric {
+		labels := map[string]string{}
+		for _, l := range m.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["device"] != "sda" {
+			t.Errorf("got labels %v, want device=sda", labels)
+		}
+		if got := m.Gauge.GetValue(); math.Abs(got-want[labels[downsampleWindowLabel]]) > 1e-9 {
+			t.Errorf("window %s: got %v, want %v", labels[downsampleWindowLabel], got, want[labels[downsampleWindowLabel]])
+		}
+	}
+
+	// Series missing from a run are forgotten.
+	d.observe(start.Add(61*time.Second), nil)
+	if metrics := d.aggregate(start.Add(61 * time.Second)); len(metrics) != 0 {
+		t.Errorf("got %d metrics after the series went away, want none", len(metrics))
+	}
+}
+
+func TestDownsamplerMinMax(t *testing.T) {
+	gauge := prometheus.NewDesc("node_test_gauge", "Test gauge.", nil, nil)
+	d := newDownsampler([]time.Duration{time.Minute}, []string{"min", "max"})
+
+	// A short spike between scrapes.
+	start := time.Unix(1000, 0)
+	for i, value := range []float64{1, 1, 9, 1, 0.5, 1} {
+		d.observe(start.Add(time.Duration(i)*time.Second), []prometheus.Metric{
+			prometheus.MustNewConstMetric(gauge, prometheus.GaugeValue, value),
+		})
+	}
+
+	want := map[string]float64{"node_test_gauge_window_min": 0.5, "node_test_gauge_window_max": 9}
+	families := gatherDownsampled(t, d.aggregate(start.Add(6*time.Second)))
+	if len(families) != len(want) {
+		t.Fatalf("got %d metrics, want %d", len(families), len(want))
+	}
+	for _, mf := range families {
+		if got := mf.Metric[0].Gauge.GetValue(); got != want[mf.GetName()] {
+			t.Errorf("%s: got %v, want %v", mf.GetName(), got, want[mf.GetName()])
+		}
+	}
+}
+
+// gatherDownsampled gathers the aggregates of a downsampler into metric
+// families.
+func gatherDownsampled(t *testing.T, metrics []prometheus.Metric) []*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(downsampledRun(metrics))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return families
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	debugFailCollectors = kingpin.Flag("debug.fail-collector",
+		"Make a collector fail every scrape without running it, in the form collector or collector=message. Can be repeated. For testing alerts and dashboards only.").Hidden().Strings()
+	debugDelayCollectors = kingpin.Flag("debug.delay-collector",
+		"Delay every update of a collector, in the form collector=duration. Can be repeated. For testing alerts and dashboards only.").Hidden().Strings()
+)
+
+// collectorFault is a failure injected into a collector.
+type collectorFault struct {
+	err   error
+	delay time.Duration
+}
+
+// warnFaultsOnce logs the injected faults once, as collectors are created for
+// every filtered scrape.
+var warnFaultsOnce sync.Once
+
+// parseCollectorFaults returns the faults injected into the given collectors
+// with --debug.fail-collector and --debug.delay-collector.
+func parseCollectorFaults(collectors map[string]Collector, logger log.Logger) (map[string]*collectorFault, error) {
+	if len(*debugFailCollectors) == 0 && len(*debugDelayCollectors) == 0 {
+		return nil, nil
+	}
+
+	delays, err := parseCollectorDurations(collectors, 0, *debugDelayCollectors)
+	if err != nil {
+		return nil, fmt.Errorf("--debug.delay-collector: %w", err)
+	}
+	faults := map[string]*collectorFault{}
+	for name, delay := range delays {
+		faults[name] = &collectorFault{delay: delay}
+	}
+
+	for _, fail := range *debugFailCollectors {
+		name, message, _ := strings.Cut(fail, "=")
+		if _, ok := factories[name]; !ok {
+			return nil, fmt.Errorf("--debug.fail-collector: unknown collector %q", name)
+		}
+		if _, ok := collectors[name]; !ok {
+			continue
+		}
+		if message == "" {
+			message = "failure injected with --debug.fail-collector"
+		}
+		if faults[name] == nil {
+			faults[name] = &collectorFault{}
+		}
+		faults[name].err = errors.New(message)
+	}
+
+	warnFaultsOnce.Do(func() {
+		level.Warn(logger).Log("msg", "Injecting failures into collectors, do not use in production", "fail", strings.Join(*debugFailCollectors, ","), "delay", strings.Join(*debugDelayCollectors, ","))
+	})
+	return faults, nil
+}
+
+// wrap returns an update injecting the fault into the given one.
+func (f *collectorFault) wrap(update func(chan<- prometheus.Metric) error) func(chan<- prometheus.Metric) error {
+	return func(ch chan<- prometheus.Metric) error {
+		time.Sleep(f.delay)
+		if f.err != nil {
+			return f.err
+		}
+		return update(ch)
+	}
+}
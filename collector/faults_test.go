@@ -0,0 +1,60 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectorFaults(t *testing.T) {
+	fail, delay := *debugFailCollectors, *debugDelayCollectors
+	defer func() { *debugFailCollectors, *debugDelayCollectors = fail, delay }()
+
+	*debugFailCollectors = []string{"maintenance=BMC unreachable", "carbon"}
+	*debugDelayCollectors = []string{"maintenance=10ms"}
+	collectors := map[string]Collector{"maintenance": nil, "loadavg": nil}
+	faults, err := parseCollectorFaults(collectors, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faults) != 1 {
+		t.Fatalf("got faults for %d collectors, want only the enabled maintenance collector", len(faults))
+	}
+
+	ran := false
+	update := faults["maintenance"].wrap(func(chan<- prometheus.Metric) error {
+		ran = true
+		return nil
+	})
+	begin := time.Now()
+	err = update(make(chan prometheus.Metric))
+	if err == nil || err.Error() != "BMC unreachable" {
+		t.Errorf("got error %v, want the injected one", err)
+	}
+	if ran {
+		t.Error("failing collector was run")
+	}
+	if time.Since(begin) < 10*time.Millisecond {
+		t.Error("update was not delayed")
+	}
+
+	*debugFailCollectors = []string{"nonexistent"}
+	if _, err := parseCollectorFaults(collectors, log.NewNopLogger()); err == nil {
+		t.Error("expected error for unknown collector")
+	}
+}
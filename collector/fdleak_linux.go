@@ -0,0 +1,261 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nofdleak
+// +build !nofdleak
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+var (
+	fdLeakProcessInclude = kingpin.Flag("collector.fdleak.process-include", "Regexp of process names (comm) to track file descriptors of.").String()
+	fdLeakWindow         = kingpin.Flag("collector.fdleak.window", "Time window over which file descriptor growth is computed.").Default("1h").Duration()
+	fdLeakMinWindow      = kingpin.Flag("collector.fdleak.min-window", "Time a process must have been observed for before its file descriptor growth is exposed.").Default("15m").Duration()
+)
+
+const fdLeakSubsystem = "fdleak"
+
+type fdLeakCollector struct {
+	fs             procfs.FS
+	processInclude *regexp.Regexp
+	window         time.Duration
+	minWindow      time.Duration
+	logger         log.Logger
+
+	openFDs    *prometheus.Desc
+	usageRatio *prometheus.Desc
+	growthRate *prometheus.Desc
+
+	mu sync.Mutex
+	// samples holds the file descriptor counts of every tracked process
+	// within the window, oldest first.
+	samples map[int][]fdSample
+}
+
+type fdSample struct {
+	time  time.Time
+	count float64
+}
+
+type fdLeakStats struct {
+	openFDs    float64
+	usageRatio float64
+	growthRate float64
+	hasRate    bool
+}
+
+func init() {
+	registerCollector("fdleak", defaultDisabled, NewFDLeakCollector)
+}
+
+// NewFDLeakCollector returns a new Collector tracking the number of open file
+// descriptors of selected processes and how fast it grows.
+func NewFDLeakCollector(logger log.Logger) (Collector, error) {
+	if *fdLeakProcessInclude == "" {
+		return nil, errors.New("--collector.fdleak.process-include must be set")
+	}
+	processInclude, err := regexp.Compile(*fdLeakProcessInclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collector.fdleak.process-include: %w", err)
+	}
+	if *fdLeakWindow <= 0 {
+		return nil, errors.New("--collector.fdleak.window must be positive")
+	}
+	if *fdLeakMinWindow < 0 || *fdLeakMinWindow > *fdLeakWindow {
+		return nil, errors.New("--collector.fdleak.min-window must be between 0 and --collector.fdleak.window")
+	}
+
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open procfs: %w", err)
+	}
+
+	return &fdLeakCollector{
+		fs:             fs,
+		processInclude: processInclude,
+		window:         *fdLeakWindow,
+		minWindow:      *fdLeakMinWindow,
+		logger:         logger,
+		openFDs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, fdLeakSubsystem, "open_fds"),
+			"Number of open file descriptors of all processes with this name.",
+			[]string{"name"}, nil,
+		),
+		usageRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, fdLeakSubsystem, "fd_usage_ratio"),
+			"Highest ratio of open file descriptors to the soft limit among processes with this name.",
+			[]string{"name"}, nil,
+		),
+		growthRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, fdLeakSubsystem, "fd_growth_per_hour"),
+			"Highest file descriptor growth per hour over --collector.fdleak.window among processes with this name. Only processes observed for at least --collector.fdleak.min-window are considered.",
+			[]string{"name"}, nil,
+		),
+		samples: map[int][]fdSample{},
+	}, nil
+}
+
+func (c *fdLeakCollector) Update(ch chan<- prometheus.Metric) error {
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	now := time.Now()
+	stats := map[string]*fdLeakStats{}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[int]bool{}
+	for _, p := range procs {
+		name, err := p.Comm()
+		if err != nil || !c.processInclude.MatchString(name) {
+			continue
+		}
+		count, err := p.FileDescriptorsLen()
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to count file descriptors", "pid", p.PID, "err", err)
+			continue
+		}
+		seen[p.PID] = true
+
+		s, ok := stats[name]
+		if !ok {
+			s = &fdLeakStats{}
+			stats[name] = s
+		}
+		s.openFDs += float64(count)
+
+		if limits, err := p.Limits(); err == nil && limits.OpenFiles > 0 {
+			if ratio := float64(count) / float64(limits.OpenFiles); ratio > s.usageRatio {
+				s.usageRatio = ratio
+			}
+		}
+
+		if rate, ok := c.observe(p.PID, now, float64(count)); ok && (!s.hasRate || rate > s.growthRate) {
+			s.growthRate = rate
+			s.hasRate = true
+		}
+	}
+
+	// Forget processes which exited.
+	for pid := range c.samples {
+		if !seen[pid] {
+			delete(c.samples, pid)
+		}
+	}
+
+	for name, s := range stats {
+		ch <- prometheus.MustNewConstMetric(c.openFDs, prometheus.GaugeValue, s.openFDs, name)
+		ch <- prometheus.MustNewConstMetric(c.usageRatio, prometheus.GaugeValue, s.usageRatio, name)
+		if s.hasRate {
+			ch <- prometheus.MustNewConstMetric(c.growthRate, prometheus.GaugeValue, s.growthRate, name)
+		}
+	}
+
+	return nil
+}
+
+// observe records a file descriptor count of a process and returns its growth
+// per hour since the oldest sample within the window. No growth is returned
+// until the process has been observed for the minimum window, extrapolating
+// from a few seconds of samples turns short bursts into huge rates.
+func (c *fdLeakCollector) observe(pid int, now time.Time, count float64) (float64, bool) {
+	samples := c.samples[pid]
+	for len(samples) > 0 && now.Sub(samples[0].time) > c.window {
+		samples = samples[1:]
+	}
+	samples = append(samples, fdSample{time: now, count: count})
+	c.samples[pid] = samples
+
+	oldest := samples[0]
+	elapsed := now.Sub(oldest.time)
+	if elapsed <= 0 || elapsed < c.minWindow {
+		return 0, false
+	}
+	return (count - oldest.count) / elapsed.Hours(), true
+}
+
+// fdLeakState is the persisted state of the collector. PIDs are only
+// meaningful within a boot, so the state is tied to the boot ID.
+type fdLeakState struct {
+	BootID  string                  `json:"boot_id"`
+	Samples map[int][]fdSampleState `json:"samples"`
+}
+
+type fdSampleState struct {
+	Time  time.Time `json:"time"`
+	Count float64   `json:"count"`
+}
+
+func (c *fdLeakCollector) saveState() (json.RawMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := fdLeakState{BootID: bootID(), Samples: make(map[int][]fdSampleState, len(c.samples))}
+	for pid, samples := range c.samples {
+		for _, s := range samples {
+			state.Samples[pid] = append(state.Samples[pid], fdSampleState{Time: s.time, Count: s.count})
+		}
+	}
+	return json.Marshal(state)
+}
+
+// loadState restores the samples of a previous run. Samples of processes that
+// exited in the meantime are dropped on the next scrape.
+func (c *fdLeakCollector) loadState(data json.RawMessage) error {
+	var state fdLeakState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.BootID != bootID() {
+		level.Debug(c.logger).Log("msg", "discarding file descriptor samples of a previous boot")
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for pid, samples := range state.Samples {
+		for _, s := range samples {
+			c.samples[pid] = append(c.samples[pid], fdSample{time: s.Time, count: s.Count})
+		}
+	}
+	return nil
+}
+
+// bootID returns the random ID the kernel generates on every boot.
+func bootID() string {
+	data, err := os.ReadFile(procFilePath("sys/kernel/random/boot_id"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
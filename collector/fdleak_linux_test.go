@@ -0,0 +1,123 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nofdleak
+// +build !nofdleak
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFDLeakObserve(t *testing.T) {
+	c := &fdLeakCollector{window: time.Hour, minWindow: 15 * time.Minute, samples: map[int][]fdSample{}}
+	start := time.Unix(1700000000, 0)
+
+	for _, tc := range []struct {
+		after   time.Duration
+		count   float64
+		rate    float64
+		hasRate bool
+	}{
+		{after: 0, count: 10},
+		// A burst shortly after the process was first seen must not be
+		// extrapolated to an hourly rate.
+		{after: 30 * time.Second, count: 60},
+		{after: 15 * time.Minute, count: 20, rate: 40, hasRate: true},
+		{after: 30 * time.Minute, count: 30, rate: 40, hasRate: true},
+		// Samples older than the window are dropped.
+		{after: 75 * time.Minute, count: 40, rate: 20, hasRate: true},
+	} {
+		rate, ok := c.observe(1, start.Add(tc.after), tc.count)
+		if ok != tc.hasRate || rate != tc.rate {
+			t.Errorf("after %s: got rate %v (%t), want %v (%t)", tc.after, rate, ok, tc.rate, tc.hasRate)
+		}
+	}
+}
+
+func TestFDLeakCollector(t *testing.T) {
+	proc := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(proc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process := func(pid int, comm string, fds int, limit int) {
+		t.Helper()
+		dir := strconv.Itoa(pid)
+		write(dir+"/comm", comm+"\n")
+		write(dir+"/limits", "Limit                     Soft Limit           Hard Limit           Units     \n"+
+			"Max open files            "+strconv.Itoa(limit)+"                 4096                 files     \n")
+		if err := os.MkdirAll(filepath.Join(proc, dir, "fd"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for fd := 0; fd < fds; fd++ {
+			if err := os.Symlink("/dev/null", filepath.Join(proc, dir, "fd", strconv.Itoa(fd))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	process(100, "app", 4, 8)
+	process(101, "app", 2, 16)
+	process(102, "worker", 3, 12)
+	process(103, "sshd", 5, 1024)
+
+	defer func(path, include string, window, minWindow time.Duration) {
+		*procPath, *fdLeakProcessInclude, *fdLeakWindow, *fdLeakMinWindow = path, include, window, minWindow
+	}(*procPath, *fdLeakProcessInclude, *fdLeakWindow, *fdLeakMinWindow)
+	*procPath = proc
+	*fdLeakProcessInclude = "app|worker"
+	*fdLeakWindow = time.Hour
+	*fdLeakMinWindow = 15 * time.Minute
+
+	c, err := NewFDLeakCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pid 100 was seen with the same count half an hour ago, the other
+	// processes are new and don't have a growth rate yet.
+	c.(*fdLeakCollector).samples[100] = []fdSample{{time: time.Now().Add(-30 * time.Minute), count: 4}}
+
+	want := `# HELP node_fdleak_fd_growth_per_hour Highest file descriptor growth per hour over --collector.fdleak.window among processes with this name. Only processes observed for at least --collector.fdleak.min-window are considered.
+# TYPE node_fdleak_fd_growth_per_hour gauge
+node_fdleak_fd_growth_per_hour{name="app"} 0
+# HELP node_fdleak_fd_usage_ratio Highest ratio of open file descriptors to the soft limit among processes with this name.
+# TYPE node_fdleak_fd_usage_ratio gauge
+node_fdleak_fd_usage_ratio{name="app"} 0.5
+node_fdleak_fd_usage_ratio{name="worker"} 0.25
+# HELP node_fdleak_open_fds Number of open file descriptors of all processes with this name.
+# TYPE node_fdleak_open_fds gauge
+node_fdleak_open_fds{name="app"} 6
+node_fdleak_open_fds{name="worker"} 3
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -60,15 +60,18 @@ var (
 		"Regexp of filesystem types to ignore for filesystem collector.",
 	).Hidden().String()
 
+	mountPointsExcludeFile, mountPointsIncludeFile = deviceListFileFlags("filesystem", "mount-points", "mount points")
+
 	filesystemLabelNames = []string{"device", "mountpoint", "fstype", "device_error"}
 )
 
 type filesystemCollector struct {
-	excludedMountPointsPattern    *regexp.Regexp
+	mountPointFilter              deviceFilter
 	excludedFSTypesPattern        *regexp.Regexp
 	sizeDesc, freeDesc, availDesc *prometheus.Desc
 	filesDesc, filesFreeDesc      *prometheus.Desc
 	roDesc, deviceErrorDesc       *prometheus.Desc
+	options                       filesystemOptions
 	logger                        log.Logger
 }
 
@@ -109,7 +112,11 @@ func NewFilesystemCollector(logger log.Logger) (Collector, error) {
 
 	subsystem := "filesystem"
 	level.Info(logger).Log("msg", "Parsed flag --collector.filesystem.mount-points-exclude", "flag", *mountPointsExclude)
-	mountPointPattern := regexp.MustCompile(*mountPointsExclude)
+	mountPointFilter, err := newDeviceFilter(*mountPointsExclude, "").
+		withListFiles(*mountPointsExcludeFile, *mountPointsIncludeFile)
+	if err != nil {
+		return nil, err
+	}
 	level.Info(logger).Log("msg", "Parsed flag --collector.filesystem.fs-types-exclude", "flag", *fsTypesExclude)
 	filesystemsTypesPattern := regexp.MustCompile(*fsTypesExclude)
 
@@ -156,16 +163,17 @@ func NewFilesystemCollector(logger log.Logger) (Collector, error) {
 	)
 
 	return &filesystemCollector{
-		excludedMountPointsPattern: mountPointPattern,
-		excludedFSTypesPattern:     filesystemsTypesPattern,
-		sizeDesc:                   sizeDesc,
-		freeDesc:                   freeDesc,
-		availDesc:                  availDesc,
-		filesDesc:                  filesDesc,
-		filesFreeDesc:              filesFreeDesc,
-		roDesc:                     roDesc,
-		deviceErrorDesc:            deviceErrorDesc,
-		logger:                     logger,
+		mountPointFilter:       mountPointFilter,
+		excludedFSTypesPattern: filesystemsTypesPattern,
+		sizeDesc:               sizeDesc,
+		freeDesc:               freeDesc,
+		availDesc:              availDesc,
+		filesDesc:              filesDesc,
+		filesFreeDesc:          filesFreeDesc,
+		roDesc:                 roDesc,
+		deviceErrorDesc:        deviceErrorDesc,
+		options:                newFilesystemOptions(),
+		logger:                 logger,
 	}, nil
 }
 
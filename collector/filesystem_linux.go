@@ -46,6 +46,19 @@ var statWorkerCount = kingpin.Flag("collector.filesystem.stat-workers",
 var stuckMounts = make(map[string]struct{})
 var stuckMountsMtx = &sync.Mutex{}
 
+// filesystemOptions are the Linux specific flags of the collector.
+type filesystemOptions struct {
+	statWorkerCount int
+	mountTimeout    time.Duration
+}
+
+func newFilesystemOptions() filesystemOptions {
+	return filesystemOptions{
+		statWorkerCount: *statWorkerCount,
+		mountTimeout:    *mountTimeout,
+	}
+}
+
 // GetStats returns filesystem stats.
 func (c *filesystemCollector) GetStats() ([]filesystemStats, error) {
 	mps, err := mountPointDetails(c.logger)
@@ -57,7 +70,7 @@ func (c *filesystemCollector) GetStats() ([]filesystemStats, error) {
 	statChan := make(chan filesystemStats)
 	wg := sync.WaitGroup{}
 
-	workerCount := *statWorkerCount
+	workerCount := c.options.statWorkerCount
 	if workerCount < 1 {
 		workerCount = 1
 	}
@@ -74,7 +87,7 @@ func (c *filesystemCollector) GetStats() ([]filesystemStats, error) {
 
 	go func() {
 		for _, labels := range mps {
-			if c.excludedMountPointsPattern.MatchString(labels.mountPoint) {
+			if c.mountPointFilter.ignored(labels.mountPoint) {
 				level.Debug(c.logger).Log("msg", "Ignoring mount point", "mountpoint", labels.mountPoint)
 				continue
 			}
@@ -119,7 +132,7 @@ func (c *filesystemCollector) processStat(labels filesystemLabels) filesystemSta
 	}
 
 	success := make(chan struct{})
-	go stuckMountWatcher(labels.mountPoint, success, c.logger)
+	go stuckMountWatcher(labels.mountPoint, c.options.mountTimeout, success, c.logger)
 
 	buf := new(unix.Statfs_t)
 	err := unix.Statfs(rootfsFilePath(labels.mountPoint), buf)
@@ -157,8 +170,8 @@ func (c *filesystemCollector) processStat(labels filesystemLabels) filesystemSta
 // stuckMountWatcher listens on the given success channel and if the channel closes
 // then the watcher does nothing. If instead the timeout is reached, the
 // mount point that is being watched is marked as stuck.
-func stuckMountWatcher(mountPoint string, success chan struct{}, logger log.Logger) {
-	mountCheckTimer := time.NewTimer(*mountTimeout)
+func stuckMountWatcher(mountPoint string, timeout time.Duration, success chan struct{}, logger log.Logger) {
+	mountCheckTimer := time.NewTimer(timeout)
 	defer mountCheckTimer.Stop()
 	select {
 	case <-success:
@@ -0,0 +1,207 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nogeoip
+// +build !nogeoip
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+var (
+	geoIPASNDatabase     = kingpin.Flag("collector.geoip.asn-database", "Path to a MaxMind DB with autonomous system data (e.g. GeoLite2-ASN.mmdb).").String()
+	geoIPCountryDatabase = kingpin.Flag("collector.geoip.country-database", "Path to a MaxMind DB with country data (e.g. GeoLite2-Country.mmdb).").String()
+	geoIPMaxSeries       = kingpin.Flag("collector.geoip.max-series", "Maximum number of asn/country series to expose, the remaining connections are aggregated into asn=\"other\",country=\"other\".").Default("100").Int()
+)
+
+const (
+	geoIPUnknown = "unknown"
+	geoIPOther   = "other"
+)
+
+type geoIPCollector struct {
+	fs        procfs.FS
+	asnDB     *maxminddb.Reader
+	countryDB *maxminddb.Reader
+	maxSeries int
+	desc      *prometheus.Desc
+	logger    log.Logger
+}
+
+type geoIPASNRecord struct {
+	ASN uint `maxminddb:"autonomous_system_number"`
+}
+
+type geoIPCountryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+type geoIPKey struct {
+	asn     string
+	country string
+}
+
+func init() {
+	registerCollector("geoip", defaultDisabled, NewGeoIPCollector)
+}
+
+// NewGeoIPCollector returns a new Collector exposing established outbound TCP
+// connections aggregated by the autonomous system and country of their
+// destination.
+func NewGeoIPCollector(logger log.Logger) (Collector, error) {
+	if *geoIPASNDatabase == "" && *geoIPCountryDatabase == "" {
+		return nil, errors.New("at least one of --collector.geoip.asn-database and --collector.geoip.country-database must be set")
+	}
+
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open procfs: %w", err)
+	}
+
+	c := &geoIPCollector{
+		fs:        fs,
+		maxSeries: *geoIPMaxSeries,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "geoip", "outbound_tcp_connections"),
+			"Number of established outbound TCP connections by destination autonomous system and country.",
+			[]string{"asn", "country"}, nil,
+		),
+		logger: logger,
+	}
+	if *geoIPASNDatabase != "" {
+		if c.asnDB, err = maxminddb.Open(*geoIPASNDatabase); err != nil {
+			return nil, fmt.Errorf("failed to open ASN database: %w", err)
+		}
+	}
+	if *geoIPCountryDatabase != "" {
+		if c.countryDB, err = maxminddb.Open(*geoIPCountryDatabase); err != nil {
+			return nil, fmt.Errorf("failed to open country database: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *geoIPCollector) Update(ch chan<- prometheus.Metric) error {
+	sockets, err := c.fs.NetTCP()
+	if err != nil {
+		return fmt.Errorf("couldn't get tcp sockets: %w", err)
+	}
+	if _, err := os.Stat(procFilePath("net/tcp6")); err == nil {
+		sockets6, err := c.fs.NetTCP6()
+		if err != nil {
+			return fmt.Errorf("couldn't get tcp6 sockets: %w", err)
+		}
+		sockets = append(sockets, sockets6...)
+	}
+
+	listening := map[uint64]bool{}
+	for _, s := range sockets {
+		if tcpConnectionState(s.St) == tcpListen {
+			listening[s.LocalPort] = true
+		}
+	}
+
+	counts := map[geoIPKey]float64{}
+	for _, s := range sockets {
+		if tcpConnectionState(s.St) != tcpEstablished || listening[s.LocalPort] {
+			continue
+		}
+		if !isPublicIP(s.RemAddr) {
+			continue
+		}
+		counts[c.lookup(s.RemAddr)]++
+	}
+
+	for key, value := range limitGeoIPSeries(counts, c.maxSeries) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value, key.asn, key.country)
+	}
+
+	return nil
+}
+
+func (c *geoIPCollector) lookup(ip net.IP) geoIPKey {
+	key := geoIPKey{asn: geoIPUnknown, country: geoIPUnknown}
+	if c.asnDB != nil {
+		var record geoIPASNRecord
+		if err := c.asnDB.Lookup(ip, &record); err != nil {
+			level.Debug(c.logger).Log("msg", "failed to look up ASN", "ip", ip, "err", err)
+		} else if record.ASN != 0 {
+			key.asn = strconv.FormatUint(uint64(record.ASN), 10)
+		}
+	}
+	if c.countryDB != nil {
+		var record geoIPCountryRecord
+		if err := c.countryDB.Lookup(ip, &record); err != nil {
+			level.Debug(c.logger).Log("msg", "failed to look up country", "ip", ip, "err", err)
+		} else if record.Country.ISOCode != "" {
+			key.country = record.Country.ISOCode
+		}
+	}
+	return key
+}
+
+// isPublicIP reports whether ip is a globally routable address.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// limitGeoIPSeries keeps the maxSeries largest counts and aggregates the
+// others into a single series.
+func limitGeoIPSeries(counts map[geoIPKey]float64, maxSeries int) map[geoIPKey]float64 {
+	if maxSeries <= 0 || len(counts) <= maxSeries {
+		return counts
+	}
+
+	keys := make([]geoIPKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		if keys[i].asn != keys[j].asn {
+			return keys[i].asn < keys[j].asn
+		}
+		return keys[i].country < keys[j].country
+	})
+
+	// Reserve one series for the aggregate.
+	limited := make(map[geoIPKey]float64, maxSeries)
+	other := geoIPKey{asn: geoIPOther, country: geoIPOther}
+	for i, key := range keys {
+		if i < maxSeries-1 {
+			limited[key] = counts[key]
+		} else {
+			limited[other] += counts[key]
+		}
+	}
+	return limited
+}
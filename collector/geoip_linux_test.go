@@ -0,0 +1,85 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nogeoip
+// +build !nogeoip
+
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGeoIPCollector(t *testing.T) {
+	defer func(proc, asn, country string, maxSeries int) {
+		*procPath, *geoIPASNDatabase, *geoIPCountryDatabase, *geoIPMaxSeries = proc, asn, country, maxSeries
+	}(*procPath, *geoIPASNDatabase, *geoIPCountryDatabase, *geoIPMaxSeries)
+	// geoip.mmdb maps 1.1.1.0/24 to AS13335 in AU, 8.8.8.0/24 and
+	// 2001:4860::/32 to AS15169 in US, 9.9.9.0/24 to AS19281 without a
+	// country and 4.4.4.0/24 to US without an ASN.
+	*procPath = "fixtures/geoip/proc"
+	*geoIPASNDatabase = "fixtures/geoip/geoip.mmdb"
+	*geoIPCountryDatabase = "fixtures/geoip/geoip.mmdb"
+	*geoIPMaxSeries = 100
+
+	c, err := NewGeoIPCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connections to the listening ports, to private and loopback addresses
+	// and in other states than established are left out. 5.5.5.5 is missing
+	// from the database.
+	want := `# HELP node_geoip_outbound_tcp_connections Number of established outbound TCP connections by destination autonomous system and country.
+# TYPE node_geoip_outbound_tcp_connections gauge
+node_geoip_outbound_tcp_connections{asn="13335",country="AU"} 2
+node_geoip_outbound_tcp_connections{asn="15169",country="US"} 2
+node_geoip_outbound_tcp_connections{asn="19281",country="unknown"} 1
+node_geoip_outbound_tcp_connections{asn="unknown",country="US"} 1
+node_geoip_outbound_tcp_connections{asn="unknown",country="unknown"} 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitGeoIPSeries(t *testing.T) {
+	counts := map[geoIPKey]float64{
+		{asn: "13335", country: "US"}: 10,
+		{asn: "15169", country: "US"}: 7,
+		{asn: "3320", country: "DE"}:  2,
+		{asn: "2914", country: "JP"}:  1,
+	}
+
+	got := limitGeoIPSeries(counts, 3)
+	want := map[geoIPKey]float64{
+		{asn: "13335", country: "US"}:    10,
+		{asn: "15169", country: "US"}:    7,
+		{asn: "other", country: "other"}: 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := limitGeoIPSeries(counts, 0); !reflect.DeepEqual(got, counts) {
+		t.Errorf("expected no limit, got %v", got)
+	}
+}
@@ -21,7 +21,7 @@ import (
 )
 
 func readUintFromFile(path string) (uint64, error) {
-	data, err := os.ReadFile(path)
+	data, err := readSourceFile(path, os.ReadFile)
 	if err != nil {
 		return 0, err
 	}
@@ -48,3 +48,11 @@ var metricNameRegex = regexp.MustCompile(`_*[^0-9A-Za-z_]+_*`)
 func SanitizeMetricName(metricName string) string {
 	return metricNameRegex.ReplaceAllString(metricName, "_")
 }
+
+// boolToFloat converts a bool to the value of a metric.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
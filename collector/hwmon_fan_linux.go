@@ -0,0 +1,215 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nohwmon
+// +build !nohwmon
+
+package collector
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	collectorHWmonFanHealthWindow = kingpin.Flag("collector.hwmon.fan-health-window", "Time window over which fan speed stability is tracked. 0 disables fan health metrics.").Default("0s").Duration()
+)
+
+var (
+	fanRPMStddevDesc = prometheus.NewDesc("node_hwmon_fan_rpm_stddev",
+		"Standard deviation of the fan speed in RPM over --collector.hwmon.fan-health-window.",
+		hwmonLabelDesc, nil)
+	fanStalledDesc = prometheus.NewDesc("node_hwmon_fan_stalled",
+		"Whether the fan reports 0 RPM while driven by a non-zero PWM duty cycle.",
+		hwmonLabelDesc, nil)
+	fanPWMDeviationDesc = prometheus.NewDesc("node_hwmon_fan_pwm_deviation_ratio",
+		"Relative deviation of the fan speed per PWM duty cycle from its average over --collector.hwmon.fan-health-window.",
+		hwmonLabelDesc, nil)
+)
+
+// fanHealth tracks the speed of fans between scrapes. Degrading bearings show
+// up as RPM instability, or as a changing RPM for the same commanded PWM duty
+// cycle, before the fan fails.
+type fanHealth struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	samples map[string][]fanSample
+}
+
+type fanSample struct {
+	time time.Time
+	rpm  float64
+	// rpmPerPWM is the fan speed divided by the PWM duty cycle, NaN if the
+	// fan has no PWM control.
+	rpmPerPWM float64
+}
+
+func newFanHealth(window time.Duration) *fanHealth {
+	if window <= 0 {
+		return nil
+	}
+	return &fanHealth{
+		window:  window,
+		samples: map[string][]fanSample{},
+	}
+}
+
+// update records the fan speeds of a chip at now and exposes the fan health
+// metrics.
+func (f *fanHealth) update(ch chan<- prometheus.Metric, chip string, data map[string]map[string]string, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sensor, sensorData := range data {
+		_, sensorType, sensorNum, _ := explodeSensorFilename(sensor)
+		if sensorType != "fan" {
+			continue
+		}
+		rpm, err := strconv.ParseFloat(sensorData["input"], 64)
+		if err != nil {
+			continue
+		}
+
+		// Fans are usually driven by the PWM output with the same index.
+		pwm := math.NaN()
+		if pwmData, ok := data["pwm"+strconv.Itoa(sensorNum)]; ok {
+			if v, err := strconv.ParseFloat(pwmData[""], 64); err == nil {
+				pwm = v
+			}
+		}
+
+		stalled := 0.0
+		if rpm == 0 && pwm > 0 {
+			stalled = 1
+		}
+		ch <- prometheus.MustNewConstMetric(fanStalledDesc, prometheus.GaugeValue, stalled, chip, sensor)
+
+		sample := fanSample{time: now, rpm: rpm, rpmPerPWM: math.NaN()}
+		if pwm > 0 {
+			sample.rpmPerPWM = rpm / pwm
+		}
+
+		key := chip + "/" + sensor
+		samples := f.samples[key]
+		for len(samples) > 0 && now.Sub(samples[0].time) > f.window {
+			samples = samples[1:]
+		}
+		samples = append(samples, sample)
+		f.samples[key] = samples
+
+		ch <- prometheus.MustNewConstMetric(fanRPMStddevDesc, prometheus.GaugeValue, fanRPMStddev(samples), chip, sensor)
+		if deviation, ok := fanPWMDeviation(samples); ok {
+			ch <- prometheus.MustNewConstMetric(fanPWMDeviationDesc, prometheus.GaugeValue, deviation, chip, sensor)
+		}
+	}
+}
+
+// prune forgets the fans which have not been seen within the window, such as
+// the ones of removed devices or of chips which got renumbered.
+func (f *fanHealth) prune(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, samples := range f.samples {
+		if len(samples) == 0 || now.Sub(samples[len(samples)-1].time) > f.window {
+			delete(f.samples, key)
+		}
+	}
+}
+
+// fanSampleState is the persisted form of a fanSample. JSON has no NaN, so
+// fans without PWM control have no RPMPerPWM.
+type fanSampleState struct {
+	Time      time.Time `json:"time"`
+	RPM       float64   `json:"rpm"`
+	RPMPerPWM *float64  `json:"rpm_per_pwm,omitempty"`
+}
+
+func (f *fanHealth) saveState() (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := make(map[string][]fanSampleState, len(f.samples))
+	for key, samples := range f.samples {
+		for _, s := range samples {
+			ss := fanSampleState{Time: s.time, RPM: s.rpm}
+			if !math.IsNaN(s.rpmPerPWM) {
+				rpmPerPWM := s.rpmPerPWM
+				ss.RPMPerPWM = &rpmPerPWM
+			}
+			state[key] = append(state[key], ss)
+		}
+	}
+	return json.Marshal(state)
+}
+
+func (f *fanHealth) loadState(data json.RawMessage) error {
+	var state map[string][]fanSampleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, samples := range state {
+		for _, ss := range samples {
+			s := fanSample{time: ss.Time, rpm: ss.RPM, rpmPerPWM: math.NaN()}
+			if ss.RPMPerPWM != nil {
+				s.rpmPerPWM = *ss.RPMPerPWM
+			}
+			f.samples[key] = append(f.samples[key], s)
+		}
+	}
+	return nil
+}
+
+func fanRPMStddev(samples []fanSample) float64 {
+	var sum, sumSquares float64
+	for _, s := range samples {
+		sum += s.rpm
+		sumSquares += s.rpm * s.rpm
+	}
+	n := float64(len(samples))
+	mean := sum / n
+	return math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+}
+
+// fanPWMDeviation returns the relative deviation of the latest RPM per PWM
+// duty cycle from the average of the window.
+func fanPWMDeviation(samples []fanSample) (float64, bool) {
+	latest := samples[len(samples)-1].rpmPerPWM
+	if math.IsNaN(latest) {
+		return 0, false
+	}
+
+	var sum, n float64
+	for _, s := range samples {
+		if !math.IsNaN(s.rpmPerPWM) {
+			sum += s.rpmPerPWM
+			n++
+		}
+	}
+	mean := sum / n
+	if mean == 0 {
+		return 0, false
+	}
+	return math.Abs(latest-mean) / mean, true
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nohwmon
+// +build !nohwmon
+
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fanHealthMetrics runs an update of the fans of chip and returns the metric
+// values keyed by descriptor and sensor.
+func fanHealthMetrics(t *testing.T, f *fanHealth, chip string, data map[string]map[string]string, now time.Time) map[*prometheus.Desc]map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	f.update(ch, chip, data, now)
+	close(ch)
+
+	metrics := map[*prometheus.Desc]map[string]float64{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		var sensor string
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "sensor" {
+				sensor = l.GetValue()
+			}
+		}
+		if metrics[m.Desc()] == nil {
+			metrics[m.Desc()] = map[string]float64{}
+		}
+		metrics[m.Desc()][sensor] = readGauge(t, m)
+	}
+	return metrics
+}
+
+func TestFanHealth(t *testing.T) {
+	f := newFanHealth(10 * time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	// fan1 is driven by pwm1, fan2 has no PWM control, fan3 is stalled.
+	scrape := func(after time.Duration, fan1, pwm1, fan2 string) map[*prometheus.Desc]map[string]float64 {
+		return fanHealthMetrics(t, f, "chip", map[string]map[string]string{
+			"fan1": {"input": fan1},
+			"pwm1": {"": pwm1},
+			"fan2": {"input": fan2},
+			"fan3": {"input": "0"},
+			"pwm3": {"": "128"},
+		}, start.Add(after))
+	}
+
+	m := scrape(0, "1000", "100", "2000")
+	if got := m[fanStalledDesc]; got["fan1"] != 0 || got["fan2"] != 0 || got["fan3"] != 1 {
+		t.Errorf("unexpected stalled fans %v", got)
+	}
+	if got := m[fanRPMStddevDesc]; got["fan1"] != 0 || got["fan2"] != 0 {
+		t.Errorf("expected no deviation with a single sample, got %v", got)
+	}
+	if got := m[fanPWMDeviationDesc]; len(got) != 1 || got["fan1"] != 0 {
+		t.Errorf("expected the PWM deviation of fan1 only, got %v", got)
+	}
+
+	// fan1 spins faster for the same duty cycle.
+	m = scrape(time.Minute, "1400", "100", "2200")
+	if got := m[fanRPMStddevDesc]; got["fan1"] != 200 || got["fan2"] != 100 {
+		t.Errorf("unexpected RPM standard deviation %v", got)
+	}
+	if got := m[fanPWMDeviationDesc]["fan1"]; math.Abs(got-1.0/6) > 1e-9 {
+		t.Errorf("got PWM deviation %v, want 1/6", got)
+	}
+
+	// The first samples fall out of the window.
+	m = scrape(12*time.Minute, "1400", "100", "2200")
+	if got := m[fanRPMStddevDesc]; got["fan1"] != 0 || got["fan2"] != 0 {
+		t.Errorf("expected samples outside of the window to be dropped, got %v", got)
+	}
+}
+
+func TestFanHealthPrune(t *testing.T) {
+	f := newFanHealth(10 * time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	fanHealthMetrics(t, f, "old", map[string]map[string]string{"fan1": {"input": "1000"}}, start)
+	fanHealthMetrics(t, f, "chip", map[string]map[string]string{"fan1": {"input": "1000"}}, start.Add(5*time.Minute))
+	f.prune(start.Add(5 * time.Minute))
+	if len(f.samples) != 2 {
+		t.Fatalf("expected both fans within the window, got %v", f.samples)
+	}
+
+	fanHealthMetrics(t, f, "chip", map[string]map[string]string{"fan1": {"input": "1000"}}, start.Add(11*time.Minute))
+	f.prune(start.Add(11 * time.Minute))
+	if _, ok := f.samples["old/fan1"]; ok || len(f.samples) != 1 {
+		t.Errorf("expected the vanished fan to be forgotten, got %v", f.samples)
+	}
+}
+
+func TestFanPWMDeviation(t *testing.T) {
+	nan := math.NaN()
+	for _, tc := range []struct {
+		rpmPerPWM []float64
+		want      float64
+		ok        bool
+	}{
+		{[]float64{10, 10, 10}, 0, true},
+		{[]float64{10, nan, 20}, 1.0 / 3, true},
+		{[]float64{10, 20, nan}, 0, false},
+		{[]float64{0, 0}, 0, false},
+	} {
+		var samples []fanSample
+		for _, v := range tc.rpmPerPWM {
+			samples = append(samples, fanSample{rpmPerPWM: v})
+		}
+		got, ok := fanPWMDeviation(samples)
+		if ok != tc.ok || math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("fanPWMDeviation(%v) = %v, %t, want %v, %t", tc.rpmPerPWM, got, ok, tc.want, tc.ok)
+		}
+	}
+}
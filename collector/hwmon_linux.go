@@ -17,24 +17,27 @@
 package collector
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/sys/unix"
 )
 
 var (
 	collectorHWmonChipInclude = kingpin.Flag("collector.hwmon.chip-include", "Regexp of hwmon chip to include (mutually exclusive to device-exclude).").String()
 	collectorHWmonChipExclude = kingpin.Flag("collector.hwmon.chip-exclude", "Regexp of hwmon chip to exclude (mutually exclusive to device-include).").String()
 
+	collectorHWmonChipExcludeFile, collectorHWmonChipIncludeFile = deviceListFileFlags("hwmon", "chip", "hwmon chips")
+
 	hwmonInvalidMetricChars = regexp.MustCompile("[^a-z0-9:_]")
 	hwmonFilenameFormat     = regexp.MustCompile(`^(?P<type>[^0-9]+)(?P<id>[0-9]*)?(_(?P<property>.+))?$`)
 	hwmonLabelDesc          = []string{"chip", "sensor"}
@@ -52,16 +55,37 @@ func init() {
 
 type hwMonCollector struct {
 	deviceFilter deviceFilter
+	fanHealth    *fanHealth
 	logger       log.Logger
 }
 
+func (c *hwMonCollector) saveState() (json.RawMessage, error) {
+	if c.fanHealth == nil {
+		return json.RawMessage("null"), nil
+	}
+	return c.fanHealth.saveState()
+}
+
+func (c *hwMonCollector) loadState(data json.RawMessage) error {
+	if c.fanHealth == nil || string(data) == "null" {
+		return nil
+	}
+	return c.fanHealth.loadState(data)
+}
+
 // NewHwMonCollector returns a new Collector exposing /sys/class/hwmon stats
 // (similar to lm-sensors).
 func NewHwMonCollector(logger log.Logger) (Collector, error) {
+	deviceFilter, err := newDeviceFilter(*collectorHWmonChipExclude, *collectorHWmonChipInclude).
+		withListFiles(*collectorHWmonChipExcludeFile, *collectorHWmonChipIncludeFile)
+	if err != nil {
+		return nil, err
+	}
 
 	return &hwMonCollector{
 		logger:       logger,
-		deviceFilter: newDeviceFilter(*collectorHWmonChipExclude, *collectorHWmonChipInclude),
+		deviceFilter: deviceFilter,
+		fanHealth:    newFanHealth(*collectorHWmonFanHealthWindow),
 	}, nil
 }
 
@@ -86,28 +110,6 @@ func addValueFile(data map[string]map[string]string, sensor string, prop string,
 	data[sensor][prop] = value
 }
 
-// sysReadFile is a simplified os.ReadFile that invokes syscall.Read directly.
-func sysReadFile(file string) ([]byte, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	// On some machines, hwmon drivers are broken and return EAGAIN.  This causes
-	// Go's os.ReadFile implementation to poll forever.
-	//
-	// Since we either want to read data or bail immediately, do the simplest
-	// possible read using system call directly.
-	b := make([]byte, 128)
-	n, err := unix.Read(int(f.Fd()), b)
-	if err != nil {
-		return nil, err
-	}
-
-	return b[:n], nil
-}
-
 // explodeSensorFilename splits a sensor name into <type><num>_<property>.
 func explodeSensorFilename(filename string) (ok bool, sensorType string, sensorNum int, sensorProperty string) {
 	matches := hwmonFilenameFormat.FindStringSubmatch(filename)
@@ -180,6 +182,10 @@ func (c *hwMonCollector) updateHwmon(ch chan<- prometheus.Metric, dir string) er
 		}
 	}
 
+	if c.fanHealth != nil {
+		c.fanHealth.update(ch, hwmonName, data, time.Now())
+	}
+
 	hwmonChipName, err := c.hwmonHumanReadableChipName(dir)
 	if err == nil {
 		// sensor chip metadata
@@ -468,5 +474,9 @@ func (c *hwMonCollector) Update(ch chan<- prometheus.Metric) error {
 		}
 	}
 
+	if c.fanHealth != nil {
+		c.fanHealth.prune(time.Now())
+	}
+
 	return lastErr
 }
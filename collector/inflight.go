@@ -0,0 +1,69 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// inFlightUpdate is a collector update in progress.
+type inFlightUpdate struct {
+	collector string
+	begin     time.Time
+}
+
+// inFlightUpdates holds the collector updates in progress, so that a
+// collector hung on e.g. an unresponsive sysfs read can be told from a slow
+// one.
+var inFlightUpdates = struct {
+	sync.Mutex
+	next    uint64
+	updates map[uint64]inFlightUpdate
+}{updates: map[uint64]inFlightUpdate{}}
+
+// trackUpdate records the runs of an update in inFlightUpdates.
+func trackUpdate(name string, update func(chan<- prometheus.Metric) error) func(chan<- prometheus.Metric) error {
+	return func(ch chan<- prometheus.Metric) error {
+		inFlightUpdates.Lock()
+		id := inFlightUpdates.next
+		inFlightUpdates.next++
+		inFlightUpdates.updates[id] = inFlightUpdate{collector: name, begin: time.Now()}
+		inFlightUpdates.Unlock()
+
+		defer func() {
+			inFlightUpdates.Lock()
+			delete(inFlightUpdates.updates, id)
+			inFlightUpdates.Unlock()
+		}()
+		return update(ch)
+	}
+}
+
+// OldestUpdate returns the collector and the start of the longest running
+// collector update, ok is false if no update is running. Updates abandoned
+// after --collector.timeout are still running until their collector returns.
+func OldestUpdate() (collector string, begin time.Time, ok bool) {
+	inFlightUpdates.Lock()
+	defer inFlightUpdates.Unlock()
+
+	for _, u := range inFlightUpdates.updates {
+		if !ok || u.begin.Before(begin) {
+			collector, begin, ok = u.collector, u.begin, true
+		}
+	}
+	return collector, begin, ok
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOldestUpdate(t *testing.T) {
+	if _, _, ok := OldestUpdate(); ok {
+		t.Fatal("unexpected update in progress")
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	update := trackUpdate("inflight_test", func(ch chan<- prometheus.Metric) error {
+		close(started)
+		<-release
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		update(nil)
+		close(done)
+	}()
+
+	<-started
+	if name, begin, ok := OldestUpdate(); !ok || name != "inflight_test" || begin.IsZero() {
+		t.Errorf("got %q, %v, %v, want the running update", name, begin, ok)
+	}
+	close(release)
+	<-done
+	if _, _, ok := OldestUpdate(); ok {
+		t.Error("finished update still in progress")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+	"sync"
+)
+
+// maxInternedInt bounds the numbers interned by itoaLabel, which covers the
+// CPU numbers of the largest machines.
+const maxInternedInt = 1 << 14
+
+// internedInts holds the formatted numbers returned by itoaLabel.
+var internedInts = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// itoaLabel formats a number used as label value, such as a CPU number. Per
+// CPU metrics format the same numbers for every series on every scrape, which
+// adds up to a lot of garbage on hosts with hundreds of CPUs, so the strings
+// are interned.
+func itoaLabel(n int) string {
+	if n < 0 || n >= maxInternedInt {
+		return strconv.Itoa(n)
+	}
+
+	internedInts.RLock()
+	if n < len(internedInts.values) {
+		s := internedInts.values[n]
+		internedInts.RUnlock()
+		return s
+	}
+	internedInts.RUnlock()
+
+	internedInts.Lock()
+	defer internedInts.Unlock()
+	for i := len(internedInts.values); i <= n; i++ {
+		internedInts.values = append(internedInts.values, strconv.Itoa(i))
+	}
+	return internedInts.values[n]
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestItoaLabel(t *testing.T) {
+	for _, n := range []int{-1, 0, 7, 255, 1023, maxInternedInt - 1, maxInternedInt, 1 << 20} {
+		if got, want := itoaLabel(n), strconv.Itoa(n); got != want {
+			t.Errorf("itoaLabel(%d) = %q, want %q", n, got, want)
+		}
+	}
+
+	itoaLabel(1023)
+	if allocs := testing.AllocsPerRun(100, func() { itoaLabel(1023) }); allocs != 0 {
+		t.Errorf("itoaLabel of an interned number allocated %v times", allocs)
+	}
+}
+
+func BenchmarkItoaLabel(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		itoaLabel(i % 512)
+	}
+}
@@ -43,7 +43,7 @@ func (c *interruptsCollector) Update(ch chan<- prometheus.Metric) (err error) {
 			if err != nil {
 				return fmt.Errorf("invalid value %s in interrupts: %w", value, err)
 			}
-			ch <- c.desc.mustNewConstMetric(fv, strconv.Itoa(cpuNo), name, interrupt.info, interrupt.devices)
+			ch <- c.desc.mustNewConstMetric(fv, itoaLabel(cpuNo), name, interrupt.info, interrupt.devices)
 		}
 	}
 	return err
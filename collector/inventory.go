@@ -0,0 +1,74 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// CollectorInfo describes a collector built into node_exporter.
+type CollectorInfo struct {
+	Name    string
+	Enabled bool
+	// Flags are the flags of the collector, e.g.
+	// --collector.filesystem.mount-points-exclude.
+	Flags []CollectorFlag
+}
+
+// CollectorFlag is a command line flag of a collector.
+type CollectorFlag struct {
+	Name    string
+	Help    string
+	Default string
+	// Value is the current value, with the passwords of URLs redacted.
+	Value string
+}
+
+// Collectors returns every collector, enabled or not, sorted by name.
+func Collectors() []CollectorInfo {
+	flags := kingpin.CommandLine.Model().Flags
+	infos := make([]CollectorInfo, 0, len(collectorState))
+	for name, enabled := range collectorState {
+		info := CollectorInfo{Name: name, Enabled: *enabled, Flags: []CollectorFlag{}}
+		for _, f := range flags {
+			if !strings.HasPrefix(f.Name, "collector."+name+".") {
+				continue
+			}
+			info.Flags = append(info.Flags, CollectorFlag{
+				Name:    f.Name,
+				Help:    f.Help,
+				Default: strings.Join(f.Default, ","),
+				Value:   redactURLPassword(f.String()),
+			})
+		}
+		sort.Slice(info.Flags, func(i, j int) bool { return info.Flags[i].Name < info.Flags[j].Name })
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// redactURLPassword redacts the password of a URL with credentials, such as
+// --collector.supervisord.url, leaving other values as they are.
+func redactURLPassword(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
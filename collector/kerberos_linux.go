@@ -0,0 +1,399 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nokerberos
+// +build !nokerberos
+
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const kerberosSubsystem = "kerberos"
+
+var (
+	kerberosKeytab = kingpin.Flag("collector.kerberos.keytab",
+		"Keytab whose keys are exposed by the kerberos collector.").Default("/etc/krb5.keytab").String()
+	kerberosCcaches = kingpin.Flag("collector.kerberos.ccache",
+		"Credential cache file whose tickets are exposed by the kerberos collector. Can be repeated.").Strings()
+	kerberosSSSDPath = kingpin.Flag("collector.kerberos.sssd-path",
+		"Directory of the sssd caches.").Default("/var/lib/sss").String()
+)
+
+type kerberosCollector struct {
+	logger   log.Logger
+	sssd     func() (sssdInterface, error)
+	keytab   string
+	ccaches  []string
+	sssdPath string
+
+	keytabKeyTimestampDesc *prometheus.Desc
+	ticketExpiryDesc       *prometheus.Desc
+	ticketRenewUntilDesc   *prometheus.Desc
+	sssdDomainOnlineDesc   *prometheus.Desc
+	sssdDomainActiveDesc   *prometheus.Desc
+	sssdCacheSizeBytesDesc *prometheus.Desc
+}
+
+func init() {
+	registerCollector("kerberos", defaultDisabled, NewKerberosCollector)
+}
+
+// NewKerberosCollector returns a new Collector exposing the keys of the host
+// keytab, the tickets of credential caches and the state of sssd.
+func NewKerberosCollector(logger log.Logger) (Collector, error) {
+	return newKerberosCollector(logger, newSSSDInfopipe), nil
+}
+
+func newKerberosCollector(logger log.Logger, sssd func() (sssdInterface, error)) *kerberosCollector {
+	return &kerberosCollector{
+		logger:   logger,
+		sssd:     sssd,
+		keytab:   *kerberosKeytab,
+		ccaches:  *kerberosCcaches,
+		sssdPath: *kerberosSSSDPath,
+		keytabKeyTimestampDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, kerberosSubsystem, "keytab_key_timestamp_seconds"),
+			"Time the keys of a principal and version in the keytab were set. Machine accounts expire when their key is not renewed.",
+			[]string{"keytab", "principal", "kvno"}, nil,
+		),
+		ticketExpiryDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, kerberosSubsystem, "ticket_expiry_timestamp_seconds"),
+			"Time a ticket in a credential cache expires.",
+			[]string{"ccache", "client", "server"}, nil,
+		),
+		ticketRenewUntilDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, kerberosSubsystem, "ticket_renew_until_timestamp_seconds"),
+			"Time until which a ticket in a credential cache can be renewed, 0 if it is not renewable.",
+			[]string{"ccache", "client", "server"}, nil,
+		),
+		sssdDomainOnlineDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sssd", "domain_online"),
+			"Whether the backend of an sssd domain is online.",
+			[]string{"domain"}, nil,
+		),
+		sssdDomainActiveDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sssd", "domain_active_server"),
+			"Server of an sssd domain the backend talks to, by service.",
+			[]string{"domain", "service", "server"}, nil,
+		),
+		sssdCacheSizeBytesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "sssd", "cache_size_bytes"),
+			"Size of a cache file of sssd.",
+			[]string{"cache"}, nil,
+		),
+	}
+}
+
+func (c *kerberosCollector) Update(ch chan<- prometheus.Metric) error {
+	if err := c.updateKeytab(ch); err != nil {
+		return err
+	}
+	for _, path := range c.ccaches {
+		if err := c.updateCcache(ch, path); err != nil {
+			return err
+		}
+	}
+	if err := c.updateSSSDCaches(ch); err != nil {
+		return err
+	}
+	return c.updateSSSDDomains(ch)
+}
+
+func (c *kerberosCollector) updateKeytab(ch chan<- prometheus.Metric) error {
+	data, err := os.ReadFile(rootfsFilePath(c.keytab))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		level.Debug(c.logger).Log("msg", "unable to read keytab", "path", c.keytab, "err", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := parseKeytab(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse keytab %s: %w", c.keytab, err)
+	}
+
+	// Keytabs have an entry per encryption type of a key version, which
+	// are set together.
+	type key struct {
+		principal string
+		kvno      uint32
+	}
+	timestamps := map[key]uint32{}
+	for _, e := range entries {
+		k := key{e.principal, e.kvno}
+		if e.timestamp > timestamps[k] {
+			timestamps[k] = e.timestamp
+		}
+	}
+	for k, timestamp := range timestamps {
+		ch <- prometheus.MustNewConstMetric(c.keytabKeyTimestampDesc, prometheus.GaugeValue, float64(timestamp),
+			c.keytab, k.principal, strconv.FormatUint(uint64(k.kvno), 10))
+	}
+	return nil
+}
+
+func (c *kerberosCollector) updateCcache(ch chan<- prometheus.Metric, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Credential caches only exist while someone is logged in.
+		level.Debug(c.logger).Log("msg", "credential cache does not exist", "path", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	creds, err := parseCcache(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse credential cache %s: %w", path, err)
+	}
+	for _, cred := range creds {
+		ch <- prometheus.MustNewConstMetric(c.ticketExpiryDesc, prometheus.GaugeValue, float64(cred.endTime), path, cred.client, cred.server)
+		ch <- prometheus.MustNewConstMetric(c.ticketRenewUntilDesc, prometheus.GaugeValue, float64(cred.renewTill), path, cred.client, cred.server)
+	}
+	return nil
+}
+
+func (c *kerberosCollector) updateSSSDCaches(ch chan<- prometheus.Metric) error {
+	root := rootfsFilePath(c.sssdPath)
+	for _, pattern := range []string{"db/*.ldb", "mc/*"} {
+		files, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fi, err := os.Stat(file)
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "unable to stat sssd cache", "path", file, "err", err)
+				continue
+			}
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+			cache, _ := filepath.Rel(root, file)
+			ch <- prometheus.MustNewConstMetric(c.sssdCacheSizeBytesDesc, prometheus.GaugeValue, float64(fi.Size()), cache)
+		}
+	}
+	return nil
+}
+
+func (c *kerberosCollector) updateSSSDDomains(ch chan<- prometheus.Metric) error {
+	sssd, err := c.sssd()
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "sssd infopipe is not available", "err", err)
+		return nil
+	}
+	defer sssd.close()
+
+	domains, err := sssd.domains()
+	if err != nil {
+		return fmt.Errorf("unable to list sssd domains: %w", err)
+	}
+	for _, d := range domains {
+		ch <- prometheus.MustNewConstMetric(c.sssdDomainOnlineDesc, prometheus.GaugeValue, boolToFloat(d.online), d.name)
+		for service, server := range d.activeServers {
+			ch <- prometheus.MustNewConstMetric(c.sssdDomainActiveDesc, prometheus.GaugeValue, 1, d.name, service, server)
+		}
+	}
+	return nil
+}
+
+// kerberosReader reads the big-endian fields of keytabs and credential
+// caches, remembering the first error.
+type kerberosReader struct {
+	data []byte
+	err  error
+}
+
+var errKerberosTruncated = errors.New("truncated data")
+
+func (r *kerberosReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errKerberosTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kerberosReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *kerberosReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *kerberosReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// keytabEntry is a key of a principal in a keytab.
+type keytabEntry struct {
+	principal string
+	timestamp uint32
+	kvno      uint32
+}
+
+// parseKeytab parses a keytab in the version 2 format of MIT Kerberos, also
+// written by Heimdal, Samba and adcli.
+func parseKeytab(data []byte) ([]keytabEntry, error) {
+	r := &kerberosReader{data: data}
+	if version := r.uint16(); r.err != nil || version != 0x0502 {
+		return nil, fmt.Errorf("unsupported keytab version %#x", version)
+	}
+
+	var entries []keytabEntry
+	for len(r.data) > 0 {
+		size := int32(r.uint32())
+		if size < 0 {
+			// Holes of deleted entries.
+			r.bytes(int(-size))
+			continue
+		}
+		record := r.bytes(int(size))
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		er := &kerberosReader{data: record}
+		components := make([]string, er.uint16())
+		realm := string(er.bytes(int(er.uint16())))
+		for i := range components {
+			components[i] = string(er.bytes(int(er.uint16())))
+		}
+		er.uint32() // name type
+		e := keytabEntry{
+			principal: strings.Join(components, "/") + "@" + realm,
+			timestamp: er.uint32(),
+			kvno:      uint32(er.uint8()),
+		}
+		er.uint16() // key type
+		er.bytes(int(er.uint16()))
+		// The 8 bit version is extended by an optional 32 bit one.
+		if len(er.data) >= 4 {
+			if kvno := er.uint32(); kvno != 0 {
+				e.kvno = kvno
+			}
+		}
+		if er.err != nil {
+			return nil, er.err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ccacheCredential is a ticket in a credential cache.
+type ccacheCredential struct {
+	client    string
+	server    string
+	endTime   uint32
+	renewTill uint32
+}
+
+// parseCcache parses a credential cache of the FILE type in version 3 or 4.
+func parseCcache(data []byte) ([]ccacheCredential, error) {
+	r := &kerberosReader{data: data}
+	version := r.uint16()
+	switch {
+	case r.err != nil:
+		return nil, r.err
+	case version == 0x0504:
+		r.bytes(int(r.uint16())) // header
+	case version != 0x0503:
+		return nil, fmt.Errorf("unsupported credential cache version %#x", version)
+	}
+	readCcachePrincipal(r) // default principal
+
+	var creds []ccacheCredential
+	for len(r.data) > 0 && r.err == nil {
+		cred := ccacheCredential{
+			client: readCcachePrincipal(r),
+			server: readCcachePrincipal(r),
+		}
+		r.uint16() // key type
+		if version == 0x0503 {
+			r.uint16()
+		}
+		r.bytes(int(r.uint32()))
+		r.uint32() // auth time
+		r.uint32() // start time
+		cred.endTime = r.uint32()
+		cred.renewTill = r.uint32()
+		r.uint8()  // is_skey
+		r.uint32() // ticket flags
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.uint16() // address type
+			r.bytes(int(r.uint32()))
+		}
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.uint16() // auth data type
+			r.bytes(int(r.uint32()))
+		}
+		r.bytes(int(r.uint32())) // ticket
+		r.bytes(int(r.uint32())) // second ticket
+		if r.err != nil {
+			return nil, r.err
+		}
+		// Configuration entries of the cache are not tickets.
+		if strings.HasSuffix(cred.server, "@X-CACHECONF:") {
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	return creds, r.err
+}
+
+func readCcachePrincipal(r *kerberosReader) string {
+	r.uint32() // name type
+	n := r.uint32()
+	// Every component takes at least 4 bytes.
+	if uint64(n)*4 > uint64(len(r.data)) {
+		r.err = errKerberosTruncated
+		return ""
+	}
+	components := make([]string, n)
+	realm := string(r.bytes(int(r.uint32())))
+	for i := range components {
+		components[i] = string(r.bytes(int(r.uint32())))
+	}
+	return strings.Join(components, "/") + "@" + realm
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nokerberos
+// +build !nokerberos
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testSSSD struct{}
+
+func (testSSSD) domains() ([]sssdDomain, error) {
+	return []sssdDomain{
+		{name: "example.com", online: true, activeServers: map[string]string{"AD": "dc1.example.com"}},
+		{name: "lab.example.com", online: false, activeServers: map[string]string{}},
+	}, nil
+}
+
+func (testSSSD) close() {}
+
+type testKerberosCollector struct {
+	c *kerberosCollector
+}
+
+func (c testKerberosCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c testKerberosCollector) Collect(ch chan<- prometheus.Metric) {
+	c.c.Update(ch)
+}
+
+func TestKerberosCollector(t *testing.T) {
+	defer func(rootfs, keytab, sssd string, ccaches []string) {
+		*rootfsPath, *kerberosKeytab, *kerberosSSSDPath, *kerberosCcaches = rootfs, keytab, sssd, ccaches
+	}(*rootfsPath, *kerberosKeytab, *kerberosSSSDPath, *kerberosCcaches)
+	*rootfsPath = "fixtures"
+	*kerberosKeytab = "/kerberos/krb5.keytab"
+	*kerberosSSSDPath = "/kerberos/sss"
+	*kerberosCcaches = []string{"fixtures/kerberos/krb5cc", "fixtures/kerberos/missing"}
+
+	want := `# HELP node_kerberos_keytab_key_timestamp_seconds Time the keys of a principal and version in the keytab were set. Machine accounts expire when their key is not renewed.
+# TYPE node_kerberos_keytab_key_timestamp_seconds gauge
+node_kerberos_keytab_key_timestamp_seconds{keytab="/kerberos/krb5.keytab",kvno="2",principal="host/web01.example.com@EXAMPLE.COM"} 1.7e+09
+node_kerberos_keytab_key_timestamp_seconds{keytab="/kerberos/krb5.keytab",kvno="3",principal="WEB01$@EXAMPLE.COM"} 1.702592e+09
+node_kerberos_keytab_key_timestamp_seconds{keytab="/kerberos/krb5.keytab",kvno="3",principal="host/web01.example.com@EXAMPLE.COM"} 1.702592e+09
+node_kerberos_keytab_key_timestamp_seconds{keytab="/kerberos/krb5.keytab",kvno="300",principal="WEB01$@EXAMPLE.COM"} 1.702592e+09
+# HELP node_kerberos_ticket_expiry_timestamp_seconds Time a ticket in a credential cache expires.
+# TYPE node_kerberos_ticket_expiry_timestamp_seconds gauge
+node_kerberos_ticket_expiry_timestamp_seconds{ccache="fixtures/kerberos/krb5cc",client="WEB01$@EXAMPLE.COM",server="krbtgt/EXAMPLE.COM@EXAMPLE.COM"} 1.702636e+09
+node_kerberos_ticket_expiry_timestamp_seconds{ccache="fixtures/kerberos/krb5cc",client="WEB01$@EXAMPLE.COM",server="ldap/dc1.example.com@EXAMPLE.COM"} 1.702636e+09
+# HELP node_kerberos_ticket_renew_until_timestamp_seconds Time until which a ticket in a credential cache can be renewed, 0 if it is not renewable.
+# TYPE node_kerberos_ticket_renew_until_timestamp_seconds gauge
+node_kerberos_ticket_renew_until_timestamp_seconds{ccache="fixtures/kerberos/krb5cc",client="WEB01$@EXAMPLE.COM",server="krbtgt/EXAMPLE.COM@EXAMPLE.COM"} 1.7032048e+09
+node_kerberos_ticket_renew_until_timestamp_seconds{ccache="fixtures/kerberos/krb5cc",client="WEB01$@EXAMPLE.COM",server="ldap/dc1.example.com@EXAMPLE.COM"} 0
+# HELP node_sssd_cache_size_bytes Size of a cache file of sssd.
+# TYPE node_sssd_cache_size_bytes gauge
+node_sssd_cache_size_bytes{cache="db/cache_example.com.ldb"} 1024
+node_sssd_cache_size_bytes{cache="mc/passwd"} 512
+# HELP node_sssd_domain_active_server Server of an sssd domain the backend talks to, by service.
+# TYPE node_sssd_domain_active_server gauge
+node_sssd_domain_active_server{domain="example.com",server="dc1.example.com",service="AD"} 1
+# HELP node_sssd_domain_online Whether the backend of an sssd domain is online.
+# TYPE node_sssd_domain_online gauge
+node_sssd_domain_online{domain="example.com"} 1
+node_sssd_domain_online{domain="lab.example.com"} 0
+`
+	c := newKerberosCollector(log.NewNopLogger(), func() (sssdInterface, error) { return testSSSD{}, nil })
+	if err := testutil.CollectAndCompare(testKerberosCollector{c}, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseKerberosTruncated(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x05, 0x02, 0x00, 0x00, 0x00, 0x10, 0x00},
+		{0x05, 0x02, 0x00, 0x00, 0x00, 0x04, 0x00, 0x01, 0xff, 0xff},
+	} {
+		if _, err := parseKeytab(data); err == nil {
+			t.Errorf("expected error parsing keytab %x", data)
+		}
+	}
+	if _, err := parseKeytab([]byte{0x05, 0x01}); err == nil {
+		t.Error("expected error parsing keytab of version 1")
+	}
+	if _, err := parseCcache([]byte{0x05, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff}); err == nil {
+		t.Error("expected error parsing truncated credential cache")
+	}
+}
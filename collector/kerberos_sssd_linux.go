@@ -0,0 +1,128 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nokerberos
+// +build !nokerberos
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	sssdInfopipeBusName = "org.freedesktop.sssd.infopipe"
+	sssdInfopipePath    = "/org/freedesktop/sssd/infopipe"
+)
+
+// sssdDomain is the state of a domain of sssd.
+type sssdDomain struct {
+	name   string
+	online bool
+	// activeServers maps the services of the domain, e.g. AD or AD_GC, to
+	// the server their backend talks to.
+	activeServers map[string]string
+}
+
+// sssdInterface is implemented by the infopipe of sssd.
+type sssdInterface interface {
+	domains() ([]sssdDomain, error)
+	close()
+}
+
+// sssdInfopipe queries sssd over the system bus, which requires the ifp
+// service to be enabled in sssd.conf.
+type sssdInfopipe struct {
+	conn *dbus.Conn
+}
+
+func newSSSDInfopipe() (sssdInterface, error) {
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var running bool
+	if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, sssdInfopipeBusName).Store(&running); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The infopipe is activated on demand, so check whether it can be.
+	if !running {
+		var activatable []string
+		if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		for _, name := range activatable {
+			running = running || name == sssdInfopipeBusName
+		}
+	}
+	if !running {
+		conn.Close()
+		return nil, errors.New("sssd infopipe is not running")
+	}
+	return &sssdInfopipe{conn: conn}, nil
+}
+
+func (s *sssdInfopipe) close() { s.conn.Close() }
+
+func (s *sssdInfopipe) domains() ([]sssdDomain, error) {
+	var paths []dbus.ObjectPath
+	if err := s.conn.Object(sssdInfopipeBusName, sssdInfopipePath).Call(sssdInfopipeBusName+".ListDomains", 0).Store(&paths); err != nil {
+		return nil, err
+	}
+
+	domains := make([]sssdDomain, 0, len(paths))
+	for _, path := range paths {
+		object := s.conn.Object(sssdInfopipeBusName, path)
+		name, err := object.GetProperty(sssdInfopipeBusName + ".Domains.name")
+		if err != nil {
+			return nil, err
+		}
+		d := sssdDomain{name: fmt.Sprint(name.Value()), activeServers: map[string]string{}}
+		if err := object.Call(sssdInfopipeBusName+".Domains.Domain.IsOnline", 0).Store(&d.online); err != nil {
+			return nil, fmt.Errorf("unable to get state of domain %s: %w", d.name, err)
+		}
+
+		var services []string
+		if err := object.Call(sssdInfopipeBusName+".Domains.Domain.ListServices", 0).Store(&services); err != nil {
+			return nil, fmt.Errorf("unable to list services of domain %s: %w", d.name, err)
+		}
+		for _, service := range services {
+			var server string
+			if err := object.Call(sssdInfopipeBusName+".Domains.Domain.ActiveServer", 0, service).Store(&server); err != nil {
+				return nil, fmt.Errorf("unable to get active server of domain %s: %w", d.name, err)
+			}
+			// There is no active server while the backend is offline.
+			if server != "" {
+				d.activeServers[service] = server
+			}
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+)
+
+// configLabels are the constant labels of the config file, added to the
+// metrics of all collectors or of one collector.
+type configLabels struct {
+	global     map[string]string
+	collectors map[string]map[string]string
+}
+
+// currentLabels holds the labels of the loaded config file.
+var currentLabels = struct {
+	sync.RWMutex
+	labels configLabels
+}{}
+
+func setConfigLabels(labels configLabels) {
+	currentLabels.Lock()
+	defer currentLabels.Unlock()
+	currentLabels.labels = labels
+}
+
+// collectorLabels returns the constant labels of a collector, sorted by name.
+// Labels of the collector take precedence over the global ones.
+func collectorLabels(name string) []*dto.LabelPair {
+	currentLabels.RLock()
+	defer currentLabels.RUnlock()
+
+	merged := map[string]string{}
+	for n, v := range currentLabels.labels.global {
+		merged[n] = v
+	}
+	for n, v := range currentLabels.labels.collectors[name] {
+		merged[n] = v
+	}
+	pairs := make([]*dto.LabelPair, 0, len(merged))
+	for n, v := range merged {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(n), Value: proto.String(v)})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].GetName() < pairs[j].GetName()
+	})
+	return pairs
+}
+
+// parseConfigLabels converts the labels of a collector in the config file,
+// which are a map of label names to values.
+func parseConfigLabels(value interface{}) (map[string]string, error) {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("labels must be a map of names to values")
+	}
+	labels := make(map[string]string, len(m))
+	for n, v := range m {
+		switch v.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			return nil, fmt.Errorf("value of label %q must be a string", n)
+		}
+		labels[fmt.Sprint(n)] = fmt.Sprint(v)
+	}
+	return labels, checkLabelNames(labels)
+}
+
+func checkLabelNames(labels map[string]string) error {
+	for name := range labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	return nil
+}
+
+// MergeLabels adds constant labels to a metric and sorts its labels by name.
+// Labels the metric already has keep their own value.
+func MergeLabels(m *dto.Metric, labels []*dto.LabelPair) {
+	existing := make(map[string]bool, len(m.Label))
+	for _, l := range m.Label {
+		existing[l.GetName()] = true
+	}
+	for _, l := range labels {
+		if !existing[l.GetName()] {
+			m.Label = append(m.Label, l)
+		}
+	}
+	sort.Slice(m.Label, func(i, j int) bool {
+		return m.Label[i].GetName() < m.Label[j].GetName()
+	})
+}
+
+// labeledMetric adds constant labels to a metric.
+type labeledMetric struct {
+	prometheus.Metric
+	labels []*dto.LabelPair
+}
+
+// Write implements prometheus.Metric.
+func (m labeledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	MergeLabels(out, m.labels)
+	return nil
+}
+
+// updateWithLabels runs the update of a collector, adding constant labels to
+// the metrics it exposes. The node_scrape_collector metrics about the run are
+// not passed through it.
+func updateWithLabels(update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric, labels []*dto.LabelPair) error {
+	labeled := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range labeled {
+			ch <- labeledMetric{Metric: m, labels: labels}
+		}
+		close(done)
+	}()
+
+	err := update(labeled)
+	close(labeled)
+	<-done
+	return err
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nolisteners
+// +build !nolisteners
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+var (
+	listenersPorts = kingpin.Flag("collector.listeners.ports", "Comma separated list of ports and port ranges to expose, e.g. \"1-1023,8080\". Defaults to all ports.").Default("").String()
+)
+
+type listenersCollector struct {
+	fs     procfs.FS
+	ports  portRanges
+	info   *prometheus.Desc
+	logger log.Logger
+}
+
+// listener is a socket accepting connections or datagrams.
+type listener struct {
+	protocol string
+	address  string
+	port     uint64
+	inode    uint64
+}
+
+// listenerProcess identifies the process owning a socket.
+type listenerProcess struct {
+	comm   string
+	cgroup string
+}
+
+func init() {
+	registerCollector("listeners", defaultDisabled, NewListenersCollector)
+}
+
+// NewListenersCollector returns a new Collector exposing listening TCP and
+// UDP sockets.
+func NewListenersCollector(logger log.Logger) (Collector, error) {
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open procfs: %w", err)
+	}
+
+	ports, err := parsePortRanges(*listenersPorts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collector.listeners.ports: %w", err)
+	}
+
+	return &listenersCollector{
+		fs:    fs,
+		ports: ports,
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listener", "info"),
+			"Listening socket and the process owning it. comm and cgroup are empty if the process cannot be determined.",
+			[]string{"protocol", "address", "port", "comm", "cgroup"}, nil,
+		),
+		logger: logger,
+	}, nil
+}
+
+func (c *listenersCollector) Update(ch chan<- prometheus.Metric) error {
+	listeners, err := c.listeners()
+	if err != nil {
+		return err
+	}
+
+	owners := c.socketOwners()
+
+	// Sockets bound with SO_REUSEPORT show up several times.
+	seen := map[string]bool{}
+	for _, l := range listeners {
+		owner := owners[l.inode]
+		port := strconv.FormatUint(l.port, 10)
+		key := strings.Join([]string{l.protocol, l.address, port, owner.comm, owner.cgroup}, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1, l.protocol, l.address, port, owner.comm, owner.cgroup)
+	}
+
+	return nil
+}
+
+func (c *listenersCollector) listeners() ([]listener, error) {
+	var listeners []listener
+
+	for _, src := range []struct {
+		protocol string
+		file     string
+		read     func() (procfs.NetTCP, error)
+		listen   func(st uint64, remPort uint64) bool
+	}{
+		{"tcp", "net/tcp", c.fs.NetTCP, isTCPListening},
+		{"tcp6", "net/tcp6", c.fs.NetTCP6, isTCPListening},
+		{"udp", "net/udp", udpAsTCP(c.fs.NetUDP), isUDPListening},
+		{"udp6", "net/udp6", udpAsTCP(c.fs.NetUDP6), isUDPListening},
+	} {
+		if _, err := os.Stat(procFilePath(src.file)); err != nil {
+			level.Debug(c.logger).Log("msg", "socket table not found, skipping", "file", src.file)
+			continue
+		}
+		sockets, err := src.read()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get %s sockets: %w", src.protocol, err)
+		}
+		for _, s := range sockets {
+			if !src.listen(s.St, s.RemPort) || !c.ports.contains(s.LocalPort) {
+				continue
+			}
+			listeners = append(listeners, listener{
+				protocol: src.protocol,
+				address:  s.LocalAddr.String(),
+				port:     s.LocalPort,
+				inode:    s.Inode,
+			})
+		}
+	}
+
+	return listeners, nil
+}
+
+// udpAsTCP adapts a UDP socket table reader to the TCP one, both tables share
+// the same format.
+func udpAsTCP(read func() (procfs.NetUDP, error)) func() (procfs.NetTCP, error) {
+	return func() (procfs.NetTCP, error) {
+		sockets, err := read()
+		return procfs.NetTCP(sockets), err
+	}
+}
+
+func isTCPListening(st uint64, _ uint64) bool {
+	return tcpConnectionState(st) == tcpListen
+}
+
+// isUDPListening reports whether a UDP socket is bound but not connected.
+func isUDPListening(st uint64, remPort uint64) bool {
+	return tcpConnectionState(st) == tcpClose && remPort == 0
+}
+
+// socketOwners maps socket inodes to the processes holding them. Processes
+// whose file descriptors cannot be read, usually due to missing privileges,
+// are skipped.
+func (c *listenersCollector) socketOwners() map[uint64]listenerProcess {
+	owners := map[uint64]listenerProcess{}
+
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "failed to list processes", "err", err)
+		return owners
+	}
+
+	for _, p := range procs {
+		targets, err := p.FileDescriptorTargets()
+		if err != nil {
+			continue
+		}
+
+		var owner *listenerProcess
+		for _, target := range targets {
+			if !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if owner == nil {
+				owner = &listenerProcess{}
+				owner.comm, _ = p.Comm()
+				if cgroups, err := p.Cgroups(); err == nil {
+					owner.cgroup = processCgroup(cgroups)
+				}
+			}
+			if _, ok := owners[inode]; !ok {
+				owners[inode] = *owner
+			}
+		}
+	}
+
+	return owners
+}
+
+// processCgroup returns the path of a process in the unified hierarchy, or in
+// the systemd named hierarchy on hosts using only cgroup v1. The controller
+// hierarchies of cgroup v1 are not used as they may place a process in
+// different groups.
+func processCgroup(cgroups []procfs.Cgroup) string {
+	var named string
+	for _, cg := range cgroups {
+		if cg.HierarchyID == 0 {
+			return cg.Path
+		}
+		for _, controller := range cg.Controllers {
+			if controller == "name=systemd" {
+				named = cg.Path
+			}
+		}
+	}
+	return named
+}
+
+type portRange struct {
+	from, to uint64
+}
+
+// portRanges is a set of port ranges. An empty set contains all ports.
+type portRanges []portRange
+
+// parsePortRanges parses a comma separated list of ports and port ranges,
+// e.g. "22,80,8000-8100".
+func parsePortRanges(s string) (portRanges, error) {
+	var ranges portRanges
+	if s == "" {
+		return ranges, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+		f, err := strconv.ParseUint(from, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", from, err)
+		}
+		t, err := strconv.ParseUint(to, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %w", to, err)
+		}
+		if f > t {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, portRange{from: f, to: t})
+	}
+
+	return ranges, nil
+}
+
+func (r portRanges) contains(port uint64) bool {
+	if len(r) == 0 {
+		return true
+	}
+	for _, pr := range r {
+		if port >= pr.from && port <= pr.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nolisteners
+// +build !nolisteners
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/procfs"
+)
+
+func TestListenersCollector(t *testing.T) {
+	proc := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(proc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const header = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	write("net/tcp", header+
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0\n"+
+		"   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0\n"+
+		"   2: 0100007F:0016 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 100 0 0 10 0\n")
+	write("net/udp", header+
+		"   0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 2001 2 0000000000000000 0\n"+
+		"   1: 0100007F:A1B2 0100007F:0035 01 00000000:00000000 00:00000000 00000000     0        0 2002 2 0000000000000000 0\n")
+	write("100/comm", "sshd\n")
+	write("100/cgroup", "12:cpu,cpuacct:/\n1:name=systemd:/system.slice/sshd.service\n0::/system.slice/sshd.service\n")
+	write("200/comm", "dnsmasq\n")
+	write("200/cgroup", "3:memory:/\n1:name=systemd:/system.slice/dnsmasq.service\n")
+	for target, link := range map[string]string{
+		"100/fd/3": "socket:[1001]",
+		"100/fd/4": "socket:[1003]",
+		"200/fd/5": "socket:[2001]",
+		"200/fd/6": "/dev/null",
+	} {
+		if err := os.MkdirAll(filepath.Join(proc, filepath.Dir(target)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(link, filepath.Join(proc, target)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(path, ports string) { *procPath, *listenersPorts = path, ports }(*procPath, *listenersPorts)
+	*procPath = proc
+	*listenersPorts = "1-1023"
+
+	c, err := NewListenersCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP node_listener_info Listening socket and the process owning it. comm and cgroup are empty if the process cannot be determined.
+# TYPE node_listener_info gauge
+node_listener_info{address="0.0.0.0",cgroup="/system.slice/dnsmasq.service",comm="dnsmasq",port="53",protocol="udp"} 1
+node_listener_info{address="0.0.0.0",cgroup="/system.slice/sshd.service",comm="sshd",port="22",protocol="tcp"} 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessCgroup(t *testing.T) {
+	for _, tc := range []struct {
+		cgroups []procfs.Cgroup
+		want    string
+	}{
+		{
+			cgroups: []procfs.Cgroup{{HierarchyID: 0, Path: "/system.slice/foo.service"}},
+			want:    "/system.slice/foo.service",
+		},
+		{
+			cgroups: []procfs.Cgroup{
+				{HierarchyID: 4, Controllers: []string{"memory"}, Path: "/"},
+				{HierarchyID: 1, Controllers: []string{"name=systemd"}, Path: "/system.slice/foo.service"},
+			},
+			want: "/system.slice/foo.service",
+		},
+		{
+			cgroups: []procfs.Cgroup{{HierarchyID: 4, Controllers: []string{"memory"}, Path: "/"}},
+			want:    "",
+		},
+	} {
+		if got := processCgroup(tc.cgroups); got != tc.want {
+			t.Errorf("processCgroup(%v) = %q, want %q", tc.cgroups, got, tc.want)
+		}
+	}
+}
@@ -18,7 +18,6 @@ package collector
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -56,7 +55,7 @@ func (c *lnstatCollector) Update(ch chan<- prometheus.Metric) error {
 		labelNames := []string{"subsystem", "cpu"}
 		for header, stats := range netStatFile.Stats {
 			for cpu, value := range stats {
-				labelValues := []string{netStatFile.Filename, strconv.Itoa(cpu)}
+				labelValues := []string{netStatFile.Filename, itoaLabel(cpu)}
 				ch <- prometheus.MustNewConstMetric(
 					prometheus.NewDesc(
 						prometheus.BuildFQName(namespace, subsystem, header+"_total"),
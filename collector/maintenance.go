@@ -0,0 +1,153 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nomaintenance
+// +build !nomaintenance
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	maintenanceFile = kingpin.Flag("collector.maintenance.file", "YAML file listing the scheduled maintenance windows of the node.").String()
+)
+
+const (
+	maintenanceStateActive    = "active"
+	maintenanceStateScheduled = "scheduled"
+	maintenanceStateNone      = "none"
+)
+
+type maintenanceCollector struct {
+	file   string
+	state  *prometheus.Desc
+	start  *prometheus.Desc
+	end    *prometheus.Desc
+	logger log.Logger
+	now    func() time.Time
+}
+
+// maintenanceConfig is the format of --collector.maintenance.file:
+//
+//	windows:
+//	  - name: kernel-upgrade
+//	    start: 2024-06-01T22:00:00Z
+//	    end: 2024-06-02T02:00:00Z
+type maintenanceConfig struct {
+	Windows []maintenanceWindow `yaml:"windows"`
+}
+
+type maintenanceWindow struct {
+	Name  string    `yaml:"name"`
+	Start time.Time `yaml:"start"`
+	End   time.Time `yaml:"end"`
+}
+
+func init() {
+	registerCollector("maintenance", defaultDisabled, NewMaintenanceCollector)
+}
+
+// NewMaintenanceCollector returns a new Collector exposing the scheduled
+// maintenance windows of the node.
+func NewMaintenanceCollector(logger log.Logger) (Collector, error) {
+	if *maintenanceFile == "" {
+		return nil, errors.New("--collector.maintenance.file must be set")
+	}
+
+	return &maintenanceCollector{
+		file: *maintenanceFile,
+		state: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "maintenance", "window"),
+			"Maintenance state of the node, one of active, scheduled or none.",
+			[]string{"state"}, nil,
+		),
+		start: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "maintenance", "window_start_timestamp_seconds"),
+			"Start of a current or upcoming maintenance window in seconds since epoch.",
+			[]string{"name"}, nil,
+		),
+		end: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "maintenance", "window_end_timestamp_seconds"),
+			"End of a current or upcoming maintenance window in seconds since epoch.",
+			[]string{"name"}, nil,
+		),
+		logger: logger,
+		now:    time.Now,
+	}, nil
+}
+
+func (c *maintenanceCollector) Update(ch chan<- prometheus.Metric) error {
+	windows, err := readMaintenanceWindows(c.file)
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	state := maintenanceStateNone
+	for _, w := range windows {
+		// Past windows are not relevant for alert routing.
+		if !w.End.After(now) {
+			continue
+		}
+		if !w.Start.After(now) {
+			state = maintenanceStateActive
+		} else if state == maintenanceStateNone {
+			state = maintenanceStateScheduled
+		}
+		ch <- prometheus.MustNewConstMetric(c.start, prometheus.GaugeValue, float64(w.Start.Unix()), w.Name)
+		ch <- prometheus.MustNewConstMetric(c.end, prometheus.GaugeValue, float64(w.End.Unix()), w.Name)
+	}
+
+	for _, s := range []string{maintenanceStateActive, maintenanceStateScheduled, maintenanceStateNone} {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, value, s)
+	}
+
+	return nil
+}
+
+func readMaintenanceWindows(path string) ([]maintenanceWindow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance file: %w", err)
+	}
+
+	var config maintenanceConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance file: %w", err)
+	}
+
+	for i, w := range config.Windows {
+		if w.Name == "" {
+			return nil, fmt.Errorf("maintenance window %d has no name", i)
+		}
+		if !w.End.After(w.Start) {
+			return nil, fmt.Errorf("maintenance window %q ends before it starts", w.Name)
+		}
+	}
+
+	return config.Windows, nil
+}
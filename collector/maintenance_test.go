@@ -0,0 +1,93 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nomaintenance
+// +build !nomaintenance
+
+package collector
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testMaintenanceCollector struct {
+	mc Collector
+}
+
+func (c testMaintenanceCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mc.Update(ch)
+}
+
+func (c testMaintenanceCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	*maintenanceFile = "fixtures/maintenance/windows.yml"
+
+	for _, tc := range []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{
+			name: "active",
+			now:  time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC),
+			want: `# HELP node_maintenance_window Maintenance state of the node, one of active, scheduled or none.
+# TYPE node_maintenance_window gauge
+node_maintenance_window{state="active"} 1
+node_maintenance_window{state="none"} 0
+node_maintenance_window{state="scheduled"} 0
+# HELP node_maintenance_window_end_timestamp_seconds End of a current or upcoming maintenance window in seconds since epoch.
+# TYPE node_maintenance_window_end_timestamp_seconds gauge
+node_maintenance_window_end_timestamp_seconds{name="kernel-upgrade"} 1.7172936e+09
+node_maintenance_window_end_timestamp_seconds{name="rack-move"} 1.7198568e+09
+# HELP node_maintenance_window_start_timestamp_seconds Start of a current or upcoming maintenance window in seconds since epoch.
+# TYPE node_maintenance_window_start_timestamp_seconds gauge
+node_maintenance_window_start_timestamp_seconds{name="kernel-upgrade"} 1.717279200e+09
+node_maintenance_window_start_timestamp_seconds{name="rack-move"} 1.7198208e+09
+`,
+		},
+		{
+			name: "none",
+			now:  time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+			want: `# HELP node_maintenance_window Maintenance state of the node, one of active, scheduled or none.
+# TYPE node_maintenance_window gauge
+node_maintenance_window{state="active"} 0
+node_maintenance_window{state="none"} 1
+node_maintenance_window{state="scheduled"} 0
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewMaintenanceCollector(log.NewLogfmtLogger(os.Stderr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.(*maintenanceCollector).now = func() time.Time { return tc.now }
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&testMaintenanceCollector{mc: c})
+			if err := testutil.GatherAndCompare(reg, strings.NewReader(tc.want)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -80,46 +80,55 @@ func (c *meminfoNumaCollector) Update(ch chan<- prometheus.Metric) error {
 }
 
 func getMemInfoNuma() ([]meminfoMetric, error) {
-	var (
-		metrics []meminfoMetric
-	)
-
 	nodes, err := filepath.Glob(sysFilePath("devices/system/node/node[0-9]*"))
 	if err != nil {
 		return nil, err
 	}
-	for _, node := range nodes {
-		meminfoFile, err := os.Open(filepath.Join(node, "meminfo"))
-		if err != nil {
-			return nil, err
-		}
-		defer meminfoFile.Close()
 
-		numaInfo, err := parseMemInfoNuma(meminfoFile)
-		if err != nil {
-			return nil, err
-		}
-		metrics = append(metrics, numaInfo...)
+	nodeMetrics := make([][]meminfoMetric, len(nodes))
+	err = forEachNUMANode(nodes, func(i int, node string) (err error) {
+		nodeMetrics[i], err = getMemInfoNumaNode(node)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		numastatFile, err := os.Open(filepath.Join(node, "numastat"))
-		if err != nil {
-			return nil, err
-		}
-		defer numastatFile.Close()
+	var metrics []meminfoMetric
+	for _, m := range nodeMetrics {
+		metrics = append(metrics, m...)
+	}
+	return metrics, nil
+}
 
-		nodeNumber := meminfoNodeRE.FindStringSubmatch(node)
-		if nodeNumber == nil {
-			return nil, fmt.Errorf("device node string didn't match regexp: %s", node)
-		}
+func getMemInfoNumaNode(node string) ([]meminfoMetric, error) {
+	meminfoFile, err := os.Open(filepath.Join(node, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer meminfoFile.Close()
 
-		numaStat, err := parseMemInfoNumaStat(numastatFile, nodeNumber[1])
-		if err != nil {
-			return nil, err
-		}
-		metrics = append(metrics, numaStat...)
+	metrics, err := parseMemInfoNuma(meminfoFile)
+	if err != nil {
+		return nil, err
 	}
 
-	return metrics, nil
+	numastatFile, err := os.Open(filepath.Join(node, "numastat"))
+	if err != nil {
+		return nil, err
+	}
+	defer numastatFile.Close()
+
+	nodeNumber := meminfoNodeRE.FindStringSubmatch(node)
+	if nodeNumber == nil {
+		return nil, fmt.Errorf("device node string didn't match regexp: %s", node)
+	}
+
+	numaStat, err := parseMemInfoNumaStat(numastatFile, nodeNumber[1])
+	if err != nil {
+		return nil, err
+	}
+	return append(metrics, numaStat...), nil
 }
 
 func parseMemInfoNuma(r io.Reader) ([]meminfoMetric, error) {
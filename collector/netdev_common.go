@@ -37,6 +37,8 @@ var (
 	oldNetdevDeviceExclude = kingpin.Flag("collector.netdev.device-blacklist", "DEPRECATED: Use collector.netdev.device-exclude").Hidden().String()
 	netdevAddressInfo      = kingpin.Flag("collector.netdev.address-info", "Collect address-info for every device").Bool()
 	netdevDetailedMetrics  = kingpin.Flag("collector.netdev.enable-detailed-metrics", "Use (incompatible) metric names that provide more detailed stats on Linux").Bool()
+
+	netdevDeviceExcludeFile, netdevDeviceIncludeFile = deviceListFileFlags("netdev", "device", "net devices")
 )
 
 type netDevCollector struct {
@@ -44,6 +46,9 @@ type netDevCollector struct {
 	deviceFilter     deviceFilter
 	metricDescsMutex sync.Mutex
 	metricDescs      map[string]*prometheus.Desc
+	addressInfo      bool
+	detailedMetrics  bool
+	options          netDevOptions
 	logger           log.Logger
 }
 
@@ -85,11 +90,20 @@ func NewNetDevCollector(logger log.Logger) (Collector, error) {
 		level.Info(logger).Log("msg", "Parsed Flag --collector.netdev.device-include", "flag", *netdevDeviceInclude)
 	}
 
+	deviceFilter, err := newDeviceFilter(*netdevDeviceExclude, *netdevDeviceInclude).
+		withListFiles(*netdevDeviceExcludeFile, *netdevDeviceIncludeFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return &netDevCollector{
-		subsystem:    "network",
-		deviceFilter: newDeviceFilter(*netdevDeviceExclude, *netdevDeviceInclude),
-		metricDescs:  map[string]*prometheus.Desc{},
-		logger:       logger,
+		subsystem:       "network",
+		deviceFilter:    deviceFilter,
+		metricDescs:     map[string]*prometheus.Desc{},
+		addressInfo:     *netdevAddressInfo,
+		detailedMetrics: *netdevDetailedMetrics,
+		options:         newNetDevOptions(),
+		logger:          logger,
 	}, nil
 }
 
@@ -110,12 +124,12 @@ func (c *netDevCollector) metricDesc(key string) *prometheus.Desc {
 }
 
 func (c *netDevCollector) Update(ch chan<- prometheus.Metric) error {
-	netDev, err := getNetDevStats(&c.deviceFilter, c.logger)
+	netDev, err := getNetDevStats(&c.deviceFilter, c.options, c.logger)
 	if err != nil {
 		return fmt.Errorf("couldn't get netstats: %w", err)
 	}
 	for dev, devStats := range netDev {
-		if !*netdevDetailedMetrics {
+		if !c.detailedMetrics {
 			legacy(devStats)
 		}
 		for key, value := range devStats {
@@ -123,7 +137,7 @@ func (c *netDevCollector) Update(ch chan<- prometheus.Metric) error {
 			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), dev)
 		}
 	}
-	if *netdevAddressInfo {
+	if c.addressInfo {
 		interfaces, err := net.Interfaces()
 		if err != nil {
 			return fmt.Errorf("could not get network interfaces: %w", err)
@@ -30,8 +30,17 @@ var (
 	netDevNetlink = kingpin.Flag("collector.netdev.netlink", "Use netlink to gather stats instead of /proc/net/dev.").Default("true").Bool()
 )
 
-func getNetDevStats(filter *deviceFilter, logger log.Logger) (netDevStats, error) {
-	if *netDevNetlink {
+// netDevOptions are the Linux specific flags of the collector.
+type netDevOptions struct {
+	netlink bool
+}
+
+func newNetDevOptions() netDevOptions {
+	return netDevOptions{netlink: *netDevNetlink}
+}
+
+func getNetDevStats(filter *deviceFilter, options netDevOptions, logger log.Logger) (netDevStats, error) {
+	if options.netlink {
 		return netlinkStats(filter, logger)
 	}
 	return procNetDevStats(filter, logger)
@@ -0,0 +1,123 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	collectorNUMAPinning = kingpin.Flag("collector.numa-pinning",
+		"Read the data of each NUMA node from a worker thread pinned to the CPUs of the node, which avoids cross-node memory traffic during scrapes of large multi-socket machines.").Bool()
+)
+
+// forEachNUMANode calls fn concurrently for the sysfs directory of every NUMA
+// node. With --collector.numa-pinning, each call runs on a thread pinned to
+// the CPUs of its node.
+func forEachNUMANode(nodes []string, fn func(i int, node string) error) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(nodes))
+	)
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			if *collectorNUMAPinning {
+				pinToNUMANode(node)
+			}
+			errs[i] = fn(i, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinToNUMANode pins the calling goroutine to a thread running on the CPUs of
+// a NUMA node that the process is allowed to run on. The thread stays locked,
+// so that it exits with the goroutine instead of going back to the scheduler
+// with a narrowed affinity. Nodes without usable CPUs, such as memory only
+// nodes, are read unpinned.
+func pinToNUMANode(node string) {
+	cpus, err := readCPUList(filepath.Join(node, "cpulist"))
+	if err != nil || len(cpus) == 0 {
+		return
+	}
+
+	runtime.LockOSThread()
+	var allowed, set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		return
+	}
+	for _, cpu := range cpus {
+		if allowed.IsSet(cpu) {
+			set.Set(cpu)
+		}
+	}
+	if set.Count() == 0 {
+		return
+	}
+	unix.SchedSetaffinity(0, &set)
+}
+
+// readCPUList reads a CPU list such as "0-3,8-11" from sysfs.
+func readCPUList(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCPUList(strings.TrimSpace(string(data)))
+}
+
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	if list == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(r, "-")
+		begin, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q: %w", list, err)
+		}
+		end := begin
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				return nil, fmt.Errorf("invalid CPU list %q: %w", list, err)
+			}
+		}
+		if end < begin {
+			return nil, fmt.Errorf("invalid CPU list %q: range %s is reversed", list, r)
+		}
+		for cpu := begin; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
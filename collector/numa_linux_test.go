@@ -0,0 +1,70 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for _, tc := range []struct {
+		list string
+		want []int
+		err  bool
+	}{
+		{list: "", want: nil},
+		{list: "0", want: []int{0}},
+		{list: "0-3,8-9,12", want: []int{0, 1, 2, 3, 8, 9, 12}},
+		{list: "3-1", err: true},
+		{list: "a-b", err: true},
+	} {
+		got, err := parseCPUList(tc.list)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", tc.list, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.list, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.list, got, tc.want)
+		}
+	}
+}
+
+func TestForEachNUMANodePinned(t *testing.T) {
+	pinning := *collectorNUMAPinning
+	*collectorNUMAPinning = true
+	defer func() { *collectorNUMAPinning = pinning }()
+
+	// node2 of the fixtures has no CPUs and is read unpinned.
+	nodes := []string{
+		"fixtures/sys/devices/system/node/node0",
+		"fixtures/sys/devices/system/node/node1",
+		"fixtures/sys/devices/system/node/node2",
+	}
+	read := make([]string, len(nodes))
+	err := forEachNUMANode(nodes, func(i int, node string) error {
+		read[i] = node
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, nodes) {
+		t.Errorf("got nodes %v, want %v", read, nodes)
+	}
+}
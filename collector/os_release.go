@@ -66,6 +66,8 @@ type osReleaseCollector struct {
 	versionDesc        *prometheus.Desc
 	supportEnd         time.Time
 	supportEndDesc     *prometheus.Desc
+	sbomFile           string
+	kernelCVEFile      string
 }
 
 type Plist struct {
@@ -84,7 +86,9 @@ func init() {
 // NewOSCollector returns a new Collector exposing os-release information.
 func NewOSCollector(logger log.Logger) (Collector, error) {
 	return &osReleaseCollector{
-		logger: logger,
+		logger:        logger,
+		sbomFile:      *osSBOMFile,
+		kernelCVEFile: *osKernelCVEFile,
 		infoDesc: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "os", "info"),
 			"A metric with a constant '1' value labeled by build_id, id, id_like, image_id, image_version, "+
@@ -198,7 +202,10 @@ func (c *osReleaseCollector) Update(ch chan<- prometheus.Metric) error {
 		ch <- prometheus.MustNewConstMetric(c.supportEndDesc, prometheus.GaugeValue, float64(c.supportEnd.Unix()))
 	}
 
-	return nil
+	if err := c.updatePackages(ch); err != nil {
+		return err
+	}
+	return c.updateKernelCVEs(ch)
 }
 
 func getMacosProductVersion(filename string) (*osRelease, error) {
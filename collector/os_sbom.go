@@ -0,0 +1,278 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	osSBOMFile = kingpin.Flag("collector.os.sbom-file",
+		"SPDX or CycloneDX JSON software bill of materials of the node, whose packages are counted by origin.").String()
+	osKernelCVEFile = kingpin.Flag("collector.os.kernel-cve-file",
+		"YAML file listing kernel CVEs with the kernel versions they affect, matched against the running kernel.").String()
+)
+
+const osPackageOriginUnknown = "unknown"
+
+var (
+	osPackagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "os", "packages"),
+		"Number of packages installed on the node by origin, from the software bill of materials.",
+		[]string{"origin"}, nil,
+	)
+	osKernelCVEAffectedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "os", "kernel_cve_affected"),
+		"Whether the running kernel is affected by a CVE of the kernel CVE file.",
+		[]string{"cve", "severity", "release"}, nil,
+	)
+)
+
+// sbomDocument holds the fields of SPDX and CycloneDX JSON documents needed to
+// count packages by origin.
+type sbomDocument struct {
+	// SPDX.
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Supplier   string `json:"supplier"`
+		Originator string `json:"originator"`
+	} `json:"packages"`
+
+	// CycloneDX.
+	BOMFormat  string `json:"bomFormat"`
+	Components []struct {
+		Publisher string `json:"publisher"`
+		Supplier  struct {
+			Name string `json:"name"`
+		} `json:"supplier"`
+	} `json:"components"`
+}
+
+// readSBOMPackageOrigins counts the packages of a software bill of materials
+// by origin, which is the supplier of a package, or its originator or
+// publisher if unknown.
+func readSBOMPackageOrigins(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc sbomDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse software bill of materials %q: %w", path, err)
+	}
+
+	origins := map[string]int{}
+	switch {
+	case doc.SPDXVersion != "":
+		for _, p := range doc.Packages {
+			origins[spdxOrigin(p.Supplier, p.Originator)]++
+		}
+	case doc.BOMFormat == "CycloneDX":
+		for _, c := range doc.Components {
+			origin := c.Supplier.Name
+			if origin == "" {
+				origin = c.Publisher
+			}
+			if origin == "" {
+				origin = osPackageOriginUnknown
+			}
+			origins[origin]++
+		}
+	default:
+		return nil, fmt.Errorf("software bill of materials %q is neither SPDX nor CycloneDX JSON", path)
+	}
+	return origins, nil
+}
+
+// spdxOrigin returns the name of the supplier or originator of an SPDX
+// package, given as "Organization: <name>", "Person: <name>" or NOASSERTION.
+func spdxOrigin(supplier, originator string) string {
+	for _, s := range []string{supplier, originator} {
+		if i := strings.Index(s, ":"); i >= 0 {
+			s = s[i+1:]
+		}
+		if s = strings.TrimSpace(s); s != "" && s != "NOASSERTION" {
+			return s
+		}
+	}
+	return osPackageOriginUnknown
+}
+
+// kernelCVEFile is the format of --collector.os.kernel-cve-file:
+//
+//	cves:
+//	  - id: CVE-2024-1086
+//	    severity: high
+//	    affected:
+//	      - introduced: 3.15
+//	        fixed: 6.1.76
+//	      - introduced: 6.2
+//	        fixed: 6.6.15
+//
+// A kernel is affected if its version is in one of the ranges, from
+// introduced included to fixed excluded. Either bound may be left out.
+type kernelCVEFile struct {
+	CVEs []kernelCVE `yaml:"cves"`
+}
+
+type kernelCVE struct {
+	ID       string             `yaml:"id"`
+	Severity string             `yaml:"severity"`
+	Affected []kernelCVEVersion `yaml:"affected"`
+}
+
+type kernelCVEVersion struct {
+	Introduced string `yaml:"introduced"`
+	Fixed      string `yaml:"fixed"`
+}
+
+func readKernelCVEs(path string) ([]kernelCVE, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f kernelCVEFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse kernel CVE file %q: %w", path, err)
+	}
+	for _, cve := range f.CVEs {
+		if cve.ID == "" {
+			return nil, fmt.Errorf("kernel CVE file %q has a CVE without id", path)
+		}
+	}
+	return f.CVEs, nil
+}
+
+// affects returns whether a kernel release is affected by the CVE.
+func (cve kernelCVE) affects(release string) bool {
+	for _, v := range cve.Affected {
+		if v.Introduced != "" && compareKernelVersions(release, v.Introduced) < 0 {
+			continue
+		}
+		if v.Fixed != "" && compareKernelVersions(release, v.Fixed) >= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// compareKernelVersions compares kernel releases the way rpm does, returning
+// -1, 0 or 1 if a is older, equal or newer than b. Runs of digits compare
+// numerically, runs of letters lexically, separators only split runs, and
+// numbers are newer than letters. Extra runs make a version newer, so that
+// 5.14.0-284.11.1.el9_2.x86_64 is older than 5.14.0-284.11.2.el9_2 but not
+// than 5.14.0-284.11.1.el9_2.
+func compareKernelVersions(a, b string) int {
+	ra, rb := versionRuns(a), versionRuns(b)
+	for i := 0; i < len(ra) && i < len(rb); i++ {
+		if c := compareVersionRuns(ra[i], rb[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(ra) > len(rb):
+		return 1
+	case len(ra) < len(rb):
+		return -1
+	}
+	return 0
+}
+
+func compareVersionRuns(a, b string) int {
+	aDigit, bDigit := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+	switch {
+	case aDigit && !bDigit:
+		return 1
+	case !aDigit && bDigit:
+		return -1
+	case aDigit:
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) > len(b) {
+				return 1
+			}
+			return -1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// versionRuns splits a version into runs of digits and letters.
+func versionRuns(v string) []string {
+	var runs []string
+	start := -1
+	for i, r := range v {
+		alnum := unicode.IsDigit(r) || unicode.IsLetter(r)
+		if start >= 0 && (!alnum || unicode.IsDigit(r) != unicode.IsDigit(rune(v[start]))) {
+			runs = append(runs, v[start:i])
+			start = -1
+		}
+		if alnum && start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, v[start:])
+	}
+	return runs
+}
+
+// updatePackages exposes the package counts of --collector.os.sbom-file.
+func (c *osReleaseCollector) updatePackages(ch chan<- prometheus.Metric) error {
+	if c.sbomFile == "" {
+		return nil
+	}
+	origins, err := readSBOMPackageOrigins(c.sbomFile)
+	if err != nil {
+		return err
+	}
+	for origin, count := range origins {
+		ch <- prometheus.MustNewConstMetric(osPackagesDesc, prometheus.GaugeValue, float64(count), origin)
+	}
+	return nil
+}
+
+// updateKernelCVEs exposes the CVEs of --collector.os.kernel-cve-file
+// affecting the running kernel.
+func (c *osReleaseCollector) updateKernelCVEs(ch chan<- prometheus.Metric) error {
+	if c.kernelCVEFile == "" {
+		return nil
+	}
+	cves, err := readKernelCVEs(c.kernelCVEFile)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(procFilePath("sys/kernel/osrelease"))
+	if err != nil {
+		return fmt.Errorf("failed to read kernel release: %w", err)
+	}
+	release := strings.TrimSpace(string(data))
+	for _, cve := range cves {
+		affected := 0.0
+		if cve.affects(release) {
+			affected = 1
+		}
+		ch <- prometheus.MustNewConstMetric(osKernelCVEAffectedDesc, prometheus.GaugeValue, affected, cve.ID, cve.Severity, release)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadSBOMPackageOrigins(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sbom string
+		want map[string]int
+	}{
+		{
+			name: "spdx",
+			sbom: `{"spdxVersion": "SPDX-2.3", "packages": [
+				{"name": "kernel", "supplier": "Organization: Red Hat"},
+				{"name": "bash", "supplier": "Organization: Red Hat"},
+				{"name": "agent", "supplier": "NOASSERTION", "originator": "Organization: Example"},
+				{"name": "tool", "supplier": "NOASSERTION"}
+			]}`,
+			want: map[string]int{"Red Hat": 2, "Example": 1, "unknown": 1},
+		},
+		{
+			name: "cyclonedx",
+			sbom: `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": [
+				{"name": "kernel", "supplier": {"name": "Red Hat"}},
+				{"name": "agent", "publisher": "Example"},
+				{"name": "tool"}
+			]}`,
+			want: map[string]int{"Red Hat": 1, "Example": 1, "unknown": 1},
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "sbom.json")
+		if err := os.WriteFile(path, []byte(tc.sbom), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readSBOMPackageOrigins(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompareKernelVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"6.1.76", "6.1.76", 0},
+		{"6.1.9", "6.1.76", -1},
+		{"6.10", "6.9.12", 1},
+		{"5.14.0-284.11.1.el9_2.x86_64", "5.14.0-284.11.2.el9_2", -1},
+		{"5.14.0-284.11.1.el9_2.x86_64", "5.14.0-284.11.1.el9_2", 1},
+		{"5.14.0-362.8.1.el9_3", "5.14.0-284.30.1.el9_2", 1},
+		{"6.8.0-rc1", "6.8.0-1", -1},
+	} {
+		if got := compareKernelVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareKernelVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestKernelCVEAffects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cves.yml")
+	if err := os.WriteFile(path, []byte(`cves:
+  - id: CVE-2024-1086
+    severity: high
+    affected:
+      - introduced: "3.15"
+        fixed: 6.1.76
+      - introduced: "6.2"
+        fixed: 6.6.15
+  - id: CVE-2023-0001
+    affected:
+      - introduced: "6.7"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cves, err := readKernelCVEs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		release string
+		want    []bool
+	}{
+		{"3.10.0-1160.el7.x86_64", []bool{false, false}},
+		{"6.1.55", []bool{true, false}},
+		{"6.1.76", []bool{false, false}},
+		{"6.5.0-generic", []bool{true, false}},
+		{"6.8.0", []bool{false, true}},
+	} {
+		for i, cve := range cves {
+			if got := cve.affects(tc.release); got != tc.want[i] {
+				t.Errorf("%s affects %s: got %v, want %v", cve.ID, tc.release, got, tc.want[i])
+			}
+		}
+	}
+}
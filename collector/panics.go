@@ -0,0 +1,66 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var scrapePanicsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_panics_total"),
+	"node_exporter: Number of panics of a collector recovered from.",
+	[]string{"collector"},
+	nil,
+)
+
+// collectorPanics counts the panics of every collector.
+var collectorPanics = struct {
+	sync.Mutex
+	panics map[string]float64
+}{panics: map[string]float64{}}
+
+// recoverPanics turns a panic of an update, e.g. an index out of range on an
+// unexpected sysfs layout, into an error of the collector, so that it does
+// not take down the exporter.
+func recoverPanics(name string, update func(chan<- prometheus.Metric) error, logger log.Logger) func(chan<- prometheus.Metric) error {
+	return func(ch chan<- prometheus.Metric) (err error) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			collectorPanics.Lock()
+			collectorPanics.panics[name]++
+			collectorPanics.Unlock()
+			level.Error(logger).Log("msg", "collector panicked", "name", name, "panic", r, "stack", debug.Stack())
+			err = fmt.Errorf("collector panicked: %v", r)
+		}()
+		return update(ch)
+	}
+}
+
+// exposePanics exposes the number of panics of a collector. It is exposed as
+// 0 before the first panic, so that increase() sees that panic.
+func exposePanics(name string, ch chan<- prometheus.Metric) {
+	collectorPanics.Lock()
+	count := collectorPanics.panics[name]
+	collectorPanics.Unlock()
+	ch <- prometheus.MustNewConstMetric(scrapePanicsDesc, prometheus.CounterValue, count, name)
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	var cards []string
+	update := recoverPanics("panic_test", func(ch chan<- prometheus.Metric) error {
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, 1, cards[0])
+		return nil
+	}, log.NewNopLogger())
+
+	ch := make(chan prometheus.Metric, 1)
+	for i := 0; i < 2; i++ {
+		err := update(ch)
+		if err == nil || !strings.Contains(err.Error(), "index out of range") {
+			t.Fatalf("got error %v, want recovered panic", err)
+		}
+	}
+	cards = []string{"a"}
+	if err := update(ch); err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	exposePanics("panic_test", ch)
+	var pb dto.Metric
+	if err := (<-ch).Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if got := pb.Counter.GetValue(); got != 2 {
+		t.Errorf("got %v panics, want 2", got)
+	}
+}
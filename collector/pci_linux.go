@@ -0,0 +1,36 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"strings"
+)
+
+// isComputeAcceleratorClass reports whether a PCI class code (e.g. "030200")
+// belongs to a 3D controller (0x0302) or a processing accelerator (0x1200).
+// VGA controllers, such as BMC graphics and integrated GPUs, are excluded.
+func isComputeAcceleratorClass(class string) bool {
+	return strings.HasPrefix(class, "0302") || strings.HasPrefix(class, "1200")
+}
+
+// readPCIID reads a hexadecimal ID such as "0x10de" from a sysfs PCI device
+// attribute and returns it lowercased without the "0x" prefix.
+func readPCIID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(string(data))), "0x"), nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// pciIDs holds the vendor and device names of a pci.ids database as shipped
+// by hwdata (https://pci-ids.ucw.cz/).
+type pciIDs struct {
+	vendors map[string]string
+	// devices is keyed by "<vendor>:<device>".
+	devices map[string]string
+}
+
+func loadPCIIDs(path string) (*pciIDs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parsePCIIDs(f)
+}
+
+func parsePCIIDs(r io.Reader) (*pciIDs, error) {
+	ids := &pciIDs{
+		vendors: map[string]string{},
+		devices: map[string]string{},
+	}
+
+	var vendor string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The device class list at the end of the file is not needed.
+		if strings.HasPrefix(line, "C ") {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\t\t"):
+			// Subsystem entries are not used.
+		case strings.HasPrefix(line, "\t"):
+			id, name, ok := splitPCIIDsLine(line[1:])
+			if !ok || vendor == "" {
+				continue
+			}
+			ids.devices[vendor+":"+id] = name
+		default:
+			id, name, ok := splitPCIIDsLine(line)
+			if !ok {
+				vendor = ""
+				continue
+			}
+			vendor = id
+			ids.vendors[id] = name
+		}
+	}
+
+	return ids, scanner.Err()
+}
+
+// splitPCIIDsLine splits a "<id>  <name>" line.
+func splitPCIIDsLine(line string) (string, string, bool) {
+	id, name, ok := strings.Cut(line, "  ")
+	if !ok || len(id) != 4 {
+		return "", "", false
+	}
+	return strings.ToLower(id), strings.TrimSpace(name), true
+}
+
+// lookup returns the vendor and device names of a PCI device.
+func (p *pciIDs) lookup(vendorID, deviceID string) (string, string, bool) {
+	device, ok := p.devices[vendorID+":"+deviceID]
+	if !ok {
+		return "", "", false
+	}
+	return p.vendors[vendorID], device, true
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noaccelerators
+// +build !noaccelerators
+
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+const pciIDsSample = `#
+#	List of PCI ID's
+#
+1002  Advanced Micro Devices, Inc. [AMD/ATI]
+	74a5  Aqua Vanjaram [Instinct MI325X]
+10de  NVIDIA Corporation
+	2335  GH100 [H200 SXM 141GB]
+		10de 18be  H200 SXM 141GB
+	2901  GB100 [B200]
+C 03  Display controller
+	00  VGA compatible controller
+`
+
+func TestParsePCIIDs(t *testing.T) {
+	ids, err := parsePCIIDs(strings.NewReader(pciIDsSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		vendorID, deviceID string
+		vendor, device     string
+		ok                 bool
+	}{
+		{"1002", "74a5", "Advanced Micro Devices, Inc. [AMD/ATI]", "Aqua Vanjaram [Instinct MI325X]", true},
+		{"10de", "2335", "NVIDIA Corporation", "GH100 [H200 SXM 141GB]", true},
+		{"10de", "2901", "NVIDIA Corporation", "GB100 [B200]", true},
+		{"10de", "18be", "", "", false},
+		{"8086", "0bd5", "", "", false},
+	} {
+		vendor, device, ok := ids.lookup(tc.vendorID, tc.deviceID)
+		if ok != tc.ok || vendor != tc.vendor || device != tc.device {
+			t.Errorf("lookup(%q, %q) = (%q, %q, %t), want (%q, %q, %t)",
+				tc.vendorID, tc.deviceID, vendor, device, ok, tc.vendor, tc.device, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/node_exporter/plugin"
+)
+
+var (
+	pluginDir = kingpin.Flag("collector.plugin-dir",
+		"Directory of plugin executables providing out-of-tree collectors, started at startup. See the plugin package.").String()
+	pluginTimeout = kingpin.Flag("collector.plugin-timeout",
+		"Time after which a plugin not answering an update is killed. It is restarted on the next update.").Default("30s").Duration()
+)
+
+// maxPluginOutputBytes limits the metrics read from a plugin for one update.
+const maxPluginOutputBytes = 16 << 20
+
+// LoadPlugins starts the plugins of --collector.plugin-dir and registers
+// their collectors, enabled. It must be called after parsing the flags and
+// before creating a NodeCollector.
+func LoadPlugins(logger log.Logger) error {
+	if *pluginDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(*pluginDir)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		p := &pluginProcess{
+			path:   filepath.Join(*pluginDir, entry.Name()),
+			logger: log.With(logger, "plugin", entry.Name()),
+		}
+		collectors, err := p.start()
+		if err != nil {
+			return fmt.Errorf("failed to start plugin %s: %w", p.path, err)
+		}
+		for _, name := range collectors {
+			if _, ok := factories[name]; ok {
+				p.stop()
+				return fmt.Errorf("collector %s of plugin %s is already registered", name, p.path)
+			}
+			name := name
+			factories[name] = func(logger log.Logger) (Collector, error) {
+				return &pluginCollector{process: p, name: name, logger: logger}, nil
+			}
+			enabled := true
+			collectorState[name] = &enabled
+		}
+		level.Info(p.logger).Log("msg", "Loaded plugin", "collectors", strings.Join(collectors, ","))
+	}
+	return nil
+}
+
+// pluginProcess is a running plugin. Updates of its collectors are
+// serialized.
+type pluginProcess struct {
+	path   string
+	logger log.Logger
+
+	mtx    sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// start starts the plugin and returns the names of its collectors from the
+// handshake.
+func (p *pluginProcess) start() ([]string, error) {
+	cmd := exec.Command(p.path)
+	cmd.Env = append(os.Environ(), plugin.CookieKey+"="+plugin.CookieValue)
+	cmd.Stderr = os.Stderr
+	pluginProcAttr(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.cmd, p.stdin, p.stdout = cmd, stdin, bufio.NewReader(stdout)
+
+	timer := time.AfterFunc(*pluginTimeout, func() { killPlugin(cmd) })
+	line, err := p.stdout.ReadString('\n')
+	timer.Stop()
+	if err != nil {
+		p.stop()
+		return nil, fmt.Errorf("failed to read handshake: %w", err)
+	}
+	fields := strings.Split(strings.TrimSuffix(line, "\n"), "|")
+	if len(fields) != 3 || fields[0] != plugin.HandshakePrefix {
+		p.stop()
+		return nil, fmt.Errorf("invalid handshake %q", line)
+	}
+	if version, err := strconv.Atoi(fields[1]); err != nil || version != plugin.ProtocolVersion {
+		p.stop()
+		return nil, fmt.Errorf("unsupported protocol version %q, expected %d", fields[1], plugin.ProtocolVersion)
+	}
+	var collectors []string
+	for _, name := range strings.Split(fields[2], ",") {
+		if !plugin.NameRE.MatchString(name) {
+			p.stop()
+			return nil, fmt.Errorf("invalid collector name %q", name)
+		}
+		collectors = append(collectors, name)
+	}
+	return collectors, nil
+}
+
+// stop kills the plugin and waits for it to exit.
+func (p *pluginProcess) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	killPlugin(p.cmd)
+	p.cmd.Wait()
+	p.cmd = nil
+}
+
+// update runs an update of a collector of the plugin, which is restarted
+// first if it died. The plugin is killed if it fails to answer in time or
+// breaks the protocol.
+func (p *pluginProcess) update(name string, ch chan<- prometheus.Metric, logger log.Logger) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.cmd == nil {
+		level.Warn(p.logger).Log("msg", "Restarting plugin")
+		if _, err := p.start(); err != nil {
+			return fmt.Errorf("failed to restart plugin: %w", err)
+		}
+	}
+
+	cmd := p.cmd
+	timer := time.AfterFunc(*pluginTimeout, func() { killPlugin(cmd) })
+	output, err := p.read(name)
+	timer.Stop()
+	if err != nil {
+		p.stop()
+		return err
+	}
+	if output.failure != "" {
+		return errors.New(output.failure)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(output.metrics))
+	if err != nil {
+		return fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	if hasTimestamps(families) {
+		return errors.New("metrics contain unsupported client-side timestamps")
+	}
+	for _, mf := range families {
+		convertMetricFamily(mf, ch, logger)
+	}
+	return nil
+}
+
+type pluginOutput struct {
+	metrics string
+	failure string
+}
+
+// read requests the metrics of a collector and reads the answer.
+func (p *pluginProcess) read(name string) (pluginOutput, error) {
+	if _, err := io.WriteString(p.stdin, plugin.CollectRequest+name+"\n"); err != nil {
+		return pluginOutput{}, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var metrics strings.Builder
+	for {
+		line, err := p.stdout.ReadString('\n')
+		if err != nil {
+			return pluginOutput{}, fmt.Errorf("failed to read metrics: %w", err)
+		}
+		switch {
+		case line == plugin.EOFLine+"\n":
+			return pluginOutput{metrics: metrics.String()}, nil
+		case strings.HasPrefix(line, plugin.ErrorPrefix):
+			return pluginOutput{failure: strings.TrimSpace(strings.TrimPrefix(line, plugin.ErrorPrefix))}, nil
+		}
+		if metrics.Len()+len(line) > maxPluginOutputBytes {
+			return pluginOutput{}, fmt.Errorf("metrics exceed %d bytes", maxPluginOutputBytes)
+		}
+		metrics.WriteString(line)
+	}
+}
+
+// pluginCollector is a collector of a plugin.
+type pluginCollector struct {
+	process *pluginProcess
+	name    string
+	logger  log.Logger
+}
+
+func (c *pluginCollector) Update(ch chan<- prometheus.Metric) error {
+	return c.process.update(c.name, ch, c.logger)
+}
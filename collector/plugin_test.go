@@ -0,0 +1,118 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const testPluginScript = `#!/bin/sh
+echo "node_exporter-plugin|1|test_array,test_hang"
+while read request name; do
+	case "$name" in
+	test_array)
+		echo "# TYPE node_test_array_up gauge"
+		echo 'node_test_array_up{array="a"} 1'
+		echo "# EOF"
+		;;
+	test_hang)
+		sleep 10
+		;;
+	esac
+done
+`
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "array"), []byte(testPluginScript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	*pluginDir, *pluginTimeout = dir, 200*time.Millisecond
+	defer func() { *pluginDir, *pluginTimeout = "", 30*time.Second }()
+
+	if err := LoadPlugins(log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	var process *pluginProcess
+	defer func() {
+		for _, name := range []string{"test_array", "test_hang"} {
+			delete(factories, name)
+			delete(collectorState, name)
+		}
+		if process != nil {
+			process.stop()
+		}
+	}()
+
+	for _, name := range []string{"test_array", "test_hang"} {
+		if enabled, ok := collectorState[name]; !ok || !*enabled {
+			t.Fatalf("collector %s of the plugin is not registered and enabled", name)
+		}
+	}
+	array, err := factories["test_array"](log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hang, err := factories["test_hang"](log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	process = array.(*pluginCollector).process
+
+	update := func(c Collector) ([]string, error) {
+		ch := make(chan prometheus.Metric, 10)
+		err := c.Update(ch)
+		close(ch)
+		var descs []string
+		for m := range ch {
+			descs = append(descs, m.Desc().String())
+		}
+		return descs, err
+	}
+
+	descs, err := update(array)
+	if err != nil || len(descs) != 1 || !strings.Contains(descs[0], "node_test_array_up") {
+		t.Fatalf("got metrics %v and error %v, want node_test_array_up", descs, err)
+	}
+
+	// A collector not answering in time kills the plugin, which is
+	// restarted by the next update.
+	if _, err := update(hang); err == nil {
+		t.Fatal("expected an error from the hanging collector")
+	}
+	if descs, err := update(array); err != nil || len(descs) != 1 {
+		t.Fatalf("got metrics %v and error %v after restart, want node_test_array_up", descs, err)
+	}
+}
+
+func TestLoadPluginsDuplicateCollector(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'node_exporter-plugin|1|cpu'\ncat >/dev/null\n"
+	if err := os.WriteFile(filepath.Join(dir, "cpu"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	*pluginDir = dir
+	defer func() { *pluginDir = "" }()
+
+	if err := LoadPlugins(log.NewNopLogger()); err == nil {
+		t.Error("expected an error for a plugin collector named like a built-in one")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nopower
+// +build !nopower
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs/sysfs"
+)
+
+var (
+	powerPSUChips = kingpin.Flag("collector.power.psu-chips", "Regexp of hwmon chip names of power supplies.").Default("acbel_fsg032|corsairpsu|dps920ab|fsp3y|ibm_cffps[0-9]?|ipsps|pfe[0-9]+").String()
+)
+
+// Sources of node_power_watts.
+const (
+	// powerSourcePlatform is a whole system power meter, usually backed by
+	// the BMC through the ACPI power meter interface.
+	powerSourcePlatform = "platform"
+	// powerSourcePSU is the sum of the power supply readings.
+	powerSourcePSU = "psu"
+	// powerSourceRAPL is the sum of the RAPL package and DRAM domains.
+	powerSourceRAPL = "rapl"
+	// powerSourceAccelerator is the sum of the accelerator card readings.
+	powerSourceAccelerator = "accelerator"
+)
+
+type powerCollector struct {
+	fs       sysfs.FS
+	psuChips *regexp.Regexp
+	logger   log.Logger
+
+	watts         *prometheus.Desc
+	estimateWatts *prometheus.Desc
+
+	mu sync.Mutex
+	// lastRAPL holds the energy counters of the previous scrape to derive
+	// the RAPL power from.
+	lastRAPL     map[string]uint64
+	lastRAPLTime time.Time
+}
+
+func init() {
+	registerCollector("power", defaultDisabled, NewPowerCollector)
+}
+
+// NewPowerCollector returns a new Collector combining the power readings
+// available on the node into a best-effort power model.
+func NewPowerCollector(logger log.Logger) (Collector, error) {
+	fs, err := sysfs.NewFS(*sysPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sysfs: %w", err)
+	}
+	psuChips, err := regexp.Compile("^(?:" + *powerPSUChips + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collector.power.psu-chips: %w", err)
+	}
+
+	return &powerCollector{
+		fs:       fs,
+		psuChips: psuChips,
+		logger:   logger,
+		watts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "power", "watts"),
+			"Power drawn as reported by a power source in watts.",
+			[]string{"source"}, nil,
+		),
+		estimateWatts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "power", "estimate_watts"),
+			"Best-effort estimate of the power drawn by the node in watts. Whole system measurements are preferred over the sum of component readings.",
+			nil, nil,
+		),
+	}, nil
+}
+
+func (c *powerCollector) Update(ch chan<- prometheus.Metric) error {
+	sources := c.hwmonPower()
+	if watts, ok := c.raplPower(); ok {
+		sources[powerSourceRAPL] = watts
+	}
+
+	if len(sources) == 0 {
+		return ErrNoData
+	}
+
+	for source, watts := range sources {
+		ch <- prometheus.MustNewConstMetric(c.watts, prometheus.GaugeValue, watts, source)
+	}
+	ch <- prometheus.MustNewConstMetric(c.estimateWatts, prometheus.GaugeValue, estimatePower(sources))
+
+	return nil
+}
+
+// estimatePower blends the power sources into a single value.
+func estimatePower(sources map[string]float64) float64 {
+	for _, source := range []string{powerSourcePlatform, powerSourcePSU} {
+		if watts, ok := sources[source]; ok {
+			return watts
+		}
+	}
+	return sources[powerSourceRAPL] + sources[powerSourceAccelerator]
+}
+
+// hwmonPower sums the power readings of hwmon chips by source.
+func (c *powerCollector) hwmonPower() map[string]float64 {
+	sources := map[string]float64{}
+
+	chips, err := filepath.Glob(sysFilePath("class/hwmon/hwmon*"))
+	if err != nil {
+		return sources
+	}
+	for _, chip := range chips {
+		name, err := sysReadFile(filepath.Join(chip, "name"))
+		if err != nil {
+			continue
+		}
+		source, ok := c.hwmonPowerSource(chip, strings.TrimSpace(string(name)))
+		if !ok {
+			continue
+		}
+
+		inputs, err := filepath.Glob(filepath.Join(chip, "power*_input"))
+		if err != nil {
+			continue
+		}
+		if len(inputs) == 0 {
+			// Some drivers, e.g. acpi_power_meter and amdgpu, only report
+			// averages.
+			if inputs, err = filepath.Glob(filepath.Join(chip, "power*_average")); err != nil {
+				continue
+			}
+		}
+		for _, input := range inputs {
+			raw, err := sysReadFile(input)
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "failed to read power", "file", input, "err", err)
+				continue
+			}
+			microWatts, err := strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+			if err != nil {
+				continue
+			}
+			sources[source] += microWatts / 1e6
+		}
+	}
+
+	return sources
+}
+
+// hwmonPowerSource returns the power source of a hwmon chip, if any. Power
+// supplies are matched by driver name, other PMBus devices such as voltage
+// regulators report their power the same way.
+func (c *powerCollector) hwmonPowerSource(chip, name string) (string, bool) {
+	if name == "acpi_power_meter" {
+		return powerSourcePlatform, true
+	}
+	if c.psuChips.MatchString(name) {
+		return powerSourcePSU, true
+	}
+	class, err := readPCIID(filepath.Join(chip, "device", "class"))
+	if err == nil && isComputeAcceleratorClass(class) {
+		return powerSourceAccelerator, true
+	}
+	return "", false
+}
+
+// raplPower derives the power of the RAPL package and DRAM domains from the
+// energy consumed since the previous scrape. No value is returned on the
+// first scrape.
+func (c *powerCollector) raplPower() (float64, bool) {
+	zones, err := sysfs.GetRaplZones(c.fs)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "failed to retrieve rapl zones", "err", err)
+		return 0, false
+	}
+
+	now := time.Now()
+	energy := map[string]uint64{}
+	maxEnergy := map[string]uint64{}
+	for _, z := range zones {
+		if !strings.HasPrefix(z.Name, "package") && z.Name != "dram" {
+			continue
+		}
+		microJoules, err := z.GetEnergyMicrojoules()
+		if err != nil {
+			if !os.IsPermission(err) {
+				level.Debug(c.logger).Log("msg", "failed to read rapl energy", "zone", z.Path, "err", err)
+			}
+			continue
+		}
+		energy[z.Path] = microJoules
+		maxEnergy[z.Path] = z.MaxMicrojoules
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last, lastTime := c.lastRAPL, c.lastRAPLTime
+	c.lastRAPL, c.lastRAPLTime = energy, now
+	if last == nil || len(energy) == 0 {
+		return 0, false
+	}
+
+	var microJoules float64
+	for path, value := range energy {
+		previous, ok := last[path]
+		if !ok {
+			return 0, false
+		}
+		if value >= previous {
+			microJoules += float64(value - previous)
+		} else {
+			// The counter wrapped around.
+			microJoules += float64(maxEnergy[path] - previous + value)
+		}
+	}
+
+	return microJoules / 1e6 / now.Sub(lastTime).Seconds(), true
+}
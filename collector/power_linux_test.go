@@ -0,0 +1,117 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nopower
+// +build !nopower
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPowerCollector(t *testing.T) {
+	sys := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(sys, "class", "hwmon", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("hwmon0/name", "ibm_cffps1")
+	write("hwmon0/power1_input", "250000000")
+	write("hwmon1/name", "ibm_cffps1")
+	write("hwmon1/power1_input", "230000000")
+	// Voltage regulators are PMBus devices too, but don't measure the
+	// whole node.
+	write("hwmon2/name", "tps53679")
+	write("hwmon2/power1_input", "90000000")
+	write("hwmon3/name", "amdgpu")
+	write("hwmon3/power1_average", "120000000")
+	write("hwmon3/device/class", "0x030200")
+	write("hwmon4/name", "coretemp")
+	write("hwmon4/temp1_input", "40000")
+	// Integrated GPUs are VGA controllers, their power is part of the
+	// package power.
+	write("hwmon5/name", "amdgpu")
+	write("hwmon5/power1_average", "15000000")
+	write("hwmon5/device/class", "0x030000")
+
+	defer func(path, chips string) { *sysPath, *powerPSUChips = path, chips }(*sysPath, *powerPSUChips)
+	*sysPath = sys
+	*powerPSUChips = "ibm_cffps[0-9]?"
+
+	c, err := NewPowerCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP node_power_estimate_watts Best-effort estimate of the power drawn by the node in watts. Whole system measurements are preferred over the sum of component readings.
+# TYPE node_power_estimate_watts gauge
+node_power_estimate_watts 480
+# HELP node_power_watts Power drawn as reported by a power source in watts.
+# TYPE node_power_watts gauge
+node_power_watts{source="accelerator"} 120
+node_power_watts{source="psu"} 480
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without power supplies, the estimate falls back to the components.
+	*powerPSUChips = "none"
+	if c, err = NewPowerCollector(log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	want = `# HELP node_power_estimate_watts Best-effort estimate of the power drawn by the node in watts. Whole system measurements are preferred over the sum of component readings.
+# TYPE node_power_estimate_watts gauge
+node_power_estimate_watts 120
+# HELP node_power_watts Power drawn as reported by a power source in watts.
+# TYPE node_power_watts gauge
+node_power_watts{source="accelerator"} 120
+`
+	reg = prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEstimatePower(t *testing.T) {
+	for _, tc := range []struct {
+		sources map[string]float64
+		want    float64
+	}{
+		{map[string]float64{powerSourcePlatform: 300, powerSourcePSU: 320, powerSourceRAPL: 150}, 300},
+		{map[string]float64{powerSourcePSU: 320, powerSourceRAPL: 150}, 320},
+		{map[string]float64{powerSourceRAPL: 150, powerSourceAccelerator: 100}, 250},
+		{map[string]float64{}, 0},
+	} {
+		if got := estimatePower(tc.sources); got != tc.want {
+			t.Errorf("estimatePower(%v) = %v, want %v", tc.sources, got, tc.want)
+		}
+	}
+}
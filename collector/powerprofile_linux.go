@@ -0,0 +1,114 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nopowerprofile
+// +build !nopowerprofile
+
+package collector
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type powerProfileCollector struct {
+	platformProfile *prometheus.Desc
+	cpuEPP          *prometheus.Desc
+	logger          log.Logger
+}
+
+func init() {
+	registerCollector("powerprofile", defaultDisabled, NewPowerProfileCollector)
+}
+
+// NewPowerProfileCollector returns a new Collector exposing the ACPI platform
+// profile and the CPU energy performance preferences.
+func NewPowerProfileCollector(logger log.Logger) (Collector, error) {
+	return &powerProfileCollector{
+		platformProfile: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "platform", "profile"),
+			"ACPI platform profile from /sys/firmware/acpi/platform_profile. The selected profile has value 1, the other supported profiles have value 0.",
+			[]string{"profile"}, nil,
+		),
+		cpuEPP: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cpu", "energy_performance_preference_info"),
+			"Energy performance preference of a CPU from /sys/devices/system/cpu/cpu<cpu>/cpufreq/energy_performance_preference.",
+			[]string{"cpu", "preference"}, nil,
+		),
+		logger: logger,
+	}, nil
+}
+
+func (c *powerProfileCollector) Update(ch chan<- prometheus.Metric) error {
+	platformErr := c.updatePlatformProfile(ch)
+	eppErr := c.updateCPUEPP(ch)
+	if platformErr != nil && eppErr != nil {
+		level.Debug(c.logger).Log("msg", "no power profile information found", "platform_profile_err", platformErr, "epp_err", eppErr)
+		return ErrNoData
+	}
+	return nil
+}
+
+func (c *powerProfileCollector) updatePlatformProfile(ch chan<- prometheus.Metric) error {
+	profile, err := os.ReadFile(sysFilePath("firmware/acpi/platform_profile"))
+	if err != nil {
+		return err
+	}
+	selected := strings.TrimSpace(string(profile))
+
+	choices := []string{selected}
+	if raw, err := os.ReadFile(sysFilePath("firmware/acpi/platform_profile_choices")); err == nil {
+		choices = strings.Fields(string(raw))
+	}
+
+	found := false
+	for _, choice := range choices {
+		value := 0.0
+		if choice == selected {
+			value = 1
+			found = true
+		}
+		ch <- prometheus.MustNewConstMetric(c.platformProfile, prometheus.GaugeValue, value, choice)
+	}
+	if !found {
+		ch <- prometheus.MustNewConstMetric(c.platformProfile, prometheus.GaugeValue, 1, selected)
+	}
+	return nil
+}
+
+func (c *powerProfileCollector) updateCPUEPP(ch chan<- prometheus.Metric) error {
+	files, err := filepath.Glob(sysFilePath("devices/system/cpu/cpu[0-9]*/cpufreq/energy_performance_preference"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("energy_performance_preference not supported")
+	}
+
+	for _, file := range files {
+		preference, err := os.ReadFile(file)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read energy performance preference", "file", file, "err", err)
+			continue
+		}
+		cpu := strings.TrimPrefix(filepath.Base(filepath.Dir(filepath.Dir(file))), "cpu")
+		ch <- prometheus.MustNewConstMetric(c.cpuEPP, prometheus.GaugeValue, 1, cpu, strings.TrimSpace(string(preference)))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nopowerprofile
+// +build !nopowerprofile
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPowerProfileCollector(t *testing.T) {
+	sys := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(sys, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("firmware/acpi/platform_profile", "balanced")
+	write("firmware/acpi/platform_profile_choices", "low-power balanced performance")
+	write("devices/system/cpu/cpu0/cpufreq/energy_performance_preference", "balance_performance")
+	write("devices/system/cpu/cpu1/cpufreq/energy_performance_preference", "power")
+
+	defer func(path string) { *sysPath = path }(*sysPath)
+	*sysPath = sys
+
+	c, err := NewPowerProfileCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP node_cpu_energy_performance_preference_info Energy performance preference of a CPU from /sys/devices/system/cpu/cpu<cpu>/cpufreq/energy_performance_preference.
+# TYPE node_cpu_energy_performance_preference_info gauge
+node_cpu_energy_performance_preference_info{cpu="0",preference="balance_performance"} 1
+node_cpu_energy_performance_preference_info{cpu="1",preference="power"} 1
+# HELP node_platform_profile ACPI platform profile from /sys/firmware/acpi/platform_profile. The selected profile has value 1, the other supported profiles have value 0.
+# TYPE node_platform_profile gauge
+node_platform_profile{profile="balanced"} 1
+node_platform_profile{profile="low-power"} 0
+node_platform_profile{profile="performance"} 0
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
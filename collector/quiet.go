@@ -0,0 +1,143 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	quietOnBattery = kingpin.Flag("collector.quiet.on-battery",
+		"Switch to quiet mode while the node runs on battery.").Bool()
+	quietCPUBudget = kingpin.Flag("collector.quiet.cpu-budget",
+		"Switch to quiet mode while node_exporter uses more than this fraction of a CPU, e.g. 0.01. It switches back below half of it. 0 disables the budget.").Default("0").Float64()
+	quietInterval = kingpin.Flag("collector.quiet.interval",
+		"In quiet mode, collectors run at most once per interval and scrapes in between are served the metrics of their latest run.").Default("2m").Duration()
+	quietDisabledCollectors = kingpin.Flag("collector.quiet.disable",
+		"Collector not run at all in quiet mode. Can be repeated.").Default("ethtool", "mountstats", "perf", "processes", "systemd", "tcpstat").Strings()
+)
+
+const (
+	// quietCheckInterval is how often scrapes check whether to switch
+	// modes, and the period over which the CPU usage is measured.
+	quietCheckInterval = 30 * time.Second
+
+	quietReasonBattery   = "battery"
+	quietReasonCPUBudget = "cpu_budget"
+)
+
+var (
+	scrapeQuietModeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "quiet_mode"),
+		"node_exporter: Whether collectors run less often to save power, see --collector.quiet.*.",
+		nil, nil,
+	)
+	scrapeQuietModeTransitionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "quiet_mode_transitions_total"),
+		"node_exporter: Number of switches into and out of quiet mode, by the mode switched to.",
+		[]string{"mode"}, nil,
+	)
+)
+
+// quietMode tracks whether node_exporter is in quiet mode, in which it saves
+// power on battery powered or otherwise constrained nodes: expensive
+// collectors are not run, and the others run at most once per
+// --collector.quiet.interval, so that their files are read in one go
+// instead of on every scrape.
+var quietMode = struct {
+	sync.Mutex
+	active      bool
+	checked     time.Time
+	cpuSeconds  float64
+	transitions map[string]float64
+}{transitions: map[string]float64{"quiet": 0, "normal": 0}}
+
+// quietModeEnabled returns whether node_exporter may switch to quiet mode.
+func quietModeEnabled() bool {
+	return *quietOnBattery || *quietCPUBudget > 0
+}
+
+// quietModeActive returns whether node_exporter is in quiet mode.
+func quietModeActive() bool {
+	quietMode.Lock()
+	defer quietMode.Unlock()
+	return quietMode.active
+}
+
+// updateQuietMode switches into or out of quiet mode, checking at most once
+// per quietCheckInterval, and returns whether quiet mode is active.
+func updateQuietMode(now time.Time, logger log.Logger) bool {
+	if !quietModeEnabled() {
+		return false
+	}
+	quietMode.Lock()
+	defer quietMode.Unlock()
+
+	elapsed := now.Sub(quietMode.checked)
+	if elapsed < quietCheckInterval {
+		return quietMode.active
+	}
+	cpuSeconds := processCPUSeconds()
+	cpuUsage := (cpuSeconds - quietMode.cpuSeconds) / elapsed.Seconds()
+	firstCheck := quietMode.checked.IsZero()
+	quietMode.checked, quietMode.cpuSeconds = now, cpuSeconds
+
+	reason := ""
+	switch {
+	case *quietOnBattery && onBattery():
+		reason = quietReasonBattery
+	case *quietCPUBudget > 0 && !firstCheck && quietMode.active && cpuUsage > *quietCPUBudget/2:
+		reason = quietReasonCPUBudget
+	case *quietCPUBudget > 0 && !firstCheck && cpuUsage > *quietCPUBudget:
+		reason = quietReasonCPUBudget
+	}
+
+	if active := reason != ""; active != quietMode.active {
+		quietMode.active = active
+		if active {
+			quietMode.transitions["quiet"]++
+			level.Info(logger).Log("msg", "Switching to quiet mode", "reason", reason, "cpu_usage", cpuUsage)
+		} else {
+			quietMode.transitions["normal"]++
+			level.Info(logger).Log("msg", "Switching back to normal mode", "cpu_usage", cpuUsage)
+		}
+	}
+	return quietMode.active
+}
+
+// quietDisabled returns whether a collector is not run in quiet mode.
+func quietDisabled(name string) bool {
+	for _, disabled := range *quietDisabledCollectors {
+		if name == disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// exposeQuietMode exposes the mode and the number of transitions.
+func exposeQuietMode(ch chan<- prometheus.Metric) {
+	quietMode.Lock()
+	defer quietMode.Unlock()
+	ch <- prometheus.MustNewConstMetric(scrapeQuietModeDesc, prometheus.GaugeValue, boolToFloat(quietMode.active))
+	for mode, count := range quietMode.transitions {
+		ch <- prometheus.MustNewConstMetric(scrapeQuietModeTransitionsDesc, prometheus.CounterValue, count, mode)
+	}
+}
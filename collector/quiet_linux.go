@@ -0,0 +1,47 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// onBattery returns whether the node runs on battery: a battery discharges
+// and no external power supply is online.
+func onBattery() bool {
+	supplies, err := filepath.Glob(sysFilePath("class/power_supply/*"))
+	if err != nil {
+		return false
+	}
+	discharging := false
+	for _, supply := range supplies {
+		read := func(attr string) string {
+			data, _ := os.ReadFile(filepath.Join(supply, attr))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Mains", "USB":
+			if read("online") == "1" {
+				return false
+			}
+		case "Battery":
+			if read("status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnBattery(t *testing.T) {
+	defer func(path string) { *sysPath = path }(*sysPath)
+	*sysPath = t.TempDir()
+
+	write := func(supply string, attrs map[string]string) {
+		dir := filepath.Join(*sysPath, "class/power_supply", supply)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for attr, value := range attrs {
+			if err := os.WriteFile(filepath.Join(dir, attr), []byte(value+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	write("BAT0", map[string]string{"type": "Battery", "status": "Discharging"})
+	write("AC", map[string]string{"type": "Mains", "online": "0"})
+	if !onBattery() {
+		t.Error("discharging battery without mains: want on battery")
+	}
+	write("AC", map[string]string{"type": "Mains", "online": "1"})
+	if onBattery() {
+		t.Error("mains online: want not on battery")
+	}
+	write("AC", map[string]string{"type": "Mains", "online": "0"})
+	write("BAT0", map[string]string{"type": "Battery", "status": "Charging"})
+	if onBattery() {
+		t.Error("charging battery: want not on battery")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQuietModeCPUBudget(t *testing.T) {
+	defer func(budget float64, cpu func() float64) {
+		*quietCPUBudget, processCPUSeconds = budget, cpu
+		quietMode.active, quietMode.checked, quietMode.cpuSeconds = false, time.Time{}, 0
+		quietMode.transitions = map[string]float64{"quiet": 0, "normal": 0}
+	}(*quietCPUBudget, processCPUSeconds)
+	*quietCPUBudget = 0.1
+
+	cpuSeconds := 0.0
+	processCPUSeconds = func() float64 { return cpuSeconds }
+	now := time.Now()
+	for _, step := range []struct {
+		elapsed time.Duration
+		cpu     float64
+		want    bool
+	}{
+		// The first check only takes the baseline.
+		{0, 100, false},
+		{quietCheckInterval, 2, false},
+		{quietCheckInterval, 4, true},
+		// Checks are not repeated within quietCheckInterval.
+		{time.Second, 0, true},
+		// Quiet mode is kept down to half of the budget.
+		{quietCheckInterval, 2, true},
+		{quietCheckInterval, 1, false},
+	} {
+		now, cpuSeconds = now.Add(step.elapsed), cpuSeconds+step.cpu
+		if got := updateQuietMode(now, log.NewNopLogger()); got != step.want {
+			t.Fatalf("after %.0fs of CPU over %s: got quiet mode %v, want %v", step.cpu, step.elapsed, got, step.want)
+		}
+	}
+
+	want := `# HELP node_scrape_quiet_mode node_exporter: Whether collectors run less often to save power, see --collector.quiet.*.
+# TYPE node_scrape_quiet_mode gauge
+node_scrape_quiet_mode 0
+# HELP node_scrape_quiet_mode_transitions_total node_exporter: Number of switches into and out of quiet mode, by the mode switched to.
+# TYPE node_scrape_quiet_mode_transitions_total counter
+node_scrape_quiet_mode_transitions_total{mode="normal"} 1
+node_scrape_quiet_mode_transitions_total{mode="quiet"} 1
+`
+	if err := testutil.CollectAndCompare(quietModeCollector{}, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type quietModeCollector struct{}
+
+func (quietModeCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (quietModeCollector) Collect(ch chan<- prometheus.Metric) {
+	exposeQuietMode(ch)
+}
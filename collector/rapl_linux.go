@@ -32,8 +32,9 @@ import (
 const raplCollectorSubsystem = "rapl"
 
 type raplCollector struct {
-	fs     sysfs.FS
-	logger log.Logger
+	fs        sysfs.FS
+	logger    log.Logger
+	zoneLabel bool
 
 	joulesMetricDesc *prometheus.Desc
 }
@@ -63,6 +64,7 @@ func NewRaplCollector(logger log.Logger) (Collector, error) {
 	collector := raplCollector{
 		fs:               fs,
 		logger:           logger,
+		zoneLabel:        *raplZoneLabel,
 		joulesMetricDesc: joulesMetricDesc,
 	}
 	return &collector, nil
@@ -96,7 +98,7 @@ func (c *raplCollector) Update(ch chan<- prometheus.Metric) error {
 
 		joules := float64(microJoules) / 1000000.0
 
-		if *raplZoneLabel {
+		if c.zoneLabel {
 			ch <- c.joulesMetricWithZoneLabel(rz, joules)
 		} else {
 			ch <- c.joulesMetric(rz, joules)
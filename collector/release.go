@@ -0,0 +1,253 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !norelease
+// +build !norelease
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	releaseFile          = kingpin.Flag("collector.release.file", "Release manifest describing the latest node_exporter release.").String()
+	releaseURL           = kingpin.Flag("collector.release.url", "HTTP URL of a release manifest describing the latest node_exporter release.").String()
+	releaseTimeout       = kingpin.Flag("collector.release.timeout", "Timeout for fetching the release manifest from --collector.release.url.").Default("10s").Duration()
+	releaseCheckInterval = kingpin.Flag("collector.release.check-interval", "How often the release manifest is read, scrapes in between reuse the previous result.").Default("1h").Duration()
+)
+
+type releaseCollector struct {
+	file            string
+	url             string
+	checkInterval   time.Duration
+	client          *http.Client
+	updateAvailable *prometheus.Desc
+	daysBehind      *prometheus.Desc
+	logger          log.Logger
+	now             func() time.Time
+
+	// mtx guards the cached manifest.
+	mtx     sync.Mutex
+	latest  *releaseManifest
+	checked time.Time
+}
+
+// releaseManifest is the format of the release manifest, which may also be
+// written as JSON:
+//
+//	version: 1.8.2
+//	date: 2024-07-14
+type releaseManifest struct {
+	Version string `yaml:"version"`
+	Date    string `yaml:"date"`
+
+	released time.Time
+}
+
+func init() {
+	registerCollector("release", defaultDisabled, NewReleaseCollector)
+}
+
+// NewReleaseCollector returns a new Collector comparing the running version of
+// node_exporter against the latest release of a release manifest.
+func NewReleaseCollector(logger log.Logger) (Collector, error) {
+	if (*releaseFile == "") == (*releaseURL == "") {
+		return nil, errors.New("exactly one of --collector.release.file and --collector.release.url must be set")
+	}
+
+	return &releaseCollector{
+		file:          *releaseFile,
+		url:           *releaseURL,
+		checkInterval: *releaseCheckInterval,
+		client:        &http.Client{Timeout: *releaseTimeout},
+		updateAvailable: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "update_available"),
+			"Whether the release manifest lists a newer version of node_exporter than the running one.",
+			[]string{"version", "latest_version"}, nil,
+		),
+		daysBehind: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "update_days_behind"),
+			"Number of days since the release of the latest version of node_exporter, 0 if the running version is up to date.",
+			nil, nil,
+		),
+		logger: logger,
+		now:    time.Now,
+	}, nil
+}
+
+func (c *releaseCollector) Update(ch chan<- prometheus.Metric) error {
+	latest, err := c.latestRelease()
+	if err != nil {
+		return err
+	}
+
+	behind, err := compareVersions(version.Version, latest.Version)
+	if err != nil {
+		return fmt.Errorf("failed to compare running version %q: %w", version.Version, err)
+	}
+
+	updateAvailable, daysBehind := 0.0, 0.0
+	if behind < 0 {
+		updateAvailable = 1
+		if elapsed := c.now().Sub(latest.released); elapsed > 0 {
+			daysBehind = elapsed.Hours() / 24
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.updateAvailable, prometheus.GaugeValue, updateAvailable, version.Version, latest.Version)
+	ch <- prometheus.MustNewConstMetric(c.daysBehind, prometheus.GaugeValue, daysBehind)
+	return nil
+}
+
+// latestRelease returns the release manifest, reading it at most once per
+// --collector.release.check-interval. The previous manifest is kept if it
+// cannot be read.
+func (c *releaseCollector) latestRelease() (*releaseManifest, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := c.now()
+	if c.latest != nil && now.Sub(c.checked) < c.checkInterval {
+		return c.latest, nil
+	}
+
+	data, err := c.read()
+	if err == nil {
+		var latest *releaseManifest
+		if latest, err = parseReleaseManifest(data); err == nil {
+			c.latest = latest
+			c.checked = now
+			return latest, nil
+		}
+	}
+	if c.latest == nil {
+		return nil, err
+	}
+	level.Warn(c.logger).Log("msg", "failed to check for a newer release, using the previous manifest", "err", err)
+	// Retry with the next scrape after the interval, not every scrape.
+	c.checked = now
+	return c.latest, nil
+}
+
+func (c *releaseCollector) read() ([]byte, error) {
+	if c.file != "" {
+		data, err := os.ReadFile(c.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read release manifest: %w", err)
+		}
+		return data, nil
+	}
+
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch release manifest: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func parseReleaseManifest(data []byte) (*releaseManifest, error) {
+	var m releaseManifest
+	// Not strict, so that manifests can carry more information, such as the
+	// download URLs.
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+	if _, err := parseVersion(m.Version); err != nil {
+		return nil, fmt.Errorf("invalid version in release manifest: %w", err)
+	}
+
+	var err error
+	if m.released, err = time.Parse("2006-01-02", m.Date); err != nil {
+		if m.released, err = time.Parse(time.RFC3339, m.Date); err != nil {
+			return nil, fmt.Errorf("invalid date %q in release manifest, expected YYYY-MM-DD or RFC 3339", m.Date)
+		}
+	}
+	return &m, nil
+}
+
+// compareVersions compares two versions of the form 1.8.2 or 1.8.0-rc.0,
+// returning -1, 0 or 1 if a is older, equal or newer than b. Pre-releases
+// are older than the release and compared as strings.
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < 3; i++ {
+		if va.numbers[i] != vb.numbers[i] {
+			if va.numbers[i] < vb.numbers[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case va.prerelease == vb.prerelease:
+		return 0, nil
+	case va.prerelease == "":
+		return 1, nil
+	case vb.prerelease == "":
+		return -1, nil
+	case va.prerelease < vb.prerelease:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+type releaseVersion struct {
+	numbers    [3]int
+	prerelease string
+}
+
+func parseVersion(s string) (releaseVersion, error) {
+	var v releaseVersion
+	core, prerelease, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q, expected <major>.<minor>.<patch>", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q, expected <major>.<minor>.<patch>", s)
+		}
+		v.numbers[i] = n
+	}
+	v.prerelease = prerelease
+	return v, nil
+}
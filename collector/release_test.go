@@ -0,0 +1,79 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !norelease
+// +build !norelease
+
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.8.2", b: "1.8.2", want: 0},
+		{a: "1.8.2", b: "1.9.0", want: -1},
+		{a: "1.10.0", b: "1.9.3", want: 1},
+		{a: "v2.0.0", b: "1.9.3", want: 1},
+		{a: "1.9.0-rc.0", b: "1.9.0", want: -1},
+		{a: "1.9.0-rc.1", b: "1.9.0-rc.0", want: 1},
+	} {
+		got, err := compareVersions(tc.a, tc.b)
+		if err != nil {
+			t.Errorf("compareVersions(%q, %q): unexpected error: %v", tc.a, tc.b, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "1.8", "1.8.x", "main"} {
+		if _, err := compareVersions(invalid, "1.8.2"); err == nil {
+			t.Errorf("compareVersions(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestParseReleaseManifest(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		want  time.Time
+		error bool
+	}{
+		{in: "version: 1.9.0\ndate: 2024-07-14\n", want: time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC)},
+		{in: `{"version": "1.9.0", "date": "2024-07-14T12:00:00Z", "url": "https://example.com"}`, want: time.Date(2024, 7, 14, 12, 0, 0, 0, time.UTC)},
+		{in: "version: 1.9\ndate: 2024-07-14\n", error: true},
+		{in: "version: 1.9.0\n", error: true},
+	} {
+		got, err := parseReleaseManifest([]byte(tc.in))
+		if tc.error {
+			if err == nil {
+				t.Errorf("parseReleaseManifest(%q): expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseReleaseManifest(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if !got.released.Equal(tc.want) {
+			t.Errorf("parseReleaseManifest(%q) released = %v, want %v", tc.in, got.released, tc.want)
+		}
+	}
+}
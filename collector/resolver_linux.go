@@ -0,0 +1,237 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noresolver
+// +build !noresolver
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resolverSubsystem = "resolver"
+
+	resolverFailureNotFound = "not_found"
+	resolverFailureTimeout  = "timeout"
+	resolverFailureError    = "error"
+)
+
+var (
+	resolverHostnames = kingpin.Flag("collector.resolver.hostname",
+		"Hostname resolved through the system resolver on every run of the resolver collector. Can be repeated.").Strings()
+	resolverTimeout = kingpin.Flag("collector.resolver.timeout",
+		"Timeout of a lookup of the resolver collector.").Default("5s").Duration()
+	resolverGetent = kingpin.Flag("collector.resolver.getent",
+		"Path of the getent binary used for lookups.").Default("getent").String()
+
+	resolverFailureReasons = []string{resolverFailureNotFound, resolverFailureTimeout, resolverFailureError}
+)
+
+type resolverCollector struct {
+	logger    log.Logger
+	lookup    func(ctx context.Context, hostname string) ([]string, error)
+	hostnames []string
+	timeout   time.Duration
+
+	lookupsDesc   *prometheus.Desc
+	failuresDesc  *prometheus.Desc
+	durationDesc  *prometheus.Desc
+	addressesDesc *prometheus.Desc
+
+	mtx      sync.Mutex
+	lookups  map[string]float64
+	failures map[string]map[string]float64
+}
+
+// resolverLookupError is the error of a lookup of a hostname, with the reason
+// exposed in the failures metric.
+type resolverLookupError struct {
+	reason string
+	err    error
+}
+
+func (e *resolverLookupError) Error() string { return e.err.Error() }
+
+func init() {
+	registerCollector("resolver", defaultDisabled, NewResolverCollector)
+}
+
+// NewResolverCollector returns a new Collector resolving hostnames through
+// the system resolver.
+//
+// Lookups go through getent, which uses getaddrinfo and thus the sources of
+// nsswitch.conf, such as sssd or LDAP. The resolver of Go bypasses them when
+// built without cgo.
+func NewResolverCollector(logger log.Logger) (Collector, error) {
+	getent, err := exec.LookPath(*resolverGetent)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find getent: %w", err)
+	}
+
+	c := newResolverCollector(logger, func(ctx context.Context, hostname string) ([]string, error) {
+		return getentLookup(ctx, getent, hostname)
+	})
+	return c, nil
+}
+
+func newResolverCollector(logger log.Logger, lookup func(context.Context, string) ([]string, error)) *resolverCollector {
+	return &resolverCollector{
+		logger:    logger,
+		lookup:    lookup,
+		hostnames: *resolverHostnames,
+		timeout:   *resolverTimeout,
+		lookupsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resolverSubsystem, "lookups_total"),
+			"Number of lookups of a hostname through the system resolver.",
+			[]string{"hostname"}, nil,
+		),
+		failuresDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resolverSubsystem, "lookup_failures_total"),
+			"Number of failed lookups of a hostname through the system resolver, by reason.",
+			[]string{"hostname", "reason"}, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resolverSubsystem, "lookup_duration_seconds"),
+			"Duration of the latest lookup of a hostname through the system resolver.",
+			[]string{"hostname"}, nil,
+		),
+		addressesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resolverSubsystem, "lookup_addresses"),
+			"Number of addresses returned by the latest lookup of a hostname through the system resolver.",
+			[]string{"hostname"}, nil,
+		),
+		lookups:  map[string]float64{},
+		failures: map[string]map[string]float64{},
+	}
+}
+
+// resolverResult is the outcome of the lookup of a hostname.
+type resolverResult struct {
+	hostname  string
+	duration  time.Duration
+	addresses int
+	err       error
+}
+
+func (c *resolverCollector) Update(ch chan<- prometheus.Metric) error {
+	if len(c.hostnames) == 0 {
+		return ErrNoData
+	}
+
+	// Lookups run concurrently, so that a hanging source of nsswitch
+	// delays the run by at most one timeout.
+	results := make([]resolverResult, len(c.hostnames))
+	var wg sync.WaitGroup
+	for i, hostname := range c.hostnames {
+		wg.Add(1)
+		go func(i int, hostname string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			defer cancel()
+			begin := time.Now()
+			addresses, err := c.lookup(ctx, hostname)
+			results[i] = resolverResult{hostname: hostname, duration: time.Since(begin), addresses: len(addresses), err: err}
+		}(i, hostname)
+	}
+	wg.Wait()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, r := range results {
+		c.lookups[r.hostname]++
+		failures, ok := c.failures[r.hostname]
+		if !ok {
+			failures = map[string]float64{}
+			for _, reason := range resolverFailureReasons {
+				failures[reason] = 0
+			}
+			c.failures[r.hostname] = failures
+		}
+		if r.err != nil {
+			reason := resolverFailureError
+			var lookupErr *resolverLookupError
+			if errors.As(r.err, &lookupErr) {
+				reason = lookupErr.reason
+			}
+			failures[reason]++
+			level.Debug(c.logger).Log("msg", "lookup failed", "hostname", r.hostname, "reason", reason, "err", r.err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.lookupsDesc, prometheus.CounterValue, c.lookups[r.hostname], r.hostname)
+		for _, reason := range resolverFailureReasons {
+			ch <- prometheus.MustNewConstMetric(c.failuresDesc, prometheus.CounterValue, failures[reason], r.hostname, reason)
+		}
+		ch <- prometheus.MustNewConstMetric(c.durationDesc, prometheus.GaugeValue, r.duration.Seconds(), r.hostname)
+		ch <- prometheus.MustNewConstMetric(c.addressesDesc, prometheus.GaugeValue, float64(r.addresses), r.hostname)
+	}
+	return nil
+}
+
+// getentLookup resolves a hostname with getent ahosts and returns the
+// distinct addresses.
+func getentLookup(ctx context.Context, getent, hostname string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, getent, "ahosts", hostname)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &resolverLookupError{resolverFailureTimeout, fmt.Errorf("lookup of %s timed out", hostname)}
+	}
+	var exitErr *exec.ExitError
+	// getent exits with 2 if the key is not found.
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return nil, &resolverLookupError{resolverFailureNotFound, fmt.Errorf("%s not found", hostname)}
+	}
+	if err != nil {
+		return nil, &resolverLookupError{resolverFailureError, fmt.Errorf("getent failed: %w: %s", err, strings.TrimSpace(stderr.String()))}
+	}
+	return parseGetentAhosts(stdout.String()), nil
+}
+
+// parseGetentAhosts returns the distinct addresses of the output of getent
+// ahosts, which lists every address once per socket type:
+//
+//	192.0.2.1       STREAM host.example.com
+//	192.0.2.1       DGRAM
+//	192.0.2.1       RAW
+func parseGetentAhosts(output string) []string {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		seen[fields[0]] = true
+	}
+	addresses := make([]string, 0, len(seen))
+	for address := range seen {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !noresolver
+// +build !noresolver
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type testResolverCollector struct {
+	c *resolverCollector
+}
+
+func (c testResolverCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c testResolverCollector) Collect(ch chan<- prometheus.Metric) {
+	c.c.Update(ch)
+}
+
+func TestResolverCollector(t *testing.T) {
+	defer func(hostnames []string) { *resolverHostnames = hostnames }(*resolverHostnames)
+	*resolverHostnames = []string{"db.example.com", "ldap.example.com"}
+
+	c := newResolverCollector(log.NewNopLogger(), func(ctx context.Context, hostname string) ([]string, error) {
+		if hostname == "ldap.example.com" {
+			return nil, &resolverLookupError{resolverFailureTimeout, errors.New("timed out")}
+		}
+		return []string{"192.0.2.1", "2001:db8::1"}, nil
+	})
+	// Run twice, the counters accumulate between runs.
+	testutil.CollectAndCount(testResolverCollector{c})
+
+	want := `# HELP node_resolver_lookup_addresses Number of addresses returned by the latest lookup of a hostname through the system resolver.
+# TYPE node_resolver_lookup_addresses gauge
+node_resolver_lookup_addresses{hostname="db.example.com"} 2
+node_resolver_lookup_addresses{hostname="ldap.example.com"} 0
+# HELP node_resolver_lookup_failures_total Number of failed lookups of a hostname through the system resolver, by reason.
+# TYPE node_resolver_lookup_failures_total counter
+node_resolver_lookup_failures_total{hostname="db.example.com",reason="error"} 0
+node_resolver_lookup_failures_total{hostname="db.example.com",reason="not_found"} 0
+node_resolver_lookup_failures_total{hostname="db.example.com",reason="timeout"} 0
+node_resolver_lookup_failures_total{hostname="ldap.example.com",reason="error"} 0
+node_resolver_lookup_failures_total{hostname="ldap.example.com",reason="not_found"} 0
+node_resolver_lookup_failures_total{hostname="ldap.example.com",reason="timeout"} 2
+# HELP node_resolver_lookups_total Number of lookups of a hostname through the system resolver.
+# TYPE node_resolver_lookups_total counter
+node_resolver_lookups_total{hostname="db.example.com"} 2
+node_resolver_lookups_total{hostname="ldap.example.com"} 2
+`
+	if err := testutil.CollectAndCompare(testResolverCollector{c}, strings.NewReader(want),
+		"node_resolver_lookup_addresses", "node_resolver_lookup_failures_total", "node_resolver_lookups_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetentLookup(t *testing.T) {
+	getent := filepath.Join(t.TempDir(), "getent")
+	script := `#!/bin/sh
+case "$2" in
+host.example.com)
+	printf '192.0.2.1       STREAM host.example.com\n192.0.2.1       DGRAM  \n192.0.2.1       RAW    \n2001:db8::1     STREAM \n'
+	;;
+*)
+	exit 2
+	;;
+esac
+`
+	if err := os.WriteFile(getent, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	addresses, err := getentLookup(context.Background(), getent, "host.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.1", "2001:db8::1"}; !reflect.DeepEqual(addresses, want) {
+		t.Errorf("want addresses %v, got %v", want, addresses)
+	}
+
+	_, err = getentLookup(context.Background(), getent, "missing.example.com")
+	var lookupErr *resolverLookupError
+	if !errors.As(err, &lookupErr) || lookupErr.reason != resolverFailureNotFound {
+		t.Errorf("want %s error, got %v", resolverFailureNotFound, err)
+	}
+}
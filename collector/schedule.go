@@ -0,0 +1,169 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scheduleCPUBudget = kingpin.Flag("collector.schedule.cpu-budget",
+		"CPU time the heavy collectors may use together per scrape, e.g. 200ms. Heavy collectors over the budget are deferred to later scrapes, which are served the metrics of their latest run. The CPU time of a collector is approximated by the CPU time of the whole process while it runs, so concurrent requests are charged to it. 0 disables the scheduling.").Default("0s").Duration()
+	scheduleHeavyCollectors = kingpin.Flag("collector.schedule.heavy",
+		"Collector scheduled within --collector.schedule.cpu-budget. Can be repeated.").Default("dirsize", "ethtool", "mountstats", "perf", "processes", "smart", "systemd").Strings()
+)
+
+var scrapeDeferredDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_deferred"),
+	"node_exporter: Whether a heavy collector was deferred to a later scrape and its latest metrics were served, see --collector.schedule.*.",
+	[]string{"collector"},
+	nil,
+)
+
+// heavyCollector holds the scheduling state of a heavy collector.
+type heavyCollector struct {
+	lastRun time.Time
+	// cost is the CPU time used by the latest run, if known.
+	cost      time.Duration
+	costKnown bool
+	// metrics and err are the result of the latest run, served while the
+	// collector is deferred.
+	metrics []prometheus.Metric
+	err     error
+	ran     bool
+}
+
+// heavySchedule holds the scheduling state of the heavy collectors by name.
+// Heavy collectors are staggered across scrapes, so that nodes enabling all
+// of them do not spike in CPU usage on every scrape, at the same time
+// fleet-wide.
+var heavySchedule = struct {
+	sync.Mutex
+	collectors map[string]*heavyCollector
+}{collectors: map[string]*heavyCollector{}}
+
+// scheduleEnabled returns whether heavy collectors are scheduled.
+func scheduleEnabled() bool {
+	return *scheduleCPUBudget > 0
+}
+
+// isHeavy returns whether a collector is listed with
+// --collector.schedule.heavy.
+func isHeavy(name string) bool {
+	for _, heavy := range *scheduleHeavyCollectors {
+		if name == heavy {
+			return true
+		}
+	}
+	return false
+}
+
+// heavyState returns the scheduling state of a heavy collector. The caller
+// must hold the lock of heavySchedule.
+func heavyState(name string) *heavyCollector {
+	state, ok := heavySchedule.collectors[name]
+	if !ok {
+		state = &heavyCollector{}
+		heavySchedule.collectors[name] = state
+	}
+	return state
+}
+
+// planHeavy splits the heavy collectors of a scrape into the ones run and
+// the ones deferred. The collectors that ran least recently go first and run
+// as long as their costs fit into the budget. Collectors whose cost is not
+// known yet are assumed to use the whole budget. The first collector always
+// runs, so that every collector runs eventually even if it alone exceeds the
+// budget.
+func planHeavy(names []string, budget time.Duration) (run, deferred []string) {
+	heavySchedule.Lock()
+	defer heavySchedule.Unlock()
+
+	sort.Slice(names, func(i, j int) bool {
+		a, b := heavyState(names[i]).lastRun, heavyState(names[j]).lastRun
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return names[i] < names[j]
+	})
+	var spent time.Duration
+	for i, name := range names {
+		cost := budget
+		if state := heavyState(name); state.costKnown {
+			cost = state.cost
+		}
+		if i > 0 && spent+cost > budget {
+			deferred = append(deferred, name)
+			continue
+		}
+		spent += cost
+		run = append(run, name)
+	}
+	return run, deferred
+}
+
+// recordHeavyCost records the CPU time used by a run of a heavy collector.
+func recordHeavyCost(name string, cost time.Duration, now time.Time) {
+	heavySchedule.Lock()
+	defer heavySchedule.Unlock()
+
+	state := heavyState(name)
+	state.lastRun, state.cost, state.costKnown = now, max(cost, 0), true
+}
+
+// updateScheduled runs the update of a heavy collector and keeps its result,
+// or serves the result of its latest run if the collector is deferred.
+func updateScheduled(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric, deferred bool) error {
+	if deferred {
+		heavySchedule.Lock()
+		state := heavyState(name)
+		ran, metrics, err := state.ran, state.metrics, state.err
+		heavySchedule.Unlock()
+
+		if !ran {
+			return ErrNoData
+		}
+		for _, m := range metrics {
+			ch <- m
+		}
+		return err
+	}
+
+	var (
+		collected []prometheus.Metric
+		metricsCh = make(chan prometheus.Metric)
+		done      = make(chan struct{})
+	)
+	go func() {
+		for m := range metricsCh {
+			collected = append(collected, m)
+			ch <- m
+		}
+		close(done)
+	}()
+	err := update(metricsCh)
+	close(metricsCh)
+	<-done
+
+	heavySchedule.Lock()
+	state := heavyState(name)
+	state.metrics, state.err, state.ran = collected, err, true
+	heavySchedule.Unlock()
+	return err
+}
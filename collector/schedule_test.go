@@ -0,0 +1,98 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPlanHeavy(t *testing.T) {
+	heavySchedule.Lock()
+	heavySchedule.collectors = map[string]*heavyCollector{}
+	heavySchedule.Unlock()
+
+	budget := 100 * time.Millisecond
+	names := []string{"perf", "processes", "smart"}
+
+	// Collectors whose cost is not known yet run one per scrape.
+	run, deferred := planHeavy(names, budget)
+	if want := []string{"perf"}; !reflect.DeepEqual(run, want) {
+		t.Fatalf("got run %v, want %v", run, want)
+	}
+	if want := []string{"processes", "smart"}; !reflect.DeepEqual(deferred, want) {
+		t.Fatalf("got deferred %v, want %v", deferred, want)
+	}
+
+	now := time.Now()
+	recordHeavyCost("perf", 150*time.Millisecond, now)
+	recordHeavyCost("processes", 40*time.Millisecond, now.Add(time.Second))
+	recordHeavyCost("smart", 50*time.Millisecond, now.Add(2*time.Second))
+
+	// The collector that ran least recently goes first, even over budget.
+	run, deferred = planHeavy(names, budget)
+	if want := []string{"perf"}; !reflect.DeepEqual(run, want) {
+		t.Errorf("got run %v, want %v", run, want)
+	}
+	if want := []string{"processes", "smart"}; !reflect.DeepEqual(deferred, want) {
+		t.Errorf("got deferred %v, want %v", deferred, want)
+	}
+
+	recordHeavyCost("perf", 150*time.Millisecond, now.Add(3*time.Second))
+	run, deferred = planHeavy(names, budget)
+	if want := []string{"processes", "smart"}; !reflect.DeepEqual(run, want) {
+		t.Errorf("got run %v, want %v", run, want)
+	}
+	if want := []string{"perf"}; !reflect.DeepEqual(deferred, want) {
+		t.Errorf("got deferred %v, want %v", deferred, want)
+	}
+}
+
+func TestUpdateScheduled(t *testing.T) {
+	desc := prometheus.NewDesc("schedule_test", "", nil, nil)
+	runs := 0
+	update := func(ch chan<- prometheus.Metric) error {
+		runs++
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(runs))
+		return nil
+	}
+	collect := func(deferred bool) ([]float64, error) {
+		ch := make(chan prometheus.Metric, 10)
+		err := updateScheduled("schedule_test", update, ch, deferred)
+		close(ch)
+		var values []float64
+		for m := range ch {
+			values = append(values, readGauge(t, m))
+		}
+		return values, err
+	}
+
+	if _, err := collect(true); !errors.Is(err, ErrNoData) {
+		t.Fatalf("got error %v for collector deferred before its first run, want no data", err)
+	}
+	if values, err := collect(false); err != nil || !reflect.DeepEqual(values, []float64{1}) {
+		t.Fatalf("got %v, %v, want [1]", values, err)
+	}
+	// Deferred collectors serve the metrics of their latest run.
+	if values, err := collect(true); err != nil || !reflect.DeepEqual(values, []float64{1}) {
+		t.Fatalf("got %v, %v, want [1]", values, err)
+	}
+	if runs != 1 {
+		t.Errorf("got %d runs, want deferred collector not to run", runs)
+	}
+}
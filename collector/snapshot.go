@@ -0,0 +1,154 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotManifestName is the name of the manifest of a snapshot of procfs
+// or sysfs, e.g. a directory recorded on a production host and mounted over
+// FUSE to replay its exact state. It lists the SHA-256 hash of every file in
+// the format of sha256sum.
+const snapshotManifestName = "MANIFEST.sha256"
+
+// SnapshotDirs returns the directories among --path.procfs and --path.sysfs
+// that are snapshots, i.e. contain a manifest.
+func SnapshotDirs() []string {
+	var dirs []string
+	for _, dir := range []string{*procPath, *sysPath} {
+		if _, err := os.Lstat(filepath.Join(dir, snapshotManifestName)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// VerifySnapshot checks that the files of a snapshot directory are exactly
+// the ones listed in its manifest, with the listed hashes, so that a
+// snapshot that was modified or copied incompletely is not replayed.
+func VerifySnapshot(dir string) error {
+	manifest, err := readSnapshotManifest(filepath.Join(dir, snapshotManifestName))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == snapshotManifestName {
+			return nil
+		}
+		want, ok := manifest[rel]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: not in manifest", rel))
+			return nil
+		}
+		delete(manifest, rel)
+		got, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			errs = append(errs, fmt.Errorf("%s: hash %s does not match manifest hash %s", rel, got, want))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	missing := make([]string, 0, len(manifest))
+	for rel := range manifest {
+		missing = append(missing, rel)
+	}
+	sort.Strings(missing)
+	for _, rel := range missing {
+		errs = append(errs, fmt.Errorf("%s: missing", rel))
+	}
+	return errors.Join(errs...)
+}
+
+// readSnapshotManifest returns the hashes listed in a manifest by the paths
+// of the files relative to the snapshot directory.
+func readSnapshotManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, rel, ok := strings.Cut(text, " ")
+		// sha256sum marks files hashed in binary mode with an asterisk.
+		rel = strings.TrimPrefix(strings.TrimPrefix(rel, " "), "*")
+		if !ok || len(hash) != hex.EncodedLen(sha256.Size) || rel == "" {
+			return nil, fmt.Errorf("%s:%d: invalid line %q, want \"<sha256>  <path>\"", path, line, text)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid hash %q", path, line, hash)
+		}
+		rel = filepath.Clean(strings.TrimPrefix(rel, "./"))
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%s:%d: path %q is outside of the snapshot", path, line, rel)
+		}
+		if _, ok := hashes[rel]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate path %q", path, line, rel)
+		}
+		hashes[rel] = strings.ToLower(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("loadavg", "0.42 0.39 0.35 1/123 4567\n")
+	write("sys/kernel/random/entropy_avail", "256\n")
+	write(snapshotManifestName, strings.Join([]string{
+		"# recorded with sha256sum",
+		"ab3eee1b05e6594563dac37e54af5c969af1c2a503c4b3d1863f9cef4d3d1f3e  ./loadavg",
+		"f16c302d5d30e1d3fbe955cf4f637f58a871adeba597922e3baad0aeeb13f656 *sys/kernel/random/entropy_avail",
+	}, "\n")+"\n")
+
+	dirs := func() []string {
+		defer func(path string) { *procPath = path }(*procPath)
+		*procPath = dir
+		return SnapshotDirs()
+	}
+	if got := dirs(); len(got) != 1 || got[0] != dir {
+		t.Fatalf("got snapshot directories %v, want %s", got, dir)
+	}
+
+	if err := VerifySnapshot(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	write("loadavg", "9.99 0.39 0.35 1/123 4567\n")
+	write("stat", "cpu 1 2 3 4\n")
+	if err := os.Remove(filepath.Join(dir, "sys/kernel/random/entropy_avail")); err != nil {
+		t.Fatal(err)
+	}
+	err := VerifySnapshot(dir)
+	if err == nil {
+		t.Fatal("modified snapshot verified")
+	}
+	for _, want := range []string{
+		"loadavg: hash",
+		"stat: not in manifest",
+		"sys/kernel/random/entropy_avail: missing",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to contain %q", err, want)
+		}
+	}
+
+	write(snapshotManifestName, "ab3eee1b05e6594563dac37e54af5c969af1c2a503c4b3d1863f9cef4d3d1f3e  ../loadavg\n")
+	if err := VerifySnapshot(dir); err == nil || !strings.Contains(err.Error(), "outside of the snapshot") {
+		t.Errorf("got error %v, want path outside of the snapshot", err)
+	}
+}
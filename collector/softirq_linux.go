@@ -18,7 +18,6 @@ package collector
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -34,34 +33,34 @@ func (c *softirqsCollector) Update(ch chan<- prometheus.Metric) (err error) {
 	}
 
 	for cpuNo, value := range softirqs.Hi {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "HI")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "HI")
 	}
 	for cpuNo, value := range softirqs.Timer {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "TIMER")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "TIMER")
 	}
 	for cpuNo, value := range softirqs.NetTx {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "NET_TX")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "NET_TX")
 	}
 	for cpuNo, value := range softirqs.NetRx {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "NET_RX")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "NET_RX")
 	}
 	for cpuNo, value := range softirqs.Block {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "BLOCK")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "BLOCK")
 	}
 	for cpuNo, value := range softirqs.IRQPoll {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "IRQ_POLL")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "IRQ_POLL")
 	}
 	for cpuNo, value := range softirqs.Tasklet {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "TASKLET")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "TASKLET")
 	}
 	for cpuNo, value := range softirqs.Sched {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "SCHED")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "SCHED")
 	}
 	for cpuNo, value := range softirqs.HRTimer {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "HRTIMER")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "HRTIMER")
 	}
 	for cpuNo, value := range softirqs.RCU {
-		ch <- c.desc.mustNewConstMetric(float64(value), strconv.Itoa(cpuNo), "RCU")
+		ch <- c.desc.mustNewConstMetric(float64(value), itoaLabel(cpuNo), "RCU")
 	}
 
 	return err
@@ -18,7 +18,6 @@ package collector
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -103,7 +102,7 @@ func (c *softnetCollector) Update(ch chan<- prometheus.Metric) error {
 	}
 
 	for _, cpuStats := range stats {
-		cpu = strconv.FormatUint(uint64(cpuStats.Index), 10)
+		cpu = itoaLabel(int(cpuStats.Index))
 
 		ch <- prometheus.MustNewConstMetric(
 			c.processed,
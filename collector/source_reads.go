@@ -0,0 +1,170 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sourceBackoffFailures = kingpin.Flag("collector.read-backoff.failures",
+		"Number of consecutive failed reads of a sysfs or procfs file, such as a broken hwmon sensor, after which it is no longer read for an exponentially growing time. Use 0 to disable.").Default("3").Int()
+	sourceBackoffMax = kingpin.Flag("collector.read-backoff.max",
+		"Maximum time for which a failing file is not read.").Default("30m").Duration()
+)
+
+// sourceBackoffInitial is the time for which a file is not read once it
+// failed --collector.read-backoff.failures times in a row. It doubles with
+// every further failure.
+const sourceBackoffInitial = 30 * time.Second
+
+var (
+	sourceReadFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "source", "read_failures_total"),
+		"node_exporter: Number of failed reads of sysfs and procfs files, by class of path, not counting missing files.",
+		[]string{"path_class"}, nil,
+	)
+	sourceBackoffPathsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "source", "read_backoff_paths"),
+		"node_exporter: Number of failing sysfs and procfs files currently not read, by class of path.",
+		[]string{"path_class"}, nil,
+	)
+)
+
+// errSourceBackoff is returned for the reads of a file skipped while it is
+// backed off.
+var errSourceBackoff = errors.New("file backed off after repeated read failures")
+
+// failingSource is a file whose last reads failed.
+type failingSource struct {
+	failures int
+	until    time.Time
+}
+
+// sourceReads tracks the read failures of files, so that files that keep
+// failing are not read on every scrape.
+var sourceReads = struct {
+	sync.Mutex
+	failures map[string]float64
+	failing  map[string]*failingSource
+	// backedOff are the files that started being backed off since the
+	// last scrape, to be logged once.
+	backedOff []string
+}{
+	failures: map[string]float64{},
+	failing:  map[string]*failingSource{},
+}
+
+// readSourceFile reads a sysfs or procfs file with read, unless the file is
+// backed off after failing repeatedly.
+func readSourceFile(path string, read func(string) ([]byte, error)) ([]byte, error) {
+	now := time.Now()
+	sourceReads.Lock()
+	if f, ok := sourceReads.failing[path]; ok && now.Before(f.until) {
+		sourceReads.Unlock()
+		return nil, fmt.Errorf("%s: %w", path, errSourceBackoff)
+	}
+	sourceReads.Unlock()
+
+	data, err := read(path)
+	recordSourceRead(path, err, now)
+	return data, err
+}
+
+// recordSourceRead records the outcome of a read of a file at now.
+func recordSourceRead(path string, err error, now time.Time) {
+	sourceReads.Lock()
+	defer sourceReads.Unlock()
+
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		delete(sourceReads.failing, path)
+		return
+	}
+	sourceReads.failures[sourcePathClass(path)]++
+	if *sourceBackoffFailures <= 0 {
+		return
+	}
+
+	f, ok := sourceReads.failing[path]
+	if !ok {
+		f = &failingSource{}
+		sourceReads.failing[path] = f
+	}
+	f.failures++
+	if f.failures < *sourceBackoffFailures {
+		return
+	}
+	if f.failures == *sourceBackoffFailures {
+		sourceReads.backedOff = append(sourceReads.backedOff, path)
+	}
+	backoff := *sourceBackoffMax
+	if shift := f.failures - *sourceBackoffFailures; shift < 32 {
+		backoff = min(sourceBackoffInitial<<shift, *sourceBackoffMax)
+	}
+	f.until = now.Add(backoff)
+}
+
+// sourcePathClass returns the class of a sysfs or procfs path for the read
+// failure metrics, e.g. sysfs/class/hwmon or procfs/pid.
+func sourcePathClass(path string) string {
+	if rel, ok := strings.CutPrefix(path, strings.TrimSuffix(*sysPath, "/")+"/"); ok {
+		parts := strings.SplitN(rel, "/", 3)
+		if len(parts) < 2 {
+			return "sysfs"
+		}
+		return "sysfs/" + parts[0] + "/" + parts[1]
+	}
+	if rel, ok := strings.CutPrefix(path, strings.TrimSuffix(*procPath, "/")+"/"); ok {
+		first, _, _ := strings.Cut(rel, "/")
+		if strings.Trim(first, "0123456789") == "" || first == "self" {
+			first = "pid"
+		}
+		return "procfs/" + first
+	}
+	return "other"
+}
+
+// exposeSourceReads exposes the read failures and logs the files that
+// started being backed off.
+func exposeSourceReads(ch chan<- prometheus.Metric, logger log.Logger) {
+	sourceReads.Lock()
+	defer sourceReads.Unlock()
+
+	for _, path := range sourceReads.backedOff {
+		level.Warn(logger).Log("msg", "file keeps failing to be read, backing off", "path", path, "failures", *sourceBackoffFailures)
+	}
+	sourceReads.backedOff = nil
+
+	now := time.Now()
+	backedOff := map[string]float64{}
+	for path, f := range sourceReads.failing {
+		if now.Before(f.until) {
+			backedOff[sourcePathClass(path)]++
+		}
+	}
+	for class, failures := range sourceReads.failures {
+		ch <- prometheus.MustNewConstMetric(sourceReadFailuresDesc, prometheus.CounterValue, failures, class)
+		ch <- prometheus.MustNewConstMetric(sourceBackoffPathsDesc, prometheus.GaugeValue, backedOff[class], class)
+	}
+}
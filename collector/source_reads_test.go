@@ -0,0 +1,113 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReadSourceFileBackoff(t *testing.T) {
+	defer func(path string) { *sysPath = path }(*sysPath)
+	defer func(failures int, max time.Duration) {
+		*sourceBackoffFailures, *sourceBackoffMax = failures, max
+	}(*sourceBackoffFailures, *sourceBackoffMax)
+	defer func() {
+		sourceReads.Lock()
+		sourceReads.failures = map[string]float64{}
+		sourceReads.failing = map[string]*failingSource{}
+		sourceReads.backedOff = nil
+		sourceReads.Unlock()
+	}()
+	*sysPath = "/sys"
+	*sourceBackoffFailures, *sourceBackoffMax = 3, 2*time.Minute
+
+	path := "/sys/class/hwmon/hwmon0/temp1_input"
+	reads := 0
+	var readErr error
+	read := func(string) ([]byte, error) {
+		reads++
+		return []byte("42000\n"), readErr
+	}
+
+	readErr = syscall.EIO
+	for i := 0; i < 3; i++ {
+		if _, err := readSourceFile(path, read); !errors.Is(err, syscall.EIO) {
+			t.Fatalf("read %d: got error %v, want EIO", i, err)
+		}
+	}
+	if _, err := readSourceFile(path, read); !errors.Is(err, errSourceBackoff) {
+		t.Fatalf("got error %v, want backoff", err)
+	}
+	if reads != 3 {
+		t.Errorf("got %d reads, want backed off file not to be read", reads)
+	}
+
+	sourceReads.Lock()
+	failures := sourceReads.failures["sysfs/class/hwmon"]
+	until := sourceReads.failing[path].until
+	sourceReads.Unlock()
+	if failures != 3 {
+		t.Errorf("got %v failures, want 3", failures)
+	}
+
+	// The backoff doubles with every further failure, up to the maximum.
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		now := until
+		recordSourceRead(path, syscall.EIO, now)
+		sourceReads.Lock()
+		until = sourceReads.failing[path].until
+		sourceReads.Unlock()
+		if got := until.Sub(now); got != want {
+			t.Errorf("got backoff %s, want %s", got, want)
+		}
+	}
+
+	// A successful read ends the backoff.
+	recordSourceRead(path, nil, time.Now())
+	readErr = nil
+	if _, err := readSourceFile(path, read); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing files are not failures.
+	recordSourceRead("/sys/class/hwmon/hwmon0/temp2_input", os.ErrNotExist, time.Now())
+	sourceReads.Lock()
+	_, failing := sourceReads.failing["/sys/class/hwmon/hwmon0/temp2_input"]
+	sourceReads.Unlock()
+	if failing {
+		t.Error("missing file should not be backed off")
+	}
+}
+
+func TestSourcePathClass(t *testing.T) {
+	defer func(sys, proc string) { *sysPath, *procPath = sys, proc }(*sysPath, *procPath)
+	*sysPath, *procPath = "/host/sys", "/host/proc/"
+
+	for path, want := range map[string]string{
+		"/host/sys/class/hwmon/hwmon3/temp1_input":      "sysfs/class/hwmon",
+		"/host/sys/bus/pci/devices/0000:3b:00.0/vendor": "sysfs/bus/pci",
+		"/host/sys/kernel":                              "sysfs",
+		"/host/proc/1234/stat":                          "procfs/pid",
+		"/host/proc/net/dev":                            "procfs/net",
+		"/etc/os-release":                               "other",
+	} {
+		if got := sourcePathClass(path); got != want {
+			t.Errorf("sourcePathClass(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -34,6 +34,7 @@ type statCollector struct {
 	procsRunning *prometheus.Desc
 	procsBlocked *prometheus.Desc
 	softIRQ      *prometheus.Desc
+	softIRQCalls bool
 	logger       log.Logger
 }
 
@@ -86,7 +87,8 @@ func NewStatCollector(logger log.Logger) (Collector, error) {
 			"Number of softirq calls.",
 			[]string{"vector"}, nil,
 		),
-		logger: logger,
+		softIRQCalls: *statSoftirqFlag,
+		logger:       logger,
 	}, nil
 }
 
@@ -106,7 +108,7 @@ func (c *statCollector) Update(ch chan<- prometheus.Metric) error {
 	ch <- prometheus.MustNewConstMetric(c.procsRunning, prometheus.GaugeValue, float64(stats.ProcessesRunning))
 	ch <- prometheus.MustNewConstMetric(c.procsBlocked, prometheus.GaugeValue, float64(stats.ProcessesBlocked))
 
-	if *statSoftirqFlag {
+	if c.softIRQCalls {
 		si := stats.SoftIRQ
 
 		for _, vec := range []struct {
@@ -0,0 +1,171 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+var (
+	statePath = kingpin.Flag("state.path", "File in which collectors persist their internal state, such as rate baselines, across restarts. Persistence is disabled if empty.").String()
+)
+
+const (
+	stateVersion = 1
+	// stateSaveInterval limits how often the state file is written, as
+	// it is updated after scrapes.
+	stateSaveInterval = time.Minute
+)
+
+// statefulCollector is implemented by collectors keeping state between
+// scrapes that should survive a restart of node_exporter.
+type statefulCollector interface {
+	// saveState returns the state of the collector to persist.
+	saveState() (json.RawMessage, error)
+	// loadState restores a state previously returned by saveState.
+	loadState(json.RawMessage) error
+}
+
+type stateFile struct {
+	Version    int                        `json:"version"`
+	Collectors map[string]json.RawMessage `json:"collectors"`
+}
+
+// collectorStates holds the persisted state of all collectors, so that
+// scrapes filtered with collect[] do not drop the state of other collectors.
+var collectorStates = struct {
+	sync.Mutex
+	loaded bool
+	saved  time.Time
+	states map[string]json.RawMessage
+}{states: map[string]json.RawMessage{}}
+
+// restoreState loads the persisted state of a newly created collector.
+func restoreState(name string, c Collector, logger log.Logger) {
+	sc, ok := c.(statefulCollector)
+	if !ok || *statePath == "" {
+		return
+	}
+
+	collectorStates.Lock()
+	defer collectorStates.Unlock()
+
+	if !collectorStates.loaded {
+		collectorStates.loaded = true
+		states, err := readStateFile(*statePath)
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to read state file, collectors start without state", "path", *statePath, "err", err)
+		} else {
+			collectorStates.states = states
+		}
+	}
+
+	state, ok := collectorStates.states[name]
+	if !ok {
+		return
+	}
+	if err := sc.loadState(state); err != nil {
+		level.Warn(logger).Log("msg", "failed to restore collector state", "collector", name, "err", err)
+	}
+}
+
+// persistState writes the state of the given collectors to --state.path, at
+// most once per stateSaveInterval.
+func persistState(collectors map[string]Collector, logger log.Logger) {
+	if *statePath == "" {
+		return
+	}
+
+	collectorStates.Lock()
+	defer collectorStates.Unlock()
+
+	now := time.Now()
+	if now.Sub(collectorStates.saved) < stateSaveInterval {
+		return
+	}
+	collectorStates.saved = now
+
+	for name, c := range collectors {
+		sc, ok := c.(statefulCollector)
+		if !ok {
+			continue
+		}
+		state, err := sc.saveState()
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to save collector state", "collector", name, "err", err)
+			continue
+		}
+		collectorStates.states[name] = state
+	}
+
+	if err := writeStateFile(*statePath, collectorStates.states); err != nil {
+		level.Warn(logger).Log("msg", "failed to write state file", "path", *statePath, "err", err)
+	}
+}
+
+func readStateFile(path string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]json.RawMessage{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f stateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if f.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state file version %d", f.Version)
+	}
+	if f.Collectors == nil {
+		f.Collectors = map[string]json.RawMessage{}
+	}
+	return f.Collectors, nil
+}
+
+// writeStateFile replaces the state file atomically, so that a crash while
+// writing does not leave a truncated file behind.
+func writeStateFile(path string, states map[string]json.RawMessage) error {
+	data, err := json.Marshal(stateFile{Version: stateVersion, Collectors: states})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
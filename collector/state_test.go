@@ -0,0 +1,56 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	states, err := readStateFile(path)
+	if err != nil {
+		t.Fatalf("reading a missing state file: %v", err)
+	}
+	if len(states) != 0 {
+		t.Errorf("expected no state from a missing state file, got %v", states)
+	}
+
+	want := map[string]json.RawMessage{
+		"fdleak": json.RawMessage(`{"boot_id":"b","samples":{}}`),
+		"hwmon":  json.RawMessage(`null`),
+	}
+	if err := writeStateFile(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readStateFile() = %s, want %s", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":2,"collectors":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readStateFile(path); err == nil {
+		t.Error("expected error for an unsupported state file version")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CollectorStatus is the outcome of the latest run of an enabled collector.
+type CollectorStatus struct {
+	Name string
+	// LastRun is the start of the latest run, zero if the collector did
+	// not run yet.
+	LastRun time.Time
+	// LastSuccess is the start of the latest successful run.
+	LastSuccess time.Time
+	Duration    time.Duration
+	// Err is the error of the latest run, nil if it succeeded.
+	Err error
+}
+
+// collectorStatuses holds the latest run of every collector.
+var collectorStatuses = struct {
+	sync.Mutex
+	statuses map[string]CollectorStatus
+}{statuses: map[string]CollectorStatus{}}
+
+// recordStatus records the outcome of a run of a collector.
+func recordStatus(name string, begin time.Time, duration time.Duration, err error) {
+	collectorStatuses.Lock()
+	defer collectorStatuses.Unlock()
+
+	status := collectorStatuses.statuses[name]
+	status.Name, status.LastRun, status.Duration, status.Err = name, begin, duration, err
+	if err == nil {
+		status.LastSuccess = begin
+	}
+	collectorStatuses.statuses[name] = status
+}
+
+// CollectorStatuses returns the status of every enabled collector, sorted by
+// name.
+func CollectorStatuses() []CollectorStatus {
+	collectorStatuses.Lock()
+	defer collectorStatuses.Unlock()
+
+	var statuses []CollectorStatus
+	for name, enabled := range collectorState {
+		if !*enabled {
+			continue
+		}
+		status, ok := collectorStatuses.statuses[name]
+		if !ok {
+			status = CollectorStatus{Name: name}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// SelfCheck checks that the procfs and sysfs mountpoints the collectors read
+// from are accessible, without running any collector.
+func SelfCheck() error {
+	f, err := os.Open(procFilePath("stat"))
+	if err != nil {
+		return fmt.Errorf("procfs is not readable: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Read(make([]byte, 1)); err != nil {
+		return fmt.Errorf("procfs is not readable: %w", err)
+	}
+	if _, err := os.ReadDir(*sysPath); err != nil {
+		return fmt.Errorf("sysfs is not readable: %w", err)
+	}
+	return nil
+}
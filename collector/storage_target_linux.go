@@ -0,0 +1,244 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && !nostorage_target
+// +build linux,!nostorage_target
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const storageTargetSubsystem = "storage_target"
+
+// mebibyte is the unit of the byte counters of LIO.
+const mebibyte = 1 << 20
+
+var (
+	storageTargetLUNLabels = []string{"fabric", "target", "tpg", "lun", "backstore", "device"}
+
+	storageTargetLUNCommandsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "lun_commands_total"),
+		"Number of SCSI commands received through a LUN of a LIO target.",
+		storageTargetLUNLabels, nil,
+	)
+	storageTargetLUNReadBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "lun_read_bytes_total"),
+		"Number of bytes read by initiators through a LUN of a LIO target, counted in MiB.",
+		storageTargetLUNLabels, nil,
+	)
+	storageTargetLUNWrittenBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "lun_written_bytes_total"),
+		"Number of bytes written by initiators through a LUN of a LIO target, counted in MiB.",
+		storageTargetLUNLabels, nil,
+	)
+	storageTargetDeviceResetsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "device_resets_total"),
+		"Number of resets of a LIO backstore device requested by initiators.",
+		[]string{"backstore", "device"}, nil,
+	)
+	storageTargetDeviceAbortsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "device_aborts_total"),
+		"Number of commands to a LIO backstore device aborted by initiators, by whether the command was found and aborted.",
+		[]string{"backstore", "device", "result"}, nil,
+	)
+	storageTargetNVMeNamespaceInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "nvme_namespace_info"),
+		"Backing device of a namespace of an NVMe-oF target subsystem. Its I/O is exposed by the diskstats collector of the device.",
+		[]string{"subsystem", "namespace", "device_path", "device"}, nil,
+	)
+	storageTargetNVMeNamespaceEnabledDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, storageTargetSubsystem, "nvme_namespace_enabled"),
+		"Whether a namespace of an NVMe-oF target subsystem is enabled.",
+		[]string{"subsystem", "namespace"}, nil,
+	)
+)
+
+// storageTargetCollector exposes the statistics of hosts serving storage to
+// other hosts, which look idle in diskstats while serving heavy remote I/O:
+// LIO SCSI targets, e.g. iSCSI, and NVMe-oF targets (nvmet), both configured
+// in configfs.
+type storageTargetCollector struct {
+	logger log.Logger
+}
+
+func init() {
+	registerCollector("storage_target", defaultDisabled, NewStorageTargetCollector)
+}
+
+// NewStorageTargetCollector returns a new Collector exposing LIO and nvmet
+// target statistics.
+func NewStorageTargetCollector(logger log.Logger) (Collector, error) {
+	return &storageTargetCollector{logger: logger}, nil
+}
+
+// configfsFilePath returns the path of a file in configfs, which is mounted
+// below sysfs.
+func configfsFilePath(name string) string {
+	return sysFilePath(filepath.Join("kernel", "config", name))
+}
+
+func (c *storageTargetCollector) Update(ch chan<- prometheus.Metric) error {
+	lio, err := c.updateLIO(ch)
+	if err != nil {
+		return fmt.Errorf("failed to read LIO statistics: %w", err)
+	}
+	nvmet, err := c.updateNVMeTarget(ch)
+	if err != nil {
+		return fmt.Errorf("failed to read nvmet configuration: %w", err)
+	}
+	if !lio && !nvmet {
+		return ErrNoData
+	}
+	return nil
+}
+
+// updateLIO exposes the statistics of the LUNs of every LIO fabric and of
+// the backstore devices. It returns whether LIO is configured.
+func (c *storageTargetCollector) updateLIO(ch chan<- prometheus.Metric) (bool, error) {
+	root := configfsFilePath("target")
+	fabrics, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, fabric := range fabrics {
+		if !fabric.IsDir() || fabric.Name() == "core" {
+			continue
+		}
+		luns, err := filepath.Glob(filepath.Join(root, fabric.Name(), "*", "tpgt_*", "lun", "lun_*"))
+		if err != nil {
+			return true, err
+		}
+		for _, lun := range luns {
+			c.updateLIOLUN(ch, fabric.Name(), lun)
+		}
+	}
+
+	devices, err := filepath.Glob(filepath.Join(root, "core", "*", "*", "statistics", "scsi_tgt_dev"))
+	if err != nil {
+		return true, err
+	}
+	for _, stats := range devices {
+		device := filepath.Dir(filepath.Dir(stats))
+		backstore, name := filepath.Base(filepath.Dir(device)), filepath.Base(device)
+		if resets, err := readUintFromFile(filepath.Join(stats, "resets")); err == nil {
+			ch <- prometheus.MustNewConstMetric(storageTargetDeviceResetsDesc, prometheus.CounterValue, float64(resets), backstore, name)
+		}
+		for result, file := range map[string]string{"aborted": "aborts_complete", "not_found": "aborts_no_task"} {
+			// Only exposed by recent kernels.
+			if aborts, err := readUintFromFile(filepath.Join(stats, file)); err == nil {
+				ch <- prometheus.MustNewConstMetric(storageTargetDeviceAbortsDesc, prometheus.CounterValue, float64(aborts), backstore, name, result)
+			}
+		}
+	}
+	return true, nil
+}
+
+// updateLIOLUN exposes the statistics of a LUN, found at
+// <fabric>/<target>/tpgt_<tpg>/lun/lun_<lun>.
+func (c *storageTargetCollector) updateLIOLUN(ch chan<- prometheus.Metric, fabric, lun string) {
+	tpg := filepath.Dir(filepath.Dir(lun))
+	target := filepath.Dir(tpg)
+	backstore, device := lioLUNDevice(lun)
+	labels := []string{
+		fabric,
+		filepath.Base(target),
+		strings.TrimPrefix(filepath.Base(tpg), "tpgt_"),
+		strings.TrimPrefix(filepath.Base(lun), "lun_"),
+		backstore,
+		device,
+	}
+
+	stats := filepath.Join(lun, "statistics", "scsi_tgt_port")
+	for _, counter := range []struct {
+		file  string
+		desc  *prometheus.Desc
+		scale float64
+	}{
+		{"in_cmds", storageTargetLUNCommandsDesc, 1},
+		{"read_mbytes", storageTargetLUNReadBytesDesc, mebibyte},
+		{"write_mbytes", storageTargetLUNWrittenBytesDesc, mebibyte},
+	} {
+		value, err := readUintFromFile(filepath.Join(stats, counter.file))
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read LUN statistics", "lun", lun, "err", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(counter.desc, prometheus.CounterValue, float64(value)*counter.scale, labels...)
+	}
+}
+
+// lioLUNDevice returns the backstore and name of the device a LUN maps,
+// from the link in the LUN directory to core/<backstore>/<device>.
+func lioLUNDevice(lun string) (string, string) {
+	entries, err := os.ReadDir(lun)
+	if err != nil {
+		return "", ""
+	}
+	for _, e := range entries {
+		if e.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(lun, e.Name()))
+		if err != nil || !strings.Contains(target, "/core/") {
+			continue
+		}
+		return filepath.Base(filepath.Dir(target)), filepath.Base(target)
+	}
+	return "", ""
+}
+
+// updateNVMeTarget exposes the namespaces of the nvmet subsystems. nvmet
+// keeps no statistics in configfs, so their backing devices are exposed to
+// join with diskstats. It returns whether nvmet is configured.
+func (c *storageTargetCollector) updateNVMeTarget(ch chan<- prometheus.Metric) (bool, error) {
+	if _, err := os.Stat(configfsFilePath("nvmet")); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	namespaces, err := filepath.Glob(configfsFilePath(filepath.Join("nvmet", "subsystems", "*", "namespaces", "*")))
+	if err != nil {
+		return true, err
+	}
+
+	for _, ns := range namespaces {
+		subsystem, nsid := filepath.Base(filepath.Dir(filepath.Dir(ns))), filepath.Base(ns)
+		data, err := readSourceFile(filepath.Join(ns, "device_path"), os.ReadFile)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to read namespace device", "namespace", ns, "err", err)
+			continue
+		}
+		devicePath := strings.TrimSpace(string(data))
+		device := ""
+		if strings.HasPrefix(devicePath, "/dev/") {
+			device = filepath.Base(devicePath)
+		}
+		ch <- prometheus.MustNewConstMetric(storageTargetNVMeNamespaceInfoDesc, prometheus.GaugeValue, 1, subsystem, nsid, devicePath, device)
+		if enabled, err := readUintFromFile(filepath.Join(ns, "enable")); err == nil {
+			ch <- prometheus.MustNewConstMetric(storageTargetNVMeNamespaceEnabledDesc, prometheus.GaugeValue, float64(enabled), subsystem, nsid)
+		}
+	}
+	return true, nil
+}
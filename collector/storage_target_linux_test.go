@@ -0,0 +1,101 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && !nostorage_target
+// +build linux,!nostorage_target
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStorageTargetCollector(t *testing.T) {
+	sys := t.TempDir()
+	configfs := filepath.Join(sys, "kernel", "config")
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(configfs, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	device := "target/core/iblock_0/disk1"
+	write(device+"/statistics/scsi_tgt_dev/resets", "2")
+	write(device+"/statistics/scsi_tgt_dev/aborts_complete", "5")
+	write(device+"/statistics/scsi_tgt_dev/aborts_no_task", "1")
+	lun := "target/iscsi/iqn.2024-01.com.example:storage/tpgt_1/lun/lun_0"
+	write(lun+"/statistics/scsi_tgt_port/in_cmds", "123456")
+	write(lun+"/statistics/scsi_tgt_port/read_mbytes", "2048")
+	write(lun+"/statistics/scsi_tgt_port/write_mbytes", "512")
+	if err := os.Symlink("../../../../../../target/core/iblock_0/disk1", filepath.Join(configfs, lun, "7f1c1b2a3d")); err != nil {
+		t.Fatal(err)
+	}
+	write("target/iscsi/discovery_auth/enforce_discovery_auth", "0")
+	ns := "nvmet/subsystems/nqn.2024-01.com.example:nvme/namespaces/1"
+	write(ns+"/device_path", "/dev/nvme0n1")
+	write(ns+"/enable", "1")
+
+	defer func(path string) { *sysPath = path }(*sysPath)
+	*sysPath = sys
+
+	c, err := NewStorageTargetCollector(log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP node_storage_target_device_aborts_total Number of commands to a LIO backstore device aborted by initiators, by whether the command was found and aborted.
+# TYPE node_storage_target_device_aborts_total counter
+node_storage_target_device_aborts_total{backstore="iblock_0",device="disk1",result="aborted"} 5
+node_storage_target_device_aborts_total{backstore="iblock_0",device="disk1",result="not_found"} 1
+# HELP node_storage_target_device_resets_total Number of resets of a LIO backstore device requested by initiators.
+# TYPE node_storage_target_device_resets_total counter
+node_storage_target_device_resets_total{backstore="iblock_0",device="disk1"} 2
+# HELP node_storage_target_lun_commands_total Number of SCSI commands received through a LUN of a LIO target.
+# TYPE node_storage_target_lun_commands_total counter
+node_storage_target_lun_commands_total{backstore="iblock_0",device="disk1",fabric="iscsi",lun="0",target="iqn.2024-01.com.example:storage",tpg="1"} 123456
+# HELP node_storage_target_lun_read_bytes_total Number of bytes read by initiators through a LUN of a LIO target, counted in MiB.
+# TYPE node_storage_target_lun_read_bytes_total counter
+node_storage_target_lun_read_bytes_total{backstore="iblock_0",device="disk1",fabric="iscsi",lun="0",target="iqn.2024-01.com.example:storage",tpg="1"} 2.147483648e+09
+# HELP node_storage_target_lun_written_bytes_total Number of bytes written by initiators through a LUN of a LIO target, counted in MiB.
+# TYPE node_storage_target_lun_written_bytes_total counter
+node_storage_target_lun_written_bytes_total{backstore="iblock_0",device="disk1",fabric="iscsi",lun="0",target="iqn.2024-01.com.example:storage",tpg="1"} 5.36870912e+08
+# HELP node_storage_target_nvme_namespace_enabled Whether a namespace of an NVMe-oF target subsystem is enabled.
+# TYPE node_storage_target_nvme_namespace_enabled gauge
+node_storage_target_nvme_namespace_enabled{namespace="1",subsystem="nqn.2024-01.com.example:nvme"} 1
+# HELP node_storage_target_nvme_namespace_info Backing device of a namespace of an NVMe-oF target subsystem. Its I/O is exposed by the diskstats collector of the device.
+# TYPE node_storage_target_nvme_namespace_info gauge
+node_storage_target_nvme_namespace_info{device="nvme0n1",device_path="/dev/nvme0n1",namespace="1",subsystem="nqn.2024-01.com.example:nvme"} 1
+`
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectorAdapter{c})
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hosts that are no storage target have no data.
+	*sysPath = t.TempDir()
+	if err := c.Update(make(chan prometheus.Metric, 10)); err != ErrNoData {
+		t.Errorf("got error %v without targets, want no data", err)
+	}
+}
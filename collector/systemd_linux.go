@@ -78,6 +78,9 @@ type systemdCollector struct {
 	// Use regexps for more flexibility than device_filter.go allows
 	systemdUnitIncludePattern *regexp.Regexp
 	systemdUnitExcludePattern *regexp.Regexp
+	enableTaskMetrics         bool
+	enableRestartsMetrics     bool
+	enableStartTimeMetrics    bool
 	logger                    log.Logger
 }
 
@@ -170,6 +173,9 @@ func NewSystemdCollector(logger log.Logger) (Collector, error) {
 		systemdVersionDesc:            systemdVersionDesc,
 		systemdUnitIncludePattern:     systemdUnitIncludePattern,
 		systemdUnitExcludePattern:     systemdUnitExcludePattern,
+		enableTaskMetrics:             *enableTaskMetrics,
+		enableRestartsMetrics:         *enableRestartsMetrics,
+		enableStartTimeMetrics:        *enableStartTimeMetrics,
 		logger:                        logger,
 	}, nil
 }
@@ -221,7 +227,7 @@ func (c *systemdCollector) Update(ch chan<- prometheus.Metric) error {
 		level.Debug(c.logger).Log("msg", "collectUnitStatusMetrics took", "duration_seconds", time.Since(begin).Seconds())
 	}()
 
-	if *enableStartTimeMetrics {
+	if c.enableStartTimeMetrics {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -231,7 +237,7 @@ func (c *systemdCollector) Update(ch chan<- prometheus.Metric) error {
 		}()
 	}
 
-	if *enableTaskMetrics {
+	if c.enableTaskMetrics {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -295,7 +301,7 @@ func (c *systemdCollector) collectUnitStatusMetrics(conn *dbus.Conn, ch chan<- p
 				c.unitDesc, prometheus.GaugeValue, isActive,
 				unit.Name, stateName, serviceType)
 		}
-		if *enableRestartsMetrics && strings.HasSuffix(unit.Name, ".service") {
+		if c.enableRestartsMetrics && strings.HasSuffix(unit.Name, ".service") {
 			// NRestarts wasn't added until systemd 235.
 			restartsCount, err := conn.GetUnitTypePropertyContext(context.TODO(), unit.Name, "Service", "NRestarts")
 			if err != nil {
@@ -0,0 +1,78 @@
+// Copyright 2015 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+type tcpConnectionState int
+
+const (
+	// TCP_ESTABLISHED
+	tcpEstablished tcpConnectionState = iota + 1
+	// TCP_SYN_SENT
+	tcpSynSent
+	// TCP_SYN_RECV
+	tcpSynRecv
+	// TCP_FIN_WAIT1
+	tcpFinWait1
+	// TCP_FIN_WAIT2
+	tcpFinWait2
+	// TCP_TIME_WAIT
+	tcpTimeWait
+	// TCP_CLOSE
+	tcpClose
+	// TCP_CLOSE_WAIT
+	tcpCloseWait
+	// TCP_LAST_ACK
+	tcpLastAck
+	// TCP_LISTEN
+	tcpListen
+	// TCP_CLOSING
+	tcpClosing
+	// TCP_RX_BUFFER
+	tcpRxQueuedBytes
+	// TCP_TX_BUFFER
+	tcpTxQueuedBytes
+)
+
+func (st tcpConnectionState) String() string {
+	switch st {
+	case tcpEstablished:
+		return "established"
+	case tcpSynSent:
+		return "syn_sent"
+	case tcpSynRecv:
+		return "syn_recv"
+	case tcpFinWait1:
+		return "fin_wait1"
+	case tcpFinWait2:
+		return "fin_wait2"
+	case tcpTimeWait:
+		return "time_wait"
+	case tcpClose:
+		return "close"
+	case tcpCloseWait:
+		return "close_wait"
+	case tcpLastAck:
+		return "last_ack"
+	case tcpListen:
+		return "listen"
+	case tcpClosing:
+		return "closing"
+	case tcpRxQueuedBytes:
+		return "rx_queued_bytes"
+	case tcpTxQueuedBytes:
+		return "tx_queued_bytes"
+	default:
+		return "unknown"
+	}
+}
@@ -27,37 +27,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type tcpConnectionState int
-
-const (
-	// TCP_ESTABLISHED
-	tcpEstablished tcpConnectionState = iota + 1
-	// TCP_SYN_SENT
-	tcpSynSent
-	// TCP_SYN_RECV
-	tcpSynRecv
-	// TCP_FIN_WAIT1
-	tcpFinWait1
-	// TCP_FIN_WAIT2
-	tcpFinWait2
-	// TCP_TIME_WAIT
-	tcpTimeWait
-	// TCP_CLOSE
-	tcpClose
-	// TCP_CLOSE_WAIT
-	tcpCloseWait
-	// TCP_LAST_ACK
-	tcpLastAck
-	// TCP_LISTEN
-	tcpListen
-	// TCP_CLOSING
-	tcpClosing
-	// TCP_RX_BUFFER
-	tcpRxQueuedBytes
-	// TCP_TX_BUFFER
-	tcpTxQueuedBytes
-)
-
 type tcpStatCollector struct {
 	desc   typedDesc
 	logger log.Logger
@@ -198,36 +167,3 @@ func parseTCPStats(msgs []netlink.Message) (map[tcpConnectionState]float64, erro
 
 	return tcpStats, nil
 }
-
-func (st tcpConnectionState) String() string {
-	switch st {
-	case tcpEstablished:
-		return "established"
-	case tcpSynSent:
-		return "syn_sent"
-	case tcpSynRecv:
-		return "syn_recv"
-	case tcpFinWait1:
-		return "fin_wait1"
-	case tcpFinWait2:
-		return "fin_wait2"
-	case tcpTimeWait:
-		return "time_wait"
-	case tcpClose:
-		return "close"
-	case tcpCloseWait:
-		return "close_wait"
-	case tcpLastAck:
-		return "last_ack"
-	case tcpListen:
-		return "listen"
-	case tcpClosing:
-		return "closing"
-	case tcpRxQueuedBytes:
-		return "rx_queued_bytes"
-	case tcpTxQueuedBytes:
-		return "tx_queued_bytes"
-	default:
-		return "unknown"
-	}
-}
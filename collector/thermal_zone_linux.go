@@ -20,6 +20,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -35,6 +38,7 @@ type thermalZoneCollector struct {
 	coolingDeviceCurState *prometheus.Desc
 	coolingDeviceMaxState *prometheus.Desc
 	zoneTemp              *prometheus.Desc
+	zoneTripPointTemp     *prometheus.Desc
 	logger                log.Logger
 }
 
@@ -56,6 +60,11 @@ func NewThermalZoneCollector(logger log.Logger) (Collector, error) {
 			"Zone temperature in Celsius",
 			[]string{"zone", "type"}, nil,
 		),
+		zoneTripPointTemp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, thermalZone, "trip_point_temp"),
+			"Zone trip point temperature in Celsius",
+			[]string{"zone", "type", "trip_point", "trip_point_type"}, nil,
+		),
 		coolingDeviceCurState: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, coolingDevice, "cur_state"),
 			"Current throttle state of the cooling device",
@@ -88,6 +97,7 @@ func (c *thermalZoneCollector) Update(ch chan<- prometheus.Metric) error {
 			stats.Name,
 			stats.Type,
 		)
+		c.updateTripPoints(ch, stats)
 	}
 
 	coolingDevices, err := c.fs.ClassCoolingDeviceStats()
@@ -115,3 +125,42 @@ func (c *thermalZoneCollector) Update(ch chan<- prometheus.Metric) error {
 
 	return nil
 }
+
+// updateTripPoints exposes the trip points of a thermal zone, the temperatures
+// at which the kernel starts cooling or shuts down the system.
+func (c *thermalZoneCollector) updateTripPoints(ch chan<- prometheus.Metric, stats sysfs.ClassThermalZoneStats) {
+	zonePath := sysFilePath(filepath.Join("class/thermal", thermalZone+stats.Name))
+	temps, err := filepath.Glob(filepath.Join(zonePath, "trip_point_*_temp"))
+	if err != nil {
+		return
+	}
+
+	for _, tempFile := range temps {
+		tripPoint := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(tempFile), "trip_point_"), "_temp")
+		raw, err := os.ReadFile(tempFile)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "Could not read trip point temperature", "file", tempFile, "err", err)
+			continue
+		}
+		temp, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "Could not parse trip point temperature", "file", tempFile, "err", err)
+			continue
+		}
+		tripType, err := os.ReadFile(filepath.Join(zonePath, "trip_point_"+tripPoint+"_type"))
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "Could not read trip point type", "zone", stats.Name, "trip_point", tripPoint, "err", err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.zoneTripPointTemp,
+			prometheus.GaugeValue,
+			float64(temp)/1000.0,
+			stats.Name,
+			stats.Type,
+			tripPoint,
+			strings.TrimSpace(string(tripType)),
+		)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	collectorTimeout = kingpin.Flag("collector.timeout",
+		"Maximum duration of a collector per scrape, 0 to disable. The metrics of a collector exceeding it are cut off and node_scrape_collector_success is 0, while the scrape of the other collectors completes.").Default("0s").Duration()
+	collectorTimeoutOverrides = kingpin.Flag("collector.timeout-override",
+		"Timeout of a collector overriding --collector.timeout, in the form collector=duration. Can be repeated.").Strings()
+)
+
+var scrapeTimeoutsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "scrape", "collector_timeouts_total"),
+	"node_exporter: Number of scrapes of a collector cut off by its timeout.",
+	[]string{"collector"},
+	nil,
+)
+
+// collectorTimeouts counts the timeouts of every collector and tracks the
+// updates still running after timing out.
+var collectorTimeouts = struct {
+	sync.Mutex
+	timeouts map[string]float64
+	running  map[string]bool
+}{
+	timeouts: map[string]float64{},
+	running:  map[string]bool{},
+}
+
+// timeoutsEnabled returns whether any collector has a timeout.
+func timeoutsEnabled() bool {
+	return *collectorTimeout > 0 || len(*collectorTimeoutOverrides) > 0
+}
+
+// parseCollectorTimeouts returns the timeouts of the given collectors from
+// --collector.timeout and --collector.timeout-override.
+func parseCollectorTimeouts(collectors map[string]Collector) (map[string]time.Duration, error) {
+	timeouts, err := parseCollectorDurations(collectors, *collectorTimeout, *collectorTimeoutOverrides)
+	if err != nil {
+		return nil, fmt.Errorf("--collector.timeout-override: %w", err)
+	}
+	return timeouts, nil
+}
+
+// parseCollectorDurations returns a duration for each of the given
+// collectors, the default value unless overridden by a collector=duration
+// override.
+func parseCollectorDurations(collectors map[string]Collector, defaultValue time.Duration, overrides []string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration, len(collectors))
+	if defaultValue > 0 {
+		for name := range collectors {
+			durations[name] = defaultValue
+		}
+	}
+	for _, override := range overrides {
+		name, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid override %q, expected collector=duration", override)
+		}
+		if _, ok := factories[name]; !ok {
+			return nil, fmt.Errorf("override for unknown collector %q", name)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid override %q: %w", override, err)
+		}
+		if _, ok := collectors[name]; ok {
+			durations[name] = d
+		}
+	}
+	return durations, nil
+}
+
+// updateWithTimeout runs a collector, passing on the metrics it exposes until
+// the timeout expires. A timed out update keeps running in the background,
+// its remaining metrics are discarded, and the collector is not run again
+// until it returns, so that a stuck collector does not pile up goroutines.
+func updateWithTimeout(name string, update func(chan<- prometheus.Metric) error, ch chan<- prometheus.Metric, timeout time.Duration) error {
+	collectorTimeouts.Lock()
+	if collectorTimeouts.running[name] {
+		collectorTimeouts.timeouts[name]++
+		collectorTimeouts.Unlock()
+		return fmt.Errorf("previous update still running after timeout of %s", timeout)
+	}
+	collectorTimeouts.running[name] = true
+	collectorTimeouts.Unlock()
+
+	metrics := make(chan prometheus.Metric)
+	done := make(chan error, 1)
+	go func() {
+		err := update(metrics)
+		close(metrics)
+		collectorTimeouts.Lock()
+		delete(collectorTimeouts.running, name)
+		collectorTimeouts.Unlock()
+		done <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case m, ok := <-metrics:
+			if !ok {
+				return <-done
+			}
+			ch <- m
+		case <-timer.C:
+			go func() {
+				for range metrics {
+				}
+			}()
+			collectorTimeouts.Lock()
+			collectorTimeouts.timeouts[name]++
+			collectorTimeouts.Unlock()
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+}
+
+// exposeTimeouts exposes the number of timeouts of a collector.
+func exposeTimeouts(name string, ch chan<- prometheus.Metric) {
+	collectorTimeouts.Lock()
+	count := collectorTimeouts.timeouts[name]
+	collectorTimeouts.Unlock()
+	ch <- prometheus.MustNewConstMetric(scrapeTimeoutsDesc, prometheus.CounterValue, count, name)
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestUpdateWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	update := func(ch chan<- prometheus.Metric) error {
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, 1, "a")
+		<-release
+		ch <- prometheus.MustNewConstMetric(anomalyTestDesc, prometheus.CounterValue, 2, "b")
+		return nil
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	if err := updateWithTimeout("timeout_test", update, ch, 10*time.Millisecond); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if len(ch) != 1 {
+		t.Errorf("got %d metrics, want the one exposed before the timeout", len(ch))
+	}
+
+	// The stuck update is not started again.
+	if err := updateWithTimeout("timeout_test", update, ch, time.Second); err == nil {
+		t.Fatal("expected error while the previous update is running")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		collectorTimeouts.Lock()
+		running := collectorTimeouts.running["timeout_test"]
+		collectorTimeouts.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out update did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(ch) != 1 {
+		t.Errorf("got %d metrics, want metrics of the timed out update to be discarded", len(ch))
+	}
+
+	if err := updateWithTimeout("timeout_test", update, ch, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != 3 {
+		t.Errorf("got %d metrics, want 3", len(ch))
+	}
+
+	collectorTimeouts.Lock()
+	timeouts := collectorTimeouts.timeouts["timeout_test"]
+	collectorTimeouts.Unlock()
+	if timeouts != 2 {
+		t.Errorf("got %v timeouts, want 2", timeouts)
+	}
+}
@@ -45,7 +45,8 @@ type wifiCollector struct {
 	stationTransmitFailedTotal   *prometheus.Desc
 	stationBeaconLossTotal       *prometheus.Desc
 
-	logger log.Logger
+	fixtures string
+	logger   log.Logger
 }
 
 var (
@@ -160,12 +161,13 @@ func NewWifiCollector(logger log.Logger) (Collector, error) {
 			labels,
 			nil,
 		),
-		logger: logger,
+		fixtures: *collectorWifi,
+		logger:   logger,
 	}, nil
 }
 
 func (c *wifiCollector) Update(ch chan<- prometheus.Metric) error {
-	stat, err := newWifiStater(*collectorWifi)
+	stat, err := newWifiStater(c.fixtures)
 	if err != nil {
 		// Cannot access wifi metrics, report no error.
 		if errors.Is(err, os.ErrNotExist) {
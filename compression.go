@@ -0,0 +1,160 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// metricsCompressor compresses the responses of the metrics handlers as
+// configured by --web.compression and --web.compression.gzip-level.
+var metricsCompressor = mustNewCompressor([]string{"zstd", "gzip"}, gzip.DefaultCompression)
+
+// compressor compresses responses with the first of its encodings, in the
+// order of preference, that the client accepts. Encoders are pooled, as
+// creating them takes more CPU time than compressing most responses.
+type compressor struct {
+	encodings []string
+	gzipPool  sync.Pool
+	zstdPool  sync.Pool
+}
+
+func newCompressor(encodings []string, gzipLevel int) (*compressor, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, gzipLevel); err != nil {
+		return nil, fmt.Errorf("invalid gzip level %d, must be between %d and %d", gzipLevel, gzip.StatelessCompression, gzip.BestCompression)
+	}
+	c := &compressor{encodings: encodings}
+	for _, encoding := range encodings {
+		if encoding != "none" {
+			continue
+		}
+		if len(encodings) > 1 {
+			return nil, fmt.Errorf("compression none can't be combined with other encodings")
+		}
+		c.encodings = nil
+	}
+	c.gzipPool.New = func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzipLevel)
+		return w
+	}
+	c.zstdPool.New = func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}
+	return c, nil
+}
+
+func mustNewCompressor(encodings []string, gzipLevel int) *compressor {
+	c, err := newCompressor(encodings, gzipLevel)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// wrap compresses the responses of next.
+func (c *compressor) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := c.negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressedResponseWriter{ResponseWriter: w, compressor: c, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the first encoding of the compressor that is accepted by
+// an Accept-Encoding header, or "" if none is.
+func (c *compressor) negotiate(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		encoding, params, _ := strings.Cut(part, ";")
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = v
+			}
+		}
+		accepted[encoding] = q > 0
+	}
+	for _, encoding := range c.encodings {
+		if ok, listed := accepted[encoding]; ok || !listed && accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressedResponseWriter writes the body of a response through a
+// compressing writer, taken from the pool of the compressor once the header
+// is written.
+type compressedResponseWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+	encoding   string
+	w          io.WriteCloser
+}
+
+func (w *compressedResponseWriter) WriteHeader(code int) {
+	if w.w != nil {
+		return
+	}
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.Header().Del("Content-Length")
+	switch w.encoding {
+	case "gzip":
+		gz := w.compressor.gzipPool.Get().(*gzip.Writer)
+		gz.Reset(w.ResponseWriter)
+		w.w = gz
+	case "zstd":
+		zw := w.compressor.zstdPool.Get().(*zstd.Encoder)
+		zw.Reset(w.ResponseWriter)
+		w.w = zw
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressedResponseWriter) Write(b []byte) (int, error) {
+	if w.w == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.w.Write(b)
+}
+
+// close flushes the compressed body and returns the writer to its pool.
+func (w *compressedResponseWriter) close() {
+	if w.w == nil {
+		return
+	}
+	w.w.Close()
+	switch w.encoding {
+	case "gzip":
+		w.compressor.gzipPool.Put(w.w)
+	case "zstd":
+		w.compressor.zstdPool.Put(w.w)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressorNegotiate(t *testing.T) {
+	c := mustNewCompressor([]string{"zstd", "gzip"}, gzip.DefaultCompression)
+	for acceptEncoding, want := range map[string]string{
+		"":                        "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br, zstd": "zstd",
+		"zstd;q=0, gzip;q=0.5":    "gzip",
+		"*":                       "zstd",
+		"*, zstd;q=0":             "gzip",
+		"identity":                "",
+	} {
+		if got := c.negotiate(acceptEncoding); got != want {
+			t.Errorf("Accept-Encoding %q: got %q, want %q", acceptEncoding, got, want)
+		}
+	}
+
+	if _, err := newCompressor([]string{"gzip"}, 10); err == nil {
+		t.Error("invalid gzip level accepted")
+	}
+	if _, err := newCompressor([]string{"none", "gzip"}, gzip.DefaultCompression); err == nil {
+		t.Error("none combined with gzip accepted")
+	}
+}
+
+func TestCompressorWrap(t *testing.T) {
+	body := strings.Repeat("node_interrupts_total{cpu=\"0\",type=\"LOC\"} 42\n", 1000)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	})
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		"":     func(r io.Reader) (io.Reader, error) { return r, nil },
+	}
+
+	for _, tc := range []struct {
+		encodings      []string
+		acceptEncoding string
+		want           string
+	}{
+		{[]string{"zstd", "gzip"}, "gzip, zstd", "zstd"},
+		{[]string{"zstd", "gzip"}, "gzip", "gzip"},
+		{[]string{"none"}, "gzip, zstd", ""},
+	} {
+		handler := mustNewCompressor(tc.encodings, 1).wrap(next)
+		// Twice, to reuse the pooled encoders.
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tc.want {
+				t.Fatalf("%v: got encoding %q, want %q", tc.encodings, got, tc.want)
+			}
+			r, err := decoders[tc.want](rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != body {
+				t.Errorf("%v: body changed by compression", tc.encodings)
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+// dashboardCollectors are the collectors read by the dashboard, if enabled.
+var dashboardCollectors = []string{"accelerators", "cpu", "filesystem", "loadavg", "meminfo"}
+
+// dashboardSample holds the key metrics of the node at one point in time.
+// Unknown values are NaN.
+type dashboardSample struct {
+	Time       time.Time
+	CPUBusy    float64
+	MemoryUsed float64
+	RootFSUsed float64
+	Load1      float64
+}
+
+// dashboardFilesystem is a row of the filesystems table.
+type dashboardFilesystem struct {
+	Mountpoint, Device, FSType string
+	Size                       float64
+	Used                       float64
+}
+
+// dashboardCard is a row of the accelerators table.
+type dashboardCard struct {
+	PCIAddress, Vendor, Model, NUMANode, Resource string
+}
+
+// sampleRing keeps the latest samples, overwriting the oldest.
+type sampleRing struct {
+	samples []dashboardSample
+	next    int
+	full    bool
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{samples: make([]dashboardSample, size)}
+}
+
+func (r *sampleRing) add(s dashboardSample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// all returns the samples from oldest to newest.
+func (r *sampleRing) all() []dashboardSample {
+	if !r.full {
+		return append([]dashboardSample(nil), r.samples[:r.next]...)
+	}
+	return append(append([]dashboardSample(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// dashboard samples key metrics at a fixed interval into a ring buffer and
+// renders them as a static HTML page, for air-gapped nodes without Grafana.
+type dashboard struct {
+	interval time.Duration
+	logger   log.Logger
+
+	mtx         sync.Mutex
+	history     *sampleRing
+	filesystems []dashboardFilesystem
+	cards       []dashboardCard
+	// cpuIdle and cpuTotal are the CPU seconds of the previous sample.
+	cpuIdle, cpuTotal float64
+}
+
+func newDashboard(interval, retention time.Duration, logger log.Logger) *dashboard {
+	size := int(retention / interval)
+	if size < 2 {
+		size = 2
+	}
+	return &dashboard{interval: interval, logger: logger, history: newSampleRing(size)}
+}
+
+// start samples every interval until the context is done.
+func (d *dashboard) start(ctx context.Context) {
+	d.sample(time.Now())
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				d.sample(now)
+			}
+		}
+	}()
+}
+
+// sample runs the enabled dashboard collectors and records their metrics.
+func (d *dashboard) sample(now time.Time) {
+	var enabled []string
+	for _, name := range dashboardCollectors {
+		if _, err := collector.NewNodeCollector(d.logger, name); err == nil {
+			enabled = append(enabled, name)
+		}
+	}
+	if len(enabled) == 0 {
+		return
+	}
+	nc, err := collector.NewNodeCollector(d.logger, enabled...)
+	if err != nil {
+		level.Warn(d.logger).Log("msg", "Couldn't create dashboard collectors", "err", err)
+		return
+	}
+	r := prometheus.NewRegistry()
+	if err := r.Register(nc); err != nil {
+		level.Warn(d.logger).Log("msg", "Couldn't register dashboard collectors", "err", err)
+		return
+	}
+	mfs, err := r.Gather()
+	if err != nil {
+		level.Debug(d.logger).Log("msg", "Dashboard metrics incomplete", "err", err)
+	}
+	d.record(now, mfs)
+}
+
+// record adds the metrics of a gather to the history.
+func (d *dashboard) record(now time.Time, mfs []*dto.MetricFamily) {
+	families := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	s := dashboardSample{Time: now, CPUBusy: math.NaN(), MemoryUsed: math.NaN(), RootFSUsed: math.NaN(), Load1: math.NaN()}
+
+	if mf, ok := families["node_cpu_seconds_total"]; ok {
+		var idle, total float64
+		for _, m := range mf.Metric {
+			v := m.GetCounter().GetValue()
+			total += v
+			if mode := labelValue(m, "mode"); mode == "idle" || mode == "iowait" {
+				idle += v
+			}
+		}
+		if d.cpuTotal > 0 && total > d.cpuTotal {
+			s.CPUBusy = 100 * (1 - (idle-d.cpuIdle)/(total-d.cpuTotal))
+		}
+		d.cpuIdle, d.cpuTotal = idle, total
+	}
+
+	available, okAvailable := gaugeValue(families, "node_memory_MemAvailable_bytes")
+	memTotal, okTotal := gaugeValue(families, "node_memory_MemTotal_bytes")
+	if okAvailable && okTotal && memTotal > 0 {
+		s.MemoryUsed = 100 * (1 - available/memTotal)
+	}
+
+	if load, ok := gaugeValue(families, "node_load1"); ok {
+		s.Load1 = load
+	}
+
+	d.filesystems = d.filesystems[:0]
+	if mf, ok := families["node_filesystem_size_bytes"]; ok {
+		avail := map[string]float64{}
+		if af, ok := families["node_filesystem_avail_bytes"]; ok {
+			for _, m := range af.Metric {
+				avail[labelValue(m, "mountpoint")] = m.GetGauge().GetValue()
+			}
+		}
+		for _, m := range mf.Metric {
+			size := m.GetGauge().GetValue()
+			mountpoint := labelValue(m, "mountpoint")
+			fs := dashboardFilesystem{
+				Mountpoint: mountpoint,
+				Device:     labelValue(m, "device"),
+				FSType:     labelValue(m, "fstype"),
+				Size:       size,
+			}
+			if a, ok := avail[mountpoint]; ok && size > 0 {
+				fs.Used = 100 * (1 - a/size)
+			}
+			if mountpoint == "/" {
+				s.RootFSUsed = fs.Used
+			}
+			d.filesystems = append(d.filesystems, fs)
+		}
+		sort.Slice(d.filesystems, func(i, j int) bool {
+			return d.filesystems[i].Mountpoint < d.filesystems[j].Mountpoint
+		})
+	}
+
+	d.cards = d.cards[:0]
+	if mf, ok := families["node_accelerator_card_info"]; ok {
+		for _, m := range mf.Metric {
+			d.cards = append(d.cards, dashboardCard{
+				PCIAddress: labelValue(m, "pci_address"),
+				Vendor:     labelValue(m, "vendor"),
+				Model:      labelValue(m, "model"),
+				NUMANode:   labelValue(m, "numa_node"),
+				Resource:   labelValue(m, "resource"),
+			})
+		}
+		sort.Slice(d.cards, func(i, j int) bool {
+			return d.cards[i].PCIAddress < d.cards[j].PCIAddress
+		})
+	}
+
+	d.history.add(s)
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// gaugeValue returns the value of a gauge without labels.
+func gaugeValue(families map[string]*dto.MetricFamily, name string) (float64, bool) {
+	mf, ok := families[name]
+	if !ok || len(mf.Metric) != 1 {
+		return 0, false
+	}
+	return mf.Metric[0].GetGauge().GetValue(), true
+}
+
+const (
+	chartWidth  = 600
+	chartHeight = 100
+)
+
+// dashboardChart is an SVG line chart of one metric of the history.
+type dashboardChart struct {
+	Title   string
+	Unit    string
+	Current string
+	Max     float64
+	Points  string
+}
+
+func newDashboardChart(title, unit string, samples []dashboardSample, value func(dashboardSample) float64, max float64) dashboardChart {
+	c := dashboardChart{Title: title, Unit: unit, Current: "n/a", Max: max}
+	if c.Max == 0 {
+		for _, s := range samples {
+			if v := value(s); v > c.Max {
+				c.Max = v
+			}
+		}
+		if c.Max == 0 {
+			c.Max = 1
+		}
+	}
+
+	last := len(samples) - 1
+	if last < 1 {
+		last = 1
+	}
+	var points []string
+	for i, s := range samples {
+		v := value(s)
+		if math.IsNaN(v) {
+			continue
+		}
+		x := float64(chartWidth) * float64(i) / float64(last)
+		y := chartHeight - chartHeight*math.Min(v, c.Max)/c.Max
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		c.Current = fmt.Sprintf("%.2f", v)
+	}
+	c.Points = strings.Join(points, " ")
+	return c
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"bytes": func(v float64) string {
+		units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+		i := 0
+		for v >= 1024 && i < len(units)-1 {
+			v /= 1024
+			i++
+		}
+		return fmt.Sprintf("%.1f %s", v, units[i])
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>Node Exporter Dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+svg { border: 1px solid #ccc; background: #fafafa; }
+polyline { fill: none; stroke: #e6522c; stroke-width: 1.5; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Node Exporter Dashboard</h1>
+<p>Last {{.Window}}, sampled every {{.Interval}}.</p>
+{{range .Charts}}
+<h2>{{.Title}}: {{.Current}} {{.Unit}}</h2>
+<svg width="600" height="100" viewBox="0 0 600 100"><polyline points="{{.Points}}"/></svg>
+{{end}}
+<h2>Filesystems</h2>
+{{if .Filesystems}}<table>
+<tr><th>Mountpoint</th><th>Device</th><th>Type</th><th>Size</th><th>Used</th></tr>
+{{range .Filesystems}}<tr><td>{{.Mountpoint}}</td><td>{{.Device}}</td><td>{{.FSType}}</td><td>{{bytes .Size}}</td><td>{{printf "%.1f" .Used}}%</td></tr>
+{{end}}</table>{{else}}<p>No filesystems.</p>{{end}}
+<h2>Accelerators</h2>
+{{if .Cards}}<table>
+<tr><th>PCI address</th><th>Vendor</th><th>Model</th><th>NUMA node</th><th>Resource</th></tr>
+{{range .Cards}}<tr><td>{{.PCIAddress}}</td><td>{{.Vendor}}</td><td>{{.Model}}</td><td>{{.NUMANode}}</td><td>{{.Resource}}</td></tr>
+{{end}}</table>{{else}}<p>No accelerators.</p>{{end}}
+</body>
+</html>
+`))
+
+// ServeHTTP implements http.Handler.
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mtx.Lock()
+	samples := d.history.all()
+	data := struct {
+		Refresh     int
+		Window      time.Duration
+		Interval    time.Duration
+		Charts      []dashboardChart
+		Filesystems []dashboardFilesystem
+		Cards       []dashboardCard
+	}{
+		Refresh:     int(d.interval.Seconds()),
+		Window:      d.interval * time.Duration(len(d.history.samples)),
+		Interval:    d.interval,
+		Filesystems: append([]dashboardFilesystem(nil), d.filesystems...),
+		Cards:       append([]dashboardCard(nil), d.cards...),
+	}
+	d.mtx.Unlock()
+
+	data.Charts = []dashboardChart{
+		newDashboardChart("CPU busy", "%", samples, func(s dashboardSample) float64 { return s.CPUBusy }, 100),
+		newDashboardChart("Memory used", "%", samples, func(s dashboardSample) float64 { return s.MemoryUsed }, 100),
+		newDashboardChart("Root filesystem used", "%", samples, func(s dashboardSample) float64 { return s.RootFSUsed }, 100),
+		newDashboardChart("Load 1m", "", samples, func(s dashboardSample) float64 { return s.Load1 }, 0),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		level.Error(d.logger).Log("msg", "Couldn't render dashboard", "err", err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSampleRing(t *testing.T) {
+	r := newSampleRing(3)
+	for i := 1; i <= 4; i++ {
+		r.add(dashboardSample{Load1: float64(i)})
+	}
+	var got []float64
+	for _, s := range r.all() {
+		got = append(got, s.Load1)
+	}
+	if len(got) != 3 || got[0] != 2 || got[2] != 4 {
+		t.Errorf("got %v, want [2 3 4]", got)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	d := newDashboard(time.Second, time.Minute, log.NewNopLogger())
+
+	cpu := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_cpu_seconds_total", Help: "CPU."}, []string{"cpu", "mode"})
+	total := prometheus.NewGauge(prometheus.GaugeOpts{Name: "node_memory_MemTotal_bytes", Help: "Total."})
+	available := prometheus.NewGauge(prometheus.GaugeOpts{Name: "node_memory_MemAvailable_bytes", Help: "Available."})
+	size := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "node_filesystem_size_bytes", Help: "Size."}, []string{"device", "fstype", "mountpoint"})
+	avail := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "node_filesystem_avail_bytes", Help: "Avail."}, []string{"device", "fstype", "mountpoint"})
+	cards := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "node_accelerator_card_info", Help: "Cards."}, []string{"pci_address", "vendor", "model", "numa_node", "resource"})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(cpu, total, available, size, avail, cards)
+
+	total.Set(1000)
+	available.Set(250)
+	size.WithLabelValues("/dev/sda1", "ext4", "/").Set(200)
+	avail.WithLabelValues("/dev/sda1", "ext4", "/").Set(50)
+	cards.WithLabelValues("0000:3b:00.0", "nvidia", "H100", "0", "nvidia.com/gpu").Set(1)
+
+	now := time.Now()
+	for i, busy := range []float64{0, 3} {
+		cpu.WithLabelValues("0", "user").Add(busy)
+		cpu.WithLabelValues("0", "idle").Add(1)
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.record(now.Add(time.Duration(i)*time.Second), mfs)
+	}
+
+	samples := d.history.all()
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	if !math.IsNaN(samples[0].CPUBusy) || samples[1].CPUBusy != 75 {
+		t.Errorf("got CPU busy %v and %v, want NaN and 75", samples[0].CPUBusy, samples[1].CPUBusy)
+	}
+	if s := samples[1]; s.MemoryUsed != 75 || s.RootFSUsed != 75 || !math.IsNaN(s.Load1) {
+		t.Errorf("unexpected sample %+v", s)
+	}
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/dashboard", nil))
+	body := w.Body.String()
+	for _, want := range []string{"CPU busy: 75.00 %", "Load 1m: n/a", "/dev/sda1", "H100"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard misses %q", want)
+		}
+	}
+}
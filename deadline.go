@@ -0,0 +1,51 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// scrapeTimeoutHeader is the header in which Prometheus sends the timeout
+// of its scrapes.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeDeadlines is set by --web.scrape-deadline and
+// --web.scrape-deadline-offset.
+var scrapeDeadlines struct {
+	enabled bool
+	offset  time.Duration
+}
+
+// scrapeDeadline returns the time by which the collectors have to be done
+// with a scrape starting at now: the scrape timeout sent by the scraper
+// minus the offset left to encode and send the response. It returns the zero
+// time if deadlines are disabled or the scraper sent no valid timeout.
+func scrapeDeadline(r *http.Request, now time.Time) time.Time {
+	if !scrapeDeadlines.enabled {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if scrapeDeadlines.offset < timeout {
+		// An offset beyond the timeout would leave no time at all.
+		timeout -= scrapeDeadlines.offset
+	}
+	return now.Add(timeout)
+}
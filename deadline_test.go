@@ -0,0 +1,52 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScrapeDeadline(t *testing.T) {
+	defer func(enabled bool, offset time.Duration) {
+		scrapeDeadlines.enabled, scrapeDeadlines.offset = enabled, offset
+	}(scrapeDeadlines.enabled, scrapeDeadlines.offset)
+	scrapeDeadlines.enabled, scrapeDeadlines.offset = true, 500*time.Millisecond
+
+	now := time.Unix(1000, 0)
+	for header, want := range map[string]time.Time{
+		"10":      now.Add(9500 * time.Millisecond),
+		"2.5":     now.Add(2 * time.Second),
+		"0.2":     now.Add(200 * time.Millisecond),
+		"":        {},
+		"0":       {},
+		"invalid": {},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if header != "" {
+			r.Header.Set(scrapeTimeoutHeader, header)
+		}
+		if got := scrapeDeadline(r, now); !got.Equal(want) {
+			t.Errorf("timeout %q: got deadline %v, want %v", header, got, want)
+		}
+	}
+
+	scrapeDeadlines.enabled = false
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set(scrapeTimeoutHeader, "10")
+	if got := scrapeDeadline(r, now); !got.IsZero() {
+		t.Errorf("got deadline %v with deadlines disabled", got)
+	}
+}
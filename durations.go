@@ -0,0 +1,52 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	scrapeDurations = newDurationHistogramVec(
+		"node_exporter_scrape_duration_seconds",
+		"Duration of the scrapes of a metrics path, including views.",
+		"path",
+	)
+	pushDurations = newDurationHistogramVec(
+		"node_exporter_push_duration_seconds",
+		"Duration of the pushes of the metrics, by protocol.",
+		"protocol",
+	)
+)
+
+// newDurationHistogramVec returns a histogram of durations of the exporter
+// itself. It is exposed as a native histogram to scrapers negotiating the
+// protobuf format, with classic buckets as fallback, like
+// node_scrape_collector_duration_seconds with
+// --collector.scrape-duration-histogram.
+func newDurationHistogramVec(name, help string, labels ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:                            name,
+			Help:                            help,
+			Buckets:                         []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		},
+		labels,
+	)
+}
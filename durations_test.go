@@ -0,0 +1,39 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestDurationHistogramVec(t *testing.T) {
+	h := newDurationHistogramVec("test_duration_seconds", "Test durations.", "path")
+	h.WithLabelValues("/metrics").Observe(0.042)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(h)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram := mfs[0].Metric[0].GetHistogram()
+	if len(histogram.GetBucket()) == 0 {
+		t.Error("classic buckets missing")
+	}
+	if histogram.Schema == nil || len(histogram.GetPositiveSpan()) == 0 {
+		t.Error("native histogram missing")
+	}
+}
@@ -3,6 +3,7 @@
 set -euf -o pipefail
 
 enabled_collectors=$(cat << COLLECTORS
+  accelerators
   arp
   bcache
   bonding
@@ -69,7 +70,7 @@ cd "$(dirname $0)"
 port="$((10000 + (RANDOM % 10000)))"
 tmpdir=$(mktemp -d /tmp/node_exporter_e2e_test.XXXXXX)
 
-skip_re="^(go_|node_exporter_build_info|node_scrape_collector_duration_seconds|process_|node_textfile_mtime_seconds|node_time_(zone|seconds)|node_network_(receive|transmit)_(bytes|packets)_total)"
+skip_re="^(go_|node_exporter_build_info|node_scrape_collector_duration_seconds|node_exporter_scrape_duration_seconds|process_|node_textfile_mtime_seconds|node_time_(zone|seconds)|node_network_(receive|transmit)_(bytes|packets)_total)"
 
 arch="$(uname -m)"
 
@@ -0,0 +1,74 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/node_exporter/collector"
+	"google.golang.org/protobuf/proto"
+)
+
+// parseExtraLabels parses "name=value" pairs as passed to --metric.extra-label.
+func parseExtraLabels(pairs []string) (prometheus.Labels, error) {
+	labels := prometheus.Labels{}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid extra label %q, expected name=value", pair)
+		}
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return nil, fmt.Errorf("invalid extra label name %q", name)
+		}
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("duplicate extra label %q", name)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// extraLabelsGatherer adds constant labels to every metric returned by the
+// wrapped Gatherer. Metrics already carrying one of the labels keep their
+// own value.
+type extraLabelsGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+func newExtraLabelsGatherer(gatherer prometheus.Gatherer, labels prometheus.Labels) prometheus.Gatherer {
+	if len(labels) == 0 {
+		return gatherer
+	}
+	g := &extraLabelsGatherer{gatherer: gatherer}
+	for name, value := range labels {
+		g.labels = append(g.labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	return g
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *extraLabelsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			collector.MergeLabels(m, g.labels)
+		}
+	}
+	return mfs, err
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseExtraLabels(t *testing.T) {
+	labels, err := parseExtraLabels([]string{"rack=R12", "env=prod", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels["rack"] != "R12" || labels["env"] != "prod" || labels["empty"] != "" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	for _, invalid := range [][]string{{"rack"}, {"1rack=R12"}, {"__name__=x"}, {"rack=R12", "rack=R13"}} {
+		if _, err := parseExtraLabels(invalid); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+}
+
+func TestExtraLabelsGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_metric", Help: "Test metric."}, []string{"env", "zone"})
+	gauge.WithLabelValues("dev", "a").Set(1)
+	reg.MustRegister(gauge)
+
+	g := newExtraLabelsGatherer(reg, prometheus.Labels{"rack": "R12", "env": "prod"})
+	want := `# HELP test_metric Test metric.
+# TYPE test_metric gauge
+test_metric{env="dev",rack="R12",zone="a"} 1
+`
+	if err := testutil.GatherAndCompare(g, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
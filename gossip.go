@@ -0,0 +1,358 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	peerUpDesc = prometheus.NewDesc(
+		"node_peer_up",
+		"Whether a gossip heartbeat was received from a peer node_exporter within --gossip.peer-timeout.",
+		[]string{"peer", "address"}, nil,
+	)
+	peerLastHeartbeatDesc = prometheus.NewDesc(
+		"node_peer_last_heartbeat_timestamp_seconds",
+		"Unix time of the last gossip heartbeat received from a peer node_exporter.",
+		[]string{"peer", "address"}, nil,
+	)
+)
+
+const (
+	// gossipMaxMessageSize bounds the heartbeats, which fit in one UDP
+	// datagram.
+	gossipMaxMessageSize = 65507
+	// gossipForgetTimeouts is the number of peer timeouts after which a
+	// peer learned from other peers, rather than configured, is forgotten.
+	gossipForgetTimeouts = 10
+	// gossipMaxLearnedPeers bounds the number of learned peers, and thereby
+	// the series and the heartbeats sent.
+	gossipMaxLearnedPeers = 256
+)
+
+// gossipMessage is the heartbeat sent to the peers.
+type gossipMessage struct {
+	Name string `json:"name"`
+	// Peers are the addresses of the peers that are up, so that the peers
+	// of a node learn about each other.
+	Peers []string `json:"peers"`
+}
+
+// gossipPeer is a node_exporter exchanging heartbeats with this one.
+type gossipPeer struct {
+	name string
+	// static peers are configured with --gossip.peer and never forgotten.
+	static   bool
+	lastSeen time.Time
+}
+
+// gossip exchanges heartbeats with peer node_exporters over UDP, giving
+// every node a view of the reachability of the others that does not depend
+// on the path to Prometheus. Heartbeats are sent from the listening socket,
+// so the source address of a heartbeat is the address the peer listens on.
+// Peers are only learned from heartbeats authenticated with a key; without
+// one, only the configured peers are tracked.
+type gossip struct {
+	name    string
+	key     []byte
+	timeout time.Duration
+	conn    *net.UDPConn
+	logger  log.Logger
+
+	mtx   sync.Mutex
+	peers map[string]*gossipPeer
+	// self are the addresses of this node_exporter, learned from peers.
+	self map[string]bool
+	// learned is the number of peers that are not static.
+	learned int
+}
+
+// gossipCollector exposes the peers, if gossip is enabled, on every handler.
+var gossipCollector = &gossip{}
+
+// start listens for heartbeats on listenAddress and sends one to every peer
+// every interval until the context is done. Heartbeats are authenticated
+// with an HMAC if keyFile is set.
+func (g *gossip) start(ctx context.Context, listenAddress string, peers []string, interval, timeout time.Duration, name, keyFile string, logger log.Logger) error {
+	s, err := parseGossipSettings(listenAddress, peers, interval, timeout, name, keyFile)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+	peerAddrs := map[string]*gossipPeer{}
+	for _, addr := range s.peers {
+		peerAddrs[addr.String()] = &gossipPeer{static: true}
+	}
+
+	g.mtx.Lock()
+	g.name, g.key, g.timeout, g.conn, g.logger = s.name, s.key, timeout, conn, logger
+	g.peers, g.self, g.learned = peerAddrs, map[string]bool{}, 0
+	g.mtx.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go g.receive()
+	go func() {
+		g.send(time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				g.send(now)
+			}
+		}
+	}()
+	return nil
+}
+
+// gossipSettings are the resolved gossip flags.
+type gossipSettings struct {
+	name       string
+	key        []byte
+	listenAddr *net.UDPAddr
+	peers      []*net.UDPAddr
+}
+
+// parseGossipSettings validates the gossip flags and resolves the addresses,
+// without listening yet.
+func parseGossipSettings(listenAddress string, peers []string, interval, timeout time.Duration, name, keyFile string) (*gossipSettings, error) {
+	if interval <= 0 || timeout <= 0 {
+		return nil, errors.New("--gossip.interval and --gossip.peer-timeout must be positive")
+	}
+	s := &gossipSettings{name: name}
+	if s.name == "" {
+		var err error
+		if s.name, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gossip key: %w", err)
+		}
+		if s.key = bytes.TrimSpace(data); len(s.key) == 0 {
+			return nil, errors.New("gossip key file is empty")
+		}
+	}
+	var err error
+	if s.listenAddr, err = net.ResolveUDPAddr("udp", listenAddress); err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		addr, err := net.ResolveUDPAddr("udp", peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gossip peer %q: %w", peer, err)
+		}
+		s.peers = append(s.peers, addr)
+	}
+	return s, nil
+}
+
+// addr returns the address heartbeats are received on.
+func (g *gossip) addr() net.Addr {
+	return g.conn.LocalAddr()
+}
+
+// send sends a heartbeat to every peer and forgets the learned peers that
+// have not been seen for a long time.
+func (g *gossip) send(now time.Time) {
+	g.mtx.Lock()
+	msg := gossipMessage{Name: g.name, Peers: []string{}}
+	var targets []string
+	for addr, peer := range g.peers {
+		if !peer.static && now.Sub(peer.lastSeen) > gossipForgetTimeouts*g.timeout {
+			g.forget(addr)
+			continue
+		}
+		targets = append(targets, addr)
+		if g.up(peer, now) {
+			msg.Peers = append(msg.Peers, addr)
+		}
+	}
+	g.mtx.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		level.Error(g.logger).Log("msg", "Failed to encode gossip heartbeat", "err", err)
+		return
+	}
+	data = g.sign(data)
+	for _, target := range targets {
+		addr, err := net.ResolveUDPAddr("udp", target)
+		if err == nil {
+			_, err = g.conn.WriteToUDP(data, addr)
+		}
+		if err != nil {
+			level.Debug(g.logger).Log("msg", "Failed to send gossip heartbeat", "peer", target, "err", err)
+		}
+	}
+}
+
+// receive handles the heartbeats of the peers until the connection is
+// closed.
+func (g *gossip) receive() {
+	buf := make([]byte, gossipMaxMessageSize)
+	for {
+		n, addr, err := g.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			level.Debug(g.logger).Log("msg", "Failed to receive gossip heartbeat", "err", err)
+			continue
+		}
+		data, ok := g.verify(buf[:n])
+		if !ok {
+			level.Debug(g.logger).Log("msg", "Dropping unauthenticated gossip heartbeat", "peer", addr)
+			continue
+		}
+		var msg gossipMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			level.Debug(g.logger).Log("msg", "Dropping invalid gossip heartbeat", "peer", addr, "err", err)
+			continue
+		}
+		g.heartbeat(addr.String(), msg, time.Now())
+	}
+}
+
+// heartbeat records a heartbeat received from the peer at addr. Heartbeats
+// of unknown senders are dropped unless peers can be learned.
+func (g *gossip) heartbeat(addr string, msg gossipMessage, now time.Time) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	peer, ok := g.peers[addr]
+	if msg.Name == g.name {
+		// A peer passed on the address of this node_exporter.
+		if ok {
+			g.self[addr] = true
+			g.forget(addr)
+		}
+		return
+	}
+	if !ok {
+		if peer = g.learn(addr); peer == nil {
+			level.Debug(g.logger).Log("msg", "Dropping gossip heartbeat of unknown peer", "peer", addr)
+			return
+		}
+	}
+	peer.name, peer.lastSeen = msg.Name, now
+
+	for _, other := range msg.Peers {
+		if _, ok := g.peers[other]; !ok && !g.self[other] {
+			if learned := g.learn(other); learned != nil {
+				learned.lastSeen = now
+			}
+		}
+	}
+}
+
+// learn adds a peer that is not configured, if heartbeats are authenticated
+// and the limit of learned peers is not reached. It returns nil otherwise.
+func (g *gossip) learn(addr string) *gossipPeer {
+	if g.key == nil || g.learned >= gossipMaxLearnedPeers {
+		return nil
+	}
+	peer := &gossipPeer{}
+	g.peers[addr] = peer
+	g.learned++
+	return peer
+}
+
+// forget removes a peer.
+func (g *gossip) forget(addr string) {
+	if peer, ok := g.peers[addr]; ok {
+		if !peer.static {
+			g.learned--
+		}
+		delete(g.peers, addr)
+	}
+}
+
+// up returns whether a heartbeat of a peer was received within the timeout.
+func (g *gossip) up(peer *gossipPeer, now time.Time) bool {
+	return peer.name != "" && now.Sub(peer.lastSeen) <= g.timeout
+}
+
+// sign prefixes a heartbeat with its HMAC, if a key is set.
+func (g *gossip) sign(data []byte) []byte {
+	if g.key == nil {
+		return data
+	}
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(data)
+	return append(mac.Sum(nil), data...)
+}
+
+// verify checks and strips the HMAC of a heartbeat, if a key is set.
+func (g *gossip) verify(data []byte) ([]byte, bool) {
+	if g.key == nil {
+		return data, true
+	}
+	if len(data) < sha256.Size {
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(data[sha256.Size:])
+	return data[sha256.Size:], hmac.Equal(mac.Sum(nil), data[:sha256.Size])
+}
+
+// Describe implements prometheus.Collector.
+func (g *gossip) Describe(ch chan<- *prometheus.Desc) {
+	ch <- peerUpDesc
+	ch <- peerLastHeartbeatDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if gossip is
+// disabled.
+func (g *gossip) Collect(ch chan<- prometheus.Metric) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	now := time.Now()
+	for addr, peer := range g.peers {
+		up := 0.0
+		if g.up(peer, now) {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(peerUpDesc, prometheus.GaugeValue, up, peer.name, addr)
+		if peer.name != "" {
+			ch <- prometheus.MustNewConstMetric(peerLastHeartbeatDesc, prometheus.GaugeValue, float64(peer.lastSeen.UnixNano())/1e9, peer.name, addr)
+		}
+	}
+}
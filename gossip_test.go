@@ -0,0 +1,141 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func startTestGossip(t *testing.T, ctx context.Context, name, keyFile string, peers ...*gossip) *gossip {
+	t.Helper()
+	var addrs []string
+	for _, p := range peers {
+		addrs = append(addrs, p.addr().String())
+	}
+	g := &gossip{}
+	if err := g.start(ctx, "127.0.0.1:0", addrs, 10*time.Millisecond, time.Second, name, keyFile, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// upPeers returns the names of the peers that are up.
+func upPeers(g *gossip) map[string]bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	up := map[string]bool{}
+	for _, peer := range g.peers {
+		if g.up(peer, time.Now()) {
+			up[peer.name] = true
+		}
+	}
+	return up
+}
+
+func waitForPeers(t *testing.T, g *gossip, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		up := upPeers(g)
+		missing := false
+		for _, name := range want {
+			missing = missing || !up[name]
+		}
+		if !missing && len(up) == len(want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got peers %v up, want %v", up, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGossip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keyFile := filepath.Join(t.TempDir(), "gossip.key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a and c only know b, and learn about each other from its heartbeats.
+	b := startTestGossip(t, ctx, "b", keyFile)
+	a := startTestGossip(t, ctx, "a", keyFile, b)
+	c := startTestGossip(t, ctx, "c", keyFile, b, a)
+	waitForPeers(t, a, "b", "c")
+	waitForPeers(t, b, "a", "c")
+	waitForPeers(t, c, "a", "b")
+
+	// Heartbeats signed with another key are dropped.
+	startTestGossip(t, ctx, "d", "", b)
+	time.Sleep(100 * time.Millisecond)
+	waitForPeers(t, b, "a", "c")
+
+	// A node listed as its own peer ignores itself.
+	self := &gossip{}
+	if err := self.start(ctx, "127.0.0.1:0", nil, 10*time.Millisecond, time.Second, "self", "", log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	self.heartbeat(self.addr().String(), gossipMessage{Name: "self"}, time.Now())
+	if len(upPeers(self)) != 0 {
+		t.Error("node lists itself as peer")
+	}
+}
+
+func TestGossipLearnPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Without a key, unknown senders and the peers they pass on are ignored.
+	g := &gossip{}
+	if err := g.start(ctx, "127.0.0.1:0", []string{"127.0.0.1:1"}, time.Hour, time.Minute, "g", "", log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	g.heartbeat("127.0.0.1:2", gossipMessage{Name: "spoofed", Peers: []string{"127.0.0.1:3"}}, now)
+	g.heartbeat("127.0.0.1:1", gossipMessage{Name: "static", Peers: []string{"127.0.0.1:4"}}, now)
+	if up := upPeers(g); len(up) != 1 || !up["static"] {
+		t.Errorf("got peers %v up, want only static", up)
+	}
+	if len(g.peers) != 1 {
+		t.Errorf("got %d peers, want only the configured one", len(g.peers))
+	}
+
+	// With a key, the number of learned peers is bounded.
+	keyFile := filepath.Join(t.TempDir(), "gossip.key")
+	if err := os.WriteFile(keyFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k := &gossip{}
+	if err := k.start(ctx, "127.0.0.1:0", nil, time.Hour, time.Minute, "k", keyFile, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	msg := gossipMessage{Name: "sender"}
+	for i := 0; i < 2*gossipMaxLearnedPeers; i++ {
+		msg.Peers = append(msg.Peers, fmt.Sprintf("10.0.%d.%d:9101", i/256, i%256))
+	}
+	k.heartbeat("127.0.0.1:1", msg, now)
+	if len(k.peers) != gossipMaxLearnedPeers || k.learned != gossipMaxLearnedPeers {
+		t.Errorf("got %d peers, want %d", len(k.peers), gossipMaxLearnedPeers)
+	}
+}
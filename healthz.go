@@ -0,0 +1,86 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+// healthzResponse is the JSON body of /healthz.
+type healthzResponse struct {
+	Status     string                   `json:"status"`
+	Error      string                   `json:"error,omitempty"`
+	Collectors []healthzCollectorStatus `json:"collectors"`
+}
+
+type healthzCollectorStatus struct {
+	Name            string     `json:"name"`
+	LastRun         *time.Time `json:"last_run,omitempty"`
+	LastSuccess     *time.Time `json:"last_success,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// healthzHandler serves /healthz for load balancers and readiness probes. It
+// responds with 200 if check succeeds and 503 otherwise. Failing collectors
+// do not fail the check, as e.g. a collector without data on a host would
+// keep the exporter unready forever, but are listed in the body with the
+// outcome of their latest run.
+func healthzHandler(check func() error, statuses func() []collector.CollectorStatus, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Only GET or HEAD requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := healthzResponse{Status: "ok", Collectors: []healthzCollectorStatus{}}
+		code := http.StatusOK
+		if err := check(); err != nil {
+			resp.Status, resp.Error = "failed", err.Error()
+			code = http.StatusServiceUnavailable
+		}
+		for _, s := range statuses() {
+			status := healthzCollectorStatus{Name: s.Name, DurationSeconds: s.Duration.Seconds()}
+			if !s.LastRun.IsZero() {
+				lastRun := s.LastRun
+				status.LastRun = &lastRun
+			}
+			if !s.LastSuccess.IsZero() {
+				lastSuccess := s.LastSuccess
+				status.LastSuccess = &lastSuccess
+			}
+			if s.Err != nil {
+				status.Error = s.Err.Error()
+			}
+			resp.Collectors = append(resp.Collectors, status)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		if r.Method == http.MethodHead {
+			return
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			level.Debug(logger).Log("msg", "Failed to write health response", "err", err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+func TestHealthzHandler(t *testing.T) {
+	begin := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	statuses := func() []collector.CollectorStatus {
+		return []collector.CollectorStatus{
+			{Name: "cpu", LastRun: begin, LastSuccess: begin, Duration: 20 * time.Millisecond},
+			{Name: "hwmon", LastRun: begin, LastSuccess: begin.Add(-time.Minute), Duration: time.Second, Err: errors.New("read failed")},
+			{Name: "textfile"},
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		check      error
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "healthy", wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "unhealthy", check: errors.New("procfs is not readable"), wantCode: http.StatusServiceUnavailable, wantStatus: "failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := healthzHandler(func() error { return tc.check }, statuses, log.NewNopLogger())
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("got status %d, want %d", rec.Code, tc.wantCode)
+			}
+
+			var resp healthzResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tc.wantStatus {
+				t.Errorf("got status %q, want %q", resp.Status, tc.wantStatus)
+			}
+			if len(resp.Collectors) != 3 {
+				t.Fatalf("got %d collectors, want 3", len(resp.Collectors))
+			}
+			if hwmon := resp.Collectors[1]; hwmon.Error != "read failed" || !hwmon.LastSuccess.Equal(begin.Add(-time.Minute)) || hwmon.DurationSeconds != 1 {
+				t.Errorf("unexpected hwmon status %+v", hwmon)
+			}
+			if textfile := resp.Collectors[2]; textfile.LastRun != nil || textfile.LastSuccess != nil {
+				t.Errorf("collector that did not run has runs: %+v", textfile)
+			}
+		})
+	}
+
+	h := healthzHandler(func() error { return nil }, statuses, log.NewNopLogger())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/healthz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
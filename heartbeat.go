@@ -0,0 +1,150 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	heartbeatTimestampDesc = prometheus.NewDesc(
+		"node_heartbeat_timestamp_seconds",
+		"Unix time of the last heartbeat of node_exporter, taken at --heartbeat.interval independently of scrapes.",
+		nil, nil,
+	)
+	heartbeatPushFailuresDesc = prometheus.NewDesc(
+		"node_heartbeat_push_failures_total",
+		"Number of heartbeats that could not be pushed to --heartbeat.push-url.",
+		nil, nil,
+	)
+)
+
+// heartbeat records that node_exporter is alive at a fixed interval, so that
+// dead man's switch alerts can tell a dead exporter, whose heartbeat stops,
+// from a broken scrape pipeline, which stops scraping a heartbeat that is
+// still fresh. The heartbeat is optionally pushed to an external endpoint.
+type heartbeat struct {
+	pushURL string
+	client  *http.Client
+	logger  log.Logger
+
+	mtx          sync.Mutex
+	timestamp    time.Time
+	pushFailures float64
+}
+
+// heartbeatCollector exposes the heartbeat, if enabled, on every handler.
+var heartbeatCollector = &heartbeat{}
+
+// start beats every interval until the context is done.
+func (h *heartbeat) start(ctx context.Context, interval time.Duration, pushURL string, logger log.Logger) {
+	h.pushURL = pushURL
+	h.client = &http.Client{Timeout: interval}
+	h.logger = logger
+
+	h.beat(ctx, time.Now())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				h.beat(ctx, now)
+			}
+		}
+	}()
+}
+
+func (h *heartbeat) beat(ctx context.Context, now time.Time) {
+	h.mtx.Lock()
+	h.timestamp = now
+	h.mtx.Unlock()
+
+	if h.pushURL == "" {
+		return
+	}
+	if err := h.push(ctx); err != nil {
+		level.Warn(h.logger).Log("msg", "Failed to push heartbeat", "url", h.pushURL, "err", err)
+		h.mtx.Lock()
+		h.pushFailures++
+		h.mtx.Unlock()
+	}
+}
+
+// push POSTs the heartbeat metrics in the text exposition format, which is
+// accepted by the Pushgateway and ignored by plain ping endpoints.
+func (h *heartbeat) push(ctx context.Context) error {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(h); err != nil {
+		return err
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.pushURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (h *heartbeat) Describe(ch chan<- *prometheus.Desc) {
+	ch <- heartbeatTimestampDesc
+	ch <- heartbeatPushFailuresDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed before the
+// first heartbeat, i.e. if heartbeats are disabled.
+func (h *heartbeat) Collect(ch chan<- prometheus.Metric) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.timestamp.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(heartbeatTimestampDesc, prometheus.GaugeValue, float64(h.timestamp.UnixNano())/1e9)
+	if h.pushURL != "" {
+		ch <- prometheus.MustNewConstMetric(heartbeatPushFailuresDesc, prometheus.CounterValue, h.pushFailures)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeartbeat(t *testing.T) {
+	pushes := make(chan string, 10)
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushes <- string(body)
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	h := &heartbeat{}
+	if n := testutil.CollectAndCount(h); n != 0 {
+		t.Fatalf("got %d metrics before the first heartbeat, want 0", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.start(ctx, time.Hour, server.URL, log.NewNopLogger())
+
+	if body := <-pushes; !strings.Contains(body, "node_heartbeat_timestamp_seconds ") {
+		t.Errorf("pushed heartbeat is missing the timestamp:\n%s", body)
+	}
+	if n := testutil.CollectAndCount(h, "node_heartbeat_timestamp_seconds"); n != 1 {
+		t.Errorf("got %d heartbeat timestamps, want 1", n)
+	}
+
+	fail.Store(true)
+	h.beat(ctx, time.Now())
+	<-pushes
+	want := `# HELP node_heartbeat_push_failures_total Number of heartbeats that could not be pushed to --heartbeat.push-url.
+# TYPE node_heartbeat_push_failures_total counter
+node_heartbeat_push_failures_total 1
+`
+	if err := testutil.CollectAndCompare(h, strings.NewReader(want), "node_heartbeat_push_failures_total"); err != nil {
+		t.Error(err)
+	}
+}
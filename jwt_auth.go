@@ -0,0 +1,472 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	jwtRejectedMissing   = "missing_token"
+	jwtRejectedMalformed = "malformed"
+	jwtRejectedKey       = "unknown_key"
+	jwtRejectedSignature = "invalid_signature"
+	jwtRejectedClaims    = "invalid_claims"
+
+	// jwtLeeway is the clock skew tolerated when checking the expiry and
+	// not before times of tokens.
+	jwtLeeway = time.Minute
+	// jwksMinRefreshInterval limits how often tokens signed with unknown
+	// keys make the JWKS be fetched again.
+	jwksMinRefreshInterval = time.Minute
+)
+
+var jwtRejectedDesc = prometheus.NewDesc(
+	"node_exporter_jwt_rejected_total",
+	"Number of scrapes rejected by the validation of bearer tokens against --web.jwt.jwks-url, by reason.",
+	[]string{"reason"}, nil,
+)
+
+// jwtAuthenticator only lets scrapes through with a bearer token that is a
+// JWT signed by a key of a JWKS, such as the OIDC tokens forwarded by an
+// ingress. Only asymmetric algorithms are accepted.
+type jwtAuthenticator struct {
+	jwksURL         string
+	issuer          string
+	audience        string
+	refreshInterval time.Duration
+	client          *http.Client
+	logger          log.Logger
+	now             func() time.Time
+
+	keysMtx     sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+
+	mtx      sync.Mutex
+	rejected map[string]float64
+}
+
+// jwtCollector authenticates the scrapes of the metrics path.
+var jwtCollector = &jwtAuthenticator{}
+
+// configure sets the JWKS URL the signing keys are fetched from and the
+// issuer and audience the tokens must have, not checked if empty. Scrapes
+// are not authenticated without a JWKS URL.
+func (a *jwtAuthenticator) configure(jwksURL, issuer, audience string, refreshInterval time.Duration, logger log.Logger) error {
+	if jwksURL == "" {
+		return nil
+	}
+	if err := validateJWKSURL(jwksURL); err != nil {
+		return err
+	}
+	a.jwksURL = jwksURL
+	a.issuer = issuer
+	a.audience = audience
+	a.refreshInterval = refreshInterval
+	// Tests set a client trusting their server.
+	if a.client == nil {
+		a.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	a.logger = logger
+	a.now = time.Now
+	a.rejected = map[string]float64{
+		jwtRejectedMissing:   0,
+		jwtRejectedMalformed: 0,
+		jwtRejectedKey:       0,
+		jwtRejectedSignature: 0,
+		jwtRejectedClaims:    0,
+	}
+
+	// The keys are fetched again on the first scrape if the identity
+	// provider is not reachable yet.
+	if err := a.refreshKeys(); err != nil {
+		level.Warn(logger).Log("msg", "Failed to fetch JWKS", "url", jwksURL, "err", err)
+	}
+	return nil
+}
+
+// validateJWKSURL checks --web.jwt.jwks-url.
+func validateJWKSURL(jwksURL string) error {
+	u, err := url.Parse(jwksURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid --web.jwt.jwks-url %q", jwksURL)
+	}
+	// The keys of a JWKS fetched over plain HTTP could be replaced on the
+	// way to sign tokens for any scrape.
+	if u.Scheme != "https" {
+		return fmt.Errorf("--web.jwt.jwks-url %q must be an https URL", jwksURL)
+	}
+	return nil
+}
+
+// wrap rejects the scrapes without a valid bearer token with 401.
+func (a *jwtAuthenticator) wrap(next http.Handler) http.Handler {
+	if a.jwksURL == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			a.reject(jwtRejectedMissing)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if reason, err := a.validate(token); err != nil {
+			a.reject(reason)
+			level.Debug(a.logger).Log("msg", "Rejected scrape with invalid bearer token", "remote_addr", r.RemoteAddr, "reason", reason, "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wrapView authenticates the scrapes of a view with its basic_auth_users if
+// it has any, as both take the Authorization header, and with a bearer token
+// otherwise.
+func (a *jwtAuthenticator) wrapView(h *viewHandler) http.Handler {
+	if len(h.view.BasicAuthUsers) > 0 {
+		return h
+	}
+	return a.wrap(h)
+}
+
+func (a *jwtAuthenticator) reject(reason string) {
+	a.mtx.Lock()
+	a.rejected[reason]++
+	a.mtx.Unlock()
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	Expiry    *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+}
+
+// jwtAudience is the aud claim, either a string or an array of strings.
+type jwtAudience []string
+
+func (aud *jwtAudience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*aud = jwtAudience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	*aud = l
+	return nil
+}
+
+// validate checks the signature and the claims of a token, and returns the
+// reason to reject it with otherwise.
+func (a *jwtAuthenticator) validate(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtRejectedMalformed, errors.New("token is not a signed JWT")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return jwtRejectedMalformed, fmt.Errorf("invalid header: %w", err)
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return jwtRejectedMalformed, fmt.Errorf("invalid claims: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtRejectedMalformed, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return jwtRejectedKey, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return jwtRejectedSignature, err
+	}
+
+	now := a.now()
+	switch {
+	case claims.Expiry == nil:
+		return jwtRejectedClaims, errors.New("token has no expiry")
+	case now.After(time.Unix(int64(*claims.Expiry), 0).Add(jwtLeeway)):
+		return jwtRejectedClaims, errors.New("token expired")
+	case claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(int64(*claims.NotBefore), 0)):
+		return jwtRejectedClaims, errors.New("token not valid yet")
+	case a.issuer != "" && claims.Issuer != a.issuer:
+		return jwtRejectedClaims, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case a.audience != "" && !claims.Audience.contains(a.audience):
+		return jwtRejectedClaims, fmt.Errorf("unexpected audience %q", strings.Join(claims.Audience, ","))
+	}
+	return "", nil
+}
+
+func (aud jwtAudience) contains(audience string) bool {
+	for _, a := range aud {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key with the given ID. The JWKS is fetched again
+// every refresh interval, and when a token is signed with an unknown key
+// after a key rotation.
+func (a *jwtAuthenticator) key(kid string) (crypto.PublicKey, error) {
+	a.keysMtx.Lock()
+	key, ok := a.lookupKey(kid)
+	age := a.now().Sub(a.keysFetched)
+	a.keysMtx.Unlock()
+
+	if age > a.refreshInterval || (!ok && age > jwksMinRefreshInterval) {
+		if err := a.refreshKeys(); err != nil {
+			level.Warn(a.logger).Log("msg", "Failed to fetch JWKS", "url", a.jwksURL, "err", err)
+		}
+		a.keysMtx.Lock()
+		key, ok = a.lookupKey(kid)
+		a.keysMtx.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey returns the key with the given ID, or the only key if tokens do
+// not name the key. It must be called with keysMtx held.
+func (a *jwtAuthenticator) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if key, ok := a.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (a *jwtAuthenticator) refreshKeys() error {
+	a.keysMtx.Lock()
+	// Failed fetches are not retried before jwksMinRefreshInterval either.
+	a.keysFetched = a.now()
+	a.keysMtx.Unlock()
+
+	keys, err := fetchJWKS(a.client, a.jwksURL)
+	if err != nil {
+		return err
+	}
+	a.keysMtx.Lock()
+	a.keys = keys
+	a.keysMtx.Unlock()
+	return nil
+}
+
+// jsonWebKey is a key of a JWKS as defined by RFC 7517 and RFC 7518.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS returns the RSA and EC signing keys of a JWKS by key ID. Other
+// keys are skipped.
+func fetchJWKS(client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the key, nil if its type or curve is not supported.
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			// Keys on other curves, such as secp256k1, are skipped like
+			// other unsupported keys.
+			return nil, nil
+		}
+		x, err := decodeJWKInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// verifyJWTSignature verifies the signature of a JWS with the RS, PS and ES
+// algorithms of RFC 7518. The key type must match the algorithm, so that a
+// token cannot pick a weaker verification.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if alg[:2] != "ES" || k.Curve.Params().BitSize != map[crypto.Hash]int{crypto.SHA256: 256, crypto.SHA384: 384, crypto.SHA512: 521}[hash] {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("algorithm %q does not match the signing key", alg)
+}
+
+// Describe implements prometheus.Collector.
+func (a *jwtAuthenticator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jwtRejectedDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if tokens are
+// not validated.
+func (a *jwtAuthenticator) Collect(ch chan<- prometheus.Metric) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for reason, count := range a.rejected {
+		ch <- prometheus.MustNewConstMetric(jwtRejectedDesc, prometheus.CounterValue, count, reason)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		// Keys on unsupported curves are skipped.
+		{"kty": "EC", "kid": "secp256k1", "crv": "secp256k1", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+	}}
+	fetches := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	sign := func(alg, kid string, claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+		payload, _ := json.Marshal(claims)
+		signed := b64(header) + "." + b64(payload)
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		var signature []byte
+		switch alg {
+		case "RS256":
+			signature, _ = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest.Sum(nil))
+		case "ES256":
+			r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest.Sum(nil))
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+		return signed + "." + b64(signature)
+	}
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"iss": "https://idp.example.com", "aud": []string{"node-exporter", "other"}, "exp": now.Add(time.Hour).Unix()}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	a := &jwtAuthenticator{client: server.Client()}
+	if err := a.configure(server.URL, "https://idp.example.com", "node-exporter", time.Hour, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	h := a.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	valid := sign("RS256", "rsa", claims(nil))
+	for name, test := range map[string]struct {
+		token string
+		want  int
+	}{
+		"RS256":              {valid, http.StatusOK},
+		"ES256":              {sign("ES256", "ec", claims(map[string]interface{}{"aud": "node-exporter"})), http.StatusOK},
+		"no token":           {"", http.StatusUnauthorized},
+		"malformed":          {"not-a-jwt", http.StatusUnauthorized},
+		"unknown key":        {sign("RS256", "rotated", claims(nil)), http.StatusUnauthorized},
+		"none algorithm":     {strings.Join(strings.Split(valid, ".")[:2], ".") + ".", http.StatusUnauthorized},
+		"algorithm mismatch": {sign("ES256", "rsa", claims(nil)), http.StatusUnauthorized},
+		"tampered":           {sign("RS256", "rsa", claims(nil))[:len(valid)-4] + "AAAA", http.StatusUnauthorized},
+		"expired":            {sign("RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), http.StatusUnauthorized},
+		"no expiry":          {sign("RS256", "rsa", claims(map[string]interface{}{"exp": nil})), http.StatusUnauthorized},
+		"wrong issuer":       {sign("RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example.com"})), http.StatusUnauthorized},
+		"wrong audience":     {sign("RS256", "rsa", claims(map[string]interface{}{"aud": "grafana"})), http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", name, rec.Code, test.want)
+		}
+	}
+
+	// Unknown keys only make the JWKS be fetched again after
+	// jwksMinRefreshInterval.
+	if fetches != 1 {
+		t.Errorf("got %d JWKS fetches, want 1", fetches)
+	}
+	now = now.Add(2 * jwksMinRefreshInterval)
+	a.key("rotated")
+	if fetches != 2 {
+		t.Errorf("got %d JWKS fetches after unknown key, want 2", fetches)
+	}
+
+	want := `# HELP node_exporter_jwt_rejected_total Number of scrapes rejected by the validation of bearer tokens against --web.jwt.jwks-url, by reason.
+# TYPE node_exporter_jwt_rejected_total counter
+node_exporter_jwt_rejected_total{reason="invalid_claims"} 4
+node_exporter_jwt_rejected_total{reason="invalid_signature"} 3
+node_exporter_jwt_rejected_total{reason="malformed"} 1
+node_exporter_jwt_rejected_total{reason="missing_token"} 1
+node_exporter_jwt_rejected_total{reason="unknown_key"} 1
+`
+	if err := testutil.CollectAndCompare(a, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateJWKSURL(t *testing.T) {
+	for url, valid := range map[string]bool{
+		"https://idp.example.com/.well-known/jwks.json": true,
+		"http://idp.example.com/.well-known/jwks.json":  false,
+		"idp.example.com/jwks":                          false,
+		"https:///jwks":                                 false,
+	} {
+		if err := validateJWKSURL(url); (err == nil) != valid {
+			t.Errorf("%s: got error %v, want valid %t", url, err, valid)
+		}
+	}
+}
+
+func TestJWTWrapView(t *testing.T) {
+	a := &jwtAuthenticator{jwksURL: "https://idp.example.com/jwks", rejected: map[string]float64{}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	a.wrapView(&viewHandler{handler: ok}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/team", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("view without users: got status %d, want a bearer token challenge", rec.Code)
+	}
+
+	// Views with their own users keep basic authentication.
+	rec = httptest.NewRecorder()
+	a.wrapView(&viewHandler{view: metricView{BasicAuthUsers: map[string]string{"team": "hash"}}, handler: ok}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics/team", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "Basic" {
+		t.Errorf("view with users: got status %d, want a basic auth challenge", rec.Code)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-systemd/v22/activation"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/exporter-toolkit/web"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v2"
+)
+
+// listenersConfig is the format of --web.config.file when it lists the
+// addresses to listen on:
+//
+//	listeners:
+//	  - address: 0.0.0.0:9100
+//	    web_config_file: web-ipv4.yml
+//	  - address: "[::]:9100"
+//	    web_config_file: web-ipv6.yml
+//	  - address: 127.0.0.1:9101
+//	    h2c: true
+//	  - address: unix:///run/node_exporter.sock
+//	  - address: systemd://node_exporter-tls
+//	    web_config_file: web-tls.yml
+//
+// Each listener is served with its own exporter-toolkit web config, so that
+// addresses can have independent TLS and authentication settings. Without
+// listeners, the file is an exporter-toolkit web config used for all
+// --web.listen-address.
+type listenersConfig struct {
+	Listeners []listenerConfig `yaml:"listeners"`
+}
+
+type listenerConfig struct {
+	Address string `yaml:"address"`
+	// WebConfigFile is the exporter-toolkit web config of the listener,
+	// relative to the listeners file. The listener serves plain HTTP
+	// without authentication if empty.
+	WebConfigFile string `yaml:"web_config_file"`
+	// H2C enables HTTP/2 over cleartext for scrapers multiplexing requests
+	// on one connection. HTTP/2 over TLS is enabled by http_server_config in
+	// the web config instead.
+	H2C bool `yaml:"h2c"`
+}
+
+// loadListenersConfig returns the listeners of a web config file, or none if
+// it is a plain exporter-toolkit web config.
+func loadListenersConfig(path string) ([]listenerConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web config: %w", err)
+	}
+
+	var config listenersConfig
+	if err := yaml.Unmarshal(data, &config); err != nil || len(config.Listeners) == 0 {
+		// Errors are reported by the exporter-toolkit.
+		return nil, nil
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse web config with listeners, other settings belong to the web_config_file of the listeners: %w", err)
+	}
+
+	addresses := map[string]bool{}
+	for i, l := range config.Listeners {
+		if l.Address == "" {
+			return nil, fmt.Errorf("listener %d has no address", i)
+		}
+		if addresses[l.Address] {
+			return nil, fmt.Errorf("duplicate listener address %q", l.Address)
+		}
+		addresses[l.Address] = true
+
+		if l.WebConfigFile == "" {
+			continue
+		}
+		if !filepath.IsAbs(l.WebConfigFile) {
+			l.WebConfigFile = filepath.Join(filepath.Dir(path), l.WebConfigFile)
+		}
+		if err := web.Validate(l.WebConfigFile); err != nil {
+			return nil, fmt.Errorf("invalid web config of listener %q: %w", l.Address, err)
+		}
+		if l.H2C {
+			if err := checkH2C(l.WebConfigFile); err != nil {
+				return nil, fmt.Errorf("invalid listener %q: %w", l.Address, err)
+			}
+		}
+		config.Listeners[i] = l
+	}
+	return config.Listeners, nil
+}
+
+// checkH2C rejects HTTP/2 over cleartext with TLS or basic auth. TLS
+// negotiates HTTP/2 by itself, and requests multiplexed by h2c would bypass
+// the authentication of the exporter-toolkit.
+func checkH2C(webConfigFile string) error {
+	data, err := os.ReadFile(webConfigFile)
+	if err != nil {
+		return err
+	}
+	var c web.Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	if c.TLSConfig.TLSCertPath != "" || c.TLSConfig.TLSCert != "" {
+		return errors.New("h2c cannot be combined with TLS, use http_server_config.http2 instead")
+	}
+	if len(c.Users) > 0 {
+		return errors.New("h2c cannot be combined with basic auth")
+	}
+	return nil
+}
+
+// validateWebConfig validates a web config file with or without listeners.
+func validateWebConfig(path string) error {
+	listeners, err := loadListenersConfig(path)
+	if err != nil || len(listeners) > 0 {
+		return err
+	}
+	return web.Validate(path)
+}
+
+const (
+	// unixSocketPrefix marks listen addresses that are paths of unix
+	// sockets.
+	unixSocketPrefix = "unix://"
+	// systemdSocketPrefix marks listen addresses that are sockets passed
+	// by systemd socket activation, by their FileDescriptorName=.
+	systemdSocketPrefix = "systemd://"
+)
+
+// systemdListeners holds the sockets passed by systemd socket activation by
+// name. systemd passes them once, so they are loaded on first use.
+var systemdListeners = struct {
+	sync.Mutex
+	loaded    bool
+	listeners map[string][]net.Listener
+}{}
+
+// systemdListener returns a socket passed by systemd with the given name,
+// which defaults to the name of the socket unit. Each socket is returned
+// once.
+func systemdListener(name string) (net.Listener, error) {
+	systemdListeners.Lock()
+	defer systemdListeners.Unlock()
+
+	if !systemdListeners.loaded {
+		listeners, err := activation.ListenersWithNames()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sockets from systemd: %w", err)
+		}
+		systemdListeners.listeners, systemdListeners.loaded = listeners, true
+	}
+	for len(systemdListeners.listeners[name]) > 0 {
+		listener := systemdListeners.listeners[name][0]
+		systemdListeners.listeners[name] = systemdListeners.listeners[name][1:]
+		// Datagram sockets are passed as nil listeners.
+		if listener != nil {
+			return listener, nil
+		}
+	}
+	return nil, fmt.Errorf("no stream socket named %q passed by systemd", name)
+}
+
+// socketPermissions are the permissions of the unix sockets listened on.
+type socketPermissions struct {
+	mode os.FileMode
+	// group owns the sockets if not empty, e.g. to let a local scrape proxy
+	// connect without being root.
+	group string
+}
+
+// parseSocketMode parses the octal permissions of --web.socket-mode.
+func parseSocketMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q, want octal permissions such as 0660", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+// hasUnixSocket returns whether any of the addresses is a unix socket.
+func hasUnixSocket(addresses []string) bool {
+	for _, address := range addresses {
+		if strings.HasPrefix(address, unixSocketPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listen listens on a TCP address, on a unix socket for addresses of the
+// form unix:///path/to/socket, or returns a socket passed by systemd for
+// addresses of the form systemd://name.
+func listen(address string, perms socketPermissions) (net.Listener, error) {
+	if name, ok := strings.CutPrefix(address, systemdSocketPrefix); ok {
+		return systemdListener(name)
+	}
+	path, ok := strings.CutPrefix(address, unixSocketPrefix)
+	if !ok {
+		return net.Listen(listenNetwork(address), address)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("unix socket address %q has no path", address)
+	}
+
+	// The socket of a previous run is left behind if it did not exit
+	// cleanly. Other files are not removed.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := setSocketPermissions(path, perms); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of socket %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+func setSocketPermissions(path string, perms socketPermissions) error {
+	if perms.group != "" {
+		group, err := user.LookupGroup(perms.group)
+		if err != nil {
+			return err
+		}
+		gid, err := strconv.Atoi(group.Gid)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(path, -1, gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(path, perms.mode)
+}
+
+// serveAddresses serves a server on the addresses of --web.listen-address, including unix sockets, which the exporter-toolkit
+// does not listen on by itself.
+func serveAddresses(server *http.Server, addresses []string, perms socketPermissions, flags *web.FlagConfig, logger log.Logger) error {
+	netListeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		listener, err := listen(address, perms)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		netListeners = append(netListeners, listener)
+	}
+	return web.ServeMultiple(netListeners, server, flags, logger)
+}
+
+// listenNetwork returns the network to listen on for an address. IP literals
+// are bound to their address family only, so that an IPv4 and an IPv6 address
+// can be listened on side by side on the same port. The IPv6 wildcard address
+// is the exception, it keeps accepting IPv4 connections as with
+// --web.listen-address, tcp6 would set IPV6_V6ONLY.
+func listenNetwork(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "tcp"
+	}
+	ip, err := netip.ParseAddr(host)
+	switch {
+	case err != nil, ip == netip.IPv6Unspecified():
+		return "tcp"
+	case ip.Unmap().Is4():
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// serveListeners serves a handler on every listener, each with its own server
+// and web config.
+func serveListeners(listeners []listenerConfig, handler http.Handler, perms socketPermissions, logger log.Logger) error {
+	netListeners := make([]net.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		listener, err := listen(l.Address, perms)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		netListeners = append(netListeners, listener)
+	}
+
+	var errs errgroup.Group
+	for i, l := range listeners {
+		listener, webConfigFile := netListeners[i], l.WebConfigFile
+		server := &http.Server{Handler: handler}
+		if l.H2C {
+			server.Handler = h2c.NewHandler(handler, &http2.Server{})
+			level.Info(logger).Log("msg", "HTTP/2 over cleartext is enabled", "address", l.Address)
+		}
+		errs.Go(func() error {
+			return web.Serve(listener, server, &web.FlagConfig{WebConfigFile: &webConfigFile}, logger)
+		})
+	}
+	return errs.Wait()
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadListenersConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("auth.yml", "basic_auth_users:\n  prometheus: $2a$04$/Pc6evmo5TuuzlYX0cvlPeaJIfFbBThelI606FDB3OfPHx6BDEAmi\n")
+
+	listeners, err := loadListenersConfig(write("plain.yml", "http_server_config:\n  http2: false\n"))
+	if err != nil || listeners != nil {
+		t.Errorf("web config without listeners: got %v, %v", listeners, err)
+	}
+
+	listeners, err = loadListenersConfig(write("web.yml", `listeners:
+  - address: 0.0.0.0:9100
+    web_config_file: auth.yml
+  - address: "[::]:9100"
+    h2c: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("got %d listeners, want 2", len(listeners))
+	}
+	if want := filepath.Join(dir, "auth.yml"); listeners[0].WebConfigFile != want {
+		t.Errorf("web config file = %s, want %s", listeners[0].WebConfigFile, want)
+	}
+	if !listeners[1].H2C {
+		t.Error("expected h2c on second listener")
+	}
+
+	for _, invalid := range []string{
+		"listeners:\n  - web_config_file: auth.yml\n",
+		"listeners:\n  - address: :9100\n  - address: :9100\n",
+		"listeners:\n  - address: :9100\n    web_config_file: missing.yml\n",
+		"listeners:\n  - address: :9100\n    web_config_file: auth.yml\n    h2c: true\n",
+		"listeners:\n  - address: :9100\nbasic_auth_users: {}\n",
+	} {
+		if _, err := loadListenersConfig(write("invalid.yml", invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	for address, want := range map[string]string{
+		":9100":            "tcp",
+		"localhost:9100":   "tcp",
+		"0.0.0.0:9100":     "tcp4",
+		"10.0.0.5:9100":    "tcp4",
+		"[::]:9100":        "tcp",
+		"[2001:db8::5]:9":  "tcp6",
+		"[fe80::1%eth0]:9": "tcp6",
+	} {
+		if got := listenNetwork(address); got != want {
+			t.Errorf("listenNetwork(%q) = %s, want %s", address, got, want)
+		}
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_exporter.sock")
+	perms := socketPermissions{mode: 0o600}
+
+	// Leave a stale socket behind, as after a crash.
+	stale, err := listen(unixSocketPrefix+path, perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listen(unixSocketPrefix+path, perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0o600 {
+		t.Errorf("want socket mode 0600, got %o", got)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(unixSocketPrefix+file, perms); err == nil {
+		t.Error("expected error listening on a regular file")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestParseSocketMode(t *testing.T) {
+	if mode, err := parseSocketMode("0660"); err != nil || mode != 0o660 {
+		t.Errorf("parseSocketMode(0660) = %o, %v", mode, err)
+	}
+	for _, invalid := range []string{"", "rw", "0999", "01777"} {
+		if _, err := parseSocketMode(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestSystemdListener(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+
+	systemdListeners.Lock()
+	systemdListeners.loaded = true
+	systemdListeners.listeners = map[string][]net.Listener{"node_exporter.socket": {nil, tcp}}
+	systemdListeners.Unlock()
+	defer func() {
+		systemdListeners.Lock()
+		systemdListeners.loaded, systemdListeners.listeners = false, nil
+		systemdListeners.Unlock()
+	}()
+
+	listener, err := listen(systemdSocketPrefix+"node_exporter.socket", socketPermissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener != tcp {
+		t.Errorf("got listener %v, want %v", listener.Addr(), tcp.Addr())
+	}
+	// Every socket is returned once.
+	if _, err := listen(systemdSocketPrefix+"node_exporter.socket", socketPermissions{}); err == nil {
+		t.Error("expected error for a socket already in use")
+	}
+	if _, err := listen(systemdSocketPrefix+"missing", socketPermissions{}); err == nil {
+		t.Error("expected error for a missing socket")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Naming schemes of --metrics.naming-scheme.
+const (
+	namingLegacy   = "legacy"
+	namingBoth     = "both"
+	namingStandard = "standard"
+)
+
+// namingRule renames legacy metric names lacking a unit suffix or having it
+// in the wrong place. An empty standard name keeps the legacy name.
+type namingRule struct {
+	legacy   *regexp.Regexp
+	standard string
+}
+
+// namingRules are applied in order, the first matching rule wins.
+var namingRules = []namingRule{
+	{regexp.MustCompile(`^node_bcache_writeback_rate$`), "node_bcache_writeback_rate_bytes"},
+	{regexp.MustCompile(`^node_nfsd_disk_bytes_(read|written)_total$`), "node_nfsd_disk_${1}_bytes_total"},
+	{regexp.MustCompile(`^node_qdisc_backlog$`), "node_qdisc_backlog_bytes"},
+	{regexp.MustCompile(`^node_udp_queues$`), "node_udp_queues_bytes"},
+	// The meminfo_numa fields are in bytes, except for the huge page
+	// counts and the numastat counters.
+	{regexp.MustCompile(`^node_memory_numa_(HugePages_.*|.*_total)$`), ""},
+	{regexp.MustCompile(`^node_memory_numa_(.+)$`), "node_memory_numa_${1}_bytes"},
+}
+
+// standardName returns the standard name of a metric, false if the name
+// doesn't change.
+func standardName(name string) (string, bool) {
+	for _, rule := range namingRules {
+		match := rule.legacy.FindStringSubmatchIndex(name)
+		if match == nil {
+			continue
+		}
+		if rule.standard == "" {
+			return "", false
+		}
+		return string(rule.legacy.ExpandString(nil, rule.standard, name, match)), true
+	}
+	return "", false
+}
+
+// namingGatherer exposes the metric families of the wrapped Gatherer under
+// their standard names, optionally keeping the legacy names during a
+// migration.
+type namingGatherer struct {
+	gatherer   prometheus.Gatherer
+	keepLegacy bool
+}
+
+func newNamingGatherer(gatherer prometheus.Gatherer, scheme string) prometheus.Gatherer {
+	if scheme != namingBoth && scheme != namingStandard {
+		return gatherer
+	}
+	return &namingGatherer{gatherer: gatherer, keepLegacy: scheme == namingBoth}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *namingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	renamed := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		name, ok := standardName(mf.GetName())
+		if !ok {
+			renamed = append(renamed, mf)
+			continue
+		}
+		if g.keepLegacy {
+			legacy := proto.Clone(mf).(*dto.MetricFamily)
+			legacy.Help = proto.String(fmt.Sprintf("%s Deprecated, use %s.", mf.GetHelp(), name))
+			renamed = append(renamed, legacy)
+		}
+		mf.Name = proto.String(name)
+		renamed = append(renamed, mf)
+	}
+	// Renamed families are no longer in order.
+	sort.Slice(renamed, func(i, j int) bool {
+		return renamed[i].GetName() < renamed[j].GetName()
+	})
+	return renamed, err
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStandardName(t *testing.T) {
+	for legacy, want := range map[string]string{
+		"node_qdisc_backlog":                 "node_qdisc_backlog_bytes",
+		"node_nfsd_disk_bytes_read_total":    "node_nfsd_disk_read_bytes_total",
+		"node_memory_numa_Active_anon":       "node_memory_numa_Active_anon_bytes",
+		"node_memory_numa_HugePages_Total":   "",
+		"node_memory_numa_numa_hit_total":    "",
+		"node_memory_MemTotal_bytes":         "",
+		"node_network_receive_bytes_total":   "",
+		"node_bcache_writeback_rate":         "node_bcache_writeback_rate_bytes",
+		"node_bcache_writeback_rate_seconds": "",
+	} {
+		got, ok := standardName(legacy)
+		if ok != (want != "") || got != want {
+			t.Errorf("standardName(%q) = %q, %v, want %q", legacy, got, ok, want)
+		}
+	}
+}
+
+func TestNamingGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"node_qdisc_backlog", "node_load1"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "Test metric."}))
+	}
+
+	if g := newNamingGatherer(reg, namingLegacy); g != prometheus.Gatherer(reg) {
+		t.Error("legacy naming scheme should not wrap the gatherer")
+	}
+
+	standard := `# HELP node_load1 Test metric.
+# TYPE node_load1 gauge
+node_load1 0
+# HELP node_qdisc_backlog_bytes Test metric.
+# TYPE node_qdisc_backlog_bytes gauge
+node_qdisc_backlog_bytes 0
+`
+	if err := testutil.GatherAndCompare(newNamingGatherer(reg, namingStandard), strings.NewReader(standard)); err != nil {
+		t.Error(err)
+	}
+
+	both := standard + `# HELP node_qdisc_backlog Test metric. Deprecated, use node_qdisc_backlog_bytes.
+# TYPE node_qdisc_backlog gauge
+node_qdisc_backlog 0
+`
+	if err := testutil.GatherAndCompare(newNamingGatherer(reg, namingBoth), strings.NewReader(both)); err != nil {
+		t.Error(err)
+	}
+}
@@ -14,14 +14,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	stdlog "log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/user"
+	"regexp"
 	"runtime"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
@@ -43,48 +48,174 @@ import (
 // created on the fly, if filtering is requested. Create instances with
 // newHandler.
 type handler struct {
+	// mtx guards the unfiltered handler, which is rebuilt when the
+	// collectors are re-created by a reload of the config file.
+	mtx               sync.Mutex
 	unfilteredHandler http.Handler
+	// unfilteredGatherer gathers the metrics served by the unfiltered
+	// handler, e.g. to push them.
+	unfilteredGatherer prometheus.Gatherer
+	generation         uint64
+	// enabledCollectors are the collectors run by the unfiltered handler.
+	enabledCollectors []string
 	// exporterMetricsRegistry is a separate registry for the metrics about
 	// the exporter itself.
 	exporterMetricsRegistry *prometheus.Registry
 	includeExporterMetrics  bool
-	maxRequests             int
-	logger                  log.Logger
+	// extraLabels are added to every exposed metric.
+	extraLabels prometheus.Labels
+	// relabelConfigs are applied to the metrics of the collectors.
+	relabelConfigs []*relabelConfig
+	// namingScheme selects legacy or standard metric names.
+	namingScheme string
+	// view restricts the collectors and metrics served by the handler.
+	view   metricView
+	logger log.Logger
 }
 
-func newHandler(includeExporterMetrics bool, maxRequests int, logger log.Logger) *handler {
+func newHandler(includeExporterMetrics bool, extraLabels prometheus.Labels, relabelConfigs []*relabelConfig, namingScheme string, logger log.Logger) *handler {
+	h, err := newHandlerForView(metricView{}, includeExporterMetrics, extraLabels, relabelConfigs, namingScheme, logger)
+	if err != nil {
+		panic(fmt.Sprintf("Couldn't create metrics handler: %s", err))
+	}
+	return h
+}
+
+// newHandlerForView returns a handler serving only the collectors and
+// metrics of a view.
+func newHandlerForView(view metricView, includeExporterMetrics bool, extraLabels prometheus.Labels, relabelConfigs []*relabelConfig, namingScheme string, logger log.Logger) (*handler, error) {
 	h := &handler{
 		exporterMetricsRegistry: prometheus.NewRegistry(),
 		includeExporterMetrics:  includeExporterMetrics,
-		maxRequests:             maxRequests,
+		extraLabels:             extraLabels,
+		relabelConfigs:          relabelConfigs,
+		namingScheme:            namingScheme,
+		view:                    view,
 		logger:                  logger,
 	}
 	if h.includeExporterMetrics {
 		h.exporterMetricsRegistry.MustRegister(
 			promcollectors.NewProcessCollector(promcollectors.ProcessCollectorOpts{}),
 			promcollectors.NewGoCollector(),
+			scrapeDurations,
+			pushDurations,
 		)
 	}
-	if innerHandler, err := h.innerHandler(); err != nil {
-		panic(fmt.Sprintf("Couldn't create metrics handler: %s", err))
-	} else {
-		h.unfilteredHandler = innerHandler
+	h.generation = collector.Generation()
+	innerHandler, gatherer, err := h.innerHandler(nil, nil, time.Time{})
+	if err != nil {
+		return nil, err
 	}
-	return h
+	h.unfilteredHandler, h.unfilteredGatherer = innerHandler, gatherer
+	return h, nil
+}
+
+// currentUnfilteredHandler returns the unfiltered handler, rebuilding it if
+// collectors were re-created since it was built. The previous handler is kept
+// if rebuilding fails.
+func (h *handler) currentUnfilteredHandler() http.Handler {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if generation := collector.Generation(); generation != h.generation {
+		innerHandler, gatherer, err := h.innerHandler(nil, nil, time.Time{})
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Couldn't rebuild metrics handler after reload", "err", err)
+			return h.unfilteredHandler
+		}
+		h.unfilteredHandler, h.unfilteredGatherer = innerHandler, gatherer
+		h.generation = generation
+	}
+	return h.unfilteredHandler
+}
+
+// currentEnabledCollectors returns the collectors run by the unfiltered
+// handler.
+func (h *handler) currentEnabledCollectors() []string {
+	h.currentUnfilteredHandler()
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.enabledCollectors
+}
+
+// currentUnfilteredGatherer returns the gatherer of the unfiltered handler.
+func (h *handler) currentUnfilteredGatherer() prometheus.Gatherer {
+	h.currentUnfilteredHandler()
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return h.unfilteredGatherer
+}
+
+// excludeCollectors returns the collectors that are not excluded.
+func excludeCollectors(collectors, excludes []string) []string {
+	excluded := make(map[string]bool, len(excludes))
+	for _, e := range excludes {
+		excluded[e] = true
+	}
+	var filters []string
+	for _, c := range collectors {
+		if !excluded[c] {
+			filters = append(filters, c)
+		}
+	}
+	return filters
 }
 
 // ServeHTTP implements http.Handler.
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	filters := r.URL.Query()["collect[]"]
-	level.Debug(h.logger).Log("msg", "collect query:", "filters", filters)
+	defer watchdogCollector.trackScrape()()
+	defer func(begin time.Time) {
+		scrapeDurations.WithLabelValues(r.URL.Path).Observe(time.Since(begin).Seconds())
+	}(time.Now())
 
-	if len(filters) == 0 {
+	query := r.URL.Query()
+	filters := query["collect[]"]
+	excludes := query["exclude[]"]
+	metricNames := query["metric[]"]
+	level.Debug(h.logger).Log("msg", "collect query:", "filters", filters, "excludes", excludes, "metrics", metricNames)
+
+	var spans collector.SpanRecorder
+	if trace := scrapeTracer.start(r); trace != nil {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() { scrapeTracer.finish(trace, r, recorder.status) }()
+		w = recorder
+		spans = trace
+	}
+
+	deadline := scrapeDeadline(r, time.Now())
+
+	if len(filters) == 0 && len(excludes) == 0 && len(metricNames) == 0 && spans == nil && deadline.IsZero() {
 		// No filters, use the prepared unfiltered handler.
-		h.unfilteredHandler.ServeHTTP(w, r)
+		h.currentUnfilteredHandler().ServeHTTP(w, r)
+		return
+	}
+	if len(filters) > 0 && len(excludes) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("collect[] and exclude[] can't be combined"))
 		return
 	}
-	// To serve filtered metrics, we create a filtering handler on the fly.
-	filteredHandler, err := h.innerHandler(filters...)
+	if len(excludes) > 0 {
+		filters = excludeCollectors(h.currentEnabledCollectors(), excludes)
+		if len(filters) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("exclude[] excludes all collectors"))
+			return
+		}
+	}
+	metrics, err := compileMetricNames(metricNames)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("Invalid metric[] filter: %s", err)))
+		return
+	}
+	if len(filters) > 0 && !h.view.allowsCollectors(filters) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Collector not part of this view"))
+		return
+	}
+	// To serve filtered, traced or deadlined metrics, we create a handler
+	// on the fly.
+	filteredHandler, _, err := h.innerHandler(spans, metrics, deadline, filters...)
 	if err != nil {
 		level.Warn(h.logger).Log("msg", "Couldn't create filtered metrics handler:", "err", err)
 		w.WriteHeader(http.StatusBadRequest)
@@ -98,16 +229,24 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // wrapped by the outer handler and also the filtered handlers created on the
 // fly. The former is accomplished by calling innerHandler without any arguments
 // (in which case it will log all the collectors enabled via command-line
-// flags).
-func (h *handler) innerHandler(filters ...string) (http.Handler, error) {
+// flags). spans, if not nil, records the collectors of a traced scrape,
+// metrics, if not nil, restricts the exposed metric names and deadline, if
+// not zero, cuts off the collectors of the scrape. The gatherer of the
+// metrics served by the handler is returned with it.
+func (h *handler) innerHandler(spans collector.SpanRecorder, metrics *regexp.Regexp, deadline time.Time, filters ...string) (http.Handler, prometheus.Gatherer, error) {
+	if len(filters) == 0 {
+		filters = h.view.Collectors
+	}
 	nc, err := collector.NewNodeCollector(h.logger, filters...)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't create collector: %s", err)
+		return nil, nil, fmt.Errorf("couldn't create collector: %s", err)
 	}
+	nc.Spans = spans
+	nc.Deadline = deadline
 
 	// Only log the creation of an unfiltered handler, which should happen
-	// only once upon startup.
-	if len(filters) == 0 {
+	// only once upon startup and after reloads.
+	if len(filters) == 0 && spans == nil && metrics == nil && deadline.IsZero() {
 		level.Info(h.logger).Log("msg", "Enabled collectors")
 		collectors := []string{}
 		for n := range nc.Collectors {
@@ -117,42 +256,66 @@ func (h *handler) innerHandler(filters ...string) (http.Handler, error) {
 		for _, c := range collectors {
 			level.Info(h.logger).Log("collector", c)
 		}
+		h.enabledCollectors = collectors
 	}
 
 	r := prometheus.NewRegistry()
-	r.MustRegister(versioncollector.NewCollector("node_exporter"))
+	r.MustRegister(versioncollector.NewCollector("node_exporter"), heartbeatCollector, gossipCollector, watchdogCollector, otlpPushCollector, remoteWriteCollector, scrapeLimitCollector, clientCertCollector, jwtCollector, sourceIPCollector)
 	if err := r.Register(nc); err != nil {
-		return nil, fmt.Errorf("couldn't register node collector: %s", err)
+		return nil, nil, fmt.Errorf("couldn't register node collector: %s", err)
 	}
 
-	var handler http.Handler
+	var (
+		handler  http.Handler
+		gatherer prometheus.Gatherer
+	)
 	if h.includeExporterMetrics {
+		gatherer = h.wrapGatherer(prometheus.Gatherers{h.exporterMetricsRegistry, r}, metrics)
 		handler = promhttp.HandlerFor(
-			prometheus.Gatherers{h.exporterMetricsRegistry, r},
+			gatherer,
 			promhttp.HandlerOpts{
-				ErrorLog:            stdlog.New(log.NewStdlibAdapter(level.Error(h.logger)), "", 0),
-				ErrorHandling:       promhttp.ContinueOnError,
-				MaxRequestsInFlight: h.maxRequests,
-				Registry:            h.exporterMetricsRegistry,
+				ErrorLog:      stdlog.New(log.NewStdlibAdapter(level.Error(h.logger)), "", 0),
+				ErrorHandling: promhttp.ContinueOnError,
+				Registry:      h.exporterMetricsRegistry,
+				// Responses are compressed by metricsCompressor.
+				DisableCompression: true,
 			},
 		)
+		if openMetricsEnabled {
+			handler = openMetricsHandler(gatherer, handler, h.logger)
+		}
 		// Note that we have to use h.exporterMetricsRegistry here to
 		// use the same promhttp metrics for all expositions.
 		handler = promhttp.InstrumentMetricHandler(
 			h.exporterMetricsRegistry, handler,
 		)
 	} else {
+		gatherer = h.wrapGatherer(r, metrics)
 		handler = promhttp.HandlerFor(
-			r,
+			gatherer,
 			promhttp.HandlerOpts{
-				ErrorLog:            stdlog.New(log.NewStdlibAdapter(level.Error(h.logger)), "", 0),
-				ErrorHandling:       promhttp.ContinueOnError,
-				MaxRequestsInFlight: h.maxRequests,
+				ErrorLog:      stdlog.New(log.NewStdlibAdapter(level.Error(h.logger)), "", 0),
+				ErrorHandling: promhttp.ContinueOnError,
+				// Responses are compressed by metricsCompressor.
+				DisableCompression: true,
 			},
 		)
+		if openMetricsEnabled {
+			handler = openMetricsHandler(gatherer, handler, h.logger)
+		}
 	}
 
-	return handler, nil
+	return metricsCompressor.wrap(handler), gatherer, nil
+}
+
+// wrapGatherer applies the naming scheme, tenant devices, relabel rules, extra labels and
+// metric filters of the handler to the metrics of g.
+func (h *handler) wrapGatherer(g prometheus.Gatherer, metrics *regexp.Regexp) prometheus.Gatherer {
+	g = newNamingGatherer(g, h.namingScheme)
+	g = newTenantGatherer(g, h.view.tenants)
+	g = newRelabelGatherer(g, h.relabelConfigs)
+	g = newExtraLabelsGatherer(g, h.extraLabels)
+	return newMetricFilterGatherer(newMetricFilterGatherer(g, h.view.metrics), metrics)
 }
 
 func main() {
@@ -165,10 +328,50 @@ func main() {
 			"web.disable-exporter-metrics",
 			"Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).",
 		).Bool()
+		compression = kingpin.Flag(
+			"web.compression",
+			"Content encoding of metrics responses, in the order of preference, used if the scraper accepts it: zstd or gzip. Can be repeated. none disables compression.",
+		).Default("zstd", "gzip").Enums("zstd", "gzip", "none")
+		gzipLevel = kingpin.Flag(
+			"web.compression.gzip-level",
+			"Level of gzip compression of metrics responses, from 1 (fastest) to 9 (best compression). -2 is Huffman only and -3 stateless compression, both faster still.",
+		).Default("6").Int()
+		enableScrapeDeadline = kingpin.Flag(
+			"web.scrape-deadline",
+			"Cut off the collectors still running when the scrape timeout sent by Prometheus in the X-Prometheus-Scrape-Timeout-Seconds header is about to expire.",
+		).Bool()
+		scrapeDeadlineOffset = kingpin.Flag(
+			"web.scrape-deadline-offset",
+			"Time before the scrape timeout at which the collectors are cut off, left to encode and send the response.",
+		).Default("500ms").Duration()
+		enableOpenMetrics = kingpin.Flag(
+			"web.openmetrics",
+			"Serve the OpenMetrics text format to scrapers asking for it, with info and stateset metric types and _created timestamps of counters.",
+		).Bool()
 		maxRequests = kingpin.Flag(
 			"web.max-requests",
-			"Maximum number of parallel scrape requests. Use 0 to disable.",
+			"Maximum number of parallel scrape requests over all metrics endpoints, including filtered scrapes and views. Use 0 to disable.",
 		).Default("40").Int()
+		rateLimit = kingpin.Flag(
+			"web.rate-limit",
+			"Maximum rate of scrape requests per client IP address, in requests per second, e.g. 0.1 for one scrape every 10s. Use 0 to disable.",
+		).Default("0").Float64()
+		rateLimitBurst = kingpin.Flag(
+			"web.rate-limit-burst",
+			"Number of scrape requests a client can make at once before --web.rate-limit applies.",
+		).Default("5").Int()
+		allowedCIDRs = kingpin.Flag(
+			"web.allowed-cidrs",
+			"Networks allowed to make requests to any endpoint, in CIDR notation and separated by commas, e.g. 10.0.8.0/24. All networks are allowed if empty. Can be repeated.",
+		).Strings()
+		trustedProxyCIDRs = kingpin.Flag(
+			"web.trusted-proxy-cidrs",
+			"Networks of the proxies whose --web.trusted-proxy-header is used as client address for --web.allowed-cidrs and --web.rate-limit, in CIDR notation and separated by commas. Can be repeated.",
+		).Strings()
+		trustedProxyHeader = kingpin.Flag(
+			"web.trusted-proxy-header",
+			"Header in which trusted proxies pass on the address of the client.",
+		).Default("X-Forwarded-For").String()
 		disableDefaultCollectors = kingpin.Flag(
 			"collector.disable-defaults",
 			"Set all collectors to disabled by default.",
@@ -176,40 +379,357 @@ func main() {
 		maxProcs = kingpin.Flag(
 			"runtime.gomaxprocs", "The target number of CPUs Go will run on (GOMAXPROCS)",
 		).Envar("GOMAXPROCS").Default("1").Int()
+		extraLabelFlags = kingpin.Flag(
+			"metric.extra-label",
+			"Label added to every exposed metric, in the form name=value. Can be repeated.",
+		).Strings()
+		relabelConfigFile = kingpin.Flag(
+			"metric.relabel-config-file",
+			"YAML file with metric_relabel_configs rules, as in Prometheus, applied to the exposed metrics.",
+		).String()
+		namingScheme = kingpin.Flag(
+			"metrics.naming-scheme",
+			"Names of the metrics whose legacy names lack or misplace their unit: legacy, standard, or both during a migration.",
+		).Default(namingLegacy).Enum(namingLegacy, namingBoth, namingStandard)
+		configFile = kingpin.Flag(
+			"config.file",
+			"YAML file with the settings of the collectors, applied to the --collector.* flags not given on the command line. Reloaded on SIGHUP and /-/reload.",
+		).String()
+		viewsFile = kingpin.Flag(
+			"web.views-file",
+			"YAML file defining named views of the metrics, each served at <web.telemetry-path>/<view>.",
+		).String()
+		tenantsFile = kingpin.Flag(
+			"web.tenants-file",
+			"YAML file mapping devices to tenants. The metrics of the devices of all tenants, labeled with their tenant, are served at <web.telemetry-path>/tenants, and those of each tenant at <web.telemetry-path>/tenants/<tenant>.",
+		).String()
+		heartbeatInterval = kingpin.Flag(
+			"heartbeat.interval",
+			"Interval at which node_heartbeat_timestamp_seconds is updated, independently of scrapes. Use 0 to disable.",
+		).Default("0s").Duration()
+		heartbeatPushURL = kingpin.Flag(
+			"heartbeat.push-url",
+			"URL to POST every heartbeat to in the text exposition format, e.g. a Pushgateway or a dead man's switch service.",
+		).String()
+		gossipListenAddress = kingpin.Flag(
+			"gossip.listen-address",
+			"UDP address to exchange heartbeats with peer node_exporters on, e.g. :9101, exposed as node_peer_up. Gossip is disabled if empty.",
+		).String()
+		gossipPeers = kingpin.Flag(
+			"gossip.peer",
+			"UDP address of a peer node_exporter to send heartbeats to, in the form host:port. Peers of peers are learned from their heartbeats. Can be repeated.",
+		).Strings()
+		gossipInterval = kingpin.Flag(
+			"gossip.interval",
+			"Interval at which heartbeats are sent to the peers.",
+		).Default("5s").Duration()
+		gossipPeerTimeout = kingpin.Flag(
+			"gossip.peer-timeout",
+			"Time after the last heartbeat of a peer after which it is considered down.",
+		).Default("30s").Duration()
+		gossipName = kingpin.Flag(
+			"gossip.name",
+			"Name of this node_exporter in the heartbeats, the peer label of its peers. The hostname if empty.",
+		).String()
+		gossipKeyFile = kingpin.Flag(
+			"gossip.key-file",
+			"File with a key shared by the peers to authenticate the heartbeats with. Required to learn peers from the heartbeats. Heartbeats are not authenticated, and only the configured peers are tracked, if empty.",
+		).String()
+		enableReload = kingpin.Flag(
+			"web.enable-reload",
+			"Enable POST /-/reload, which applies configuration changes like SIGHUP.",
+		).Bool()
+		enableQuit = kingpin.Flag(
+			"web.enable-quit",
+			"Enable POST /-/quit, which makes node_exporter exit, e.g. to be restarted by its service manager with a new binary.",
+		).Bool()
+		dashboardEnabled = kingpin.Flag(
+			"web.dashboard",
+			"Serve a read-only HTML dashboard of key metrics with their recent history at /dashboard.",
+		).Bool()
+		dashboardInterval = kingpin.Flag(
+			"web.dashboard.interval",
+			"Interval at which the dashboard samples its metrics.",
+		).Default("15s").Duration()
+		dashboardRetention = kingpin.Flag(
+			"web.dashboard.retention",
+			"History kept in memory for the dashboard.",
+		).Default("1h").Duration()
+		tracingEndpoint = kingpin.Flag(
+			"tracing.otlp-endpoint",
+			"OTLP/HTTP traces endpoint to export a trace of every sampled scrape to, with a span per collector, e.g. http://localhost:4318/v1/traces. Tracing is disabled if empty.",
+		).String()
+		tracingSamplingRatio = kingpin.Flag(
+			"tracing.sampling-ratio",
+			"Ratio of the scrapes to trace, between 0 and 1. Scrapes with a W3C traceparent header follow its sampling decision instead.",
+		).Default("1").Float64()
+		pushEndpoint = kingpin.Flag(
+			"push.otlp-endpoint",
+			"OTLP/HTTP metrics endpoint to push the metrics to every --push.interval, e.g. http://localhost:4318/v1/metrics, for hosts that can't be scraped. Pushing is disabled if empty.",
+		).String()
+		pushInterval = kingpin.Flag(
+			"push.interval",
+			"Interval at which the metrics are pushed to --push.otlp-endpoint and with --push.remote-write-config-file.",
+		).Default("1m").Duration()
+		pushHeaders = kingpin.Flag(
+			"push.otlp-header",
+			"Header added to the pushes to --push.otlp-endpoint, in the form 'Name: value', e.g. for authentication. Can be repeated.",
+		).Strings()
+		pushRemoteWriteConfigFile = kingpin.Flag(
+			"push.remote-write-config-file",
+			"YAML file with the URL and HTTP client settings of a Prometheus remote write endpoint to push the metrics to every --push.interval. Remote write is disabled if empty.",
+		).String()
+		socketMode = kingpin.Flag(
+			"web.socket-mode",
+			"Permissions of the unix sockets listened on with --web.listen-address=unix:///path/to/socket, in octal.",
+		).Default("0660").String()
+		socketGroup = kingpin.Flag(
+			"web.socket-group",
+			"Group owning the unix sockets listened on, e.g. the group of a local scrape proxy. The group of node_exporter if empty.",
+		).String()
+		clientCertAllowedNames = kingpin.Flag(
+			"web.client-cert-allowed-name",
+			"Regular expression of the common name or subject alternative names of the client certificates allowed to make requests, e.g. spiffe://example.org/ns/monitoring/.*. Requires client certificates to be verified by --web.config.file. Can be repeated.",
+		).Strings()
+		jwtJWKSURL = kingpin.Flag(
+			"web.jwt.jwks-url",
+			"HTTPS URL of the JWKS with the keys signing the bearer tokens required to scrape --web.telemetry-path, its views, /dashboard and /api/v1/collectors, e.g. the jwks_uri of an OIDC provider. Tokens are not required if empty.",
+		).String()
+		jwtIssuer = kingpin.Flag(
+			"web.jwt.issuer",
+			"Issuer the bearer tokens must have in their iss claim. Not checked if empty.",
+		).String()
+		jwtAudience = kingpin.Flag(
+			"web.jwt.audience",
+			"Audience the bearer tokens must have in their aud claim. Not checked if empty.",
+		).String()
+		jwtJWKSRefreshInterval = kingpin.Flag(
+			"web.jwt.jwks-refresh-interval",
+			"Interval at which the JWKS is fetched again. It is also fetched when a token is signed with an unknown key.",
+		).Default("1h").Duration()
 		toolkitFlags = kingpinflag.AddFlags(kingpin.CommandLine, ":9100")
+
+		checkConfigCmd = kingpin.Command("check-config", "Validate the configuration, print the enabled collectors and the files they read, and exit.")
 	)
 
+	// Serving metrics is the default when no command is given.
+	kingpin.Command("serve", "Serve metrics.").Default().Hidden()
+
 	promlogConfig := &promlog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promlogConfig)
 	kingpin.Version(version.Print("node_exporter"))
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := promlog.New(promlogConfig)
 
 	if *disableDefaultCollectors {
 		collector.DisableDefaultCollectors()
 	}
+	if err := collector.LoadPlugins(logger); err != nil {
+		level.Error(logger).Log("msg", "Error loading plugins", "err", err)
+		os.Exit(1)
+	}
+	configErr := collector.LoadConfig(*configFile, os.Args[1:])
+	settings := serverSettings{
+		allowedCIDRs:           *allowedCIDRs,
+		trustedProxyCIDRs:      *trustedProxyCIDRs,
+		clientCertAllowedNames: *clientCertAllowedNames,
+		jwtJWKSURL:             *jwtJWKSURL,
+		compression:            *compression,
+		gzipLevel:              *gzipLevel,
+		socketMode:             *socketMode,
+		heartbeatInterval:      *heartbeatInterval,
+		heartbeatPushURL:       *heartbeatPushURL,
+		dashboardEnabled:       *dashboardEnabled,
+		dashboardInterval:      *dashboardInterval,
+		gossipListenAddress:    *gossipListenAddress,
+		gossipPeers:            *gossipPeers,
+		gossipInterval:         *gossipInterval,
+		gossipPeerTimeout:      *gossipPeerTimeout,
+		gossipName:             *gossipName,
+		gossipKeyFile:          *gossipKeyFile,
+		pushInterval:           *pushInterval,
+		remoteWriteConfigFile:  *pushRemoteWriteConfigFile,
+	}
+	if command == checkConfigCmd.FullCommand() {
+		if !checkConfig(os.Stdout, *configFile, configErr, *toolkitFlags.WebConfigFile, *viewsFile, *tenantsFile, *extraLabelFlags, *relabelConfigFile, settings, logger) {
+			os.Exit(1)
+		}
+		return
+	}
+	if configErr != nil {
+		level.Error(logger).Log("msg", "Error loading config file", "err", configErr)
+		os.Exit(1)
+	}
+	settings.validate(func(what string, err error) {
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid "+what, "err", err)
+			os.Exit(1)
+		}
+	})
+	for _, dir := range collector.SnapshotDirs() {
+		if err := collector.VerifySnapshot(dir); err != nil {
+			level.Error(logger).Log("msg", "Error verifying snapshot", "path", dir, "err", err)
+			os.Exit(1)
+		}
+		level.Warn(logger).Log("msg", "Serving the metrics of a snapshot instead of the running host", "path", dir)
+	}
 	level.Info(logger).Log("msg", "Starting node_exporter", "version", version.Info())
 	level.Info(logger).Log("msg", "Build context", "build_context", version.BuildContext())
 	if user, err := user.Current(); err == nil && user.Uid == "0" {
 		level.Warn(logger).Log("msg", "Node Exporter is running as root user. This exporter is designed to run as unprivileged user, root is not required.")
 	}
+	extraLabels, err := parseExtraLabels(*extraLabelFlags)
+	if err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	var relabelConfigs []*relabelConfig
+	if *relabelConfigFile != "" {
+		relabelConfigs, err = loadRelabelConfigs(*relabelConfigFile)
+		if err != nil {
+			level.Error(logger).Log("err", err)
+			os.Exit(1)
+		}
+	}
 	runtime.GOMAXPROCS(*maxProcs)
 	level.Debug(logger).Log("msg", "Go MAXPROCS", "procs", runtime.GOMAXPROCS(0))
 
-	http.Handle(*metricsPath, newHandler(!*disableExporterMetrics, *maxRequests, logger))
+	if *heartbeatInterval > 0 {
+		heartbeatCollector.start(context.Background(), *heartbeatInterval, *heartbeatPushURL, logger)
+	}
+
+	if *gossipListenAddress != "" {
+		if err := gossipCollector.start(context.Background(), *gossipListenAddress, *gossipPeers, *gossipInterval, *gossipPeerTimeout, *gossipName, *gossipKeyFile, logger); err != nil {
+			level.Error(logger).Log("msg", "Invalid gossip settings", "err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "Exchanging heartbeats with peers", "address", *gossipListenAddress, "peers", len(*gossipPeers))
+	}
+
+	if err := watchdogCollector.start(context.Background(), logger); err != nil {
+		level.Error(logger).Log("msg", "Invalid systemd watchdog settings", "err", err)
+		os.Exit(1)
+	}
+
+	if *tracingEndpoint != "" {
+		scrapeTracer, err = newOTLPTracer(*tracingEndpoint, *tracingSamplingRatio, logger)
+		if err != nil {
+			level.Error(logger).Log("err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "Exporting scrape traces", "endpoint", *tracingEndpoint, "sampling_ratio", *tracingSamplingRatio)
+	}
+
+	openMetricsEnabled = *enableOpenMetrics
+	scrapeDeadlines.enabled, scrapeDeadlines.offset = *enableScrapeDeadline, *scrapeDeadlineOffset
+	metricsCompressor, err = newCompressor(*compression, *gzipLevel)
+	if err != nil {
+		level.Error(logger).Log("msg", "Invalid compression settings", "err", err)
+		os.Exit(1)
+	}
+	metricsHandler := newHandler(!*disableExporterMetrics, extraLabels, relabelConfigs, *namingScheme, logger)
+	scrapeLimitCollector.configure(*maxRequests, *rateLimit, *rateLimitBurst)
+	if err := jwtCollector.configure(*jwtJWKSURL, *jwtIssuer, *jwtAudience, *jwtJWKSRefreshInterval, logger); err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	if err := sourceIPCollector.configure(*allowedCIDRs, *trustedProxyCIDRs, *trustedProxyHeader, logger); err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	http.Handle(*metricsPath, scrapeLimitCollector.wrap(jwtCollector.wrap(metricsHandler)))
+	if *pushEndpoint != "" {
+		if err := otlpPushCollector.start(context.Background(), *pushEndpoint, *pushInterval, *pushHeaders, metricsHandler.currentUnfilteredGatherer, logger); err != nil {
+			level.Error(logger).Log("msg", "Invalid push settings", "err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "Pushing metrics", "endpoint", *pushEndpoint, "interval", *pushInterval)
+	}
+	if *pushRemoteWriteConfigFile != "" {
+		rwConfig, err := loadRemoteWriteConfig(*pushRemoteWriteConfigFile)
+		if err == nil {
+			err = remoteWriteCollector.start(context.Background(), rwConfig, *pushInterval, metricsHandler.currentUnfilteredGatherer, logger)
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid remote write settings", "err", err)
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "Pushing metrics with remote write", "url", rwConfig.URL, "interval", *pushInterval)
+	}
+	http.Handle("/api/v1/collectors", scrapeLimitCollector.wrap(jwtCollector.wrap(collectorsAPIHandler(collector.Collectors, collectorMetricFamilies(logger), logger))))
+	http.Handle("/healthz", healthzHandler(collector.SelfCheck, collector.CollectorStatuses, logger))
+	if *enableReload {
+		http.Handle("/-/reload", jwtCollector.wrap(reloadHandler(*toolkitFlags.WebConfigFile, logger)))
+	}
+	handleReloadSignals(*toolkitFlags.WebConfigFile, logger)
+	if *enableQuit {
+		quit := make(chan struct{})
+		http.Handle("/-/quit", jwtCollector.wrap(quitHandler(quit)))
+		handleQuit(quit, logger)
+	}
+	landingLinks := []web.LandingLinks{
+		{
+			Address: *metricsPath,
+			Text:    "Metrics",
+		},
+		{
+			Address: "/healthz",
+			Text:    "Health",
+		},
+	}
+	if *dashboardEnabled {
+		d := newDashboard(*dashboardInterval, *dashboardRetention, logger)
+		d.start(context.Background())
+		http.Handle("/dashboard", jwtCollector.wrap(d))
+		landingLinks = append(landingLinks, web.LandingLinks{
+			Address: "/dashboard",
+			Text:    "Dashboard",
+		})
+	}
+	if *viewsFile != "" {
+		views, err := loadViewsConfig(*viewsFile)
+		if err != nil {
+			level.Error(logger).Log("err", err)
+			os.Exit(1)
+		}
+		for _, name := range sortedViewNames(views) {
+			view := views[name]
+			h, err := newHandlerForView(view, !*disableExporterMetrics, extraLabels, relabelConfigs, *namingScheme, logger)
+			if err != nil {
+				level.Error(logger).Log("msg", "Couldn't create handler for view", "view", name, "err", err)
+				os.Exit(1)
+			}
+			path := strings.TrimSuffix(*metricsPath, "/") + "/" + name
+			http.Handle(path, scrapeLimitCollector.wrap(jwtCollector.wrapView(&viewHandler{view: view, handler: h})))
+			level.Info(logger).Log("msg", "Serving metrics view", "view", name, "path", path)
+			landingLinks = append(landingLinks, web.LandingLinks{
+				Address: path,
+				Text:    "Metrics view " + name,
+			})
+		}
+	}
+	if *tenantsFile != "" {
+		tenants, err := loadTenantsConfig(*tenantsFile)
+		if err == nil {
+			err = handleTenantViews(tenants, strings.TrimSuffix(*metricsPath, "/")+"/tenants", !*disableExporterMetrics, extraLabels, relabelConfigs, *namingScheme, logger)
+		}
+		if err != nil {
+			level.Error(logger).Log("err", err)
+			os.Exit(1)
+		}
+		landingLinks = append(landingLinks, web.LandingLinks{
+			Address: strings.TrimSuffix(*metricsPath, "/") + "/tenants",
+			Text:    "Metrics of tenant devices",
+		})
+	}
 	if *metricsPath != "/" {
 		landingConfig := web.LandingConfig{
 			Name:        "Node Exporter",
 			Description: "Prometheus Node Exporter",
 			Version:     version.Info(),
-			Links: []web.LandingLinks{
-				{
-					Address: *metricsPath,
-					Text:    "Metrics",
-				},
-			},
+			Links:       landingLinks,
 		}
 		landingPage, err := web.NewLandingPage(landingConfig)
 		if err != nil {
@@ -219,8 +739,38 @@ func main() {
 		http.Handle("/", landingPage)
 	}
 
-	server := &http.Server{}
-	if err := web.ListenAndServe(server, toolkitFlags, logger); err != nil {
+	listeners, err := loadListenersConfig(*toolkitFlags.WebConfigFile)
+	if err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	mode, err := parseSocketMode(*socketMode)
+	if err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	perms := socketPermissions{mode: mode, group: *socketGroup}
+	if err := clientCertCollector.configure(*clientCertAllowedNames, logger); err != nil {
+		level.Error(logger).Log("err", err)
+		os.Exit(1)
+	}
+	rootHandler := sourceIPCollector.wrap(clientCertCollector.wrap(http.DefaultServeMux))
+	notifyReady(logger)
+	if len(listeners) > 0 {
+		if *toolkitFlags.WebSystemdSocket {
+			level.Error(logger).Log("msg", "--web.systemd-socket cannot be combined with listeners in --web.config.file, use systemd://name listener addresses instead")
+			os.Exit(1)
+		}
+		level.Info(logger).Log("msg", "Listening on the listeners of the web config, ignoring --web.listen-address", "listeners", len(listeners))
+		err = serveListeners(listeners, rootHandler, perms, logger)
+	} else if !*toolkitFlags.WebSystemdSocket && hasUnixSocket(*toolkitFlags.WebListenAddresses) {
+		server := &http.Server{Handler: rootHandler}
+		err = serveAddresses(server, *toolkitFlags.WebListenAddresses, perms, toolkitFlags, logger)
+	} else {
+		server := &http.Server{Handler: rootHandler}
+		err = web.ListenAndServe(server, toolkitFlags, logger)
+	}
+	if err != nil {
 		level.Error(logger).Log("err", err)
 		os.Exit(1)
 	}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -34,6 +35,16 @@ const (
 	address = "localhost:19100"
 )
 
+func TestExcludeCollectors(t *testing.T) {
+	collectors := []string{"cpu", "filesystem", "meminfo", "netdev"}
+	if got, want := excludeCollectors(collectors, []string{"filesystem", "unknown"}), []string{"cpu", "meminfo", "netdev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := excludeCollectors(collectors, collectors); len(got) != 0 {
+		t.Errorf("excluding all collectors left %v", got)
+	}
+}
+
 func TestFileDescriptorLeak(t *testing.T) {
 	if _, err := os.Stat(binary); err != nil {
 		t.Skipf("node_exporter binary not available, try to run `make build` first: %s", err)
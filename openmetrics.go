@@ -0,0 +1,241 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// openMetricsEnabled is set by --web.openmetrics.
+var openMetricsEnabled bool
+
+// openMetricsStateSets are the gauges exposed as OpenMetrics statesets, with
+// the label holding their state. OpenMetrics names that label after the
+// metric.
+var openMetricsStateSets = map[string]string{
+	"node_connectivity_state": "state",
+	"node_md_state":           "state",
+	"node_platform_profile":   "profile",
+	"node_systemd_unit_state": "state",
+}
+
+// processStartTime is the created timestamp of the counters of node_exporter
+// itself.
+var processStartTime = time.Now()
+
+// openMetricsHandler serves the metrics of g in the OpenMetrics text format
+// to scrapers asking for it in their Accept header, and passes the other
+// scrapes on to next. Unlike the encoding of client_golang, it exposes
+// *_info gauges as info metrics, the gauges of openMetricsStateSets as
+// statesets and _created timestamps of counters.
+func openMetricsHandler(g prometheus.Gatherer, next http.Handler, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mfs, err := g.Gather()
+		if err != nil {
+			level.Error(logger).Log("msg", "error gathering metrics", "err", err)
+			if len(mfs) == 0 {
+				http.Error(w, "An error has occurred while serving metrics:\n\n"+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		if err := writeOpenMetrics(w, mfs); err != nil {
+			level.Error(logger).Log("msg", "error encoding metrics", "err", err)
+		}
+	})
+}
+
+// writeOpenMetrics writes metric families in the OpenMetrics text format.
+// Counters without a created timestamp get the boot time of the node,
+// exposed as node_boot_time_seconds, or the start time of node_exporter for
+// the counters about node_exporter itself.
+func writeOpenMetrics(w io.Writer, mfs []*dto.MetricFamily) error {
+	bw := bufio.NewWriter(w)
+	bootTime := bootTimeOf(mfs)
+	for _, mf := range mfs {
+		if label, ok := openMetricsStateSets[mf.GetName()]; ok && isStateSet(mf, label) {
+			writeOpenMetricsStateSet(bw, mf, label)
+			continue
+		}
+		if isInfo(mf) {
+			writeOpenMetricsInfo(bw, mf)
+			continue
+		}
+		if _, err := expfmt.MetricFamilyToOpenMetrics(bw, withCreatedTimestamps(mf, bootTime), expfmt.WithCreatedLines()); err != nil {
+			return err
+		}
+	}
+	if _, err := expfmt.FinalizeOpenMetrics(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// bootTimeOf returns the boot time of the node if it is among the metric
+// families.
+func bootTimeOf(mfs []*dto.MetricFamily) time.Time {
+	for _, mf := range mfs {
+		if mf.GetName() == "node_boot_time_seconds" && len(mf.GetMetric()) > 0 {
+			sec, frac := math.Modf(mf.GetMetric()[0].GetGauge().GetValue())
+			return time.Unix(int64(sec), int64(frac*1e9))
+		}
+	}
+	return time.Time{}
+}
+
+// withCreatedTimestamps returns a counter family with created timestamps
+// for the counters that lack one. Other families are returned as is.
+func withCreatedTimestamps(mf *dto.MetricFamily, bootTime time.Time) *dto.MetricFamily {
+	if mf.GetType() != dto.MetricType_COUNTER {
+		return mf
+	}
+	var created time.Time
+	for _, prefix := range []string{"go_", "process_", "promhttp_", "node_exporter_", "node_scrape_"} {
+		if strings.HasPrefix(mf.GetName(), prefix) {
+			created = processStartTime
+			break
+		}
+	}
+	if created.IsZero() && strings.HasPrefix(mf.GetName(), "node_") {
+		created = bootTime
+	}
+	if created.IsZero() {
+		return mf
+	}
+
+	mf = proto.Clone(mf).(*dto.MetricFamily)
+	for _, m := range mf.GetMetric() {
+		if m.GetCounter() != nil && m.GetCounter().GetCreatedTimestamp() == nil {
+			m.GetCounter().CreatedTimestamp = timestamppb.New(created)
+		}
+	}
+	return mf
+}
+
+// isInfo returns whether a family is an info metric: a gauge named *_info
+// whose values are all 1.
+func isInfo(mf *dto.MetricFamily) bool {
+	if mf.GetType() != dto.MetricType_GAUGE || !strings.HasSuffix(mf.GetName(), "_info") || mf.GetName() == "_info" {
+		return false
+	}
+	for _, m := range mf.GetMetric() {
+		if m.GetGauge().GetValue() != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isStateSet returns whether a family is a stateset: a gauge whose values
+// are 0 or 1 by the states in label.
+func isStateSet(mf *dto.MetricFamily, label string) bool {
+	if mf.GetType() != dto.MetricType_GAUGE {
+		return false
+	}
+	for _, m := range mf.GetMetric() {
+		if v := m.GetGauge().GetValue(); v != 0 && v != 1 {
+			return false
+		}
+		hasState := false
+		for _, lp := range m.GetLabel() {
+			switch lp.GetName() {
+			case label:
+				hasState = true
+			case mf.GetName():
+				return false
+			}
+		}
+		if !hasState {
+			return false
+		}
+	}
+	return true
+}
+
+func writeOpenMetricsInfo(w *bufio.Writer, mf *dto.MetricFamily) {
+	writeOpenMetricsMetadata(w, strings.TrimSuffix(mf.GetName(), "_info"), "info", mf.GetHelp())
+	for _, m := range mf.GetMetric() {
+		writeOpenMetricsSample(w, mf.GetName(), m.GetLabel(), 1, m.TimestampMs)
+	}
+}
+
+func writeOpenMetricsStateSet(w *bufio.Writer, mf *dto.MetricFamily, label string) {
+	writeOpenMetricsMetadata(w, mf.GetName(), "stateset", mf.GetHelp())
+	for _, m := range mf.GetMetric() {
+		labels := make([]*dto.LabelPair, 0, len(m.GetLabel()))
+		state := ""
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == label {
+				state = lp.GetValue()
+				continue
+			}
+			labels = append(labels, lp)
+		}
+		labels = append(labels, &dto.LabelPair{Name: proto.String(mf.GetName()), Value: proto.String(state)})
+		writeOpenMetricsSample(w, mf.GetName(), labels, m.GetGauge().GetValue(), m.TimestampMs)
+	}
+}
+
+func writeOpenMetricsMetadata(w *bufio.Writer, name, typ, help string) {
+	if help != "" {
+		w.WriteString("# HELP " + name + " " + escapeOpenMetrics(help) + "\n")
+	}
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func writeOpenMetricsSample(w *bufio.Writer, name string, labels []*dto.LabelPair, value float64, timestampMs *int64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, lp := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(lp.GetName() + `="` + escapeOpenMetrics(lp.GetValue()) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64))
+	if timestampMs != nil {
+		w.WriteString(" " + strconv.FormatFloat(float64(*timestampMs)/1000, 'f', -1, 64))
+	}
+	w.WriteByte('\n')
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeOpenMetrics(s string) string {
+	return openMetricsEscaper.Replace(s)
+}
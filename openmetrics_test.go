@@ -0,0 +1,102 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// constCollector exposes fixed metrics.
+type constCollector []prometheus.Metric
+
+func (c constCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
+
+func TestOpenMetricsHandler(t *testing.T) {
+	defer func(start time.Time) { processStartTime = start }(processStartTime)
+	processStartTime = time.Unix(1700000100, 0)
+
+	gauge := func(name string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(name, "Test "+name+".", labels, nil)
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(constCollector{
+		prometheus.MustNewConstMetric(gauge("node_boot_time_seconds"), prometheus.GaugeValue, 1700000000),
+		prometheus.MustNewConstMetric(gauge("node_uname_info", "release"), prometheus.GaugeValue, 1, "6.1.0"),
+		prometheus.MustNewConstMetric(gauge("node_md_state", "device", "state"), prometheus.GaugeValue, 1, "md0", "active"),
+		prometheus.MustNewConstMetric(gauge("node_md_state", "device", "state"), prometheus.GaugeValue, 0, "md0", "inactive"),
+		prometheus.MustNewConstMetric(gauge("node_intr_total"), prometheus.CounterValue, 42),
+		prometheus.MustNewConstMetric(gauge("node_scrape_collector_panics_total"), prometheus.CounterValue, 0),
+	})
+	handler := openMetricsHandler(reg, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), nil)
+
+	scrape := func(accept string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Header().Get("Content-Type"), string(body)
+	}
+
+	contentType, body := scrape("application/openmetrics-text;version=1.0.0")
+	if !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Fatalf("got content type %q, want OpenMetrics", contentType)
+	}
+	want := `# HELP node_boot_time_seconds Test node_boot_time_seconds.
+# TYPE node_boot_time_seconds gauge
+node_boot_time_seconds 1.7e+09
+# HELP node_intr Test node_intr_total.
+# TYPE node_intr counter
+node_intr_total 42.0
+node_intr_created 1.7e+09
+# HELP node_md_state Test node_md_state.
+# TYPE node_md_state stateset
+node_md_state{device="md0",node_md_state="active"} 1
+node_md_state{device="md0",node_md_state="inactive"} 0
+# HELP node_scrape_collector_panics Test node_scrape_collector_panics_total.
+# TYPE node_scrape_collector_panics counter
+node_scrape_collector_panics_total 0.0
+node_scrape_collector_panics_created 1.7000001e+09
+# HELP node_uname Test node_uname_info.
+# TYPE node_uname info
+node_uname_info{release="6.1.0"} 1
+# EOF
+`
+	if body != want {
+		t.Errorf("got\n%s\nwant\n%s", body, want)
+	}
+
+	// Other scrapers are served the Prometheus text format as before.
+	contentType, body = scrape("text/plain")
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Fatalf("got content type %q, want text format", contentType)
+	}
+	if !strings.Contains(body, `node_md_state{device="md0",state="active"} 1`) {
+		t.Errorf("text format changed:\n%s", body)
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+)
+
+// otlpTemporalityCumulative is the OTLP aggregation temporality of
+// Prometheus counters and histograms.
+const otlpTemporalityCumulative = 2
+
+var (
+	otlpPushFailuresDesc = prometheus.NewDesc(
+		"node_exporter_otlp_push_failures_total",
+		"Number of pushes to --push.otlp-endpoint that failed.",
+		nil, nil,
+	)
+	otlpPushLastSuccessDesc = prometheus.NewDesc(
+		"node_exporter_otlp_push_last_success_timestamp_seconds",
+		"Unix time of the last successful push to --push.otlp-endpoint.",
+		nil, nil,
+	)
+)
+
+// otlpPusher pushes the metrics of node_exporter at a fixed interval to an
+// OpenTelemetry collector using OTLP over HTTP with JSON encoding, for hosts
+// that can't be scraped.
+type otlpPusher struct {
+	endpoint string
+	headers  http.Header
+	client   *http.Client
+	resource []otlpKeyValue
+	// started is the start time of the cumulative counters.
+	started time.Time
+	logger  log.Logger
+
+	mtx         sync.Mutex
+	failures    float64
+	lastSuccess time.Time
+}
+
+// otlpPushCollector exposes the state of the push, if enabled, on every
+// handler.
+var otlpPushCollector = &otlpPusher{}
+
+// start pushes the metrics of gatherer every interval until the context is
+// done. headers are added to the requests, in the form "Name: value".
+func (p *otlpPusher) start(ctx context.Context, endpoint string, interval time.Duration, headers []string, gatherer func() prometheus.Gatherer, logger log.Logger) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid push interval %s", interval)
+	}
+	p.headers = http.Header{}
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header %q, expected Name: value", header)
+		}
+		p.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	p.endpoint = endpoint
+	p.client = &http.Client{Timeout: interval}
+	p.resource = otlpResourceAttributes()
+	p.started = time.Now()
+	p.logger = logger
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			begin := time.Now()
+			err := p.push(ctx, gatherer())
+			pushDurations.WithLabelValues("otlp").Observe(time.Since(begin).Seconds())
+			if err != nil {
+				level.Warn(p.logger).Log("msg", "Failed to push metrics", "endpoint", p.endpoint, "err", err)
+				p.mtx.Lock()
+				p.failures++
+				p.mtx.Unlock()
+			} else {
+				p.mtx.Lock()
+				p.lastSuccess = time.Now()
+				p.mtx.Unlock()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// push gathers the metrics and POSTs them. As with scrapes, the metrics that
+// could be gathered are pushed if some collectors fail.
+func (p *otlpPusher) push(ctx context.Context, gatherer prometheus.Gatherer) error {
+	mfs, err := gatherer.Gather()
+	if err != nil {
+		if len(mfs) == 0 {
+			return err
+		}
+		level.Debug(p.logger).Log("msg", "Pushing partial metrics", "err", err)
+	}
+
+	body, err := json.Marshal(otlpMetricsExportRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: p.resource},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: "node_exporter", Version: version.Version},
+				Metrics: otlpMetrics(mfs, p.started, time.Now()),
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range p.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (p *otlpPusher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- otlpPushFailuresDesc
+	ch <- otlpPushLastSuccessDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if pushing is
+// disabled.
+func (p *otlpPusher) Collect(ch chan<- prometheus.Metric) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.endpoint == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(otlpPushFailuresDesc, prometheus.CounterValue, p.failures)
+	if !p.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(otlpPushLastSuccessDesc, prometheus.GaugeValue, float64(p.lastSuccess.UnixNano())/1e9)
+	}
+}
+
+// otlpMetrics converts metric families to OTLP metrics. Counters and
+// histograms are cumulative since started. Native histograms are only
+// converted through their classic buckets, if any.
+func otlpMetrics(mfs []*dto.MetricFamily, started, now time.Time) []otlpMetric {
+	start, end := unixNano(started), unixNano(now)
+	metrics := make([]otlpMetric, 0, len(mfs))
+	for _, mf := range mfs {
+		m := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+			for _, metric := range mf.Metric {
+				sum.DataPoints = append(sum.DataPoints, otlpNumberDataPoint{
+					Attributes:        otlpLabels(metric.Label),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      end,
+					AsDouble:          otlpDouble(metric.GetCounter().GetValue()),
+				})
+			}
+			m.Sum = sum
+		case dto.MetricType_SUMMARY:
+			summary := &otlpSummary{}
+			for _, metric := range mf.Metric {
+				s := metric.GetSummary()
+				dp := otlpSummaryDataPoint{
+					Attributes:        otlpLabels(metric.Label),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      end,
+					Count:             strconv.FormatUint(s.GetSampleCount(), 10),
+					Sum:               otlpDouble(s.GetSampleSum()),
+				}
+				for _, q := range s.Quantile {
+					dp.QuantileValues = append(dp.QuantileValues, otlpQuantileValue{Quantile: otlpDouble(q.GetQuantile()), Value: otlpDouble(q.GetValue())})
+				}
+				summary.DataPoints = append(summary.DataPoints, dp)
+			}
+			m.Summary = summary
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			histogram := &otlpHistogram{AggregationTemporality: otlpTemporalityCumulative}
+			for _, metric := range mf.Metric {
+				histogram.DataPoints = append(histogram.DataPoints, otlpHistogramPoint(metric, start, end))
+			}
+			m.Histogram = histogram
+		default:
+			gauge := &otlpGauge{}
+			for _, metric := range mf.Metric {
+				value := metric.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = metric.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, otlpNumberDataPoint{
+					Attributes:   otlpLabels(metric.Label),
+					TimeUnixNano: end,
+					AsDouble:     otlpDouble(value),
+				})
+			}
+			m.Gauge = gauge
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// otlpHistogramPoint converts the cumulative buckets of a Prometheus
+// histogram to the per bucket counts of OTLP, the last one counting the
+// observations above the highest bound.
+func otlpHistogramPoint(metric *dto.Metric, start, end string) otlpHistogramDataPoint {
+	h := metric.GetHistogram()
+	dp := otlpHistogramDataPoint{
+		Attributes:        otlpLabels(metric.Label),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      end,
+		Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:               otlpDouble(h.GetSampleSum()),
+	}
+	var previous uint64
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			break
+		}
+		dp.ExplicitBounds = append(dp.ExplicitBounds, otlpDouble(b.GetUpperBound()))
+		dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-previous, 10))
+		previous = b.GetCumulativeCount()
+	}
+	dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+	return dp
+}
+
+func otlpLabels(labels []*dto.LabelPair) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(labels))
+	for _, l := range labels {
+		attributes = append(attributes, stringAttribute(l.GetName(), l.GetValue()))
+	}
+	return attributes
+}
+
+// otlpDouble is a float64 encoded as in the JSON encoding of protobuf, which
+// has strings for the special values JSON numbers can't represent.
+type otlpDouble float64
+
+func (d otlpDouble) MarshalJSON() ([]byte, error) {
+	f := float64(d)
+	switch {
+	case math.IsNaN(f):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(f, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(f, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return json.Marshal(f)
+}
+
+// The types below are the JSON encoding of an OTLP
+// ExportMetricsServiceRequest, limited to the fields set by node_exporter.
+type otlpMetricsExportRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          otlpDouble     `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	// Count and BucketCounts are strings as 64 bit integers are encoded
+	// as strings in the JSON encoding of protobuf.
+	Count          string       `json:"count"`
+	Sum            otlpDouble   `json:"sum"`
+	BucketCounts   []string     `json:"bucketCounts"`
+	ExplicitBounds []otlpDouble `json:"explicitBounds,omitempty"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes        []otlpKeyValue      `json:"attributes,omitempty"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	TimeUnixNano      string              `json:"timeUnixNano"`
+	Count             string              `json:"count"`
+	Sum               otlpDouble          `json:"sum"`
+	QuantileValues    []otlpQuantileValue `json:"quantileValues,omitempty"`
+}
+
+type otlpQuantileValue struct {
+	Quantile otlpDouble `json:"quantile"`
+	Value    otlpDouble `json:"value"`
+}
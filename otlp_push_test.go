@@ -0,0 +1,131 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOTLPPush(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_test_total", Help: "Test counter."}, []string{"device"})
+	counter.WithLabelValues("sda").Add(3)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "node_test_gauge", Help: "Test gauge."})
+	gauge.Set(math.NaN())
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "node_test_seconds", Help: "Test histogram.", Buckets: []float64{1, 2}})
+	for _, v := range []float64{0.5, 1.5, 1.5, 5} {
+		histogram.Observe(v)
+	}
+	reg.MustRegister(counter, gauge, histogram)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &otlpPusher{}
+	if err := p.start(ctx, server.URL, time.Hour, []string{"Authorization: Bearer secret"}, func() prometheus.Gatherer { return reg }, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	var r *http.Request
+	select {
+	case r = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no push received")
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("got Authorization header %q, want the configured one", got)
+	}
+
+	var req struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []map[string]json.RawMessage `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]map[string]json.RawMessage{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		var name string
+		json.Unmarshal(m["name"], &name)
+		metrics[name] = m
+	}
+
+	var sum struct {
+		DataPoints []struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+			AsDouble   float64        `json:"asDouble"`
+		} `json:"dataPoints"`
+		AggregationTemporality int  `json:"aggregationTemporality"`
+		IsMonotonic            bool `json:"isMonotonic"`
+	}
+	if err := json.Unmarshal(metrics["node_test_total"]["sum"], &sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.DataPoints) != 1 || sum.DataPoints[0].AsDouble != 3 || !sum.IsMonotonic || sum.AggregationTemporality != otlpTemporalityCumulative ||
+		sum.DataPoints[0].Attributes[0].Key != "device" || *sum.DataPoints[0].Attributes[0].Value.StringValue != "sda" {
+		t.Errorf("unexpected counter %s", metrics["node_test_total"]["sum"])
+	}
+
+	var gaugeJSON struct {
+		DataPoints []struct {
+			AsDouble string `json:"asDouble"`
+		} `json:"dataPoints"`
+	}
+	if err := json.Unmarshal(metrics["node_test_gauge"]["gauge"], &gaugeJSON); err != nil || gaugeJSON.DataPoints[0].AsDouble != "NaN" {
+		t.Errorf("unexpected gauge %s: %v", metrics["node_test_gauge"]["gauge"], err)
+	}
+
+	var hist struct {
+		DataPoints []struct {
+			Count          string    `json:"count"`
+			BucketCounts   []string  `json:"bucketCounts"`
+			ExplicitBounds []float64 `json:"explicitBounds"`
+		} `json:"dataPoints"`
+	}
+	if err := json.Unmarshal(metrics["node_test_seconds"]["histogram"], &hist); err != nil {
+		t.Fatal(err)
+	}
+	if dp := hist.DataPoints[0]; dp.Count != "4" || !reflect.DeepEqual(dp.BucketCounts, []string{"1", "2", "1"}) || !reflect.DeepEqual(dp.ExplicitBounds, []float64{1, 2}) {
+		t.Errorf("unexpected histogram %s", metrics["node_test_seconds"]["histogram"])
+	}
+}
+
+func TestOTLPPushInvalidHeader(t *testing.T) {
+	p := &otlpPusher{}
+	if err := p.start(context.Background(), "http://localhost", time.Minute, []string{"no value"}, nil, log.NewNopLogger()); err == nil {
+		t.Error("expected an error for a header without a colon")
+	}
+}
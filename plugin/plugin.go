@@ -0,0 +1,168 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin serves collectors built outside of node_exporter to it.
+//
+// A plugin is an executable in the --collector.plugin-dir of node_exporter,
+// which starts it at startup and keeps it running. The plugin calls Serve
+// with its collectors, which node_exporter then manages like its own, with
+// collect[] filtering and per-collector success and duration metrics:
+//
+//	func main() {
+//		plugin.Serve(map[string]plugin.Collector{
+//			"storage_array": newStorageArrayCollector(),
+//		})
+//	}
+//
+// Plugins talk to node_exporter over their standard input and output, so
+// they must not write anything else to their standard output. They may log
+// to their standard error, which node_exporter passes through.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// The protocol between node_exporter and a plugin:
+//
+//  1. node_exporter starts the plugin with CookieKey=CookieValue in its
+//     environment.
+//  2. The plugin writes the handshake line "node_exporter-plugin|<version>|<collectors>",
+//     with the comma separated names of its collectors.
+//  3. node_exporter writes "collect <collector>" lines, to which the plugin
+//     answers with the metrics of the collector in the text format followed
+//     by a "# EOF" line, or with a "# ERROR <message>" line if the collector
+//     failed.
+//  4. node_exporter closes the standard input of the plugin to stop it.
+const (
+	CookieKey       = "NODE_EXPORTER_PLUGIN"
+	CookieValue     = "7d4c2ab0e1f94c0e8b3a6f5d29e1c8b4"
+	ProtocolVersion = 1
+
+	HandshakePrefix = "node_exporter-plugin"
+	CollectRequest  = "collect "
+	EOFLine         = "# EOF"
+	ErrorPrefix     = "# ERROR "
+)
+
+// NameRE matches valid collector names.
+var NameRE = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Collector is the interface of the collectors of a plugin, the same as the
+// one of the collectors of node_exporter.
+type Collector interface {
+	// Update sends the metrics of the collector to ch.
+	Update(ch chan<- prometheus.Metric) error
+}
+
+// Serve serves the collectors to node_exporter until it stops the plugin. It
+// exits the process if the plugin was not started by node_exporter.
+func Serve(collectors map[string]Collector) {
+	if os.Getenv(CookieKey) != CookieValue {
+		fmt.Fprintln(os.Stderr, "This is a node_exporter plugin, it is started by node_exporter from its --collector.plugin-dir.")
+		os.Exit(1)
+	}
+	if err := serve(collectors, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(collectors map[string]Collector, r io.Reader, w io.Writer) error {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		if !NameRE.MatchString(name) {
+			return fmt.Errorf("invalid collector name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%s|%d|%s\n", HandshakePrefix, ProtocolVersion, strings.Join(names, ","))
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	in := bufio.NewScanner(r)
+	for in.Scan() {
+		name, ok := strings.CutPrefix(in.Text(), CollectRequest)
+		if !ok {
+			return fmt.Errorf("unexpected request %q", in.Text())
+		}
+		c, ok := collectors[name]
+		if !ok {
+			fmt.Fprintf(out, "%sunknown collector %q\n", ErrorPrefix, name)
+		} else if err := collect(c, out); err != nil {
+			fmt.Fprintf(out, "%s%s\n", ErrorPrefix, strings.ReplaceAll(err.Error(), "\n", " "))
+		} else {
+			fmt.Fprintln(out, EOFLine)
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return in.Err()
+}
+
+// collect writes the metrics of a collector in the text format. Nothing is
+// written if it fails.
+func collect(c Collector, w io.Writer) error {
+	reg := prometheus.NewPedanticRegistry()
+	uc := &updateCollector{collector: c}
+	if err := reg.Register(uc); err != nil {
+		return err
+	}
+	mfs, err := reg.Gather()
+	if uc.err != nil {
+		return uc.err
+	}
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			return err
+		}
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// updateCollector adapts a Collector to a prometheus.Collector, keeping the
+// error of its update.
+type updateCollector struct {
+	collector Collector
+	err       error
+}
+
+// Describe implements prometheus.Collector. It describes nothing, which makes
+// the collector unchecked, as the metrics of a Collector are not known in
+// advance.
+func (c *updateCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *updateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.err = c.collector.Update(ch)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testCollector struct {
+	err error
+}
+
+func (c testCollector) Update(ch chan<- prometheus.Metric) error {
+	desc := prometheus.NewDesc("node_array_up", "Whether the array is up.", []string{"array"}, nil)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "a")
+	return c.err
+}
+
+func TestServe(t *testing.T) {
+	requests := "collect array\ncollect broken\ncollect missing\n"
+	var out strings.Builder
+	err := serve(map[string]Collector{
+		"array":  testCollector{},
+		"broken": testCollector{err: errors.New("array\nunreachable")},
+	}, strings.NewReader(requests), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `node_exporter-plugin|1|array,broken
+# HELP node_array_up Whether the array is up.
+# TYPE node_array_up gauge
+node_array_up{array="a"} 1
+# EOF
+# ERROR array unreachable
+# ERROR unknown collector "missing"
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestServeInvalidName(t *testing.T) {
+	var out strings.Builder
+	if err := serve(map[string]Collector{"Array": testCollector{}}, strings.NewReader(""), &out); err == nil {
+		t.Error("expected an error for an invalid collector name")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+// Relabel actions, a subset of the Prometheus metric_relabel_configs ones.
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelLabelDrop = "labeldrop"
+	relabelLabelKeep = "labelkeep"
+)
+
+// relabelFile is the format of --metric.relabel-config-file, which follows
+// metric_relabel_configs of the Prometheus scrape config:
+//
+//	metric_relabel_configs:
+//	  - source_labels: [__name__]
+//	    regex: node_interrupts_total
+//	    action: drop
+//	  - regex: queue
+//	    action: labeldrop
+type relabelFile struct {
+	MetricRelabelConfigs []*relabelConfig `yaml:"metric_relabel_configs"`
+}
+
+// relabelConfig is a rule applied to the labels of every exposed metric.
+type relabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+
+	regex *regexp.Regexp
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, setting the defaults of
+// Prometheus.
+func (c *relabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = relabelConfig{Separator: ";", Regex: "(.*)", Replacement: "$1", Action: relabelReplace}
+	type plain relabelConfig
+	return unmarshal((*plain)(c))
+}
+
+func loadRelabelConfigs(path string) ([]*relabelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relabel config file: %w", err)
+	}
+
+	var f relabelFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse relabel config file: %w", err)
+	}
+
+	for i, c := range f.MetricRelabelConfigs {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("invalid relabel config %d: %w", i, err)
+		}
+	}
+	return f.MetricRelabelConfigs, nil
+}
+
+func (c *relabelConfig) validate() error {
+	re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	c.regex = re
+
+	for _, l := range c.SourceLabels {
+		if !model.LabelName(l).IsValid() {
+			return fmt.Errorf("invalid source label %q", l)
+		}
+	}
+
+	switch c.Action {
+	case relabelReplace:
+		if !model.LabelName(c.TargetLabel).IsValid() {
+			return fmt.Errorf("invalid target label %q", c.TargetLabel)
+		}
+		// Renaming would move metrics between families, which may not
+		// agree on type and help.
+		if c.TargetLabel == model.MetricNameLabel {
+			return fmt.Errorf("metric names can't be replaced")
+		}
+	case relabelKeep, relabelDrop:
+	case relabelLabelDrop, relabelLabelKeep:
+		if len(c.SourceLabels) > 0 || c.TargetLabel != "" {
+			return fmt.Errorf("%s only takes a regex", c.Action)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", c.Action)
+	}
+	return nil
+}
+
+// relabel applies the rules to a metric of the given name, returning false
+// if the metric is dropped.
+func relabel(name string, m *dto.Metric, configs []*relabelConfig) bool {
+	labelValue := func(label string) string {
+		if label == model.MetricNameLabel {
+			return name
+		}
+		for _, l := range m.Label {
+			if l.GetName() == label {
+				return l.GetValue()
+			}
+		}
+		return ""
+	}
+
+	for _, c := range configs {
+		values := make([]string, 0, len(c.SourceLabels))
+		for _, l := range c.SourceLabels {
+			values = append(values, labelValue(l))
+		}
+		value := strings.Join(values, c.Separator)
+
+		switch c.Action {
+		case relabelKeep:
+			if !c.regex.MatchString(value) {
+				return false
+			}
+		case relabelDrop:
+			if c.regex.MatchString(value) {
+				return false
+			}
+		case relabelReplace:
+			match := c.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			target := string(c.regex.ExpandString(nil, c.Replacement, value, match))
+			m.Label = setLabel(m.Label, c.TargetLabel, target)
+		case relabelLabelDrop, relabelLabelKeep:
+			labels := m.Label[:0]
+			for _, l := range m.Label {
+				if c.regex.MatchString(l.GetName()) == (c.Action == relabelLabelKeep) {
+					labels = append(labels, l)
+				}
+			}
+			m.Label = labels
+		}
+	}
+	return true
+}
+
+// setLabel sets the value of a label, removing the label if the value is
+// empty like Prometheus does.
+func setLabel(labels []*dto.LabelPair, name, value string) []*dto.LabelPair {
+	kept := labels[:0]
+	for _, l := range labels {
+		if l.GetName() != name {
+			kept = append(kept, l)
+		}
+	}
+	if value == "" {
+		return kept
+	}
+	kept = append(kept, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].GetName() < kept[j].GetName()
+	})
+	return kept
+}
+
+// relabelGatherer applies relabel rules to the metrics of the wrapped
+// Gatherer, dropping the metric families left without metrics.
+type relabelGatherer struct {
+	gatherer prometheus.Gatherer
+	configs  []*relabelConfig
+}
+
+func newRelabelGatherer(gatherer prometheus.Gatherer, configs []*relabelConfig) prometheus.Gatherer {
+	if len(configs) == 0 {
+		return gatherer
+	}
+	return &relabelGatherer{gatherer: gatherer, configs: configs}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	relabeled := mfs[:0]
+	for _, mf := range mfs {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			if relabel(mf.GetName(), m, g.configs) {
+				metrics = append(metrics, m)
+			}
+		}
+		mf.Metric = metrics
+		if len(mf.Metric) > 0 {
+			relabeled = append(relabeled, mf)
+		}
+	}
+	return relabeled, err
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeRelabelConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "relabel.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRelabelGatherer(t *testing.T) {
+	configs, err := loadRelabelConfigs(writeRelabelConfigFile(t, `metric_relabel_configs:
+  - source_labels: [__name__]
+    regex: node_interrupts_total
+    action: drop
+  - source_labels: [__name__, device]
+    regex: 'node_network_.*;lo'
+    action: drop
+  - source_labels: [device]
+    regex: 'eth(\d+)'
+    target_label: port
+  - regex: queue
+    action: labeldrop
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	interrupts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_interrupts_total", Help: "Interrupts."}, []string{"cpu"})
+	interrupts.WithLabelValues("0").Add(1)
+	network := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_network_receive_bytes_total", Help: "Received bytes."}, []string{"device", "queue"})
+	network.WithLabelValues("lo", "0").Add(1)
+	network.WithLabelValues("eth1", "0").Add(2)
+	reg.MustRegister(interrupts, network)
+
+	want := `# HELP node_network_receive_bytes_total Received bytes.
+# TYPE node_network_receive_bytes_total counter
+node_network_receive_bytes_total{device="eth1",port="1"} 2
+`
+	if err := testutil.GatherAndCompare(newRelabelGatherer(reg, configs), strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRelabelConfigsInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"metric_relabel_configs:\n  - regex: '('\n    action: drop\n",
+		"metric_relabel_configs:\n  - action: hashmod\n",
+		"metric_relabel_configs:\n  - source_labels: [a]\n    target_label: __name__\n",
+		"metric_relabel_configs:\n  - source_labels: [a]\n    target_label: 'not valid'\n",
+		"metric_relabel_configs:\n  - source_labels: [a]\n    action: labeldrop\n",
+		"metric_relabel_configs:\n  - unknown: true\n",
+	} {
+		if _, err := loadRelabelConfigs(writeRelabelConfigFile(t, invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+// reload re-reads the configuration files of the collectors and validates
+// the web config. The exporter-toolkit reads the web config and the TLS
+// certificates it refers to on every new connection, so a broken certificate
+// would only show in failing handshakes otherwise.
+func reload(webConfigFile string, logger log.Logger) error {
+	level.Info(logger).Log("msg", "Reloading configuration")
+	if err := collector.Reload(logger); err != nil {
+		return err
+	}
+	if webConfigFile != "" {
+		if err := validateWebConfig(webConfigFile); err != nil {
+			return fmt.Errorf("invalid web config: %w", err)
+		}
+	}
+	level.Info(logger).Log("msg", "Completed reloading configuration")
+	return nil
+}
+
+// handleReloadSignals reloads the configuration on SIGHUP.
+func handleReloadSignals(webConfigFile string, logger log.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reload(webConfigFile, logger); err != nil {
+				level.Error(logger).Log("msg", "Error reloading configuration", "err", err)
+			}
+		}
+	}()
+}
+
+// reloadHandler serves POST /-/reload.
+func reloadHandler(webConfigFile string, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(webConfigFile, logger); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// quitHandler serves POST /-/quit, which makes node_exporter exit after
+// responding.
+func quitHandler(quit chan<- struct{}) http.Handler {
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, "Requesting termination... Goodbye!")
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		once.Do(func() { close(quit) })
+	})
+}
+
+// handleQuit exits once a quit is requested.
+func handleQuit(quit <-chan struct{}, logger log.Logger) {
+	go func() {
+		<-quit
+		level.Warn(logger).Log("msg", "Received termination request via web service, exiting")
+		if _, err := notifySystemd(daemon.SdNotifyStopping); err != nil {
+			level.Warn(logger).Log("msg", "Failed to notify systemd", "err", err)
+		}
+		os.Exit(0)
+	}()
+}
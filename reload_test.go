@@ -0,0 +1,77 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestReloadHandlerValidatesWebConfig(t *testing.T) {
+	webConfigFile := filepath.Join(t.TempDir(), "web-config.yml")
+	h := reloadHandler(webConfigFile, log.NewNopLogger())
+	reload := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/-/reload", nil))
+		return rec.Code
+	}
+
+	if err := os.WriteFile(webConfigFile, []byte("tls_server_config:\n  cert_file: missing.crt\n  key_file: missing.key\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := reload(); code != http.StatusInternalServerError {
+		t.Errorf("missing certificate: got status %d, want %d", code, http.StatusInternalServerError)
+	}
+
+	if err := os.WriteFile(webConfigFile, []byte("http_server_config:\n  http2: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := reload(); code != http.StatusOK {
+		t.Errorf("valid web config: got status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestQuitHandler(t *testing.T) {
+	quit := make(chan struct{})
+	h := quitHandler(quit)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/-/quit", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+	select {
+	case <-quit:
+		t.Fatal("GET requested termination")
+	default:
+	}
+
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/-/quit", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("POST: got status %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+	select {
+	case <-quit:
+	default:
+		t.Fatal("POST did not request termination")
+	}
+}
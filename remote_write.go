@@ -0,0 +1,399 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	remoteWriteSamplesDesc = prometheus.NewDesc(
+		"node_exporter_remote_write_samples_total",
+		"Number of samples pushed with remote write, by result.",
+		[]string{"result"}, nil,
+	)
+	remoteWriteLastSuccessDesc = prometheus.NewDesc(
+		"node_exporter_remote_write_last_success_timestamp_seconds",
+		"Unix time of the last push with remote write whose samples were all accepted.",
+		nil, nil,
+	)
+)
+
+// remoteWriteConfig is the format of --push.remote-write-config-file:
+//
+//	url: https://mimir.example.com/api/v1/push
+//	basic_auth:
+//	  username: node
+//	  password_file: /etc/node_exporter/remote-write-password
+//	tls_config:
+//	  ca_file: /etc/node_exporter/ca.pem
+//	headers:
+//	  X-Scope-OrgID: edge
+//
+// All the settings of the HTTP client of Prometheus are supported.
+type remoteWriteConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Timeout of a request, 30s by default.
+	Timeout model.Duration `yaml:"timeout"`
+	// MaxSamplesPerSend splits the samples of a push into batches, 2000
+	// by default.
+	MaxSamplesPerSend int `yaml:"max_samples_per_send"`
+	// MaxRetries of a batch failing with a recoverable error, 3 by
+	// default. The batch is dropped after the last retry.
+	MaxRetries int `yaml:"max_retries"`
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries, 30ms and 5s by default.
+	MinBackoff model.Duration `yaml:"min_backoff"`
+	MaxBackoff model.Duration `yaml:"max_backoff"`
+
+	HTTPClientConfig config.HTTPClientConfig `yaml:",inline"`
+}
+
+func loadRemoteWriteConfig(path string) (*remoteWriteConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote write config: %w", err)
+	}
+	c := &remoteWriteConfig{
+		Timeout:           model.Duration(30 * time.Second),
+		MaxSamplesPerSend: 2000,
+		MaxRetries:        3,
+		MinBackoff:        model.Duration(30 * time.Millisecond),
+		MaxBackoff:        model.Duration(5 * time.Second),
+		HTTPClientConfig:  config.DefaultHTTPClientConfig,
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse remote write config: %w", err)
+	}
+	if c.URL == "" {
+		return nil, errors.New("remote write config has no url")
+	}
+	if c.MaxSamplesPerSend <= 0 || c.MaxRetries < 0 || c.Timeout <= 0 || c.MinBackoff <= 0 || c.MaxBackoff < c.MinBackoff {
+		return nil, errors.New("invalid remote write queue settings")
+	}
+	c.HTTPClientConfig.SetDirectory(filepath.Dir(path))
+	if err := c.HTTPClientConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid remote write http client config: %w", err)
+	}
+	return c, nil
+}
+
+// remoteWriter pushes the metrics of node_exporter at a fixed interval with
+// the Prometheus remote write protocol, for hosts that can't be scraped. It
+// keeps nothing on disk: batches that can't be sent after retries are
+// dropped.
+type remoteWriter struct {
+	config *remoteWriteConfig
+	client *http.Client
+	logger log.Logger
+
+	mtx         sync.Mutex
+	sent        float64
+	failed      float64
+	lastSuccess time.Time
+}
+
+// remoteWriteCollector exposes the state of remote write, if enabled, on
+// every handler.
+var remoteWriteCollector = &remoteWriter{}
+
+// start pushes the metrics of gatherer every interval until the context is
+// done.
+func (w *remoteWriter) start(ctx context.Context, c *remoteWriteConfig, interval time.Duration, gatherer func() prometheus.Gatherer, logger log.Logger) error {
+	client, err := newRemoteWriteClient(c, interval)
+	if err != nil {
+		return err
+	}
+	w.mtx.Lock()
+	w.config, w.client, w.logger = c, client, logger
+	w.mtx.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			begin := time.Now()
+			w.push(ctx, gatherer(), begin)
+			pushDurations.WithLabelValues("remote_write").Observe(time.Since(begin).Seconds())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// newRemoteWriteClient returns the client pushing to the endpoint of c every
+// interval.
+func newRemoteWriteClient(c *remoteWriteConfig, interval time.Duration) (*http.Client, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid push interval %s", interval)
+	}
+	client, err := config.NewClientFromConfig(c.HTTPClientConfig, "remote_write")
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = time.Duration(c.Timeout)
+	return client, nil
+}
+
+// push gathers the metrics and sends them in batches. As with scrapes, the
+// metrics that could be gathered are pushed if some collectors fail.
+func (w *remoteWriter) push(ctx context.Context, gatherer prometheus.Gatherer, now time.Time) {
+	mfs, err := gatherer.Gather()
+	if err != nil {
+		if len(mfs) == 0 {
+			level.Warn(w.logger).Log("msg", "Failed to gather metrics to push", "err", err)
+			return
+		}
+		level.Debug(w.logger).Log("msg", "Pushing partial metrics", "err", err)
+	}
+
+	series := remoteWriteSeries(mfs, now)
+	allSent := true
+	for len(series) > 0 {
+		n := min(len(series), w.config.MaxSamplesPerSend)
+		batch := series[:n]
+		series = series[n:]
+
+		err := w.send(ctx, batch)
+		w.mtx.Lock()
+		if err != nil {
+			w.failed += float64(len(batch))
+			allSent = false
+		} else {
+			w.sent += float64(len(batch))
+		}
+		w.mtx.Unlock()
+		if err != nil {
+			level.Warn(w.logger).Log("msg", "Dropping samples that could not be pushed", "url", w.config.URL, "samples", len(batch), "err", err)
+		}
+	}
+	if allSent {
+		w.mtx.Lock()
+		w.lastSuccess = time.Now()
+		w.mtx.Unlock()
+	}
+}
+
+// recoverableError is an error after which a request is retried.
+type recoverableError struct {
+	error
+}
+
+// send sends a batch, retrying with exponential backoff on recoverable
+// errors.
+func (w *remoteWriter) send(ctx context.Context, batch []remoteWriteTimeSeries) error {
+	body := snappy.Encode(nil, marshalWriteRequest(batch))
+	backoff := time.Duration(w.config.MinBackoff)
+	for attempt := 0; ; attempt++ {
+		err := w.sendOnce(ctx, body)
+		var recoverable recoverableError
+		if err == nil || !errors.As(err, &recoverable) || attempt >= w.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, time.Duration(w.config.MaxBackoff))
+	}
+}
+
+func (w *remoteWriter) sendOnce(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range w.config.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "node_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// As in Prometheus, server errors and rate limiting are retried,
+	// other client errors are not.
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// Describe implements prometheus.Collector.
+func (w *remoteWriter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- remoteWriteSamplesDesc
+	ch <- remoteWriteLastSuccessDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if remote
+// write is disabled.
+func (w *remoteWriter) Collect(ch chan<- prometheus.Metric) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.config == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(remoteWriteSamplesDesc, prometheus.CounterValue, w.sent, "sent")
+	ch <- prometheus.MustNewConstMetric(remoteWriteSamplesDesc, prometheus.CounterValue, w.failed, "failed")
+	if !w.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(remoteWriteLastSuccessDesc, prometheus.GaugeValue, float64(w.lastSuccess.UnixNano())/1e9)
+	}
+}
+
+type remoteWriteLabel struct {
+	name, value string
+}
+
+// remoteWriteTimeSeries is a series with a single sample.
+type remoteWriteTimeSeries struct {
+	labels    []remoteWriteLabel
+	value     float64
+	timestamp int64
+}
+
+// remoteWriteSeries converts metric families to series the way Prometheus
+// would scrape them, with a sample at now.
+func remoteWriteSeries(mfs []*dto.MetricFamily, now time.Time) []remoteWriteTimeSeries {
+	ts := now.UnixMilli()
+	var series []remoteWriteTimeSeries
+	add := func(name string, labels []*dto.LabelPair, value float64, extra ...string) {
+		s := remoteWriteTimeSeries{value: value, timestamp: ts}
+		s.labels = append(s.labels, remoteWriteLabel{model.MetricNameLabel, name})
+		for _, l := range labels {
+			s.labels = append(s.labels, remoteWriteLabel{l.GetName(), l.GetValue()})
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			s.labels = append(s.labels, remoteWriteLabel{extra[i], extra[i+1]})
+		}
+		sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+		series = append(series, s)
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.Label, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.Label, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.Label, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					add(name, m.Label, q.GetValue(), model.QuantileLabel, formatFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", m.Label, s.GetSampleSum())
+				add(name+"_count", m.Label, float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.Bucket {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						infSeen = true
+					}
+					add(name+"_bucket", m.Label, float64(b.GetCumulativeCount()), model.BucketLabel, formatFloat(b.GetUpperBound()))
+				}
+				if !infSeen {
+					add(name+"_bucket", m.Label, float64(h.GetSampleCount()), model.BucketLabel, "+Inf")
+				}
+				add(name+"_sum", m.Label, h.GetSampleSum())
+				add(name+"_count", m.Label, float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// marshalWriteRequest encodes a prometheus.WriteRequest of the remote write
+// protocol 1.0:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func marshalWriteRequest(series []remoteWriteTimeSeries) []byte {
+	var b, ts, msg []byte
+	for _, s := range series {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			msg = msg[:0]
+			msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+			msg = protowire.AppendString(msg, l.name)
+			msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+			msg = protowire.AppendString(msg, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, msg)
+		}
+		msg = msg[:0]
+		msg = protowire.AppendTag(msg, 1, protowire.Fixed64Type)
+		msg = protowire.AppendFixed64(msg, math.Float64bits(s.value))
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, msg)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+	return b
+}
@@ -0,0 +1,207 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes the series of a remote write request into their
+// labels, in the text format, and values.
+func decodeWriteRequest(t *testing.T, b []byte) map[string]float64 {
+	t.Helper()
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+			n = fn(num, typ, b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+		}
+	}
+
+	series := map[string]float64{}
+	fields(b, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		ts, n := protowire.ConsumeBytes(b)
+		var (
+			labels string
+			value  float64
+		)
+		fields(ts, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				var name, val string
+				fields(msg, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					if num == 1 {
+						name = s
+					} else {
+						val = s
+					}
+					return n
+				})
+				labels += name + "=" + val + ","
+			case 2:
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						v, n := protowire.ConsumeFixed64(b)
+						value = math.Float64frombits(v)
+						return n
+					}
+					return protowire.ConsumeFieldValue(num, typ, b)
+				})
+			}
+			return n
+		})
+		series[labels] = value
+		return n
+	})
+	return series
+}
+
+func TestRemoteWriteSeries(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_test_total", Help: "Test counter."}, []string{"device"})
+	counter.WithLabelValues("sda").Add(3)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "node_test_seconds", Help: "Test histogram.", Buckets: []float64{1}})
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	reg.MustRegister(counter, histogram)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := decodeWriteRequest(t, marshalWriteRequest(remoteWriteSeries(mfs, time.Unix(1000, 0))))
+	want := map[string]float64{
+		"__name__=node_test_total,device=sda,":       3,
+		"__name__=node_test_seconds_bucket,le=1,":    1,
+		"__name__=node_test_seconds_bucket,le=+Inf,": 2,
+		"__name__=node_test_seconds_sum,":            5.5,
+		"__name__=node_test_seconds_count,":          2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRemoteWritePush(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests int
+		received map[string]float64
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		// The first request fails with a recoverable error.
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Scope-OrgID") != "edge" || r.Header.Get("Content-Encoding") != "snappy" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user, pass, _ := r.BasicAuth()
+		if user != "node" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = decodeWriteRequest(t, body)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "remote-write.yml")
+	if err := os.WriteFile(path, []byte(`url: `+server.URL+`
+basic_auth:
+  username: node
+  password: secret
+headers:
+  X-Scope-OrgID: edge
+min_backoff: 1ms
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadRemoteWriteConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "node_test_gauge", Help: "Test gauge."})
+	gauge.Set(42)
+	reg.MustRegister(gauge)
+
+	w := &remoteWriter{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := w.start(ctx, c, time.Hour, func() prometheus.Gatherer { return reg }, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w.mtx.Lock()
+		sent, failed := w.sent, w.failed
+		w.mtx.Unlock()
+		if sent+failed > 0 {
+			if sent != 1 || failed != 0 {
+				t.Fatalf("got %v samples sent and %v failed, want 1 sent", sent, failed)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no samples pushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if requests != 2 {
+		t.Errorf("got %d requests, want a retry after the first one", requests)
+	}
+	if want := map[string]float64{"__name__=node_test_gauge,": 42}; !reflect.DeepEqual(received, want) {
+		t.Errorf("got %v, want %v", received, want)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	scrapeRejectedConcurrency = "concurrency"
+	scrapeRejectedRateLimit   = "rate_limit"
+
+	// scrapeLimiterPruneInterval is how often the token buckets of clients
+	// that stopped scraping are dropped.
+	scrapeLimiterPruneInterval = time.Minute
+)
+
+var scrapesRejectedDesc = prometheus.NewDesc(
+	"node_exporter_scrapes_rejected_total",
+	"Number of scrape requests rejected by --web.max-requests or --web.rate-limit.",
+	[]string{"reason"}, nil,
+)
+
+// scrapeLimiter caps the number of concurrent scrapes over all metrics
+// handlers, including filtered scrapes and views, and optionally the rate of
+// scrapes per client IP with a token bucket. Piled up scrapes of several
+// Prometheus replicas and ad-hoc requests otherwise run the collectors
+// concurrently.
+type scrapeLimiter struct {
+	inFlight chan struct{}
+	rate     float64
+	burst    float64
+
+	mtx      sync.Mutex
+	buckets  map[string]*tokenBucket
+	pruned   time.Time
+	rejected map[string]float64
+}
+
+// tokenBucket holds the scrapes a client can still make.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// scrapeLimitCollector limits the scrapes of every handler.
+var scrapeLimitCollector = &scrapeLimiter{}
+
+// configure sets the maximum number of concurrent scrapes and the rate and
+// burst of scrapes per client. 0 disables the respective limit.
+func (l *scrapeLimiter) configure(maxRequests int, rate float64, burst int) {
+	l.rejected = map[string]float64{}
+	if maxRequests > 0 {
+		l.inFlight = make(chan struct{}, maxRequests)
+		l.rejected[scrapeRejectedConcurrency] = 0
+	}
+	if rate > 0 {
+		l.rejected[scrapeRejectedRateLimit] = 0
+	}
+	l.rate = rate
+	l.burst = math.Max(float64(burst), 1)
+	l.buckets = map[string]*tokenBucket{}
+}
+
+// wrap rejects the scrapes exceeding the limits before passing them on.
+func (l *scrapeLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(clientIP(r), time.Now()) {
+			l.reject(scrapeRejectedRateLimit)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/l.rate))))
+			http.Error(w, "Rate limit of scrape requests reached", http.StatusTooManyRequests)
+			return
+		}
+		if l.inFlight != nil {
+			select {
+			case l.inFlight <- struct{}{}:
+				defer func() { <-l.inFlight }()
+			default:
+				l.reject(scrapeRejectedConcurrency)
+				http.Error(w, "Limit of concurrent requests reached, try again later.", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of a client, if rate limiting is
+// enabled.
+func (l *scrapeLimiter) allow(client string, now time.Time) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if now.Sub(l.pruned) > scrapeLimiterPruneInterval {
+		l.pruned = now
+		for c, b := range l.buckets {
+			if b.refill(now, l.rate, l.burst) >= l.burst {
+				delete(l.buckets, c)
+			}
+		}
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	if b.refill(now, l.rate, l.burst) < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens accrued since the last refill and returns the
+// tokens in the bucket.
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) float64 {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+		b.last = now
+	}
+	return b.tokens
+}
+
+func (l *scrapeLimiter) reject(reason string) {
+	l.mtx.Lock()
+	l.rejected[reason]++
+	l.mtx.Unlock()
+}
+
+// clientIP returns the IP address of the client of a request, which is empty
+// for unix sockets. Behind a trusted proxy it is the address the proxy passed
+// on, see sourceIPFilter.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Describe implements prometheus.Collector.
+func (l *scrapeLimiter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapesRejectedDesc
+}
+
+// Collect implements prometheus.Collector. Only the reasons of the enabled
+// limits are exposed.
+func (l *scrapeLimiter) Collect(ch chan<- prometheus.Metric) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	for reason, count := range l.rejected {
+		ch <- prometheus.MustNewConstMetric(scrapesRejectedDesc, prometheus.CounterValue, count, reason)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScrapeLimiterRate(t *testing.T) {
+	l := &scrapeLimiter{}
+	l.configure(0, 1, 2)
+
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("192.0.2.1", now); got != want {
+			t.Errorf("request %d: got allowed %t, want %t", i, got, want)
+		}
+	}
+	// Clients have their own buckets.
+	if !l.allow("192.0.2.2", now) {
+		t.Error("request of another client was not allowed")
+	}
+	// One token is added per second.
+	if !l.allow("192.0.2.1", now.Add(time.Second)) {
+		t.Error("request after a second was not allowed")
+	}
+	if l.allow("192.0.2.1", now.Add(time.Second)) {
+		t.Error("second request after a second was allowed")
+	}
+
+	// Buckets of clients that stopped scraping are pruned.
+	l.allow("192.0.2.3", now.Add(2*time.Minute))
+	if len(l.buckets) != 1 {
+		t.Errorf("got %d buckets after pruning, want 1", len(l.buckets))
+	}
+}
+
+func TestScrapeLimiterConcurrency(t *testing.T) {
+	l := &scrapeLimiter{}
+	l.configure(1, 0, 0)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := l.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d for a request over the limit, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	<-done
+
+	if got := testutil.ToFloat64(l); got != 1 {
+		t.Errorf("got %v rejected scrapes, want 1", got)
+	}
+}
+
+func TestScrapeLimiterRateLimited(t *testing.T) {
+	l := &scrapeLimiter{}
+	l.configure(0, 0.1, 1)
+	h := l.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != want {
+			t.Errorf("request %d: got status %d, want %d", i, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "10" {
+			t.Errorf("got Retry-After %q, want 10", rec.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestScrapeLimiterMetrics(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		maxRequests int
+		rate        float64
+		want        int
+	}{
+		{name: "no limits", want: 0},
+		{name: "concurrency", maxRequests: 1, want: 1},
+		{name: "rate", rate: 1, want: 1},
+		{name: "both", maxRequests: 1, rate: 1, want: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &scrapeLimiter{}
+			l.configure(tc.maxRequests, tc.rate, 1)
+			if got := testutil.CollectAndCount(l); got != tc.want {
+				t.Errorf("got %d series, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var sourceIPRejectedDesc = prometheus.NewDesc(
+	"node_exporter_source_ip_rejected_total",
+	"Number of requests rejected by --web.allowed-cidrs.",
+	nil, nil,
+)
+
+// clientIPContextKey holds the client address taken from the header of a
+// trusted proxy, so that the scrape limiter applies to the actual client.
+type clientIPContextKey struct{}
+
+// sourceIPFilter only lets scrapes through from allowed networks. Behind
+// trusted proxies, the client address is taken from a header they set, such
+// as X-Forwarded-For.
+type sourceIPFilter struct {
+	allowed        []netip.Prefix
+	trustedProxies []netip.Prefix
+	header         string
+	logger         log.Logger
+
+	mtx      sync.Mutex
+	rejected float64
+}
+
+// sourceIPCollector filters the requests of every handler.
+var sourceIPCollector = &sourceIPFilter{}
+
+// configure sets the allowed networks and the proxies whose header is
+// trusted. Scrapes are not filtered without allowed networks.
+func (f *sourceIPFilter) configure(allowed, trustedProxies []string, header string, logger log.Logger) error {
+	var err error
+	if f.allowed, f.trustedProxies, err = parseSourceIPNetworks(allowed, trustedProxies); err != nil {
+		return err
+	}
+	f.header = header
+	f.logger = logger
+	return nil
+}
+
+// parseSourceIPNetworks parses --web.allowed-cidrs and
+// --web.trusted-proxy-cidrs.
+func parseSourceIPNetworks(allowed, trustedProxies []string) (allowedPrefixes, trustedPrefixes []netip.Prefix, err error) {
+	if allowedPrefixes, err = parseCIDRs(allowed); err != nil {
+		return nil, nil, fmt.Errorf("invalid --web.allowed-cidrs: %w", err)
+	}
+	if trustedPrefixes, err = parseCIDRs(trustedProxies); err != nil {
+		return nil, nil, fmt.Errorf("invalid --web.trusted-proxy-cidrs: %w", err)
+	}
+	return allowedPrefixes, trustedPrefixes, nil
+}
+
+// parseCIDRs parses networks in CIDR notation, each value possibly holding
+// several separated by commas. Single addresses are taken as networks of one
+// address.
+func parseCIDRs(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				addr, err := netip.ParseAddr(s)
+				if err != nil {
+					return nil, err
+				}
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap rejects the requests from addresses outside of the allowed networks
+// with 403. Scrapes over unix sockets are allowed, access to them is
+// controlled by the permissions of the socket.
+func (f *sourceIPFilter) wrap(next http.Handler) http.Handler {
+	if len(f.allowed) == 0 && len(f.trustedProxies) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		addr, forwarded := f.clientAddr(r)
+		if len(f.allowed) > 0 && (!addr.IsValid() || !containsAddr(f.allowed, addr)) {
+			f.mtx.Lock()
+			f.rejected++
+			f.mtx.Unlock()
+			level.Debug(f.logger).Log("msg", "Rejected request from address not allowed", "remote_addr", r.RemoteAddr, "client", addr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if forwarded {
+			r = r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, addr.String()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientAddr returns the address of the client of a request, and whether it
+// was taken from the header of a trusted proxy. The header is read from the
+// right, skipping the addresses added by trusted proxies, as the client can
+// put any address on the left.
+func (f *sourceIPFilter) clientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	peer = peer.Unmap()
+	if f.header == "" || !containsAddr(f.trustedProxies, peer) {
+		return peer, false
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values(f.header) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	addr := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Garbage in the header is not an address to allow.
+			return netip.Addr{}, false
+		}
+		addr = hop.Unmap()
+		if !containsAddr(f.trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, addr != peer
+}
+
+// Describe implements prometheus.Collector.
+func (f *sourceIPFilter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sourceIPRejectedDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed if scrapes are
+// not filtered.
+func (f *sourceIPFilter) Collect(ch chan<- prometheus.Metric) {
+	if len(f.allowed) == 0 {
+		return
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	ch <- prometheus.MustNewConstMetric(sourceIPRejectedDesc, prometheus.CounterValue, f.rejected)
+}
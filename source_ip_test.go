@@ -0,0 +1,98 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSourceIPFilter(t *testing.T) {
+	f := &sourceIPFilter{}
+	if err := f.configure([]string{"10.0.8.0/24,192.0.2.10", "2001:db8::/32"}, []string{"10.1.0.0/16"}, "X-Forwarded-For", log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	var client string
+	h := f.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client = clientIP(r)
+	}))
+
+	for name, test := range map[string]struct {
+		remoteAddr string
+		forwarded  []string
+		want       int
+		wantClient string
+	}{
+		"allowed network":          {"10.0.8.15:4242", nil, http.StatusOK, "10.0.8.15"},
+		"allowed address":          {"192.0.2.10:4242", nil, http.StatusOK, "192.0.2.10"},
+		"allowed IPv6":             {"[2001:db8::1]:4242", nil, http.StatusOK, "2001:db8::1"},
+		"IPv4-mapped IPv6":         {"[::ffff:10.0.8.15]:4242", nil, http.StatusOK, "::ffff:10.0.8.15"},
+		"not allowed":              {"192.0.2.11:4242", nil, http.StatusForbidden, ""},
+		"untrusted header":         {"192.0.2.11:4242", []string{"10.0.8.15"}, http.StatusForbidden, ""},
+		"trusted proxy":            {"10.1.2.3:4242", []string{"10.0.8.15"}, http.StatusOK, "10.0.8.15"},
+		"chained proxies":          {"10.1.2.3:4242", []string{"10.0.8.15, 10.1.5.5", "10.1.6.6"}, http.StatusOK, "10.0.8.15"},
+		"spoofed left":             {"10.1.2.3:4242", []string{"10.0.8.15, 192.0.2.11"}, http.StatusForbidden, ""},
+		"garbage header":           {"10.1.2.3:4242", []string{"unknown"}, http.StatusForbidden, ""},
+		"proxy without header":     {"10.1.2.3:4242", nil, http.StatusForbidden, ""},
+		"only trusted proxies hop": {"10.1.2.3:4242", []string{"10.1.5.5"}, http.StatusForbidden, ""},
+	} {
+		client = ""
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.RemoteAddr = test.remoteAddr
+		for _, value := range test.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", name, rec.Code, test.want)
+		}
+		if client != test.wantClient {
+			t.Errorf("%s: got client %q, want %q", name, client, test.wantClient)
+		}
+	}
+
+	// Unix sockets are not filtered.
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.RemoteAddr = "@"
+	r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/node_exporter.sock", Net: "unix"}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("unix socket: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	want := `# HELP node_exporter_source_ip_rejected_total Number of requests rejected by --web.allowed-cidrs.
+# TYPE node_exporter_source_ip_rejected_total counter
+node_exporter_source_ip_rejected_total 6
+`
+	if err := testutil.CollectAndCompare(f, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	for _, invalid := range []string{"10.0.0.0/33", "example.com", "10.0.0.0/8,nope"} {
+		if _, err := parseCIDRs([]string{invalid}); err == nil {
+			t.Errorf("%q: expected error", invalid)
+		}
+	}
+}
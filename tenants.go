@@ -0,0 +1,210 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+// tenantLabel is the label naming the tenant of a device in the tenant views.
+const tenantLabel = "tenant"
+
+// tenantsConfig is the format of --web.tenants-file:
+//
+//	tenants:
+//	  acme:
+//	    devices:
+//	      device: [nvme1n1, /dev/nvme1n1p1, ens2f0]
+//	      pci_address: ['0000:3b:00.0']
+//	    basic_auth_users:
+//	      acme: $2y$10$...
+//
+// The devices of a tenant map label names to the values identifying its
+// devices in the metrics, e.g. the device label of the diskstats, filesystem
+// and netdev collectors and the pci_address label of the accelerators
+// collector.
+type tenantsConfig struct {
+	Tenants map[string]tenantConfig `yaml:"tenants"`
+}
+
+type tenantConfig struct {
+	Devices map[string][]string `yaml:"devices"`
+	// BasicAuthUsers maps user names to bcrypt hashed passwords allowed to
+	// scrape the view of the tenant.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// tenantDevices maps label names and values to the tenants owning the
+// devices. scope restricts the metrics to the devices of a tenant, those of
+// all tenants if empty.
+type tenantDevices struct {
+	devices map[string]map[string]string
+	scope   string
+}
+
+func loadTenantsConfig(path string) (map[string]tenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var config tenantsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants file: %w", err)
+	}
+	if _, err := newTenantDevices(config.Tenants, ""); err != nil {
+		return nil, err
+	}
+	for name, tenant := range config.Tenants {
+		if !viewNameRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid tenant name %q", name)
+		}
+		for user, hash := range tenant.BasicAuthUsers {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("invalid password hash of user %q of tenant %q: %w", user, name, err)
+			}
+		}
+	}
+	return config.Tenants, nil
+}
+
+// newTenantDevices indexes the devices of the tenants, a device may only
+// belong to one tenant.
+func newTenantDevices(tenants map[string]tenantConfig, scope string) (*tenantDevices, error) {
+	devices := map[string]map[string]string{}
+	for name, tenant := range tenants {
+		for label, values := range tenant.Devices {
+			if !model.LabelName(label).IsValid() || label == tenantLabel {
+				return nil, fmt.Errorf("invalid device label %q of tenant %q", label, name)
+			}
+			if devices[label] == nil {
+				devices[label] = map[string]string{}
+			}
+			for _, value := range values {
+				if owner, ok := devices[label][value]; ok && owner != name {
+					return nil, fmt.Errorf("device %s=%q belongs to tenants %q and %q", label, value, owner, name)
+				}
+				devices[label][value] = name
+			}
+		}
+	}
+	return &tenantDevices{devices: devices, scope: scope}, nil
+}
+
+// tenantViews returns the view of the devices of all tenants, and a view of
+// the devices of each tenant, by tenant name.
+func tenantViews(tenants map[string]tenantConfig) (metricView, map[string]metricView, error) {
+	all, err := newTenantDevices(tenants, "")
+	if err != nil {
+		return metricView{}, nil, err
+	}
+	views := make(map[string]metricView, len(tenants))
+	for name, tenant := range tenants {
+		scoped := *all
+		scoped.scope = name
+		views[name] = metricView{BasicAuthUsers: tenant.BasicAuthUsers, tenants: &scoped}
+	}
+	return metricView{tenants: all}, views, nil
+}
+
+// handleTenantViews serves the view of the devices of all tenants at path,
+// and the view of each tenant at path/<tenant>.
+func handleTenantViews(tenants map[string]tenantConfig, path string, includeExporterMetrics bool, extraLabels prometheus.Labels, relabelConfigs []*relabelConfig, namingScheme string, logger log.Logger) error {
+	all, views, err := tenantViews(tenants)
+	if err != nil {
+		return err
+	}
+	h, err := newHandlerForView(all, includeExporterMetrics, extraLabels, relabelConfigs, namingScheme, logger)
+	if err != nil {
+		return fmt.Errorf("couldn't create handler for tenants: %w", err)
+	}
+	http.Handle(path, scrapeLimitCollector.wrap(jwtCollector.wrapView(&viewHandler{view: all, handler: h})))
+	for _, name := range sortedViewNames(views) {
+		view := views[name]
+		h, err := newHandlerForView(view, includeExporterMetrics, extraLabels, relabelConfigs, namingScheme, logger)
+		if err != nil {
+			return fmt.Errorf("couldn't create handler for tenant %q: %w", name, err)
+		}
+		http.Handle(path+"/"+name, scrapeLimitCollector.wrap(jwtCollector.wrapView(&viewHandler{view: view, handler: h})))
+	}
+	level.Info(logger).Log("msg", "Serving metrics of tenant devices", "path", path, "tenants", len(views))
+	return nil
+}
+
+// tenant returns the tenant owning the device a metric is about, if any.
+func (d *tenantDevices) tenant(m *dto.Metric) (string, bool) {
+	for _, l := range m.Label {
+		if tenant, ok := d.devices[l.GetName()][l.GetValue()]; ok {
+			return tenant, true
+		}
+	}
+	return "", false
+}
+
+// tenantGatherer keeps only the metrics of the wrapped Gatherer about devices
+// of tenants, and labels them with their tenant.
+type tenantGatherer struct {
+	gatherer prometheus.Gatherer
+	devices  *tenantDevices
+}
+
+func newTenantGatherer(gatherer prometheus.Gatherer, devices *tenantDevices) prometheus.Gatherer {
+	if devices == nil {
+		return gatherer
+	}
+	return &tenantGatherer{gatherer: gatherer, devices: devices}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *tenantGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	filtered := mfs[:0]
+	for _, mf := range mfs {
+		metrics := mf.Metric[:0]
+		for _, m := range mf.Metric {
+			tenant, ok := g.devices.tenant(m)
+			if !ok || (g.devices.scope != "" && tenant != g.devices.scope) {
+				continue
+			}
+			labels := m.Label[:0]
+			for _, l := range m.Label {
+				if l.GetName() != tenantLabel {
+					labels = append(labels, l)
+				}
+			}
+			m.Label = append(labels, &dto.LabelPair{Name: proto.String(tenantLabel), Value: proto.String(tenant)})
+			sort.Slice(m.Label, func(i, j int) bool {
+				return m.Label[i].GetName() < m.Label[j].GetName()
+			})
+			metrics = append(metrics, m)
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, err
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testTenantsFile = `tenants:
+  acme:
+    devices:
+      device: [sdb, ens2f0]
+      pci_address: ['0000:3b:00.0']
+    basic_auth_users:
+      acme: $2a$04$/Pc6evmo5TuuzlYX0cvlPeaJIfFbBThelI606FDB3OfPHx6BDEAmi
+  globex:
+    devices:
+      device: [sdc]
+`
+
+func TestLoadTenantsConfig(t *testing.T) {
+	tenants, err := loadTenantsConfig(writeViewsFile(t, testTenantsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 || len(tenants["acme"].BasicAuthUsers) != 1 {
+		t.Errorf("unexpected tenants %v", tenants)
+	}
+
+	for _, invalid := range []string{
+		"tenants:\n  bad/name: {}\n",
+		"tenants:\n  a:\n    devices:\n      device: [sda]\n  b:\n    devices:\n      device: [sda]\n",
+		"tenants:\n  a:\n    devices:\n      tenant: [sda]\n",
+		"tenants:\n  a:\n    devices:\n      bad-label: [sda]\n",
+		"tenants:\n  a:\n    basic_auth_users:\n      u: plaintext\n",
+		"tenants:\n  a:\n    unknown: true\n",
+	} {
+		if _, err := loadTenantsConfig(writeViewsFile(t, invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestTenantGatherer(t *testing.T) {
+	tenants, err := loadTenantsConfig(writeViewsFile(t, testTenantsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, views, err := tenantViews(tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRegistry := func() *prometheus.Registry {
+		reg := prometheus.NewRegistry()
+		disk := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "node_disk_reads_completed_total", Help: "Test metric."}, []string{"device"})
+		for _, device := range []string{"sda", "sdb", "sdc"} {
+			disk.WithLabelValues(device).Add(1)
+		}
+		card := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "node_accelerator_card_info", Help: "Test metric."}, []string{"pci_address", "tenant"})
+		card.WithLabelValues("0000:3b:00.0", "spoofed").Set(1)
+		load := prometheus.NewGauge(prometheus.GaugeOpts{Name: "node_load1", Help: "Test metric."})
+		reg.MustRegister(disk, card, load)
+		return reg
+	}
+
+	want := `# HELP node_accelerator_card_info Test metric.
+# TYPE node_accelerator_card_info gauge
+node_accelerator_card_info{pci_address="0000:3b:00.0",tenant="acme"} 1
+# HELP node_disk_reads_completed_total Test metric.
+# TYPE node_disk_reads_completed_total counter
+node_disk_reads_completed_total{device="sdb",tenant="acme"} 1
+node_disk_reads_completed_total{device="sdc",tenant="globex"} 1
+`
+	if err := testutil.GatherAndCompare(newTenantGatherer(newRegistry(), all.tenants), strings.NewReader(want)); err != nil {
+		t.Errorf("all tenants: %s", err)
+	}
+
+	want = `# HELP node_disk_reads_completed_total Test metric.
+# TYPE node_disk_reads_completed_total counter
+node_disk_reads_completed_total{device="sdc",tenant="globex"} 1
+`
+	if err := testutil.GatherAndCompare(newTenantGatherer(newRegistry(), views["globex"].tenants), strings.NewReader(want)); err != nil {
+		t.Errorf("globex: %s", err)
+	}
+	if len(views["acme"].BasicAuthUsers) != 1 {
+		t.Error("view of acme should require its users")
+	}
+}
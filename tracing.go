@@ -0,0 +1,312 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/version"
+)
+
+// OTLP span kinds and status codes.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpStatusCodeOK     = 1
+	otlpStatusCodeError  = 2
+)
+
+// scrapeTracer exports traces of scrapes if tracing is enabled.
+var scrapeTracer *otlpTracer
+
+// otlpTracer exports a trace of every sampled scrape, with a span per
+// collector, to an OpenTelemetry collector using OTLP over HTTP with JSON
+// encoding.
+type otlpTracer struct {
+	endpoint      string
+	samplingRatio float64
+	client        *http.Client
+	resource      []otlpKeyValue
+	logger        log.Logger
+}
+
+func newOTLPTracer(endpoint string, samplingRatio float64, logger log.Logger) (*otlpTracer, error) {
+	if samplingRatio < 0 || samplingRatio > 1 {
+		return nil, fmt.Errorf("invalid sampling ratio %v, must be between 0 and 1", samplingRatio)
+	}
+	return &otlpTracer{
+		endpoint:      endpoint,
+		samplingRatio: samplingRatio,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		resource:      otlpResourceAttributes(),
+		logger:        logger,
+	}, nil
+}
+
+// otlpResourceAttributes returns the attributes of the OTLP resource
+// describing node_exporter and its host.
+func otlpResourceAttributes() []otlpKeyValue {
+	resource := []otlpKeyValue{
+		stringAttribute("service.name", "node_exporter"),
+		stringAttribute("service.version", version.Version),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		resource = append(resource, stringAttribute("host.name", hostname))
+	}
+	return resource
+}
+
+// scrapeTrace collects the spans of the collectors of a scrape.
+type scrapeTrace struct {
+	traceID  [16]byte
+	parentID [8]byte
+	spanID   [8]byte
+	begin    time.Time
+
+	mtx   sync.Mutex
+	spans []otlpSpan
+}
+
+// start returns the trace of a scrape, or nil if tracing is disabled or the
+// scrape is not sampled. A W3C traceparent header of the request makes the
+// scrape part of the trace of the scraper, which also decides the sampling.
+func (t *otlpTracer) start(r *http.Request) *scrapeTrace {
+	if t == nil {
+		return nil
+	}
+
+	s := &scrapeTrace{begin: time.Now()}
+	traceID, parentID, sampled, err := parseTraceparent(r.Header.Get("traceparent"))
+	switch {
+	case err == nil:
+		if !sampled {
+			return nil
+		}
+		s.traceID, s.parentID = traceID, parentID
+	case mathrand.Float64() >= t.samplingRatio:
+		return nil
+	default:
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// RecordCollector implements collector.SpanRecorder.
+func (s *scrapeTrace) RecordCollector(name string, begin time.Time, duration time.Duration, err error) {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            newSpanID(),
+		ParentSpanID:      hex.EncodeToString(s.spanID[:]),
+		Name:              "collector " + name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(begin),
+		EndTimeUnixNano:   unixNano(begin.Add(duration)),
+		Attributes:        []otlpKeyValue{stringAttribute("collector", name)},
+		Status:            otlpStatus{Code: otlpStatusCodeOK},
+	}
+	if err != nil {
+		span.Status = otlpStatus{Code: otlpStatusCodeError, Message: err.Error()}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.spans = append(s.spans, span)
+}
+
+// finish adds the span of the scrape request to the trace and exports it in
+// the background.
+func (t *otlpTracer) finish(s *scrapeTrace, r *http.Request, status int) {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              "scrape " + r.URL.Path,
+		Kind:              otlpSpanKindServer,
+		StartTimeUnixNano: unixNano(s.begin),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes: []otlpKeyValue{
+			stringAttribute("http.request.method", r.Method),
+			stringAttribute("url.path", r.URL.Path),
+			intAttribute("http.response.status_code", status),
+		},
+		Status: otlpStatus{Code: otlpStatusCodeOK},
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if filters := r.URL.Query()["collect[]"]; len(filters) > 0 {
+		span.Attributes = append(span.Attributes, stringAttribute("collectors", strings.Join(filters, ",")))
+	}
+	if status >= http.StatusBadRequest {
+		span.Status = otlpStatus{Code: otlpStatusCodeError, Message: http.StatusText(status)}
+	}
+
+	s.mtx.Lock()
+	spans := append([]otlpSpan{span}, s.spans...)
+	s.mtx.Unlock()
+
+	go func() {
+		if err := t.export(spans); err != nil {
+			level.Warn(t.logger).Log("msg", "Failed to export scrape trace", "endpoint", t.endpoint, "err", err)
+		}
+	}()
+}
+
+func (t *otlpTracer) export(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: t.resource},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "node_exporter", Version: version.Version},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// parseTraceparent parses a W3C trace context traceparent header.
+func parseTraceparent(header string) (traceID [16]byte, parentID [8]byte, sampled bool, err error) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return traceID, parentID, false, errors.New("invalid traceparent")
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, errors.New("invalid traceparent")
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parentID, false, err
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return traceID, parentID, false, err
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return traceID, parentID, false, err
+	}
+	if traceID == [16]byte{} || parentID == [8]byte{} {
+		return traceID, parentID, false, errors.New("invalid traceparent")
+	}
+	return traceID, parentID, flags&1 == 1, nil
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func newSpanID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// The types below are the JSON encoding of an OTLP
+// ExportTraceServiceRequest, limited to the fields set by node_exporter.
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	// IntValue is a string as 64 bit integers are encoded as strings in
+	// the JSON encoding of protobuf.
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+func stringAttribute(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) otlpKeyValue {
+	s := strconv.Itoa(value)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, parentID, sampled, err := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traceID[0] != 0x4b || parentID[7] != 0xb7 || !sampled {
+		t.Errorf("unexpected traceparent %x %x %v", traceID, parentID, sampled)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, _, _, err := parseTraceparent(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestOTLPTracer(t *testing.T) {
+	received := make(chan otlpExportRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req otlpExportRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+		}
+		received <- req
+	}))
+	defer server.Close()
+
+	tracer, err := newOTLPTracer(server.URL, 1, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/metrics?collect[]=cpu", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	trace := tracer.start(r)
+	if trace == nil {
+		t.Fatal("sampled scrape not traced")
+	}
+	trace.RecordCollector("cpu", time.Now(), time.Millisecond, errors.New("boom"))
+	tracer.finish(trace, r, http.StatusOK)
+
+	var req otlpExportRequest
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("trace not exported")
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the scrape and one collector", len(spans))
+	}
+	scrape, cpu := spans[0], spans[1]
+	if scrape.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || scrape.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("scrape span not part of the trace of the request: %+v", scrape)
+	}
+	if cpu.ParentSpanID != scrape.SpanID || cpu.Name != "collector cpu" || cpu.Status.Code != otlpStatusCodeError {
+		t.Errorf("unexpected collector span %+v", cpu)
+	}
+
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if tracer.start(r) != nil {
+		t.Error("scrape traced although the traceparent is not sampled")
+	}
+}
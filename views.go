@@ -0,0 +1,194 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+var viewNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// viewsConfig is the format of --web.views-file:
+//
+//	views:
+//	  capacity:
+//	    collectors: [cpu, meminfo, accelerators]
+//	    metrics: ['node_cpu_.*', 'node_memory_Mem.*', 'node_accelerator_.*']
+//	    basic_auth_users:
+//	      capacity: $2y$10$...
+//
+// Each view is served at <web.telemetry-path>/<name>.
+type viewsConfig struct {
+	Views map[string]metricView `yaml:"views"`
+}
+
+// metricView is a named subset of the exposed metrics.
+type metricView struct {
+	// Collectors lists the collectors run for the view, all enabled
+	// collectors if empty.
+	Collectors []string `yaml:"collectors"`
+	// Metrics are regular expressions of the metric names exposed by the
+	// view, all metrics of its collectors if empty.
+	Metrics []string `yaml:"metrics"`
+	// BasicAuthUsers maps user names to bcrypt hashed passwords allowed to
+	// scrape the view. They are checked in addition to the users of
+	// --web.config.file, which should have none for views to have
+	// independent credentials.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+
+	metrics *regexp.Regexp
+	// tenants, if not nil, restricts the metrics to the devices of tenants
+	// of --web.tenants-file.
+	tenants *tenantDevices
+}
+
+func loadViewsConfig(path string) (map[string]metricView, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read views file: %w", err)
+	}
+
+	var config viewsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse views file: %w", err)
+	}
+
+	for name, view := range config.Views {
+		if !viewNameRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid view name %q", name)
+		}
+		re, err := compileMetricNames(view.Metrics)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics of view %q: %w", name, err)
+		}
+		view.metrics = re
+		for user, hash := range view.BasicAuthUsers {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("invalid password hash of user %q in view %q: %w", user, name, err)
+			}
+		}
+		config.Views[name] = view
+	}
+
+	return config.Views, nil
+}
+
+// compileMetricNames compiles regular expressions of metric names into one
+// matching any of them, nil if there are none.
+func compileMetricNames(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + strings.Join(patterns, "|") + ")$")
+}
+
+// allowsCollectors returns whether all filters passed in collect[] are
+// collectors of the view.
+func (v metricView) allowsCollectors(filters []string) bool {
+	if len(v.Collectors) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		allowed := false
+		for _, c := range v.Collectors {
+			if filter == c {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// authenticate checks the basic auth credentials of a request against the
+// users of the view. Views without users are open.
+func (v metricView) authenticate(r *http.Request) bool {
+	if len(v.BasicAuthUsers) == 0 {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := v.BasicAuthUsers[user]
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+}
+
+// viewHandler serves the metrics of a view after authenticating the request.
+type viewHandler struct {
+	view    metricView
+	handler http.Handler
+}
+
+func (h *viewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.view.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// sortedViewNames returns the names of the views in a stable order for
+// logging and the landing page.
+func sortedViewNames(views map[string]metricView) []string {
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// metricFilterGatherer drops the metric families of the wrapped Gatherer whose
+// names do not match a view.
+type metricFilterGatherer struct {
+	gatherer prometheus.Gatherer
+	metrics  *regexp.Regexp
+}
+
+func newMetricFilterGatherer(gatherer prometheus.Gatherer, metrics *regexp.Regexp) prometheus.Gatherer {
+	if metrics == nil {
+		return gatherer
+	}
+	return &metricFilterGatherer{gatherer: gatherer, metrics: metrics}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *metricFilterGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	filtered := mfs[:0]
+	for _, mf := range mfs {
+		if g.metrics.MatchString(mf.GetName()) {
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, err
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeViewsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "views.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadViewsConfig(t *testing.T) {
+	// The password of "capacity" is "secret".
+	views, err := loadViewsConfig(writeViewsFile(t, `views:
+  capacity:
+    collectors: [cpu, meminfo]
+    metrics: ['node_cpu_.*', 'node_memory_MemTotal_bytes']
+    basic_auth_users:
+      capacity: $2a$04$/Pc6evmo5TuuzlYX0cvlPeaJIfFbBThelI606FDB3OfPHx6BDEAmi
+  everything: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capacity := views["capacity"]
+	if !capacity.metrics.MatchString("node_cpu_seconds_total") || capacity.metrics.MatchString("node_memory_MemTotal_bytes_x") {
+		t.Errorf("unexpected metrics regexp %s", capacity.metrics)
+	}
+	if !capacity.allowsCollectors([]string{"cpu"}) || capacity.allowsCollectors([]string{"cpu", "netdev"}) {
+		t.Error("unexpected collectors allowed by view")
+	}
+	if !views["everything"].allowsCollectors([]string{"netdev"}) {
+		t.Error("view without collectors should allow all collectors")
+	}
+
+	for _, tc := range []struct {
+		user, pass string
+		want       bool
+	}{
+		{"capacity", "secret", true},
+		{"capacity", "wrong", false},
+		{"other", "secret", false},
+	} {
+		r := httptest.NewRequest("GET", "/metrics/capacity", nil)
+		r.SetBasicAuth(tc.user, tc.pass)
+		if got := capacity.authenticate(r); got != tc.want {
+			t.Errorf("authenticate(%s, %s) = %v, want %v", tc.user, tc.pass, got, tc.want)
+		}
+	}
+	if capacity.authenticate(httptest.NewRequest("GET", "/metrics/capacity", nil)) {
+		t.Error("request without credentials should not be authenticated")
+	}
+
+	for _, invalid := range []string{
+		"views:\n  bad/name: {}\n",
+		"views:\n  v:\n    metrics: ['node_(']\n",
+		"views:\n  v:\n    basic_auth_users:\n      u: plaintext\n",
+		"views:\n  v:\n    unknown: true\n",
+	} {
+		if _, err := loadViewsConfig(writeViewsFile(t, invalid)); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestMetricFilterGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for _, name := range []string{"node_cpu_seconds_total", "node_load1"} {
+		reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "Test metric."}))
+	}
+
+	views, err := loadViewsConfig(writeViewsFile(t, "views:\n  cpu:\n    metrics: ['node_cpu_.*']\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newMetricFilterGatherer(reg, views["cpu"].metrics)
+	want := `# HELP node_cpu_seconds_total Test metric.
+# TYPE node_cpu_seconds_total gauge
+node_cpu_seconds_total 0
+`
+	if err := testutil.GatherAndCompare(g, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/node_exporter/collector"
+)
+
+var watchdogPingAgeDesc = prometheus.NewDesc(
+	"node_exporter_watchdog_ping_age_seconds",
+	"Seconds since node_exporter last pinged the systemd watchdog.",
+	nil, nil,
+)
+
+// watchdog pings the systemd watchdog of a Type=notify service with
+// WatchdogSec= set, as long as no collector update and no scrape has been
+// running for longer than the watchdog interval. A collector hung on e.g. an
+// unresponsive sysfs read, or a scrape wedged outside of the collectors,
+// then gets node_exporter restarted by systemd.
+type watchdog struct {
+	interval time.Duration
+	notify   func(state string) (bool, error)
+	logger   log.Logger
+
+	mtx        sync.Mutex
+	lastPing   time.Time
+	scrapes    map[uint64]time.Time
+	nextScrape uint64
+}
+
+// watchdogCollector exposes the watchdog, if enabled, on every handler.
+var watchdogCollector = &watchdog{notify: notifySystemd}
+
+func notifySystemd(state string) (bool, error) {
+	return daemon.SdNotify(false, state)
+}
+
+// notifyReady tells systemd that node_exporter started, if it runs as a
+// Type=notify service.
+func notifyReady(logger log.Logger) {
+	if _, err := notifySystemd(daemon.SdNotifyReady); err != nil {
+		level.Warn(logger).Log("msg", "Failed to notify systemd", "err", err)
+	}
+}
+
+// start pings the watchdog until the context is done, if systemd enabled it.
+func (w *watchdog) start(ctx context.Context, logger log.Logger) error {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil || interval == 0 {
+		return err
+	}
+	w.interval = interval
+	w.logger = logger
+	level.Info(logger).Log("msg", "Pinging systemd watchdog", "interval", interval)
+
+	w.ping(time.Now())
+	go func() {
+		// systemd recommends pinging at half the watchdog interval.
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				w.ping(now)
+			}
+		}
+	}()
+	return nil
+}
+
+// trackScrape records a running scrape until the returned function is
+// called.
+func (w *watchdog) trackScrape() func() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.scrapes == nil {
+		w.scrapes = map[uint64]time.Time{}
+	}
+	id := w.nextScrape
+	w.nextScrape++
+	w.scrapes[id] = time.Now()
+	return func() {
+		w.mtx.Lock()
+		delete(w.scrapes, id)
+		w.mtx.Unlock()
+	}
+}
+
+// oldestScrape returns the start of the longest running scrape, ok is false
+// if no scrape is running.
+func (w *watchdog) oldestScrape() (begin time.Time, ok bool) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for _, b := range w.scrapes {
+		if !ok || b.Before(begin) {
+			begin, ok = b, true
+		}
+	}
+	return begin, ok
+}
+
+// ping pings the watchdog unless a collector update or a scrape is hung.
+func (w *watchdog) ping(now time.Time) {
+	if name, begin, ok := collector.OldestUpdate(); ok && now.Sub(begin) > w.interval {
+		level.Error(w.logger).Log("msg", "Collector hung, not pinging systemd watchdog", "collector", name, "running_seconds", now.Sub(begin).Seconds())
+		return
+	}
+	if begin, ok := w.oldestScrape(); ok && now.Sub(begin) > w.interval {
+		level.Error(w.logger).Log("msg", "Scrape hung, not pinging systemd watchdog", "running_seconds", now.Sub(begin).Seconds())
+		return
+	}
+	if _, err := w.notify(daemon.SdNotifyWatchdog); err != nil {
+		level.Warn(w.logger).Log("msg", "Failed to ping systemd watchdog", "err", err)
+		return
+	}
+	w.mtx.Lock()
+	w.lastPing = now
+	w.mtx.Unlock()
+}
+
+// Describe implements prometheus.Collector.
+func (w *watchdog) Describe(ch chan<- *prometheus.Desc) {
+	ch <- watchdogPingAgeDesc
+}
+
+// Collect implements prometheus.Collector. Nothing is exposed before the
+// first ping, i.e. if the watchdog is disabled.
+func (w *watchdog) Collect(ch chan<- prometheus.Metric) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.lastPing.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(watchdogPingAgeDesc, prometheus.GaugeValue, time.Since(w.lastPing).Seconds())
+}
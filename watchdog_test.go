@@ -0,0 +1,85 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWatchdogPing(t *testing.T) {
+	pings := 0
+	var notifyErr error
+	w := &watchdog{
+		interval: time.Minute,
+		notify: func(state string) (bool, error) {
+			if state != "WATCHDOG=1" {
+				t.Errorf("unexpected state %q", state)
+			}
+			pings++
+			return true, notifyErr
+		},
+		logger: log.NewNopLogger(),
+	}
+
+	if n := testutil.CollectAndCount(w); n != 0 {
+		t.Errorf("got %d metrics before the first ping, want none", n)
+	}
+	w.ping(time.Now())
+	if pings != 1 {
+		t.Errorf("got %d pings, want 1", pings)
+	}
+	if n := testutil.CollectAndCount(w, "node_exporter_watchdog_ping_age_seconds"); n != 1 {
+		t.Errorf("got %d metrics after a ping, want 1", n)
+	}
+
+	// Failed pings do not count.
+	notifyErr = errors.New("no socket")
+	last := w.lastPing
+	w.ping(time.Now().Add(time.Second))
+	if w.lastPing != last {
+		t.Error("failed ping updated the ping time")
+	}
+}
+
+func TestWatchdogHungScrape(t *testing.T) {
+	pings := 0
+	w := &watchdog{
+		interval: time.Minute,
+		notify: func(state string) (bool, error) {
+			pings++
+			return true, nil
+		},
+		logger: log.NewNopLogger(),
+	}
+
+	done := w.trackScrape()
+	w.ping(time.Now())
+	if pings != 1 {
+		t.Errorf("got %d pings with a running scrape, want 1", pings)
+	}
+	w.ping(time.Now().Add(2 * time.Minute))
+	if pings != 1 {
+		t.Errorf("got %d pings with a hung scrape, want 1", pings)
+	}
+	done()
+	w.ping(time.Now().Add(2 * time.Minute))
+	if pings != 2 {
+		t.Errorf("got %d pings after the scrape finished, want 2", pings)
+	}
+}